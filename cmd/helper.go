@@ -21,7 +21,7 @@ func addPersistentFlag_commandModifiesConfiguration(cmd *cobra.Command) {
 func addFlag_commandPrintsData(cmd *cobra.Command) {
 	// We don't add a default value to the format flag
 	// because cmd wouldn't be able to overwrite the default format
-	cmd.Flags().String("format", "", "Output format (pretty, json, csv, plain)")
+	cmd.Flags().String("format", "", "Output format (pretty, json, csv, plain, parquet, arrow, sqlite)")
 	cmd.Flags().Bool("json", false, "Output format as JSON")
 	cmd.Flags().Bool("csv", false, "Output format as CSV")
 	cmd.Flags().Bool("plain", false, "Output format as plain text")
@@ -30,7 +30,7 @@ func addFlag_commandPrintsData(cmd *cobra.Command) {
 // Same as addFlag_commandPrintsData but flags will be applied
 // to all subcommands
 func addPersistentFlag_commandPrintsData(cmd *cobra.Command) {
-	cmd.PersistentFlags().String("format", "", "Output format (pretty, json, csv, plain)")
+	cmd.PersistentFlags().String("format", "", "Output format (pretty, json, csv, plain, parquet, arrow, sqlite)")
 	cmd.PersistentFlags().Bool("json", false, "Output format as JSON")
 	cmd.PersistentFlags().Bool("csv", false, "Output format as CSV")
 	cmd.PersistentFlags().Bool("plain", false, "Output format as plain text")
@@ -58,6 +58,7 @@ func addSandboxFlags(cmd *cobra.Command, isServer bool) {
 	cmd.Flags().Bool("allow-remote", false, "When sandboxed, allow read_* tables to fetch remote URLs (http/https)")
 	cmd.Flags().Bool("allow-attach", false, "When sandboxed, allow ATTACH/VACUUM INTO to on-disk paths within --allow-dirs")
 	cmd.Flags().Bool("allow-db-connections", false, "When sandboxed, allow the database reader modules (duckdb/postgres/mysql/clickhouse/cassandra)")
+	cmd.Flags().Bool("allow-write", false, "When sandboxed, allow INSERT into csv_reader/jsonl_reader tables and COPY ... TO to write files within --allow-dirs")
 	if isServer {
 		cmd.Flags().Bool("no-sandbox", false, "Disable server sandboxing entirely (UNSAFE: exposes local file read, SSRF, and arbitrary file write)")
 	} else {
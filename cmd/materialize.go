@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"github.com/julien040/anyquery/controller"
+	"github.com/spf13/cobra"
+)
+
+var materializeCmd = &cobra.Command{
+	Use:   "materialize",
+	Short: "Manage the materialized views",
+	Long: `Manage the materialized views.
+A materialized view stores the result of a query (or the rows of a plugin table) in a local table of the database.
+It avoids hitting the API of a plugin (and its rate limits) each time you query it.
+The view is refreshed on demand, or on a schedule by anyquery server.`,
+	Aliases: []string{"materialized", "mv"},
+	RunE:    controller.MaterializeList,
+	Example: `# List the materialized views of anyquery.db
+anyquery materialize
+
+# Store the issues of a repository in the table issues, refreshed every hour
+anyquery materialize create issues "SELECT * FROM github_issues_from_repository('julien040/anyquery')" --every 1h
+
+# Only request the issues updated since the last refresh
+anyquery materialize create issues "SELECT * FROM github_issues_from_repository('julien040/anyquery')" --updated-at updated_at --key id
+
+# Refresh the views whose interval has elapsed (e.g. from a cron job)
+anyquery materialize refresh --due
+
+# Delete a view and its table
+anyquery materialize delete issues`,
+}
+
+var materializeCreateCmd = &cobra.Command{
+	Use:     "create [name] [table or query]",
+	Aliases: []string{"add", "new"},
+	Short:   "Create a materialized view",
+	Long: `Create a materialized view and fill its table.
+The second argument is either the name of a table (e.g. github_my_issues) or a SELECT query.
+If the view already exists, it is redefined and its table is rebuilt.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: controller.MaterializeCreate,
+}
+
+var materializeRefreshCmd = &cobra.Command{
+	Use:   "refresh [name]...",
+	Short: "Refresh the materialized views",
+	Long: `Refresh the materialized views.
+If no name is provided, all the views of the database are refreshed.`,
+	RunE: controller.MaterializeRefresh,
+}
+
+var materializeListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the materialized views",
+	Aliases: []string{"ls", "show"},
+	RunE:    controller.MaterializeList,
+}
+
+var materializeDeleteCmd = &cobra.Command{
+	Use:     "delete [name]",
+	Short:   "Delete a materialized view and its table",
+	Aliases: []string{"rm", "remove", "drop"},
+	Args:    cobra.ExactArgs(1),
+	RunE:    controller.MaterializeDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(materializeCmd)
+	addFlag_commandPrintsData(materializeCmd)
+	addPersistentFlag_commandModifiesConfiguration(materializeCmd)
+	materializeCmd.PersistentFlags().StringP("database", "d", "anyquery.db", "Database holding the materialized views")
+	materializeCmd.PersistentFlags().StringSlice("extension", []string{}, "Load one or more extensions by specifying their path. Separate multiple extensions with a comma.")
+	materializeCmd.PersistentFlags().String("log-file", "", "Log file")
+	materializeCmd.PersistentFlags().String("log-level", "info", "Log level (trace, debug, info, warn, error, off)")
+	materializeCmd.PersistentFlags().String("log-format", "text", "Log format (text, json)")
+
+	materializeCmd.AddCommand(materializeCreateCmd)
+	materializeCreateCmd.Flags().Duration("every", 0, "Refresh the view at this interval (e.g. 30m, 1h). By default, the view is only refreshed on demand")
+	materializeCreateCmd.Flags().String("updated-at", "", "Column holding the last update of a row. Only the rows updated since the last refresh are requested")
+	materializeCreateCmd.Flags().String("key", "", "Column identifying a row, required by --updated-at to replace the updated rows")
+
+	materializeCmd.AddCommand(materializeRefreshCmd)
+	materializeRefreshCmd.Flags().Bool("due", false, "Only refresh the views whose refresh interval has elapsed")
+
+	materializeCmd.AddCommand(materializeListCmd)
+	addFlag_commandPrintsData(materializeListCmd)
+
+	materializeCmd.AddCommand(materializeDeleteCmd)
+}
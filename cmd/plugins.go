@@ -17,7 +17,9 @@ var pluginInstallCmd = &cobra.Command{
 	Use:   "install [registry] [plugin]",
 	Short: "Search and install a plugin",
 	Long: "Search and install a plugin\nIf a plugin is specified, it will be installed without searching" +
-		"\nIf the plugin is already installed, it will fail",
+		"\nIf the plugin is already installed, it will fail" +
+		"\nThe capabilities the plugin requires (network, filesystem, programs) are shown and must be confirmed," +
+		"\nunless --yes is passed. Without a terminal to confirm them (e.g. in a script), --yes is required",
 	Aliases:    []string{"i", "add"},
 	Args:       cobra.MaximumNArgs(2),
 	SuggestFor: []string{"get"},
@@ -53,8 +55,10 @@ func init() {
 	addPersistentFlag_commandModifiesConfiguration(pluginsCmd)
 	pluginsCmd.AddCommand(pluginInstallCmd)
 	addFlag_commandPrintsData(pluginInstallCmd)
+	pluginInstallCmd.Flags().BoolP("yes", "y", false, "Install the plugin without confirming its capabilities")
 	pluginsCmd.AddCommand(pluginUninstallCmd)
 	pluginsCmd.AddCommand(pluginUpdateCmd)
+	pluginUpdateCmd.Flags().BoolP("yes", "y", false, "Update the plugins without confirming the changes of their capabilities")
 
 	// Install is also a subcommand of the root command
 	rootCmd.AddCommand(pluginInstallCmd)
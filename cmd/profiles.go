@@ -67,6 +67,46 @@ If no argument is provided, the command will prompt you the registry, the plugin
 	Example: `anyquery profiles delete default github default`,
 }
 
+var profilesLimitsCmd = &cobra.Command{
+	Use:   "limits (registry plugin profile) | (plugin profile)",
+	Short: "Show or set the resource limits of a profile",
+	Long: `Show or set the resource limits of the plugin process of a profile.
+
+If only two arguments are provided, we consider that the registry is the default one.
+Without any flag, the current limits are printed. A limit set to 0 is removed.
+Except --call-timeout, the limits are only supported on Linux.
+They are applied the next time the plugin is started (e.g. when anyquery server restarts).`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: controller.ProfileLimits,
+	Example: `# Limit the memory of the plugin to 512 MiB and each call to 30 seconds
+anyquery profiles limits github default --max-memory 512 --call-timeout 30
+
+# Run the plugin in Linux namespaces without network access
+anyquery profiles limits default myplugin default --isolate-network`,
+}
+
+var profilesCacheCmd = &cobra.Command{
+	Use:   "cache (registry plugin profile) | (plugin profile)",
+	Short: "Show or set how long the rows of a profile are cached",
+	Long: `Show or set how long anyquery caches the rows returned by the tables of a profile.
+
+If only two arguments are provided, we consider that the registry is the default one.
+Without any flag, the current duration is printed.
+By default, the duration is set by the plugin (most plugins don't cache their rows).
+The rows are cached in memory, and an INSERT, UPDATE or DELETE on a table clears its cache.
+To clear the cache from a query, run SELECT clear_query_cache();`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: controller.ProfileCache,
+	Example: `# Cache the rows of the GitHub tables for 10 minutes
+anyquery profiles cache github default --ttl 10m
+
+# Use the duration set by the plugin
+anyquery profiles cache github default --ttl 0
+
+# Never cache the rows of the profile
+anyquery profiles cache default myplugin default --disable`,
+}
+
 func init() {
 	rootCmd.AddCommand(profilesCmd)
 	addPersistentFlag_commandModifiesConfiguration(profilesCmd)
@@ -76,4 +116,15 @@ func init() {
 	profilesCmd.AddCommand(profilesListCmd)
 	addFlag_commandPrintsData(profilesListCmd)
 	profilesCmd.AddCommand(profilesDeleteCmd)
+	profilesCmd.AddCommand(profilesLimitsCmd)
+	addFlag_commandPrintsData(profilesLimitsCmd)
+	profilesLimitsCmd.Flags().Int64("max-memory", 0, "The maximum memory of the plugin process in MiB")
+	profilesLimitsCmd.Flags().Int64("max-cpu-time", 0, "The maximum CPU time of the plugin process in seconds")
+	profilesLimitsCmd.Flags().Int64("max-open-files", 0, "The maximum number of files the plugin process can open")
+	profilesLimitsCmd.Flags().Int64("call-timeout", 0, "The maximum time of a call to the plugin in seconds. The plugin is restarted if it does not answer in time")
+	profilesLimitsCmd.Flags().Bool("isolate", false, "Run the plugin in new Linux user, mount, PID, IPC and UTS namespaces")
+	profilesLimitsCmd.Flags().Bool("isolate-network", false, "Run the plugin in a new Linux network namespace (no network access). Implies --isolate")
+	profilesCmd.AddCommand(profilesCacheCmd)
+	profilesCacheCmd.Flags().Duration("ttl", 0, "How long the rows of a query are cached (e.g. 30s, 10m, 1h). 0 uses the duration set by the plugin")
+	profilesCacheCmd.Flags().Bool("disable", false, "Never cache the rows of the profile, even if the plugin sets a duration")
 }
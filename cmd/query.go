@@ -43,6 +43,12 @@ func init() {
 
 	// Query flags
 	queryCmd.Flags().StringP("query", "q", "", "Query to run")
+	queryCmd.Flags().StringP("output", "o", "", "Write the results to a file instead of stdout")
+	queryCmd.Flags().String("output-table", "", "Table the rows are written in with --format sqlite (default \"results\")")
+	queryCmd.Flags().Duration("query-timeout", 0, "Maximum duration of a query (e.g. 30s, 5m). 0 means no limit")
+	queryCmd.Flags().Bool("dry-run", false, "Print the rows that INSERT, UPDATE and DELETE statements would send to the plugins without sending them")
+	queryCmd.Flags().String("otlp-endpoint", "", "Export the spans of the queries to an OpenTelemetry collector (e.g. http://localhost:4318)")
+	queryCmd.Flags().Bool("profile", false, "Print the metrics of the virtual tables (filter calls, round-trips, rows, bytes and time) after each query")
 
 	// Log flags
 	queryCmd.Flags().String("log-file", "", "Log file")
@@ -55,6 +55,8 @@ func init() {
 
 	// Query flags
 	rootCmd.Flags().StringP("query", "q", "", "Query to run")
+	rootCmd.Flags().StringP("output", "o", "", "Write the results to a file instead of stdout")
+	rootCmd.Flags().String("output-table", "", "Table the rows are written in with --format sqlite (default \"results\")")
 
 	// Log flags
 	rootCmd.Flags().String("log-file", "", "Log file")
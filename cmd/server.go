@@ -20,6 +20,9 @@ anyquery server --host 127.0.0.1 --port 3306
 # Start the server with a specific database
 anyquery server -d mydatabase.db
 
+# Serve the Prometheus metrics on http://127.0.0.1:9090/metrics
+anyquery server --metrics-address 127.0.0.1:9090
+
 # Increase the log level and redirect the output to a file
 anyquery server --log-level debug --log-file /var/log/anyquery.log`,
 }
@@ -35,6 +38,9 @@ func init() {
 	serverCmd.Flags().String("log-format", "text", "Log format (text, json)")
 	serverCmd.Flags().String("log-file", "/dev/stdout", "Log file")
 	serverCmd.Flags().String("auth-file", "", "Path to the authentication file")
+	serverCmd.Flags().Duration("query-timeout", 0, "Maximum duration of a query (e.g. 30s, 5m). 0 means no limit")
+	serverCmd.Flags().String("metrics-address", "", "Serve the Prometheus metrics of the server on this address (e.g. 127.0.0.1:9090)")
+	serverCmd.Flags().String("otlp-endpoint", "", "Export the spans of the queries to an OpenTelemetry collector (e.g. http://localhost:4318)")
 	serverCmd.Flags().Bool("dev", false, "Run the program in developer mode (implies --no-sandbox: UNSAFE, exposes local file read, SSRF, and arbitrary file write; do not use on a network-exposed server)")
 	serverCmd.Flags().StringSlice("extension", []string{}, "Load one or more extensions by specifying their path. Separate multiple extensions with a comma.")
 
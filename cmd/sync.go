@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/julien040/anyquery/controller"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [source table] [destination table]",
+	Short: "Sync a plugin table into a local table",
+	Long: `Sync the rows of a plugin table into a local table of the database.
+The rows are upserted by their key (the primary key of the plugin table by default).
+The rows deleted from the plugin table are kept in the local table, and their column _anyquery_deleted_at is set.
+The column _anyquery_synced_at holds the last time a row was inserted or updated.
+
+With --updated-at, the greatest value of the column is recorded, and the next syncs only request the rows updated since.
+Because these syncs can't see the deleted rows, run a full sync from time to time with --full to mark them.
+The flags of the first sync are remembered, so running anyquery sync again with the same tables is enough.`,
+	Args: cobra.ExactArgs(2),
+	RunE: controller.Sync,
+	Example: `# Mirror the tasks of Todoist in the table tasks of anyquery.db
+anyquery sync todoist_active_tasks tasks
+
+# Only pull the pages edited since the last sync
+anyquery sync notion_database pages --updated-at last_edited_time
+
+# Pull all the rows to mark the deleted ones
+anyquery sync notion_database pages --full
+
+# List the synced tables
+anyquery sync list`,
+}
+
+var syncListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List the synced tables",
+	Aliases: []string{"ls", "show"},
+	Args:    cobra.NoArgs,
+	RunE:    controller.SyncList,
+}
+
+var syncDeleteCmd = &cobra.Command{
+	Use:   "delete [destination table]",
+	Short: "Forget the sync state of a table",
+	Long: `Forget the sync state of a table. The next sync of the table will be a full one.
+The local table is kept unless --drop is set.`,
+	Aliases: []string{"rm", "remove", "reset"},
+	Args:    cobra.ExactArgs(1),
+	RunE:    controller.SyncDelete,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	addPersistentFlag_commandModifiesConfiguration(syncCmd)
+	syncCmd.PersistentFlags().StringP("database", "d", "anyquery.db", "Database holding the local tables")
+	syncCmd.PersistentFlags().StringSlice("extension", []string{}, "Load one or more extensions by specifying their path. Separate multiple extensions with a comma.")
+	syncCmd.PersistentFlags().String("log-file", "", "Log file")
+	syncCmd.PersistentFlags().String("log-level", "info", "Log level (trace, debug, info, warn, error, off)")
+	syncCmd.PersistentFlags().String("log-format", "text", "Log format (text, json)")
+	syncCmd.Flags().String("key", "", "Column identifying a row. By default, the primary key of the plugin table")
+	syncCmd.Flags().String("updated-at", "", "Column holding the last update of a row. Only the rows updated since the last sync are requested")
+	syncCmd.Flags().Bool("full", false, "Request all the rows, and mark the rows missing from the plugin table as deleted")
+
+	syncCmd.AddCommand(syncListCmd)
+	addFlag_commandPrintsData(syncListCmd)
+
+	syncCmd.AddCommand(syncDeleteCmd)
+	syncDeleteCmd.Flags().Bool("drop", false, "Also drop the local table")
+}
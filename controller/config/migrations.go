@@ -34,6 +34,70 @@ var migrations = []migration{
 				return false, err
 			}
 
+			return count > 0, nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "Add column protocol to plugin_installed",
+		Queries: []string{
+			`ALTER TABLE plugin_installed ADD COLUMN protocol TEXT DEFAULT '' NOT NULL`,
+		},
+		Check: func(db *sql.DB) (bool, error) {
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('plugin_installed') WHERE name = 'protocol'").Scan(&count)
+			if err != nil {
+				return false, err
+			}
+
+			return count > 0, nil
+		},
+	},
+	{
+		Version:     3,
+		Description: "Add column limits to profile",
+		Queries: []string{
+			`ALTER TABLE profile ADD COLUMN limits TEXT DEFAULT '{}' NOT NULL`,
+		},
+		Check: func(db *sql.DB) (bool, error) {
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('profile') WHERE name = 'limits'").Scan(&count)
+			if err != nil {
+				return false, err
+			}
+
+			return count > 0, nil
+		},
+	},
+	{
+		Version:     4,
+		Description: "Add column capabilities to plugin_installed",
+		Queries: []string{
+			`ALTER TABLE plugin_installed ADD COLUMN capabilities TEXT DEFAULT '' NOT NULL`,
+		},
+		Check: func(db *sql.DB) (bool, error) {
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('plugin_installed') WHERE name = 'capabilities'").Scan(&count)
+			if err != nil {
+				return false, err
+			}
+
+			return count > 0, nil
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add column cacheTTL to profile",
+		Queries: []string{
+			`ALTER TABLE profile ADD COLUMN cacheTTL INTEGER DEFAULT 0 NOT NULL`,
+		},
+		Check: func(db *sql.DB) (bool, error) {
+			var count int
+			err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('profile') WHERE name = 'cacheTTL'").Scan(&count)
+			if err != nil {
+				return false, err
+			}
+
 			return count > 0, nil
 		},
 	},
@@ -27,6 +27,17 @@ type EntityAttributeValue struct {
 	Value     string
 }
 
+type MaterializedView struct {
+	Databasepath    string
+	Name            string
+	Query           string
+	Refreshinterval int64
+	Updatedatcolumn string
+	Keycolumn       string
+	Lastrefreshed   int64
+	Lastupdatedat   string
+}
+
 type PluginInstalled struct {
 	Name              string
 	Description       sql.NullString
@@ -42,6 +53,8 @@ type PluginInstalled struct {
 	Tablename         string
 	Issharedextension int64
 	Tablemetadata     string
+	Protocol          string
+	Capabilities      string
 }
 
 type Profile struct {
@@ -49,6 +62,8 @@ type Profile struct {
 	Pluginname string
 	Registry   string
 	Config     string
+	Limits     string
+	Cachettl   int64
 }
 
 type Registry struct {
@@ -59,3 +74,13 @@ type Registry struct {
 	Checksumregistry string
 	Registryjson     string
 }
+
+type SyncState struct {
+	Databasepath    string
+	Destination     string
+	Source          string
+	Keycolumn       string
+	Updatedatcolumn string
+	Cursor          string
+	Lastsynced      int64
+}
@@ -75,10 +75,12 @@ INSERT INTO
         author,
         tablename,
         tableMetadata,
-        isSharedExtension
+        isSharedExtension,
+        protocol,
+        capabilities
     )
 VALUES
-    (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 `
 
 type AddPluginParams struct {
@@ -96,6 +98,8 @@ type AddPluginParams struct {
 	Tablename         string
 	Tablemetadata     string
 	Issharedextension int64
+	Protocol          string
+	Capabilities      string
 }
 
 func (q *Queries) AddPlugin(ctx context.Context, arg AddPluginParams) error {
@@ -114,6 +118,8 @@ func (q *Queries) AddPlugin(ctx context.Context, arg AddPluginParams) error {
 		arg.Tablename,
 		arg.Tablemetadata,
 		arg.Issharedextension,
+		arg.Protocol,
+		arg.Capabilities,
 	)
 	return err
 }
@@ -214,6 +220,23 @@ func (q *Queries) DeleteEntityAttributeValue(ctx context.Context, arg DeleteEnti
 	return err
 }
 
+const deleteMaterializedView = `-- name: DeleteMaterializedView :exec
+DELETE FROM materialized_view
+WHERE
+    databasePath = ?
+    AND name = ?
+`
+
+type DeleteMaterializedViewParams struct {
+	Databasepath string
+	Name         string
+}
+
+func (q *Queries) DeleteMaterializedView(ctx context.Context, arg DeleteMaterializedViewParams) error {
+	_, err := q.db.ExecContext(ctx, deleteMaterializedView, arg.Databasepath, arg.Name)
+	return err
+}
+
 const deletePlugin = `-- name: DeletePlugin :exec
 DELETE FROM plugin_installed
 WHERE
@@ -266,6 +289,23 @@ func (q *Queries) DeleteRegistry(ctx context.Context, name string) error {
 	return err
 }
 
+const deleteSyncState = `-- name: DeleteSyncState :exec
+DELETE FROM sync_state
+WHERE
+    databasePath = ?
+    AND destination = ?
+`
+
+type DeleteSyncStateParams struct {
+	Databasepath string
+	Destination  string
+}
+
+func (q *Queries) DeleteSyncState(ctx context.Context, arg DeleteSyncStateParams) error {
+	_, err := q.db.ExecContext(ctx, deleteSyncState, arg.Databasepath, arg.Destination)
+	return err
+}
+
 const getAlias = `-- name: GetAlias :one
 SELECT
     tablename, alias
@@ -508,9 +548,81 @@ func (q *Queries) GetEntityAttributes(ctx context.Context, entity string) ([]Get
 	return items, nil
 }
 
+const getMaterializedView = `-- name: GetMaterializedView :one
+SELECT
+    databasepath, name, query, refreshinterval, updatedatcolumn, keycolumn, lastrefreshed, lastupdatedat
+FROM
+    materialized_view
+WHERE
+    databasePath = ?
+    AND name = ?
+`
+
+type GetMaterializedViewParams struct {
+	Databasepath string
+	Name         string
+}
+
+func (q *Queries) GetMaterializedView(ctx context.Context, arg GetMaterializedViewParams) (MaterializedView, error) {
+	row := q.db.QueryRowContext(ctx, getMaterializedView, arg.Databasepath, arg.Name)
+	var i MaterializedView
+	err := row.Scan(
+		&i.Databasepath,
+		&i.Name,
+		&i.Query,
+		&i.Refreshinterval,
+		&i.Updatedatcolumn,
+		&i.Keycolumn,
+		&i.Lastrefreshed,
+		&i.Lastupdatedat,
+	)
+	return i, err
+}
+
+const getMaterializedViews = `-- name: GetMaterializedViews :many
+SELECT
+    databasepath, name, query, refreshinterval, updatedatcolumn, keycolumn, lastrefreshed, lastupdatedat
+FROM
+    materialized_view
+WHERE
+    databasePath = ?
+`
+
+func (q *Queries) GetMaterializedViews(ctx context.Context, databasepath string) ([]MaterializedView, error) {
+	rows, err := q.db.QueryContext(ctx, getMaterializedViews, databasepath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []MaterializedView
+	for rows.Next() {
+		var i MaterializedView
+		if err := rows.Scan(
+			&i.Databasepath,
+			&i.Name,
+			&i.Query,
+			&i.Refreshinterval,
+			&i.Updatedatcolumn,
+			&i.Keycolumn,
+			&i.Lastrefreshed,
+			&i.Lastupdatedat,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPlugin = `-- name: GetPlugin :one
 SELECT
-    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata
+    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata, protocol, capabilities
 FROM
     plugin_installed
 WHERE
@@ -541,13 +653,15 @@ func (q *Queries) GetPlugin(ctx context.Context, arg GetPluginParams) (PluginIns
 		&i.Tablename,
 		&i.Issharedextension,
 		&i.Tablemetadata,
+		&i.Protocol,
+		&i.Capabilities,
 	)
 	return i, err
 }
 
 const getPlugins = `-- name: GetPlugins :many
 SELECT
-    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata
+    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata, protocol, capabilities
 FROM
     plugin_installed
 `
@@ -576,6 +690,8 @@ func (q *Queries) GetPlugins(ctx context.Context) ([]PluginInstalled, error) {
 			&i.Tablename,
 			&i.Issharedextension,
 			&i.Tablemetadata,
+			&i.Protocol,
+			&i.Capabilities,
 		); err != nil {
 			return nil, err
 		}
@@ -592,7 +708,7 @@ func (q *Queries) GetPlugins(ctx context.Context) ([]PluginInstalled, error) {
 
 const getPluginsOfRegistry = `-- name: GetPluginsOfRegistry :many
 SELECT
-    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata
+    name, description, path, executablepath, version, homepage, registry, config, checksumdir, dev, author, tablename, issharedextension, tablemetadata, protocol, capabilities
 FROM
     plugin_installed
 WHERE
@@ -623,6 +739,8 @@ func (q *Queries) GetPluginsOfRegistry(ctx context.Context, registry string) ([]
 			&i.Tablename,
 			&i.Issharedextension,
 			&i.Tablemetadata,
+			&i.Protocol,
+			&i.Capabilities,
 		); err != nil {
 			return nil, err
 		}
@@ -639,7 +757,7 @@ func (q *Queries) GetPluginsOfRegistry(ctx context.Context, registry string) ([]
 
 const getProfile = `-- name: GetProfile :one
 SELECT
-    name, pluginname, registry, config
+    name, pluginname, registry, config, limits, cachettl
 FROM
     profile
 WHERE
@@ -662,13 +780,15 @@ func (q *Queries) GetProfile(ctx context.Context, arg GetProfileParams) (Profile
 		&i.Pluginname,
 		&i.Registry,
 		&i.Config,
+		&i.Limits,
+		&i.Cachettl,
 	)
 	return i, err
 }
 
 const getProfiles = `-- name: GetProfiles :many
 SELECT
-    name, pluginname, registry, config
+    name, pluginname, registry, config, limits, cachettl
 FROM
     profile
 `
@@ -687,6 +807,8 @@ func (q *Queries) GetProfiles(ctx context.Context) ([]Profile, error) {
 			&i.Pluginname,
 			&i.Registry,
 			&i.Config,
+			&i.Limits,
+			&i.Cachettl,
 		); err != nil {
 			return nil, err
 		}
@@ -703,7 +825,7 @@ func (q *Queries) GetProfiles(ctx context.Context) ([]Profile, error) {
 
 const getProfilesOfPlugin = `-- name: GetProfilesOfPlugin :many
 SELECT
-    name, pluginname, registry, config
+    name, pluginname, registry, config, limits, cachettl
 FROM
     profile
 WHERE
@@ -730,6 +852,8 @@ func (q *Queries) GetProfilesOfPlugin(ctx context.Context, arg GetProfilesOfPlug
 			&i.Pluginname,
 			&i.Registry,
 			&i.Config,
+			&i.Limits,
+			&i.Cachettl,
 		); err != nil {
 			return nil, err
 		}
@@ -746,7 +870,7 @@ func (q *Queries) GetProfilesOfPlugin(ctx context.Context, arg GetProfilesOfPlug
 
 const getProfilesOfRegistry = `-- name: GetProfilesOfRegistry :many
 SELECT
-    name, pluginname, registry, config
+    name, pluginname, registry, config, limits, cachettl
 FROM
     profile
 WHERE
@@ -767,6 +891,8 @@ func (q *Queries) GetProfilesOfRegistry(ctx context.Context, registry string) ([
 			&i.Pluginname,
 			&i.Registry,
 			&i.Config,
+			&i.Limits,
+			&i.Cachettl,
 		); err != nil {
 			return nil, err
 		}
@@ -846,6 +972,76 @@ func (q *Queries) GetRegistry(ctx context.Context, name string) (Registry, error
 	return i, err
 }
 
+const getSyncState = `-- name: GetSyncState :one
+SELECT
+    databasepath, destination, source, keycolumn, updatedatcolumn, cursor, lastsynced
+FROM
+    sync_state
+WHERE
+    databasePath = ?
+    AND destination = ?
+`
+
+type GetSyncStateParams struct {
+	Databasepath string
+	Destination  string
+}
+
+func (q *Queries) GetSyncState(ctx context.Context, arg GetSyncStateParams) (SyncState, error) {
+	row := q.db.QueryRowContext(ctx, getSyncState, arg.Databasepath, arg.Destination)
+	var i SyncState
+	err := row.Scan(
+		&i.Databasepath,
+		&i.Destination,
+		&i.Source,
+		&i.Keycolumn,
+		&i.Updatedatcolumn,
+		&i.Cursor,
+		&i.Lastsynced,
+	)
+	return i, err
+}
+
+const getSyncStates = `-- name: GetSyncStates :many
+SELECT
+    databasepath, destination, source, keycolumn, updatedatcolumn, cursor, lastsynced
+FROM
+    sync_state
+WHERE
+    databasePath = ?
+`
+
+func (q *Queries) GetSyncStates(ctx context.Context, databasepath string) ([]SyncState, error) {
+	rows, err := q.db.QueryContext(ctx, getSyncStates, databasepath)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SyncState
+	for rows.Next() {
+		var i SyncState
+		if err := rows.Scan(
+			&i.Databasepath,
+			&i.Destination,
+			&i.Source,
+			&i.Keycolumn,
+			&i.Updatedatcolumn,
+			&i.Cursor,
+			&i.Lastsynced,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setEntityAttributeValue = `-- name: SetEntityAttributeValue :exec
 INSERT
 OR REPLACE INTO entity_attribute_value (entity, attribute, value)
@@ -864,6 +1060,77 @@ func (q *Queries) SetEntityAttributeValue(ctx context.Context, arg SetEntityAttr
 	return err
 }
 
+const setMaterializedView = `-- name: SetMaterializedView :exec
+INSERT
+OR REPLACE INTO materialized_view (
+    databasePath,
+    name,
+    query,
+    refreshInterval,
+    updatedAtColumn,
+    keyColumn
+)
+VALUES
+    (?, ?, ?, ?, ?, ?)
+`
+
+type SetMaterializedViewParams struct {
+	Databasepath    string
+	Name            string
+	Query           string
+	Refreshinterval int64
+	Updatedatcolumn string
+	Keycolumn       string
+}
+
+func (q *Queries) SetMaterializedView(ctx context.Context, arg SetMaterializedViewParams) error {
+	_, err := q.db.ExecContext(ctx, setMaterializedView,
+		arg.Databasepath,
+		arg.Name,
+		arg.Query,
+		arg.Refreshinterval,
+		arg.Updatedatcolumn,
+		arg.Keycolumn,
+	)
+	return err
+}
+
+const setSyncState = `-- name: SetSyncState :exec
+INSERT
+OR REPLACE INTO sync_state (
+    databasePath,
+    destination,
+    source,
+    keyColumn,
+    updatedAtColumn,
+    cursor,
+    lastSynced
+)
+VALUES
+    (?, ?, ?, ?, ?, ?, unixepoch ())
+`
+
+type SetSyncStateParams struct {
+	Databasepath    string
+	Destination     string
+	Source          string
+	Keycolumn       string
+	Updatedatcolumn string
+	Cursor          string
+}
+
+func (q *Queries) SetSyncState(ctx context.Context, arg SetSyncStateParams) error {
+	_, err := q.db.ExecContext(ctx, setSyncState,
+		arg.Databasepath,
+		arg.Destination,
+		arg.Source,
+		arg.Keycolumn,
+		arg.Updatedatcolumn,
+		arg.Cursor,
+	)
+	return err
+}
+
 const updateConnection = `-- name: UpdateConnection :exec
 UPDATE connections
 SET
@@ -894,6 +1161,27 @@ func (q *Queries) UpdateConnection(ctx context.Context, arg UpdateConnectionPara
 	return err
 }
 
+const updateMaterializedViewRefreshed = `-- name: UpdateMaterializedViewRefreshed :exec
+UPDATE materialized_view
+SET
+    lastRefreshed = unixepoch (),
+    lastUpdatedAt = ?
+WHERE
+    databasePath = ?
+    AND name = ?
+`
+
+type UpdateMaterializedViewRefreshedParams struct {
+	Lastupdatedat string
+	Databasepath  string
+	Name          string
+}
+
+func (q *Queries) UpdateMaterializedViewRefreshed(ctx context.Context, arg UpdateMaterializedViewRefreshedParams) error {
+	_, err := q.db.ExecContext(ctx, updateMaterializedViewRefreshed, arg.Lastupdatedat, arg.Databasepath, arg.Name)
+	return err
+}
+
 const updatePlugin = `-- name: UpdatePlugin :exec
 UPDATE plugin_installed
 SET
@@ -906,7 +1194,9 @@ SET
     author = ?,
     tablename = ?,
     isSharedExtension = ?,
-    tableMetadata = ?
+    tableMetadata = ?,
+    protocol = ?,
+    capabilities = ?
 WHERE
     name = ?
     AND registry = ?
@@ -923,6 +1213,8 @@ type UpdatePluginParams struct {
 	Tablename         string
 	Issharedextension int64
 	Tablemetadata     string
+	Protocol          string
+	Capabilities      string
 	Name              string
 	Registry          string
 }
@@ -939,12 +1231,41 @@ func (q *Queries) UpdatePlugin(ctx context.Context, arg UpdatePluginParams) erro
 		arg.Tablename,
 		arg.Issharedextension,
 		arg.Tablemetadata,
+		arg.Protocol,
+		arg.Capabilities,
 		arg.Name,
 		arg.Registry,
 	)
 	return err
 }
 
+const updateProfileCacheTTL = `-- name: UpdateProfileCacheTTL :exec
+UPDATE profile
+SET
+    cacheTTL = ?
+WHERE
+    name = ?
+    AND pluginName = ?
+    AND registry = ?
+`
+
+type UpdateProfileCacheTTLParams struct {
+	Cachettl   int64
+	Name       string
+	Pluginname string
+	Registry   string
+}
+
+func (q *Queries) UpdateProfileCacheTTL(ctx context.Context, arg UpdateProfileCacheTTLParams) error {
+	_, err := q.db.ExecContext(ctx, updateProfileCacheTTL,
+		arg.Cachettl,
+		arg.Name,
+		arg.Pluginname,
+		arg.Registry,
+	)
+	return err
+}
+
 const updateProfileConfig = `-- name: UpdateProfileConfig :exec
 UPDATE profile
 SET
@@ -972,6 +1293,33 @@ func (q *Queries) UpdateProfileConfig(ctx context.Context, arg UpdateProfileConf
 	return err
 }
 
+const updateProfileLimits = `-- name: UpdateProfileLimits :exec
+UPDATE profile
+SET
+    limits = ?
+WHERE
+    name = ?
+    AND pluginName = ?
+    AND registry = ?
+`
+
+type UpdateProfileLimitsParams struct {
+	Limits     string
+	Name       string
+	Pluginname string
+	Registry   string
+}
+
+func (q *Queries) UpdateProfileLimits(ctx context.Context, arg UpdateProfileLimitsParams) error {
+	_, err := q.db.ExecContext(ctx, updateProfileLimits,
+		arg.Limits,
+		arg.Name,
+		arg.Pluginname,
+		arg.Registry,
+	)
+	return err
+}
+
 const updateProfileName = `-- name: UpdateProfileName :exec
 UPDATE profile
 SET
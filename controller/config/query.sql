@@ -27,10 +27,12 @@ INSERT INTO
         author,
         tablename,
         tableMetadata,
-        isSharedExtension
+        isSharedExtension,
+        protocol,
+        capabilities
     )
 VALUES
-    (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+    (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
 
 -- name: AddProfile :exec
 INSERT INTO
@@ -172,7 +174,9 @@ SET
     author = ?,
     tablename = ?,
     isSharedExtension = ?,
-    tableMetadata = ?
+    tableMetadata = ?,
+    protocol = ?,
+    capabilities = ?
 WHERE
     name = ?
     AND registry = ?;
@@ -186,6 +190,24 @@ WHERE
     AND pluginName = ?
     AND registry = ?;
 
+-- name: UpdateProfileLimits :exec
+UPDATE profile
+SET
+    limits = ?
+WHERE
+    name = ?
+    AND pluginName = ?
+    AND registry = ?;
+
+-- name: UpdateProfileCacheTTL :exec
+UPDATE profile
+SET
+    cacheTTL = ?
+WHERE
+    name = ?
+    AND pluginName = ?
+    AND registry = ?;
+
 -- name: UpdateProfileName :exec
 UPDATE profile
 SET
@@ -310,4 +332,91 @@ WHERE
 SELECT DISTINCT
     entity
 FROM
-    entity_attribute_value;
\ No newline at end of file
+    entity_attribute_value;
+/* -------------------------------------------------------------------------- */
+/*                             Materialized views                             */
+/* -------------------------------------------------------------------------- */
+-- name: GetMaterializedViews :many
+SELECT
+    *
+FROM
+    materialized_view
+WHERE
+    databasePath = ?;
+
+-- name: GetMaterializedView :one
+SELECT
+    *
+FROM
+    materialized_view
+WHERE
+    databasePath = ?
+    AND name = ?;
+
+-- name: SetMaterializedView :exec
+INSERT
+OR REPLACE INTO materialized_view (
+    databasePath,
+    name,
+    query,
+    refreshInterval,
+    updatedAtColumn,
+    keyColumn
+)
+VALUES
+    (?, ?, ?, ?, ?, ?);
+
+-- name: UpdateMaterializedViewRefreshed :exec
+UPDATE materialized_view
+SET
+    lastRefreshed = unixepoch (),
+    lastUpdatedAt = ?
+WHERE
+    databasePath = ?
+    AND name = ?;
+
+-- name: DeleteMaterializedView :exec
+DELETE FROM materialized_view
+WHERE
+    databasePath = ?
+    AND name = ?;
+
+/* -------------------------------------------------------------------------- */
+/*                                 Sync states                                */
+/* -------------------------------------------------------------------------- */
+-- name: GetSyncStates :many
+SELECT
+    *
+FROM
+    sync_state
+WHERE
+    databasePath = ?;
+
+-- name: GetSyncState :one
+SELECT
+    *
+FROM
+    sync_state
+WHERE
+    databasePath = ?
+    AND destination = ?;
+
+-- name: SetSyncState :exec
+INSERT
+OR REPLACE INTO sync_state (
+    databasePath,
+    destination,
+    source,
+    keyColumn,
+    updatedAtColumn,
+    cursor,
+    lastSynced
+)
+VALUES
+    (?, ?, ?, ?, ?, ?, unixepoch ());
+
+-- name: DeleteSyncState :exec
+DELETE FROM sync_state
+WHERE
+    databasePath = ?
+    AND destination = ?;
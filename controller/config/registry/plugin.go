@@ -71,18 +71,12 @@ func FindPluginVersionCandidate(plugin Plugin) (PluginFile, PluginVersion, error
 	return *candidateFile, *candidateVersion, nil
 }
 
-func InstallPlugin(queries *model.Queries, registry string, plugin string) (string, error) {
-	// Create the plugin directory
-	path := path.Join(xdg.DataHome, "anyquery", "plugins", registry, plugin, newSmallID())
-	err := os.MkdirAll(path, 0755)
-	if err != nil {
-		return "", err
-	}
-
+// FindPluginCandidate finds a plugin in a registry and the version InstallPlugin would install
+func FindPluginCandidate(queries *model.Queries, registry string, plugin string) (Plugin, PluginFile, PluginVersion, error) {
 	// Get the registry
 	_, plugins, err := LoadRegistry(queries, registry)
 	if err != nil {
-		return "", err
+		return Plugin{}, PluginFile{}, PluginVersion{}, err
 	}
 
 	// Find the plugin
@@ -96,10 +90,22 @@ func InstallPlugin(queries *model.Queries, registry string, plugin string) (stri
 
 	// Find a compatible version
 	if pluginInfo == nil {
-		return "", fmt.Errorf("plugin %s not found in registry %s", plugin, registry)
+		return Plugin{}, PluginFile{}, PluginVersion{}, fmt.Errorf("plugin %s not found in registry %s", plugin, registry)
 	}
 
 	file, version, err := FindPluginVersionCandidate(*pluginInfo)
+	return *pluginInfo, file, version, err
+}
+
+func InstallPlugin(queries *model.Queries, registry string, plugin string) (string, error) {
+	// Create the plugin directory
+	path := path.Join(xdg.DataHome, "anyquery", "plugins", registry, plugin, newSmallID())
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	pluginInfo, file, version, err := FindPluginCandidate(queries, registry, plugin)
 	if err != nil {
 		return "", err
 	}
@@ -153,6 +159,8 @@ func InstallPlugin(queries *model.Queries, registry string, plugin string) (stri
 		Config:            string(configJSON),
 		Tablename:         string(tablesJSON),
 		Tablemetadata:     string(metadataJSON),
+		Protocol:          version.Protocol,
+		Capabilities:      version.Capabilities.String(),
 		Checksumdir:       sql.NullString{},
 	})
 }
@@ -411,6 +419,8 @@ func UpdatePlugin(queries *model.Queries, registry string, plugin string) error
 		},
 		Issharedextension: int64(ternary.If(pluginInfoRegistry.Type == "sharedObject", 1, 0)),
 		Tablemetadata:     string(tableMetadata),
+		Protocol:          version.Protocol,
+		Capabilities:      version.Capabilities.String(),
 	})
 	return err
 }
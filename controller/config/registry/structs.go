@@ -33,6 +33,10 @@ type PluginVersion struct {
 	UserConfig             []UserConfig                 `json:"user_config"`
 	Tables                 []string                     `json:"tables"`
 	TablesMetadata         map[string]rpc.TableMetadata `json:"tables_metadata"` // Table name -> Table metadata
+	// The transport of the plugin: netrpc (default) or grpc
+	Protocol string `json:"protocol"`
+	// The permissions the plugin requires. If nil, the plugin does not declare them
+	Capabilities *rpc.PluginCapabilities `json:"capabilities"`
 }
 
 type PluginFile struct {
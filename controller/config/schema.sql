@@ -46,6 +46,10 @@ CREATE TABLE
         isSharedExtension INTEGER DEFAULT 0 NOT NULL,
         -- Additional metadata for the tables
         tableMetadata TEXT DEFAULT '{}' NOT NULL,
+        -- The transport of the plugin (netrpc or grpc). Empty if the plugin accepts both
+        protocol TEXT DEFAULT '' NOT NULL,
+        -- The capabilities the plugin requires as a JSON string (see rpc.PluginCapabilities). Empty if the plugin does not declare them
+        capabilities TEXT DEFAULT '' NOT NULL,
         FOREIGN KEY (registry) REFERENCES registry (name),
         PRIMARY KEY (registry, name)
     ) WITHOUT ROWID;
@@ -60,6 +64,10 @@ CREATE TABLE
         registry TEXT NOT NULL,
         -- The configuration for the profile as a JSON string
         config TEXT NOT NULL DEFAULT '{}',
+        -- The resource limits of the plugin process as a JSON string (see rpc.ResourceLimits)
+        limits TEXT NOT NULL DEFAULT '{}',
+        -- How long the rows of a query are cached in seconds. 0 uses the duration of the plugin, -1 disables the cache
+        cacheTTL INTEGER NOT NULL DEFAULT 0,
         FOREIGN KEY (registry, pluginName) REFERENCES plugin_installed (registry, name),
         PRIMARY KEY (name, pluginName, registry)
     ) WITHOUT ROWID;
@@ -89,4 +97,28 @@ CREATE TABLE
         PRIMARY KEY (entity, attribute)
     ) STRICT;
 
--- entity_attribute_value is STRICT to ensure that the value is text
\ No newline at end of file
+-- entity_attribute_value is STRICT to ensure that the value is text
+CREATE TABLE
+    IF NOT EXISTS materialized_view (
+        databasePath TEXT NOT NULL, -- The absolute path of the database holding the local table
+        name TEXT NOT NULL, -- The name of the local table
+        query TEXT NOT NULL, -- The query whose result is stored in the local table
+        refreshInterval INTEGER DEFAULT 0 NOT NULL, -- In seconds. 0 means the view is only refreshed on demand
+        updatedAtColumn TEXT DEFAULT '' NOT NULL, -- The column used by the incremental refreshes, if any
+        keyColumn TEXT DEFAULT '' NOT NULL, -- The column identifying a row in the incremental refreshes
+        lastRefreshed INTEGER DEFAULT 0 NOT NULL, -- Unix timestamp of the last refresh
+        lastUpdatedAt TEXT DEFAULT '' NOT NULL, -- The greatest value of updatedAtColumn stored so far
+        PRIMARY KEY (databasePath, name)
+    ) WITHOUT ROWID;
+
+CREATE TABLE
+    IF NOT EXISTS sync_state (
+        databasePath TEXT NOT NULL, -- The absolute path of the database holding the destination table
+        destination TEXT NOT NULL, -- The name of the destination table
+        source TEXT NOT NULL, -- The plugin table the rows are synced from
+        keyColumn TEXT NOT NULL, -- The column identifying a row, the primary key of the source by default
+        updatedAtColumn TEXT DEFAULT '' NOT NULL, -- The column used to only pull the updated rows, if any
+        cursor TEXT DEFAULT '' NOT NULL, -- The greatest value of updatedAtColumn synced so far
+        lastSynced INTEGER DEFAULT 0 NOT NULL, -- Unix timestamp of the last sync
+        PRIMARY KEY (databasePath, destination)
+    ) WITHOUT ROWID;
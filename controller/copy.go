@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/julien040/go-ternary"
+)
+
+// COPY writes the result of a query to a file, in any output format:
+//
+//	COPY (SELECT * FROM read_csv('in.csv') WHERE amount > 0) TO 'out.parquet';
+//	COPY orders TO 'orders.db' (FORMAT sqlite, TABLE orders);
+//
+// SQLite doesn't know this statement, so middlewareFileQuery and middlewareQuery
+// replace it with its query (see extractCopyStatement), and middlewareQuery writes
+// the rows to the file rather than returning them.
+// The file is opened through Restrictions.OpenLocalForWrite, so it's confined to
+// the allowed directories when the shell is sandboxed.
+
+// copyStatement is a parsed COPY ... TO statement
+type copyStatement struct {
+	// The query whose result is written
+	Query string
+	// The path of the file to write
+	Path string
+	// The format of the file, set by the FORMAT option or inferred from the extension of Path
+	Format outputTableType
+	// The table the rows are written in by the sqlite format (TABLE option)
+	TableName string
+}
+
+// copyExtensionFormats maps the extension of the path of a COPY statement to its format
+var copyExtensionFormats = map[string]outputTableType{
+	".csv":      outputTableTypeCsv,
+	".tsv":      outputTableTypePlainWithHeader,
+	".json":     outputTableTypeJsonPretty,
+	".jsonl":    outputTableTypeJsonLines,
+	".ndjson":   outputTableTypeJsonLines,
+	".parquet":  outputTableTypeParquet,
+	".pq":       outputTableTypeParquet,
+	".arrow":    outputTableTypeArrow,
+	".feather":  outputTableTypeArrow,
+	".arrows":   outputTableTypeArrowStream,
+	".db":       outputTableTypeSqlite,
+	".sqlite":   outputTableTypeSqlite,
+	".sqlite3":  outputTableTypeSqlite,
+	".md":       outputTableTypeMarkdown,
+	".markdown": outputTableTypeMarkdown,
+	".html":     outputTableTypeHtml,
+	".htm":      outputTableTypeHtml,
+}
+
+// isCopyStatement reports whether query starts with the COPY keyword
+func isCopyStatement(query string) bool {
+	query = strings.TrimSpace(query)
+	if len(query) < 5 || !strings.EqualFold(query[:4], "copy") {
+		return false
+	}
+	next := query[4]
+	return next == '(' || next == ' ' || next == '\t' || next == '\n' || next == '\r'
+}
+
+// parseCopyStatement parses a COPY (query) TO 'path' [WITH] [(option value, ...)] statement.
+// The query can also be a table name, copied as a whole.
+//
+// It returns nil if query is not a COPY statement
+func parseCopyStatement(query string) (*copyStatement, error) {
+	if !isCopyStatement(query) {
+		return nil, nil
+	}
+	rest := strings.TrimSpace(query)
+	rest = strings.TrimSpace(strings.TrimSuffix(rest, ";"))
+	rest = strings.TrimSpace(rest[4:])
+
+	stmt := &copyStatement{}
+	if strings.HasPrefix(rest, "(") {
+		end, err := closingParenthesis(rest)
+		if err != nil {
+			return nil, err
+		}
+		stmt.Query = strings.TrimSpace(rest[1:end])
+		rest = strings.TrimSpace(rest[end+1:])
+		if stmt.Query == "" {
+			return nil, fmt.Errorf("COPY: the query to copy is empty")
+		}
+	} else {
+		name, remaining := cutCopyToken(rest)
+		if name == "" {
+			return nil, fmt.Errorf("COPY: expected a table name or a query between parentheses")
+		}
+		stmt.Query = "SELECT * FROM " + name
+		stmt.TableName = strings.Trim(name, "`\"[]")
+		rest = remaining
+	}
+
+	keyword, rest := cutCopyToken(rest)
+	if !strings.EqualFold(keyword, "to") {
+		return nil, fmt.Errorf("COPY: expected TO after the query, got %q", keyword)
+	}
+
+	path, rest, err := cutStringLiteral(rest)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, fmt.Errorf("COPY: the path of the file is empty")
+	}
+	stmt.Path = path
+
+	format := ""
+	if keyword, remaining := cutCopyToken(rest); strings.EqualFold(keyword, "with") {
+		rest = remaining
+	}
+	if rest != "" {
+		if !strings.HasPrefix(rest, "(") {
+			return nil, fmt.Errorf("COPY: unexpected %q after the path", rest)
+		}
+		end, err := closingParenthesis(rest)
+		if err != nil {
+			return nil, err
+		}
+		if trailing := strings.TrimSpace(rest[end+1:]); trailing != "" {
+			return nil, fmt.Errorf("COPY: unexpected %q after the options", trailing)
+		}
+		for _, option := range strings.Split(rest[1:end], ",") {
+			key, value := cutCopyToken(strings.TrimSpace(option))
+			value = strings.TrimSpace(strings.TrimPrefix(value, "="))
+			if unquoted, remaining, err := cutStringLiteral(value); err == nil && remaining == "" {
+				value = unquoted
+			}
+			switch strings.ToLower(key) {
+			case "":
+				continue
+			case "format":
+				format = strings.ToLower(value)
+			case "table", "table_name":
+				stmt.TableName = value
+			default:
+				return nil, fmt.Errorf("COPY: unknown option %q. The supported options are FORMAT and TABLE", key)
+			}
+		}
+	}
+
+	switch {
+	case format == "ndjson":
+		stmt.Format = outputTableTypeJsonLines
+	case format != "":
+		outputType, ok := formatName[format]
+		if !ok {
+			return nil, fmt.Errorf("COPY: unknown format %q", format)
+		}
+		stmt.Format = outputType
+	default:
+		outputType, ok := copyExtensionFormats[strings.ToLower(filepath.Ext(stmt.Path))]
+		if !ok {
+			return nil, fmt.Errorf("COPY: cannot infer the format of %q from its extension. Set it with (FORMAT csv)", stmt.Path)
+		}
+		stmt.Format = outputType
+	}
+	return stmt, nil
+}
+
+// closingParenthesis returns the index of the parenthesis closing the one s starts with,
+// skipping the quoted strings and identifiers
+func closingParenthesis(s string) (int, error) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			// A doubled quote is an escaped one, and is skipped like two quotes
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"', '`':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("COPY: unbalanced parentheses")
+}
+
+// cutCopyToken returns the first word of s (a quoted identifier being one word) and the rest of s
+func cutCopyToken(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", ""
+	}
+	if s[0] == '"' || s[0] == '`' {
+		if end := strings.IndexByte(s[1:], s[0]); end >= 0 {
+			return s[:end+2], strings.TrimSpace(s[end+2:])
+		}
+	}
+	end := strings.IndexAny(s, " \t\r\n(=")
+	if end < 0 {
+		return s, ""
+	}
+	if end == 0 {
+		return "", s
+	}
+	return s[:end], strings.TrimSpace(s[end:])
+}
+
+// cutStringLiteral returns the value of the single-quoted string s starts with,
+// and the rest of s
+func cutStringLiteral(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "'") {
+		return "", "", fmt.Errorf("COPY: expected the path of the file between single quotes")
+	}
+	value := strings.Builder{}
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			value.WriteByte(s[i])
+			continue
+		}
+		// Two single quotes are an escaped one
+		if i+1 < len(s) && s[i+1] == '\'' {
+			value.WriteByte('\'')
+			i++
+			continue
+		}
+		return value.String(), strings.TrimSpace(s[i+1:]), nil
+	}
+	return "", "", fmt.Errorf("COPY: unterminated string")
+}
+
+// extractCopyStatement replaces a COPY statement with its query,
+// and keeps the statement in queryData for middlewareQuery.
+//
+// It returns false if the statement can't be parsed
+func extractCopyStatement(queryData *QueryData) bool {
+	if queryData.copyTo != nil {
+		return true
+	}
+	stmt, err := parseCopyStatement(queryData.SQLQuery)
+	if err != nil {
+		queryData.Message = err.Error()
+		queryData.StatusCode = 2
+		return false
+	}
+	if stmt != nil {
+		queryData.copyTo = stmt
+		queryData.SQLQuery = stmt.Query
+	}
+	return true
+}
+
+// copyWriter hides the *os.File of a sandboxed COPY from the encoders,
+// so that the sqlite format writes to the file opened by OpenLocalForWrite
+// rather than reopening its path
+type copyWriter struct {
+	io.Writer
+}
+
+// runCopy runs the query of a COPY statement and writes its rows to the file of the statement
+func runCopy(ctx context.Context, runner queryRunner, queryData *QueryData) bool {
+	stmt := queryData.copyTo
+	if queryData.Config.GetBool("dryRun", false) {
+		queryData.Message = fmt.Sprintf("Dry run: the result would be written to %s", stmt.Path)
+		return true
+	}
+
+	// The query runs before the file is opened, so that a failing query doesn't truncate it
+	rows, err := runner.QueryContext(ctx, queryData.SQLQuery, queryData.Args...)
+	if err != nil {
+		queryData.Message = queryErrorMessage(ctx, err)
+		queryData.StatusCode = 2
+		return false
+	}
+	defer rows.Close()
+
+	file, err := queryData.Restrictions.OpenLocalForWrite(stmt.Path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		queryData.Message = fmt.Sprintf("Could not open %s: %s", stmt.Path, err.Error())
+		queryData.StatusCode = 2
+		return false
+	}
+	defer file.Close()
+
+	var writer io.Writer = file
+	if queryData.Restrictions != nil {
+		writer = copyWriter{file}
+	}
+	table := outputTable{
+		Writer:    writer,
+		Type:      stmt.Format,
+		TableName: stmt.TableName,
+	}
+	err = table.WriteSQLRows(rows)
+	if closeErr := table.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		queryData.Message = fmt.Sprintf("Could not write %s: %s", stmt.Path, queryErrorMessage(ctx, err))
+		queryData.StatusCode = 2
+		return false
+	}
+
+	queryData.Message = fmt.Sprintf("Copied %d %s to %s", table.rowCount, ternary.If(table.rowCount > 1, "rows", "row"), stmt.Path)
+	return true
+}
@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/julien040/anyquery/module"
+	"github.com/julien040/anyquery/namespace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCopyStatement(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		query    string
+		expected *copyStatement
+	}{
+		{
+			query:    "SELECT 1",
+			expected: nil,
+		},
+		{
+			query:    "SELECT * FROM copyright",
+			expected: nil,
+		},
+		{
+			query: "COPY (SELECT * FROM read_csv('in.csv') WHERE name = ')') TO 'out.parquet';",
+			expected: &copyStatement{
+				Query:  "SELECT * FROM read_csv('in.csv') WHERE name = ')'",
+				Path:   "out.parquet",
+				Format: outputTableTypeParquet,
+			},
+		},
+		{
+			query: "copy orders to 'it''s.db'",
+			expected: &copyStatement{
+				Query:     "SELECT * FROM orders",
+				Path:      "it's.db",
+				Format:    outputTableTypeSqlite,
+				TableName: "orders",
+			},
+		},
+		{
+			query: "COPY(SELECT 1) TO 'out.txt' WITH (FORMAT 'jsonl', TABLE results)",
+			expected: &copyStatement{
+				Query:     "SELECT 1",
+				Path:      "out.txt",
+				Format:    outputTableTypeJsonLines,
+				TableName: "results",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		stmt, err := parseCopyStatement(test.query)
+		require.NoError(t, err, test.query)
+		require.Equal(t, test.expected, stmt, test.query)
+	}
+
+	for _, query := range []string{
+		"COPY (SELECT 1 TO 'out.csv'",
+		"COPY (SELECT 1) 'out.csv'",
+		"COPY (SELECT 1) TO out.csv",
+		"COPY (SELECT 1) TO 'out.unknown'",
+		"COPY (SELECT 1) TO 'out.csv' (FORMAT xml)",
+		"COPY (SELECT 1) TO 'out.csv' (HEADER true)",
+		"COPY (SELECT 1) TO 'out.csv' extra",
+	} {
+		_, err := parseCopyStatement(query)
+		require.Error(t, err, query)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "in.csv"), []byte("id,name\n1,alice\n2,bob\n3,carol\n"), 0644))
+
+	restrictions := &module.Restrictions{AllowedDirs: []string{dir}}
+	n, err := namespace.NewNamespace(namespace.NamespaceConfig{
+		InMemory:     true,
+		Restrictions: restrictions,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+	db, err := n.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	var buf bytes.Buffer
+	sh := &shell{
+		DB:          db,
+		Middlewares: []middleware{middlewareFileQuery, middlewareQuery},
+		Config: middlewareConfiguration{
+			"doNotModifyOutput": true,
+			"outputMode":        "plain",
+		},
+		Restrictions:   restrictions,
+		OutputFileDesc: &buf,
+	}
+	run := func(query string) string {
+		buf.Reset()
+		sh.Run(query)
+		return buf.String()
+	}
+	in := filepath.Join(dir, "in.csv")
+	out := filepath.Join(dir, "out.jsonl")
+
+	t.Run("Writing is denied unless the policy allows it", func(t *testing.T) {
+		output := run(fmt.Sprintf("COPY (SELECT * FROM read_csv('%s')) TO '%s'", in, out))
+		require.Contains(t, output, "sandbox: ")
+		require.NoFileExists(t, out)
+	})
+
+	restrictions.AllowWrite = true
+
+	t.Run("The rows are written in the format of the extension", func(t *testing.T) {
+		output := run(fmt.Sprintf("COPY (SELECT id, name FROM read_csv('%s') WHERE id > 1) TO '%s'", in, out))
+		require.Contains(t, output, "Copied 2 rows")
+		content, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Equal(t, "{\"id\":2,\"name\":\"bob\"}\n{\"id\":3,\"name\":\"carol\"}\n", string(content))
+	})
+
+	t.Run("The file written can be read back", func(t *testing.T) {
+		parquetOut := filepath.Join(dir, "out.parquet")
+		output := run(fmt.Sprintf("COPY (SELECT * FROM read_csv('%s')) TO '%s'", in, parquetOut))
+		require.Contains(t, output, "Copied 3 rows")
+		output = run(fmt.Sprintf("SELECT name FROM read_parquet('%s') WHERE id = 3", parquetOut))
+		require.Equal(t, "carol\n", output)
+	})
+
+	t.Run("A failing query leaves the file untouched", func(t *testing.T) {
+		output := run(fmt.Sprintf("COPY (SELECT * FROM missing_table) TO '%s'", out))
+		require.Contains(t, output, "no such table")
+		content, err := os.ReadFile(out)
+		require.NoError(t, err)
+		require.Contains(t, string(content), "carol")
+	})
+
+	t.Run("The file must be within the allowed dirs", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "out.csv")
+		output := run(fmt.Sprintf("COPY (SELECT 1) TO '%s'", outside))
+		require.Contains(t, output, "sandbox: ")
+		require.NoFileExists(t, outside)
+	})
+}
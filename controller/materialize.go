@@ -0,0 +1,614 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julien040/anyquery/controller/config/model"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// This file implements the materialized views
+//
+// A materialized view stores the result of a query (or the rows of a plugin table)
+// in a local table of the database, so that querying it again doesn't hit the plugin.
+// The views are recorded in the config database, keyed by the absolute path of the database,
+// along with their refresh interval and the time of their last refresh.
+//
+// A refresh rebuilds the local table from scratch, unless the view has an updated-at column.
+// In this case, only the rows whose updated-at column is greater than the greatest value stored so far
+// are requested, and they replace the rows of the local table with the same key.
+
+// materializeScheduleInterval is the interval at which the server checks if a view must be refreshed
+const materializeScheduleInterval = time.Minute
+
+// localDatabasePath returns the path that identifies a database in the config
+// (e.g. for its materialized views or its synced tables)
+func localDatabasePath(path string) (string, error) {
+	if path == "" || path == ":memory:" {
+		return "", fmt.Errorf("the rows can only be stored in a database file (use -d to open one)")
+	}
+	return filepath.Abs(path)
+}
+
+// materializedQuery returns the query to run for a view defined by a table name or a query
+func materializedQuery(tableOrQuery string) string {
+	tableOrQuery = strings.TrimRight(strings.TrimSpace(tableOrQuery), ";")
+	if !strings.ContainsAny(tableOrQuery, " \t\n(") {
+		return "SELECT * FROM " + tableOrQuery
+	}
+	return tableOrQuery
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// updatedAtArg converts the updated-at cursor stored in the config to a value comparable with the column
+func updatedAtArg(value string) interface{} {
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	return value
+}
+
+// isViewDue reports whether the refresh interval of the view has elapsed
+func isViewDue(view model.MaterializedView, now time.Time) bool {
+	if view.Refreshinterval <= 0 {
+		return false
+	}
+	return now.Unix()-view.Lastrefreshed >= view.Refreshinterval
+}
+
+// refreshMaterializedView runs the query of the view and stores its result in the local table
+//
+// It returns the number of rows written, and the greatest value of the updated-at column
+// to record in the config (empty if the view is not incremental)
+func refreshMaterializedView(ctx context.Context, db *sql.DB, view model.MaterializedView) (int64, string, error) {
+	// The temporary table only exists on the connection that created it
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not start the transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := quoteIdentifier(view.Name)
+	staging := quoteIdentifier("_anyquery_refresh_" + view.Name)
+
+	// Table functions such as read_json are rewritten into virtual tables, like in the shell
+	query := view.Query
+	fileQuery := &QueryData{SQLQuery: query}
+	middlewareFileQuery(fileQuery)
+	if len(fileQuery.PreExec) > 0 {
+		query = fileQuery.SQLQuery
+		for _, preExec := range fileQuery.PreExec {
+			_, err = tx.ExecContext(ctx, preExec)
+			if err != nil {
+				return 0, "", fmt.Errorf("could not open the table functions of %s: %w", view.Name, err)
+			}
+		}
+	}
+
+	// Check if the local table already exists
+	// If not, an incremental refresh must start from scratch
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_schema WHERE type = 'table' AND name = ?)", view.Name).Scan(&exists)
+	if err != nil {
+		return 0, "", fmt.Errorf("could not check if the table %s exists: %w", view.Name, err)
+	}
+
+	incremental := view.Updatedatcolumn != "" && view.Keycolumn != "" && view.Lastupdatedat != "" && exists
+	var rows int64
+	if incremental {
+		// Only request the rows updated since the last refresh.
+		// The rows of the last timestamp are requested again: rows updated in the same second may have arrived since,
+		// and the rows already stored are replaced below
+		// The constraint on the updated-at column is pushed down to the plugin if it supports it
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT * FROM (%s) WHERE %s >= ?",
+			staging, query, quoteIdentifier(view.Updatedatcolumn)), updatedAtArg(view.Lastupdatedat))
+		if err != nil {
+			return 0, "", fmt.Errorf("could not run the query of %s: %w", view.Name, err)
+		}
+
+		// The columns are matched by name because a plugin might not return them in the same order
+		var columns string
+		err = tx.QueryRowContext(ctx, `SELECT group_concat('"' || replace(s.name, '"', '""') || '"', ', ')
+			FROM pragma_table_info(?, 'temp') s JOIN pragma_table_info(?, 'main') t ON s.name = t.name`,
+			"_anyquery_refresh_"+view.Name, view.Name).Scan(&columns)
+		if err != nil {
+			return 0, "", fmt.Errorf("could not get the columns of %s: %w", view.Name, err)
+		}
+
+		key := quoteIdentifier(view.Keycolumn)
+		steps := []string{
+			fmt.Sprintf("DELETE FROM main.%s WHERE %s IN (SELECT %s FROM temp.%s)", table, key, key, staging),
+			fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM temp.%s", table, columns, columns, staging),
+		}
+		for _, step := range steps {
+			_, err = tx.ExecContext(ctx, step)
+			if err != nil {
+				return 0, "", fmt.Errorf("could not update the rows of %s: %w", view.Name, err)
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM temp.%s", staging)).Scan(&rows)
+		if err != nil {
+			return 0, "", err
+		}
+
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE temp.%s", staging))
+		if err != nil {
+			return 0, "", err
+		}
+	} else {
+		// Build the new table next to the old one, and swap them
+		// so that the old rows are kept if the query fails
+		steps := []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS main.%s", staging),
+			fmt.Sprintf("CREATE TABLE main.%s AS SELECT * FROM (%s)", staging, query),
+			fmt.Sprintf("DROP TABLE IF EXISTS main.%s", table),
+			fmt.Sprintf("ALTER TABLE main.%s RENAME TO %s", staging, table),
+		}
+		for _, step := range steps {
+			_, err = tx.ExecContext(ctx, step)
+			if err != nil {
+				return 0, "", fmt.Errorf("could not refresh %s: %w", view.Name, err)
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM main.%s", table)).Scan(&rows)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	for _, postExec := range fileQuery.PostExec {
+		_, err = tx.ExecContext(ctx, postExec)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+
+	lastUpdatedAt := ""
+	if view.Updatedatcolumn != "" {
+		var greatest sql.NullString
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT max(%s) FROM main.%s",
+			quoteIdentifier(view.Updatedatcolumn), table)).Scan(&greatest)
+		if err != nil {
+			return 0, "", fmt.Errorf("could not get the greatest value of %s: %w", view.Updatedatcolumn, err)
+		}
+		lastUpdatedAt = greatest.String
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, "", fmt.Errorf("could not commit the refresh of %s: %w", view.Name, err)
+	}
+
+	return rows, lastUpdatedAt, nil
+}
+
+// refreshAndRecordMaterializedView refreshes the view and records the refresh in the config
+func refreshAndRecordMaterializedView(ctx context.Context, db *sql.DB, queries *model.Queries, view model.MaterializedView) (int64, error) {
+	rows, lastUpdatedAt, err := refreshMaterializedView(ctx, db, view)
+	if err != nil {
+		return 0, err
+	}
+
+	err = queries.UpdateMaterializedViewRefreshed(ctx, model.UpdateMaterializedViewRefreshedParams{
+		Lastupdatedat: lastUpdatedAt,
+		Databasepath:  view.Databasepath,
+		Name:          view.Name,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not record the refresh of %s: %w", view.Name, err)
+	}
+	return rows, nil
+}
+
+// createMaterializedView records the view in the config and fills its local table
+//
+// The table must not already exist, unless it belongs to a view of the same name
+func createMaterializedView(ctx context.Context, db *sql.DB, queries *model.Queries, view model.MaterializedView) (int64, error) {
+	if view.Name == "" {
+		return 0, fmt.Errorf("the name of the view cannot be empty")
+	}
+	if view.Updatedatcolumn != "" && view.Keycolumn == "" {
+		return 0, fmt.Errorf("an incremental refresh needs a key column to replace the updated rows")
+	}
+
+	_, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{
+		Databasepath: view.Databasepath,
+		Name:         view.Name,
+	})
+	if err == sql.ErrNoRows {
+		var exists bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_schema WHERE name = ?1 UNION ALL SELECT 1 FROM pragma_module_list WHERE name = ?1)",
+			view.Name).Scan(&exists)
+		if err != nil {
+			return 0, fmt.Errorf("could not check if the table %s exists: %w", view.Name, err)
+		}
+		if exists {
+			return 0, fmt.Errorf("the table %s already exists", view.Name)
+		}
+	} else if err != nil {
+		return 0, fmt.Errorf("could not get the view %s: %w", view.Name, err)
+	}
+
+	// Fill the table before saving the view, so that a failing query leaves the previous definition untouched
+	// The last updated-at value is reset, so the first refresh always rebuilds the table
+	view.Query = materializedQuery(view.Query)
+	view.Lastupdatedat = ""
+	rows, lastUpdatedAt, err := refreshMaterializedView(ctx, db, view)
+	if err != nil {
+		return 0, err
+	}
+
+	err = queries.SetMaterializedView(ctx, model.SetMaterializedViewParams{
+		Databasepath:    view.Databasepath,
+		Name:            view.Name,
+		Query:           view.Query,
+		Refreshinterval: view.Refreshinterval,
+		Updatedatcolumn: view.Updatedatcolumn,
+		Keycolumn:       view.Keycolumn,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not save the view %s: %w", view.Name, err)
+	}
+
+	err = queries.UpdateMaterializedViewRefreshed(ctx, model.UpdateMaterializedViewRefreshedParams{
+		Lastupdatedat: lastUpdatedAt,
+		Databasepath:  view.Databasepath,
+		Name:          view.Name,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not record the refresh of %s: %w", view.Name, err)
+	}
+	return rows, nil
+}
+
+// dropMaterializedView deletes the view from the config and drops its local table
+func dropMaterializedView(ctx context.Context, db *sql.DB, queries *model.Queries, databasePath, name string) error {
+	_, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{
+		Databasepath: databasePath,
+		Name:         name,
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("the materialized view %s does not exist", name)
+	} else if err != nil {
+		return fmt.Errorf("could not get the view %s: %w", name, err)
+	}
+
+	_, err = db.ExecContext(ctx, "DROP TABLE IF EXISTS main."+quoteIdentifier(name))
+	if err != nil {
+		return fmt.Errorf("could not drop the table %s: %w", name, err)
+	}
+
+	return queries.DeleteMaterializedView(ctx, model.DeleteMaterializedViewParams{
+		Databasepath: databasePath,
+		Name:         name,
+	})
+}
+
+// scheduleMaterializedViews refreshes the views of the database when their interval elapses
+// until the context is canceled
+func scheduleMaterializedViews(ctx context.Context, db *sql.DB, queries *model.Queries, databasePath string, logger *log.Logger) {
+	ticker := time.NewTicker(materializeScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		views, err := queries.GetMaterializedViews(ctx, databasePath)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("could not list the materialized views", "error", err)
+		}
+		for _, view := range views {
+			if !isViewDue(view, time.Now()) {
+				continue
+			}
+			start := time.Now()
+			rows, err := refreshAndRecordMaterializedView(ctx, db, queries, view)
+			if err != nil {
+				logger.Error("could not refresh the materialized view", "name", view.Name, "error", err)
+				continue
+			}
+			logger.Info("Refreshed the materialized view", "name", view.Name, "rows", rows, "duration", time.Since(start))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// describeMaterializedView returns the refresh interval and the mode of the view in a human-readable form
+func describeMaterializedView(view model.MaterializedView) (interval string, mode string, lastRefreshed string) {
+	interval = "on demand"
+	if view.Refreshinterval > 0 {
+		interval = (time.Duration(view.Refreshinterval) * time.Second).String()
+	}
+	mode = "full"
+	if view.Updatedatcolumn != "" {
+		mode = "incremental on " + view.Updatedatcolumn
+	}
+	lastRefreshed = "never"
+	if view.Lastrefreshed > 0 {
+		lastRefreshed = time.Unix(view.Lastrefreshed, 0).Format(time.DateTime)
+	}
+	return
+}
+
+// openLocalDatabases opens the database of the user and the config
+func openLocalDatabases(cmd *cobra.Command) (*sql.DB, *model.Queries, string, func(), error) {
+	path, _ := cmd.Flags().GetString("database")
+	databasePath, err := localDatabasePath(path)
+	if err != nil {
+		return nil, nil, "", nil, err
+	}
+
+	configDB, queries, err := requestDatabase(cmd.Flags(), false)
+	if err != nil {
+		return nil, nil, "", nil, fmt.Errorf("could not open the config database: %w", err)
+	}
+
+	_, db, err := openUserDatabase(cmd, nil)
+	if err != nil {
+		configDB.Close()
+		return nil, nil, "", nil, err
+	}
+
+	return db, queries, databasePath, func() {
+		db.Close()
+		configDB.Close()
+	}, nil
+}
+
+func MaterializeList(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString("database")
+	databasePath, err := localDatabasePath(path)
+	if err != nil {
+		return err
+	}
+
+	db, queries, err := requestDatabase(cmd.Flags(), true)
+	if err != nil {
+		return fmt.Errorf("could not open the database: %w", err)
+	}
+	defer db.Close()
+
+	views, err := queries.GetMaterializedViews(context.Background(), databasePath)
+	if err != nil {
+		return fmt.Errorf("could not get the materialized views: %w", err)
+	}
+
+	output := outputTable{
+		Writer:  os.Stdout,
+		Columns: []string{"Name", "Query", "Refresh interval", "Mode", "Last refreshed"},
+	}
+	output.InferFlags(cmd.Flags())
+	for _, view := range views {
+		interval, mode, lastRefreshed := describeMaterializedView(view)
+		output.AddRow(view.Name, view.Query, interval, mode, lastRefreshed)
+	}
+
+	return output.Close()
+}
+
+func MaterializeCreate(cmd *cobra.Command, args []string) error {
+	every, _ := cmd.Flags().GetDuration("every")
+	updatedAt, _ := cmd.Flags().GetString("updated-at")
+	key, _ := cmd.Flags().GetString("key")
+
+	db, queries, databasePath, closeDatabases, err := openLocalDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDatabases()
+
+	start := time.Now()
+	rows, err := createMaterializedView(context.Background(), db, queries, model.MaterializedView{
+		Databasepath:    databasePath,
+		Name:            args[0],
+		Query:           strings.Join(args[1:], " "),
+		Refreshinterval: int64(every / time.Second),
+		Updatedatcolumn: updatedAt,
+		Keycolumn:       key,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ The materialized view %s has been created with %d rows in %s\n", args[0], rows, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func MaterializeRefresh(cmd *cobra.Command, args []string) error {
+	onlyDue, _ := cmd.Flags().GetBool("due")
+
+	db, queries, databasePath, closeDatabases, err := openLocalDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDatabases()
+
+	ctx := context.Background()
+	var views []model.MaterializedView
+	if len(args) > 0 {
+		for _, name := range args {
+			view, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{
+				Databasepath: databasePath,
+				Name:         name,
+			})
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("the materialized view %s does not exist", name)
+			} else if err != nil {
+				return fmt.Errorf("could not get the view %s: %w", name, err)
+			}
+			views = append(views, view)
+		}
+	} else {
+		views, err = queries.GetMaterializedViews(ctx, databasePath)
+		if err != nil {
+			return fmt.Errorf("could not get the materialized views: %w", err)
+		}
+	}
+
+	for _, view := range views {
+		if onlyDue && !isViewDue(view, time.Now()) {
+			continue
+		}
+		start := time.Now()
+		rows, err := refreshAndRecordMaterializedView(ctx, db, queries, view)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Refreshed %s (%d rows) in %s\n", view.Name, rows, time.Since(start).Round(time.Millisecond))
+	}
+
+	return nil
+}
+
+func MaterializeDelete(cmd *cobra.Command, args []string) error {
+	db, queries, databasePath, closeDatabases, err := openLocalDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDatabases()
+
+	err = dropMaterializedView(context.Background(), db, queries, databasePath, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ The materialized view %s has been deleted\n", args[0])
+	return nil
+}
+
+// materializeDotCommand runs the .materialize dot command of the shell
+//
+//	.materialize                                  lists the views
+//	.materialize refresh [name]                   refreshes one or all the views
+//	.materialize drop <name>                      deletes a view and its table
+//	.materialize [--every 1h] [--updated-at col --key col] <name> <table or query>
+func materializeDotCommand(queryData *QueryData, args []string) {
+	// parseDotFunc returns an empty argument for each repeated space
+	args = slices.DeleteFunc(args, func(arg string) bool { return arg == "" })
+
+	databasePath, err := localDatabasePath(queryData.Config.GetString("databasePath", ""))
+	if err != nil {
+		queryData.Message = err.Error()
+		queryData.StatusCode = 2
+		return
+	}
+
+	configDB, queries, err := requestDatabase(configFlagSet(queryData.Config.GetString("configPath", "")), false)
+	if err != nil {
+		queryData.Message = "could not open the config database: " + err.Error()
+		queryData.StatusCode = 2
+		return
+	}
+	defer configDB.Close()
+
+	ctx := context.Background()
+	message := strings.Builder{}
+	switch {
+	case len(args) == 0 || (len(args) == 1 && strings.ToLower(args[0]) == "list"):
+		var views []model.MaterializedView
+		views, err = queries.GetMaterializedViews(ctx, databasePath)
+		if err == nil && len(views) == 0 {
+			message.WriteString("No materialized view. Create one with .materialize <name> <table or query>")
+		}
+		for _, view := range views {
+			interval, mode, lastRefreshed := describeMaterializedView(view)
+			fmt.Fprintf(&message, "%s: %s (refreshed %s, %s, last refresh: %s)\n", view.Name, view.Query, interval, mode, lastRefreshed)
+		}
+
+	case strings.ToLower(args[0]) == "refresh":
+		var views []model.MaterializedView
+		if len(args) > 1 {
+			var view model.MaterializedView
+			view, err = queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: args[1]})
+			if err == sql.ErrNoRows {
+				err = fmt.Errorf("the materialized view %s does not exist", args[1])
+			}
+			views = append(views, view)
+		} else {
+			views, err = queries.GetMaterializedViews(ctx, databasePath)
+		}
+		for i := 0; err == nil && i < len(views); i++ {
+			var rows int64
+			rows, err = refreshAndRecordMaterializedView(ctx, queryData.DB, queries, views[i])
+			if err == nil {
+				fmt.Fprintf(&message, "Refreshed %s (%d rows)\n", views[i].Name, rows)
+			}
+		}
+
+	case strings.ToLower(args[0]) == "drop":
+		if len(args) < 2 {
+			err = fmt.Errorf("usage: .materialize drop <name>")
+		} else if err = dropMaterializedView(ctx, queryData.DB, queries, databasePath, args[1]); err == nil {
+			fmt.Fprintf(&message, "Deleted the materialized view %s", args[1])
+		}
+
+	default:
+		flags := pflag.NewFlagSet("materialize", pflag.ContinueOnError)
+		flags.SetInterspersed(false)
+		flags.SetOutput(&strings.Builder{})
+		every := flags.Duration("every", 0, "")
+		updatedAt := flags.String("updated-at", "", "")
+		key := flags.String("key", "", "")
+		if err = flags.Parse(args); err != nil {
+			break
+		}
+		if flags.NArg() < 2 {
+			err = fmt.Errorf("usage: .materialize [--every 1h] [--updated-at column --key column] <name> <table or query>")
+			break
+		}
+
+		var rows int64
+		rows, err = createMaterializedView(ctx, queryData.DB, queries, model.MaterializedView{
+			Databasepath:    databasePath,
+			Name:            flags.Arg(0),
+			Query:           strings.Join(flags.Args()[1:], " "),
+			Refreshinterval: int64(*every / time.Second),
+			Updatedatcolumn: *updatedAt,
+			Keycolumn:       *key,
+		})
+		if err == nil {
+			fmt.Fprintf(&message, "Created the materialized view %s with %d rows", flags.Arg(0), rows)
+		}
+	}
+
+	if err != nil {
+		queryData.Message = err.Error()
+		queryData.StatusCode = 2
+		return
+	}
+	queryData.Message = strings.TrimSuffix(message.String(), "\n")
+}
+
+// configFlagSet returns a flag set holding the path of the config database
+// so that the shell can call requestDatabase
+func configFlagSet(path string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.String("config", path, "")
+	return flags
+}
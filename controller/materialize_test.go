@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julien040/anyquery/controller/config"
+	"github.com/julien040/anyquery/controller/config/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaterializedView(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	configDB, queries, err := config.OpenDatabaseConnection(filepath.Join(dir, "config.db"), false)
+	require.NoError(t, err, "The config should open")
+	defer configDB.Close()
+
+	databasePath := filepath.Join(dir, "anyquery.db")
+	db, err := sql.Open("sqlite3", databasePath)
+	require.NoError(t, err, "The database should open")
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE issues (id INTEGER, updated_at TEXT, title TEXT);
+	INSERT INTO issues VALUES (1, '2024-01-01', 'first'), (2, '2024-02-01', 'second');`)
+	require.NoError(t, err)
+
+	countRows := func(query string) int {
+		var count int
+		require.NoError(t, db.QueryRow(query).Scan(&count))
+		return count
+	}
+
+	t.Run("Create a view", func(t *testing.T) {
+		rows, err := createMaterializedView(ctx, db, queries, model.MaterializedView{
+			Databasepath:    databasePath,
+			Name:            "mirror",
+			Query:           "issues",
+			Refreshinterval: 3600,
+			Updatedatcolumn: "updated_at",
+			Keycolumn:       "id",
+		})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), rows)
+		require.Equal(t, 2, countRows("SELECT count(*) FROM mirror"))
+
+		view, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: "mirror"})
+		require.NoError(t, err)
+		require.Equal(t, "SELECT * FROM issues", view.Query)
+		require.Equal(t, "2024-02-01", view.Lastupdatedat)
+		require.NotZero(t, view.Lastrefreshed)
+		require.False(t, isViewDue(view, time.Now()))
+		require.True(t, isViewDue(view, time.Now().Add(time.Hour)))
+	})
+
+	t.Run("Refresh incrementally", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE issues SET updated_at = '2024-03-01', title = 'second (edited)' WHERE id = 2;
+		INSERT INTO issues VALUES (3, '2024-04-01', 'third');
+		DELETE FROM issues WHERE id = 1;`)
+		require.NoError(t, err)
+
+		view, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: "mirror"})
+		require.NoError(t, err)
+		rows, err := refreshAndRecordMaterializedView(ctx, db, queries, view)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), rows, "Only the updated rows should be requested")
+
+		// The deleted row is kept because it's never returned by an incremental refresh
+		require.Equal(t, 3, countRows("SELECT count(*) FROM mirror"))
+		require.Equal(t, 1, countRows("SELECT count(*) FROM mirror WHERE title = 'second (edited)'"))
+
+		view, err = queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: "mirror"})
+		require.NoError(t, err)
+		require.Equal(t, "2024-04-01", view.Lastupdatedat)
+	})
+
+	t.Run("A row of the last timestamp added later is refreshed", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO issues VALUES (4, '2024-04-01', 'fourth')`)
+		require.NoError(t, err)
+
+		view, err := queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: "mirror"})
+		require.NoError(t, err)
+		_, err = refreshAndRecordMaterializedView(ctx, db, queries, view)
+		require.NoError(t, err)
+		require.Equal(t, 1, countRows("SELECT count(*) FROM mirror WHERE id = 4"))
+		require.Equal(t, 1, countRows("SELECT count(*) FROM mirror WHERE id = 3"), "The rows of the last timestamp must not be duplicated")
+	})
+
+	t.Run("Refresh fully", func(t *testing.T) {
+		rows, err := createMaterializedView(ctx, db, queries, model.MaterializedView{
+			Databasepath: databasePath,
+			Name:         "mirror",
+			Query:        "SELECT id, title FROM issues;",
+		})
+		require.NoError(t, err, "A view can be redefined")
+		require.Equal(t, int64(3), rows)
+		require.Equal(t, 3, countRows("SELECT count(*) FROM mirror"))
+		require.Equal(t, 0, countRows("SELECT count(*) FROM sqlite_schema WHERE name LIKE '_anyquery_refresh_%'"))
+	})
+
+	t.Run("Reject invalid views", func(t *testing.T) {
+		_, err := createMaterializedView(ctx, db, queries, model.MaterializedView{
+			Databasepath: databasePath,
+			Name:         "issues",
+			Query:        "SELECT 1",
+		})
+		require.Error(t, err, "An existing table must not be overwritten")
+
+		_, err = createMaterializedView(ctx, db, queries, model.MaterializedView{
+			Databasepath:    databasePath,
+			Name:            "other",
+			Query:           "SELECT * FROM issues",
+			Updatedatcolumn: "updated_at",
+		})
+		require.Error(t, err, "An incremental view needs a key column")
+
+		_, err = createMaterializedView(ctx, db, queries, model.MaterializedView{
+			Databasepath: databasePath,
+			Name:         "broken",
+			Query:        "SELECT * FROM doesnotexist",
+		})
+		require.Error(t, err)
+		_, err = queries.GetMaterializedView(ctx, model.GetMaterializedViewParams{Databasepath: databasePath, Name: "broken"})
+		require.ErrorIs(t, err, sql.ErrNoRows, "A view that fails to refresh should not be recorded")
+	})
+
+	t.Run("Drop a view", func(t *testing.T) {
+		require.NoError(t, dropMaterializedView(ctx, db, queries, databasePath, "mirror"))
+		require.Equal(t, 0, countRows("SELECT count(*) FROM sqlite_schema WHERE name = 'mirror'"))
+		require.Error(t, dropMaterializedView(ctx, db, queries, databasePath, "mirror"))
+
+		views, err := queries.GetMaterializedViews(ctx, databasePath)
+		require.NoError(t, err)
+		require.Empty(t, views)
+	})
+}
@@ -3,6 +3,9 @@
 package controller
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"math/rand/v2"
 	"os"
@@ -10,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/julien040/anyquery/module"
 	"github.com/julien040/anyquery/namespace"
 	"github.com/julien040/anyquery/other/prql"
 	"github.com/julien040/anyquery/other/sqlparser"
@@ -58,10 +62,44 @@ func middlewareDotCommand(queryData *QueryData) bool {
 		queryData.SQLQuery = "SELECT name FROM pragma_database_list;"
 		return true
 
+	case "dryrun", "dry-run":
+		if len(args) == 0 {
+			queryData.Message = fmt.Sprintf("Dry run is %s", ternary.If(queryData.Config.GetBool("dryRun", false), "on", "off"))
+		} else {
+			switch strings.ToLower(args[0]) {
+			case "on":
+				queryData.Config.SetBool("dryRun", true)
+				queryData.Message = "Dry run enabled. INSERT, UPDATE and DELETE statements won't reach the plugins"
+			case "off":
+				queryData.Config.SetBool("dryRun", false)
+				queryData.Message = "Dry run disabled"
+			default:
+				queryData.Message = "Usage: .dryrun on|off"
+				queryData.StatusCode = 2
+			}
+		}
+
 	case "help":
 		queryData.Message = "Documentation available at https://anyquery.dev/docs/usage/running-queries#commands"
 		queryData.StatusCode = 0
 
+	case "profile":
+		if len(args) == 0 {
+			queryData.Message = fmt.Sprintf("Profiling is %s", ternary.If(queryData.Config.GetBool("profile", false), "on", "off"))
+		} else {
+			switch strings.ToLower(args[0]) {
+			case "on":
+				queryData.Config.SetBool("profile", true)
+				queryData.Message = "Profiling enabled. The metrics of the virtual tables are printed after each query"
+			case "off":
+				queryData.Config.SetBool("profile", false)
+				queryData.Message = "Profiling disabled"
+			default:
+				queryData.Message = "Usage: .profile on|off"
+				queryData.StatusCode = 2
+			}
+		}
+
 	case "indexes", "index":
 		queryData.SQLQuery = "SELECT * FROM pragma_index_list;"
 
@@ -73,6 +111,9 @@ func middlewareDotCommand(queryData *QueryData) bool {
 			queryData.Config.SetBool("logEnabled", true)
 		}
 
+	case "materialize", "materialized":
+		materializeDotCommand(queryData, args)
+
 	case "maxrows":
 		if len(args) == 0 {
 			queryData.Message = "No maxrows provided"
@@ -138,6 +179,10 @@ func middlewareDotCommand(queryData *QueryData) bool {
 			}
 		} else {
 			queryData.Config.SetString("outputFile", args[0])
+			// The second argument is the table written by the sqlite output mode
+			if len(args) > 1 {
+				queryData.Config.SetString("outputTable", args[1])
+			}
 			// We don't set the message because it would be outputted
 			// to the file
 		}
@@ -309,9 +354,43 @@ func middlewareQuery(queryData *QueryData) bool {
 	if queryData.SQLQuery == "" {
 		return true
 	}
+	// A COPY statement is usually extracted by middlewareFileQuery already
+	if !extractCopyStatement(queryData) {
+		return false
+	}
+	// If the query has a context, we run it on a dedicated connection
+	// bound to the context so that the plugins can be cancelled.
+	// A dry run also needs a dedicated connection to record the writes of the plugin tables,
+	// and the profiler to record the metrics of the cursors opened by the query
+	ctx := queryData.Context
+	dryRun := queryData.Config.GetBool("dryRun", false)
+	profile := queryData.Config.GetBool("profile", false)
+	var runner queryRunner = queryData.DB
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if queryData.Context != nil || dryRun || profile {
+		conn, err := queryData.DB.Conn(ctx)
+		if err != nil {
+			queryData.Message = queryErrorMessage(ctx, err)
+			queryData.StatusCode = 2
+			return false
+		}
+		// If the context cannot be bound (e.g. not a SQLite connection),
+		// the query still runs and database/sql interrupts it once ctx is done
+		if queryData.Context != nil {
+			queryData.release, _ = namespace.BindContext(ctx, conn)
+		}
+		if profile {
+			bindProfile(queryData, conn)
+		}
+		queryData.conn = conn
+		runner = conn
+	}
+
 	// Run the pre-execution statements
 	for i, preExec := range queryData.PreExec {
-		_, err := queryData.DB.Exec(preExec)
+		_, err := runner.ExecContext(ctx, preExec)
 		if err != nil {
 			queryData.Message = fmt.Sprintf("Error in pre-execution statement %d: %s", i, err.Error())
 			queryData.StatusCode = 2
@@ -319,6 +398,11 @@ func middlewareQuery(queryData *QueryData) bool {
 		}
 	}
 
+	// The rows of a COPY statement are written to its file
+	if queryData.copyTo != nil {
+		return runCopy(ctx, runner, queryData)
+	}
+
 	// Check whether the query must be run with Query or Exec
 	// We need to check that because, for example, a CREATE VIRTUAL TABLE statement run with Query
 	// will not return an error if it fails
@@ -338,18 +422,24 @@ func middlewareQuery(queryData *QueryData) bool {
 		runWithQuery = false
 	}
 
+	// A dry run fails closed: only the statements known to be a SELECT are run as is.
+	// SQLite writes that the parser doesn't know (e.g. INSERT OR REPLACE, RETURNING) are recorded too
+	if dryRun && queryType != sqlparser.StmtSelect {
+		return runDryRun(ctx, queryData)
+	}
+
 	if runWithQuery {
-		rows, err := queryData.DB.Query(queryData.SQLQuery, queryData.Args...)
+		rows, err := runner.QueryContext(ctx, queryData.SQLQuery, queryData.Args...)
 		if err != nil {
-			queryData.Message = err.Error()
+			queryData.Message = queryErrorMessage(ctx, err)
 			queryData.StatusCode = 2
 			return false
 		}
 		queryData.Result = rows
 	} else {
-		res, err := queryData.DB.Exec(queryData.SQLQuery, queryData.Args...)
+		res, err := runner.ExecContext(ctx, queryData.SQLQuery, queryData.Args...)
 		if err != nil {
-			queryData.Message = err.Error()
+			queryData.Message = queryErrorMessage(ctx, err)
 			queryData.StatusCode = 2
 			return false
 		}
@@ -369,6 +459,103 @@ func middlewareQuery(queryData *QueryData) bool {
 	return true
 }
 
+// bindProfile records the metrics of the cursors opened on conn in queryData.Profile
+//
+// If the profile cannot be bound, the query runs without it
+func bindProfile(queryData *QueryData, conn *sql.Conn) {
+	profile := &module.Profile{}
+	release, err := namespace.BindProfile(conn, profile)
+	if err != nil {
+		return
+	}
+	queryData.Profile = profile
+	if releaseContext := queryData.release; releaseContext != nil {
+		queryData.release = func() {
+			release()
+			releaseContext()
+		}
+	} else {
+		queryData.release = release
+	}
+}
+
+// The query listing the writes recorded by a dry run, passed as a JSON array
+const dryRunQuery = `SELECT json_extract(value, '$.table') AS "table", json_extract(value, '$.operation') AS operation,
+json_extract(value, '$.primary_key') AS primary_key, json_extract(value, '$.values') AS "values" FROM json_each(?)`
+
+// runDryRun runs the statement of queryData on its dedicated connection without sending
+// the inserts, updates and deletes to the plugins, and returns the rows they would have received
+//
+// The changes made to the SQLite tables are rolled back
+func runDryRun(ctx context.Context, queryData *QueryData) bool {
+	conn := queryData.conn
+	recorder := &module.DryRun{}
+	release, err := namespace.BindDryRun(conn, recorder)
+	if err != nil {
+		queryData.Message = fmt.Sprintf("Could not start the dry run: %s", err.Error())
+		queryData.StatusCode = 2
+		return false
+	}
+	defer release()
+
+	_, err = conn.ExecContext(ctx, "SAVEPOINT anyquery_dry_run")
+	if err != nil {
+		queryData.Message = fmt.Sprintf("Could not start the dry run: %s", err.Error())
+		queryData.StatusCode = 2
+		return false
+	}
+	_, err = conn.ExecContext(ctx, queryData.SQLQuery, queryData.Args...)
+	// Use a background context so that the savepoint is released even if the query was cancelled
+	conn.ExecContext(context.Background(), "ROLLBACK TO anyquery_dry_run")
+	conn.ExecContext(context.Background(), "RELEASE anyquery_dry_run")
+	if err != nil {
+		queryData.Message = queryErrorMessage(ctx, err)
+		queryData.StatusCode = 2
+		return false
+	}
+
+	writes := recorder.Writes()
+	if len(writes) == 0 {
+		queryData.Message = "Dry run: no row would be sent to the plugins"
+		return true
+	}
+	marshalled, err := json.Marshal(writes)
+	if err != nil {
+		queryData.Message = fmt.Sprintf("Could not encode the rows of the dry run: %s", err.Error())
+		queryData.StatusCode = 2
+		return false
+	}
+	rows, err := conn.QueryContext(ctx, dryRunQuery, string(marshalled))
+	if err != nil {
+		queryData.Message = queryErrorMessage(ctx, err)
+		queryData.StatusCode = 2
+		return false
+	}
+	queryData.Result = rows
+	return true
+}
+
+// newMiddlewareAggregatePushdown returns a middleware rewriting the queries
+// that aggregate the rows of a plugin table, so that the plugin computes the aggregates
+// rather than returning every row (see namespace.RewriteAggregateQuery)
+func newMiddlewareAggregatePushdown(n *namespace.Namespace) middleware {
+	return func(queryData *QueryData) bool {
+		if n == nil || queryData.SQLQuery == "" {
+			return true
+		}
+
+		pushdown, ok := n.RewriteAggregateQuery(queryData.SQLQuery)
+		if !ok {
+			return true
+		}
+
+		queryData.PreExec = append(queryData.PreExec, pushdown.PreExec)
+		queryData.SQLQuery = pushdown.Query
+		queryData.PostExec = append(queryData.PostExec, pushdown.PostExec)
+		return true
+	}
+}
+
 func middlewareSlashCommand(queryData *QueryData) bool {
 	// Check if slash command are enabled
 	if !queryData.Config.GetBool("slash-command", false) {
@@ -461,6 +648,8 @@ var supportedTableFunctions = map[string]string{
 	"read_jsonl":   "jsonl_reader",
 	"read_ndjson":  "jsonl_reader",
 	"read_log":     "log_reader",
+	"read_xlsx":    "xlsx_reader",
+	"read_ods":     "xlsx_reader",
 	"read_file":    "file_reader",
 }
 
@@ -484,6 +673,11 @@ func middlewareFileQuery(queryData *QueryData) bool {
 	// and once the query is executed, we drop the table
 	// That's a workaround around the limitation of SQLite
 
+	// The table functions of a COPY statement are in its query
+	if !extractCopyStatement(queryData) {
+		return false
+	}
+
 	// Parse the query
 	parser, err := sqlparser.New(sqlparser.Options{
 		MySQLServerVersion: "8.0.30",
@@ -606,6 +800,8 @@ func middlewareFileQuery(queryData *QueryData) bool {
 				preExecBuilder.WriteString("jsonl_reader")
 			case "read_log":
 				preExecBuilder.WriteString("log_reader")
+			case "read_xlsx", "read_ods":
+				preExecBuilder.WriteString("xlsx_reader")
 			case "read_file":
 				preExecBuilder.WriteString("file_reader")
 			default:
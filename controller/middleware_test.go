@@ -1,8 +1,15 @@
 package controller
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
 
+	"github.com/julien040/anyquery/namespace"
+	"github.com/julien040/anyquery/rpc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,3 +44,183 @@ func TestDotParser(t *testing.T) {
 	}
 
 }
+
+func TestDryRun(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/insertplugin.out", "../test/insertplugin.go").Run()
+	require.NoError(t, err, "The plugin should build")
+
+	n, err := namespace.NewNamespace(namespace.NamespaceConfig{
+		InMemory: true,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+	err = n.LoadAnyqueryPlugin("_test/insertplugin.out", rpc.PluginManifest{
+		Name:   "insert",
+		Tables: []string{"people"},
+	}, nil, 0)
+	require.NoError(t, err, "The plugin should load")
+	db, err := n.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	var buf bytes.Buffer
+	sh := &shell{
+		DB:          db,
+		Middlewares: []middleware{middlewareDotCommand, middlewareQuery},
+		Config: middlewareConfiguration{
+			"dot-command":       true,
+			"doNotModifyOutput": true,
+			"outputMode":        "jsonl",
+		},
+		OutputFileDesc: &buf,
+	}
+	run := func(query string) string {
+		buf.Reset()
+		sh.Run(query)
+		return buf.String()
+	}
+
+	run("CREATE TABLE local(a)")
+	run(".dryrun on")
+
+	t.Run("The writes are printed rather than sent to the plugin", func(t *testing.T) {
+		out := run("INSERT INTO people(id, name, age, address) VALUES (3, 'Carol', 40, '1 Oak St')")
+		require.Contains(t, out, `"operation":"INSERT"`)
+		require.Contains(t, out, `"table":"people"`)
+		require.Contains(t, out, "Carol")
+
+		out = run("UPDATE people SET age = 21 WHERE name = 'Alice'")
+		require.Contains(t, out, `"operation":"UPDATE"`)
+		require.Contains(t, out, `"primary_key":1`)
+
+		out = run("DELETE FROM people WHERE name = 'Bob'")
+		require.Contains(t, out, `"operation":"DELETE"`)
+		require.Contains(t, out, `"primary_key":2`)
+	})
+
+	t.Run("The writes written in the SQLite dialect are recorded", func(t *testing.T) {
+		out := run("INSERT OR REPLACE INTO people(id, name, age, address) VALUES (4, 'Dave', 50, '2 Elm St')")
+		require.Contains(t, out, `"operation":"INSERT"`)
+		require.Contains(t, out, "Dave")
+
+		out = run("DELETE FROM people WHERE name GLOB 'B*'")
+		require.Contains(t, out, `"operation":"DELETE"`)
+	})
+
+	t.Run("The SQLite tables are not modified", func(t *testing.T) {
+		out := run("INSERT INTO local VALUES (1)")
+		require.Contains(t, out, "no row would be sent to the plugins")
+	})
+
+	run(".dryrun off")
+
+	t.Run("Nothing reached the plugin", func(t *testing.T) {
+		out := run("SELECT name, age FROM people ORDER BY id")
+		require.Contains(t, out, "Alice")
+		require.Contains(t, out, "Bob")
+		require.NotContains(t, out, "Carol")
+		require.NotContains(t, out, "Dave")
+		require.NotContains(t, out, "21")
+
+		out = run("SELECT count(*) AS c FROM local")
+		require.Contains(t, out, `"c":0`)
+	})
+}
+
+func TestProfile(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/insertplugin.out", "../test/insertplugin.go").Run()
+	require.NoError(t, err, "The plugin should build")
+
+	n, err := namespace.NewNamespace(namespace.NamespaceConfig{
+		InMemory: true,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+	err = n.LoadAnyqueryPlugin("_test/insertplugin.out", rpc.PluginManifest{
+		Name:   "insert",
+		Tables: []string{"people"},
+	}, nil, 0)
+	require.NoError(t, err, "The plugin should load")
+	db, err := n.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	var buf bytes.Buffer
+	sh := &shell{
+		DB:          db,
+		Middlewares: []middleware{middlewareDotCommand, middlewareQuery},
+		Config: middlewareConfiguration{
+			"dot-command":       true,
+			"doNotModifyOutput": true,
+			"outputMode":        "jsonl",
+		},
+		OutputFileDesc: &buf,
+	}
+	run := func(query string) string {
+		buf.Reset()
+		sh.Run(query)
+		return buf.String()
+	}
+
+	t.Run("No profile is printed by default", func(t *testing.T) {
+		out := run("SELECT name FROM people")
+		require.NotContains(t, out, `"profile"`)
+	})
+
+	run(".profile on")
+
+	t.Run("The JSON formats get a JSON trailer", func(t *testing.T) {
+		out := run("SELECT name FROM people")
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		require.Len(t, lines, 3, "The two rows and the trailer should be printed")
+
+		var trailer struct {
+			Profile []struct {
+				Table       string  `json:"table"`
+				FilterCalls int64   `json:"filter_calls"`
+				RoundTrips  int64   `json:"round_trips"`
+				RowsFetched int64   `json:"rows_fetched"`
+				RowsEmitted int64   `json:"rows_emitted"`
+				Bytes       int64   `json:"bytes"`
+				DurationMS  float64 `json:"duration_ms"`
+			} `json:"profile"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(lines[2]), &trailer))
+		require.Len(t, trailer.Profile, 1)
+		cursor := trailer.Profile[0]
+		require.Equal(t, "people", cursor.Table)
+		require.Equal(t, int64(1), cursor.FilterCalls)
+		require.GreaterOrEqual(t, cursor.RoundTrips, int64(1))
+		require.Equal(t, int64(2), cursor.RowsFetched)
+		require.Equal(t, int64(2), cursor.RowsEmitted)
+		require.Greater(t, cursor.Bytes, int64(0))
+	})
+
+	t.Run("The profile of a single JSON value is written apart", func(t *testing.T) {
+		var profileOutput bytes.Buffer
+		sh.ProfileFileDesc = &profileOutput
+		defer func() { sh.ProfileFileDesc = nil }()
+		run(".mode json")
+		out := run("SELECT name FROM people")
+
+		var rows []map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rows), "The output must stay a single JSON value")
+		require.Len(t, rows, 2)
+		require.Contains(t, profileOutput.String(), `"profile"`)
+	})
+
+	t.Run("The other formats get a tree", func(t *testing.T) {
+		run(".mode plain")
+		out := run("SELECT name FROM people")
+		require.Contains(t, out, "Query profile (1 cursor")
+		require.Contains(t, out, "people")
+		require.Contains(t, out, "rows fetched: 2, emitted: 2")
+	})
+
+	run(".profile off")
+
+	t.Run("The profile can be disabled", func(t *testing.T) {
+		out := run("SELECT name FROM people")
+		require.NotContains(t, out, "Query profile")
+	})
+}
@@ -32,12 +32,23 @@ const (
 	outputTableTypeMarkdown
 	outputTableTypeLineByLine
 	outputTableTypeHtml
+	outputTableTypeParquet
+	outputTableTypeArrow
+	outputTableTypeArrowStream
+	outputTableTypeSqlite
 )
 
 type outputTable struct {
 	// The columns of the table
 	Columns []string
 
+	// The declared types of the columns (e.g. INTEGER, DATETIME), if known.
+	// They are used by the binary formats to type the columns
+	ColumnTypes []string
+
+	// The table the rows are written in by the sqlite format
+	TableName string
+
 	// The type of the output
 	Type outputTableType
 
@@ -71,6 +82,20 @@ var formatName map[string]outputTableType = map[string]outputTableType{
 	"markdown":    outputTableTypeMarkdown,
 	"linebyline":  outputTableTypeLineByLine,
 	"html":        outputTableTypeHtml,
+	"parquet":     outputTableTypeParquet,
+	"arrow":       outputTableTypeArrow,
+	"arrows":      outputTableTypeArrowStream,
+	"sqlite":      outputTableTypeSqlite,
+}
+
+// isBinaryFormat reports whether the output type writes a binary file
+// that must be truncated rather than appended to
+func isBinaryFormat(outputType outputTableType) bool {
+	switch outputType {
+	case outputTableTypeParquet, outputTableTypeArrow, outputTableTypeArrowStream, outputTableTypeSqlite:
+		return true
+	}
+	return false
 }
 
 // Create a new output table and force the output type
@@ -147,6 +172,23 @@ func (o *outputTable) SetEncoder() {
 			Columns: o.Columns,
 			Writer:  o.Writer,
 		}
+	case outputTableTypeParquet:
+		o.encoder = &parquetTableEncoder{
+			binaryBatch: binaryBatch{Columns: o.Columns, ColumnTypes: o.ColumnTypes},
+			Writer:      o.Writer,
+		}
+	case outputTableTypeArrow, outputTableTypeArrowStream:
+		o.encoder = &arrowTableEncoder{
+			binaryBatch: binaryBatch{Columns: o.Columns, ColumnTypes: o.ColumnTypes},
+			Writer:      o.Writer,
+			Stream:      o.Type == outputTableTypeArrowStream,
+		}
+	case outputTableTypeSqlite:
+		o.encoder = &sqliteTableEncoder{
+			binaryBatch: binaryBatch{Columns: o.Columns, ColumnTypes: o.ColumnTypes},
+			Writer:      o.Writer,
+			Table:       o.TableName,
+		}
 
 	default:
 		// We default to plain with header if the type is unknown
@@ -197,6 +239,14 @@ func (o *outputTable) WriteSQLRows(rows *sql.Rows) error {
 	}
 	o.Columns = cols
 
+	// The declared types are only used by the binary formats
+	if columnTypes, err := rows.ColumnTypes(); err == nil {
+		o.ColumnTypes = make([]string, len(columnTypes))
+		for i, columnType := range columnTypes {
+			o.ColumnTypes[i] = columnType.DatabaseTypeName()
+		}
+	}
+
 	// Store the scannedValues of the rows
 	scannedValues := make([]interface{}, len(cols))
 	for i := range scannedValues {
@@ -279,6 +329,10 @@ func (o *outputTable) InferFlags(flag *pflag.FlagSet) {
 		}
 	}
 
+	if table, err := flag.GetString("output-table"); err == nil && table != "" {
+		o.TableName = table
+	}
+
 }
 
 // Close flushes the output
@@ -0,0 +1,375 @@
+package controller
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// This file implements the Arrow IPC format (https://arrow.apache.org/docs/format/Columnar.html#serialization-and-interprocess-communication-ipc)
+//
+// The messages are flatbuffers described by Schema.fbs, Message.fbs and File.fbs of the Arrow repository.
+// Only the types needed by SQLite values are supported, and each batch of rows is a record batch
+
+const (
+	arrowMetadataVersionV5 = 4
+
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeBinary        = 4
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeDate          = 8
+	arrowTypeTimestamp     = 10
+
+	arrowPrecisionDouble  = 2
+	arrowDateUnitDay      = 0
+	arrowDateUnitDefault  = 1 // Milliseconds
+	arrowTimeUnitMicro    = 2
+	arrowContinuationMark = 0xFFFFFFFF
+)
+
+var arrowMagic = []byte("ARROW1")
+
+type arrowTableEncoder struct {
+	binaryBatch
+	Writer io.Writer
+	// If true, the stream format is written. Otherwise, the file format (with a footer) is written
+	Stream bool
+
+	headerWritten bool
+	// The number of bytes written, to compute the offsets of the blocks of the footer
+	offset int64
+	blocks []arrowBlock
+}
+
+// arrowBlock is the position of a record batch in an Arrow file
+type arrowBlock struct {
+	offset         int64
+	metadataLength int32
+	bodyLength     int64
+}
+
+func (a *arrowTableEncoder) Write(row []interface{}) error {
+	if a.add(row) {
+		return a.flush()
+	}
+	return nil
+}
+
+func (a *arrowTableEncoder) write(p []byte) error {
+	n, err := a.Writer.Write(p)
+	a.offset += int64(n)
+	return err
+}
+
+// writeMessage writes an encapsulated message (the flatbuffer metadata followed by the body)
+// and returns the length of the metadata
+func (a *arrowTableEncoder) writeMessage(metadata []byte, body []byte) (int32, error) {
+	padded := arrowPadding(len(metadata) + 8)
+	prefix := make([]byte, 8)
+	binary.LittleEndian.PutUint32(prefix[0:4], arrowContinuationMark)
+	binary.LittleEndian.PutUint32(prefix[4:8], uint32(padded-8))
+
+	if err := a.write(prefix); err != nil {
+		return 0, err
+	}
+	if err := a.write(metadata); err != nil {
+		return 0, err
+	}
+	if err := a.write(make([]byte, padded-8-len(metadata))); err != nil {
+		return 0, err
+	}
+	if err := a.write(body); err != nil {
+		return 0, err
+	}
+	return int32(padded), nil
+}
+
+func (a *arrowTableEncoder) flush() error {
+	rows, err := a.take()
+	if err != nil {
+		return err
+	}
+
+	if !a.headerWritten {
+		format := "arrow"
+		if a.Stream {
+			format = "arrows"
+		}
+		if err := checkBinaryWriter(a.Writer, format); err != nil {
+			return err
+		}
+		if !a.Stream {
+			if err := a.write(append(append([]byte{}, arrowMagic...), 0, 0)); err != nil {
+				return err
+			}
+		}
+		builder := flatbuffers.NewBuilder(1024)
+		schema := a.buildSchema(builder)
+		builder.Finish(arrowMessage(builder, arrowHeaderSchema, schema, 0))
+		if _, err := a.writeMessage(builder.FinishedBytes(), nil); err != nil {
+			return err
+		}
+		a.headerWritten = true
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	body, nodes, buffers, err := a.buildBody(rows)
+	if err != nil {
+		return err
+	}
+	builder := flatbuffers.NewBuilder(1024)
+
+	// Vectors of structs are written backwards
+	builder.StartVector(16, len(nodes)/2, 8)
+	for i := len(nodes) - 2; i >= 0; i -= 2 {
+		builder.Prep(8, 16)
+		builder.PrependInt64(nodes[i+1]) // null_count
+		builder.PrependInt64(nodes[i])   // length
+	}
+	nodesVector := builder.EndVector(len(nodes) / 2)
+
+	builder.StartVector(16, len(buffers)/2, 8)
+	for i := len(buffers) - 2; i >= 0; i -= 2 {
+		builder.Prep(8, 16)
+		builder.PrependInt64(buffers[i+1]) // length
+		builder.PrependInt64(buffers[i])   // offset
+	}
+	buffersVector := builder.EndVector(len(buffers) / 2)
+
+	builder.StartObject(5)
+	builder.PrependInt64Slot(0, int64(len(rows)), 0)
+	builder.PrependUOffsetTSlot(1, nodesVector, 0)
+	builder.PrependUOffsetTSlot(2, buffersVector, 0)
+	recordBatch := builder.EndObject()
+	builder.Finish(arrowMessage(builder, arrowHeaderRecordBatch, recordBatch, int64(len(body))))
+
+	block := arrowBlock{offset: a.offset, bodyLength: int64(len(body))}
+	block.metadataLength, err = a.writeMessage(builder.FinishedBytes(), body)
+	if err != nil {
+		return err
+	}
+	a.blocks = append(a.blocks, block)
+	return nil
+}
+
+// buildBody returns the body of a record batch, the field nodes (length, null count)
+// and the buffers (offset, length) describing it
+func (a *arrowTableEncoder) buildBody(rows [][]interface{}) ([]byte, []int64, []int64, error) {
+	body := []byte{}
+	nodes := []int64{}
+	buffers := []int64{}
+	addBuffer := func(buffer []byte) {
+		buffers = append(buffers, int64(len(body)), int64(len(buffer)))
+		body = append(body, buffer...)
+		body = append(body, make([]byte, arrowPadding(len(buffer))-len(buffer))...)
+	}
+
+	for column, columnType := range a.types {
+		validity := make([]byte, (len(rows)+7)/8)
+		nullCount := int64(0)
+		for i, row := range rows {
+			if row[column] == nil {
+				nullCount++
+			} else {
+				validity[i/8] |= 1 << (i % 8)
+			}
+		}
+		nodes = append(nodes, int64(len(rows)), nullCount)
+		addBuffer(validity)
+
+		switch columnType {
+		case binaryColumnInt, binaryColumnFloat, binaryColumnDateTime:
+			values := make([]byte, 8*len(rows))
+			for i, row := range rows {
+				var value uint64
+				switch v := row[column].(type) {
+				case int64:
+					value = uint64(v)
+				case float64:
+					value = math.Float64bits(v)
+				case time.Time:
+					value = uint64(v.UnixMicro())
+				}
+				binary.LittleEndian.PutUint64(values[8*i:], value)
+			}
+			addBuffer(values)
+
+		case binaryColumnDate:
+			values := make([]byte, 4*len(rows))
+			for i, row := range rows {
+				if t, ok := row[column].(time.Time); ok {
+					binary.LittleEndian.PutUint32(values[4*i:], uint32(daysSinceEpoch(t)))
+				}
+			}
+			addBuffer(values)
+
+		case binaryColumnBool:
+			values := make([]byte, (len(rows)+7)/8)
+			for i, row := range rows {
+				if v, ok := row[column].(bool); ok && v {
+					values[i/8] |= 1 << (i % 8)
+				}
+			}
+			addBuffer(values)
+
+		default:
+			// Strings and blobs are an array of offsets followed by the concatenated values
+			offsets := make([]byte, 4*(len(rows)+1))
+			data := []byte{}
+			for i, row := range rows {
+				switch v := row[column].(type) {
+				case string:
+					data = append(data, v...)
+				case []byte:
+					data = append(data, v...)
+				}
+				if len(data) > math.MaxInt32 {
+					return nil, nil, nil, fmt.Errorf("column %s: the values of a batch exceed 2 GiB", a.Columns[column])
+				}
+				binary.LittleEndian.PutUint32(offsets[4*(i+1):], uint32(len(data)))
+			}
+			addBuffer(offsets)
+			addBuffer(data)
+		}
+	}
+
+	return body, nodes, buffers, nil
+}
+
+// buildSchema adds the schema of the columns to the builder and returns its offset
+func (a *arrowTableEncoder) buildSchema(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	names := uniqueColumnNames(a.Columns)
+	fields := make([]flatbuffers.UOffsetT, len(names))
+	for i, name := range names {
+		nameOffset := builder.CreateString(name)
+		var timezone flatbuffers.UOffsetT
+		if a.types[i] == binaryColumnDateTime {
+			timezone = builder.CreateString("UTC")
+		}
+
+		var typeType byte
+		switch a.types[i] {
+		case binaryColumnInt:
+			typeType = arrowTypeInt
+			builder.StartObject(2)
+			builder.PrependBoolSlot(1, true, false) // is_signed
+			builder.PrependInt32Slot(0, 64, 0)      // bitWidth
+		case binaryColumnFloat:
+			typeType = arrowTypeFloatingPoint
+			builder.StartObject(1)
+			builder.PrependInt16Slot(0, arrowPrecisionDouble, 0)
+		case binaryColumnBool:
+			typeType = arrowTypeBool
+			builder.StartObject(0)
+		case binaryColumnBlob:
+			typeType = arrowTypeBinary
+			builder.StartObject(0)
+		case binaryColumnDateTime:
+			typeType = arrowTypeTimestamp
+			builder.StartObject(2)
+			builder.PrependUOffsetTSlot(1, timezone, 0)
+			builder.PrependInt16Slot(0, arrowTimeUnitMicro, 0)
+		case binaryColumnDate:
+			typeType = arrowTypeDate
+			builder.StartObject(1)
+			builder.PrependInt16Slot(0, arrowDateUnitDay, arrowDateUnitDefault)
+		default:
+			typeType = arrowTypeUtf8
+			builder.StartObject(0)
+		}
+		typeOffset := builder.EndObject()
+
+		builder.StartVector(4, 0, 4)
+		children := builder.EndVector(0)
+
+		builder.StartObject(7)
+		builder.PrependUOffsetTSlot(5, children, 0)
+		builder.PrependUOffsetTSlot(3, typeOffset, 0)
+		builder.PrependUOffsetTSlot(0, nameOffset, 0)
+		builder.PrependByteSlot(2, typeType, 0)
+		builder.PrependBoolSlot(1, true, false) // nullable
+		fields[i] = builder.EndObject()
+	}
+
+	builder.StartVector(4, len(fields), 4)
+	for i := len(fields) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(fields[i])
+	}
+	fieldsVector := builder.EndVector(len(fields))
+
+	builder.StartObject(4)
+	builder.PrependUOffsetTSlot(1, fieldsVector, 0)
+	return builder.EndObject()
+}
+
+// arrowMessage adds a message wrapping header to the builder
+func arrowMessage(builder *flatbuffers.Builder, headerType byte, header flatbuffers.UOffsetT, bodyLength int64) flatbuffers.UOffsetT {
+	builder.StartObject(5)
+	builder.PrependInt64Slot(3, bodyLength, 0)
+	builder.PrependUOffsetTSlot(2, header, 0)
+	builder.PrependInt16Slot(0, arrowMetadataVersionV5, 0)
+	builder.PrependByteSlot(1, headerType, 0)
+	return builder.EndObject()
+}
+
+// arrowPadding rounds n up to a multiple of 8
+func arrowPadding(n int) int {
+	return (n + 7) &^ 7
+}
+
+func (a *arrowTableEncoder) Close() error {
+	if err := a.flush(); err != nil {
+		return err
+	}
+
+	// End of stream marker
+	eos := make([]byte, 8)
+	binary.LittleEndian.PutUint32(eos, arrowContinuationMark)
+	if err := a.write(eos); err != nil {
+		return err
+	}
+	if a.Stream {
+		return nil
+	}
+
+	// The footer repeats the schema and locates the record batches
+	builder := flatbuffers.NewBuilder(1024)
+	schema := a.buildSchema(builder)
+	builder.StartVector(24, len(a.blocks), 8)
+	for i := len(a.blocks) - 1; i >= 0; i-- {
+		builder.Prep(8, 24)
+		builder.PrependInt64(a.blocks[i].bodyLength)
+		builder.Pad(4)
+		builder.PrependInt32(a.blocks[i].metadataLength)
+		builder.PrependInt64(a.blocks[i].offset)
+	}
+	blocks := builder.EndVector(len(a.blocks))
+	builder.StartObject(5)
+	builder.PrependUOffsetTSlot(3, blocks, 0)
+	builder.PrependUOffsetTSlot(1, schema, 0)
+	builder.PrependInt16Slot(0, arrowMetadataVersionV5, 0)
+	builder.Finish(builder.EndObject())
+
+	footer := builder.FinishedBytes()
+	if err := a.write(footer); err != nil {
+		return err
+	}
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(footer)))
+	if err := a.write(size); err != nil {
+		return err
+	}
+	return a.write(arrowMagic)
+}
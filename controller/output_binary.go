@@ -0,0 +1,605 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/parquet-go/parquet-go"
+	"golang.org/x/term"
+)
+
+// This file defines the encoders of the binary formats (Parquet, Arrow and SQLite)
+//
+// Unlike the text formats, each column of these formats has a type.
+// It's inferred from the declared type of the column in SQLite (e.g. INTEGER, DATETIME),
+// or from the values of the first rows when SQLite doesn't know it (e.g. SELECT count(*)).
+// The rows are therefore buffered in batches of binaryBatchSize rows.
+
+// binaryBatchSize is the number of rows buffered by the binary encoders
+// before they are written (a row group in Parquet, a record batch in Arrow)
+const binaryBatchSize = 64 * 1024
+
+type binaryColumnType int
+
+const (
+	binaryColumnString binaryColumnType = iota
+	binaryColumnInt
+	binaryColumnFloat
+	binaryColumnBool
+	binaryColumnBlob
+	binaryColumnDateTime // Microseconds since the epoch, in UTC
+	binaryColumnDate     // Days since the epoch
+)
+
+// declaredBinaryColumnType maps the declared type of a SQLite column to a binary column type
+//
+// It follows the rules of the type affinity of SQLite (https://www.sqlite.org/datatype3.html)
+// and returns false if the type is empty or unknown
+func declaredBinaryColumnType(declared string) (binaryColumnType, bool) {
+	declared = strings.ToUpper(strings.TrimSpace(declared))
+	switch {
+	case declared == "":
+		return binaryColumnString, false
+	case strings.Contains(declared, "BOOL"):
+		return binaryColumnBool, true
+	case strings.Contains(declared, "DATETIME"), strings.Contains(declared, "TIMESTAMP"):
+		return binaryColumnDateTime, true
+	case declared == "DATE":
+		return binaryColumnDate, true
+	case strings.Contains(declared, "INT"):
+		return binaryColumnInt, true
+	case strings.Contains(declared, "CHAR"), strings.Contains(declared, "CLOB"), strings.Contains(declared, "TEXT"),
+		strings.Contains(declared, "STRING"), strings.Contains(declared, "JSON"), declared == "TIME":
+		return binaryColumnString, true
+	case strings.Contains(declared, "BLOB"), strings.Contains(declared, "BINARY"):
+		return binaryColumnBlob, true
+	case strings.Contains(declared, "REAL"), strings.Contains(declared, "FLOA"), strings.Contains(declared, "DOUB"),
+		strings.Contains(declared, "NUMERIC"), strings.Contains(declared, "DECIMAL"):
+		return binaryColumnFloat, true
+	}
+	return binaryColumnString, false
+}
+
+// valueBinaryColumnType returns the binary column type of a Go value
+func valueBinaryColumnType(value interface{}) binaryColumnType {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint8, uint16, uint32:
+		return binaryColumnInt
+	case float32, float64:
+		return binaryColumnFloat
+	case bool:
+		return binaryColumnBool
+	case []byte:
+		return binaryColumnBlob
+	case time.Time:
+		return binaryColumnDateTime
+	default:
+		return binaryColumnString
+	}
+}
+
+// inferBinaryColumnTypes returns the type of each column
+//
+// The declared type is used if all the values of rows can be converted to it.
+// Otherwise, the type is inferred from the values: an integer column holding a float becomes a float column,
+// and a column mixing other types becomes a string column
+func inferBinaryColumnTypes(columnCount int, declared []string, rows [][]interface{}) []binaryColumnType {
+	types := make([]binaryColumnType, columnCount)
+	for i := range types {
+		if i < len(declared) {
+			if columnType, ok := declaredBinaryColumnType(declared[i]); ok && columnAccepts(columnType, i, rows) {
+				types[i] = columnType
+				continue
+			}
+		}
+
+		inferred, found := binaryColumnString, false
+		for _, row := range rows {
+			if i >= len(row) || row[i] == nil {
+				continue
+			}
+			valueType := valueBinaryColumnType(row[i])
+			switch {
+			case !found:
+				inferred, found = valueType, true
+			case inferred == valueType:
+			case (inferred == binaryColumnInt || inferred == binaryColumnFloat) &&
+				(valueType == binaryColumnInt || valueType == binaryColumnFloat):
+				inferred = binaryColumnFloat
+			default:
+				inferred = binaryColumnString
+			}
+		}
+		types[i] = inferred
+	}
+	return types
+}
+
+// columnAccepts reports whether all the values of the column can be converted to columnType
+func columnAccepts(columnType binaryColumnType, column int, rows [][]interface{}) bool {
+	for _, row := range rows {
+		if column >= len(row) {
+			continue
+		}
+		if _, err := convertBinaryValue(columnType, row[column]); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// convertBinaryValue converts a value to the Go type of the column type
+// (int64, float64, bool, string, []byte or time.Time), or nil for a NULL
+func convertBinaryValue(columnType binaryColumnType, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	// The SQLite driver returns the zero time for a datetime it can't parse
+	if t, ok := value.(time.Time); ok && t.IsZero() {
+		return nil, nil
+	}
+
+	switch columnType {
+	case binaryColumnInt:
+		switch v := value.(type) {
+		case bool:
+			if v {
+				return int64(1), nil
+			}
+			return int64(0), nil
+		case int, int8, int16, int32, int64, uint8, uint16, uint32:
+			return reflect.ValueOf(v).Convert(reflect.TypeOf(int64(0))).Int(), nil
+		}
+
+	case binaryColumnFloat:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case float32:
+			return float64(v), nil
+		case int, int8, int16, int32, int64, uint8, uint16, uint32:
+			return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Float(), nil
+		}
+
+	case binaryColumnBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case int64:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		case int:
+			if v == 0 || v == 1 {
+				return v == 1, nil
+			}
+		}
+
+	case binaryColumnBlob:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+
+	case binaryColumnDateTime, binaryColumnDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v, nil
+		case string:
+			trimmed := strings.TrimSuffix(v, "Z")
+			for _, layout := range append([]string{time.RFC3339Nano}, binaryTimeLayouts...) {
+				if t, err := time.ParseInLocation(layout, trimmed, time.UTC); err == nil {
+					return t, nil
+				}
+			}
+		}
+
+	case binaryColumnString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			if utf8.Valid(v) {
+				return string(v), nil
+			}
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		default:
+			return fmt.Sprint(v), nil
+		}
+	}
+
+	return nil, fmt.Errorf("cannot convert %v (%T) to %s", value, value, columnType)
+}
+
+// binaryTimeLayouts are the layouts of the dates understood by SQLite
+var binaryTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+func (t binaryColumnType) String() string {
+	switch t {
+	case binaryColumnInt:
+		return "INTEGER"
+	case binaryColumnFloat:
+		return "REAL"
+	case binaryColumnBool:
+		return "BOOLEAN"
+	case binaryColumnBlob:
+		return "BLOB"
+	case binaryColumnDateTime:
+		return "DATETIME"
+	case binaryColumnDate:
+		return "DATE"
+	default:
+		return "TEXT"
+	}
+}
+
+// daysSinceEpoch returns the number of days between the epoch and the date of t
+func daysSinceEpoch(t time.Time) int32 {
+	year, month, day := t.Date()
+	return int32(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// uniqueColumnNames renames the duplicated columns (e.g. SELECT a.id, b.id) because the binary formats require unique names
+func uniqueColumnNames(columns []string) []string {
+	names := make([]string, len(columns))
+	seen := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		name := column
+		for suffix := 2; seen[strings.ToLower(name)]; suffix++ {
+			name = fmt.Sprintf("%s_%d", column, suffix)
+		}
+		seen[strings.ToLower(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+// checkBinaryWriter refuses to print a binary format in a terminal
+func checkBinaryWriter(writer io.Writer, format string) error {
+	if file, ok := writer.(*os.File); ok && term.IsTerminal(int(file.Fd())) {
+		return fmt.Errorf("the %s format can't be printed in a terminal. Redirect the output to a file (e.g. > results.%s)", format, format)
+	}
+	return nil
+}
+
+// binaryBatch buffers the rows written to a binary encoder
+type binaryBatch struct {
+	Columns     []string
+	ColumnTypes []string // The declared types of the columns
+
+	// The types of the columns, inferred from the first batch
+	types []binaryColumnType
+	rows  [][]interface{}
+}
+
+// add buffers a row, and reports whether the batch is full
+func (b *binaryBatch) add(row []interface{}) bool {
+	b.rows = append(b.rows, row)
+	return len(b.rows) >= binaryBatchSize
+}
+
+// take returns the buffered rows converted to the types of the columns, and empties the batch
+//
+// The types are inferred on the first call
+func (b *binaryBatch) take() ([][]interface{}, error) {
+	if b.types == nil {
+		b.types = inferBinaryColumnTypes(len(b.Columns), b.ColumnTypes, b.rows)
+	}
+
+	converted := make([][]interface{}, len(b.rows))
+	for i, row := range b.rows {
+		converted[i] = make([]interface{}, len(b.types))
+		for j, columnType := range b.types {
+			if j >= len(row) {
+				continue
+			}
+			value, err := convertBinaryValue(columnType, row[j])
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w (use CAST to change the type of the column)", b.Columns[j], err)
+			}
+			converted[i][j] = value
+		}
+	}
+	b.rows = b.rows[:0]
+	return converted, nil
+}
+
+/*******************
+ * PARQUET ENCODER *
+ *******************/
+
+type parquetTableEncoder struct {
+	binaryBatch
+	Writer io.Writer
+
+	writer *parquet.Writer
+}
+
+// parquetGroup is a group of parquet columns that keeps the order of the columns
+// (parquet.Group sorts them by name)
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g *parquetGroup) Fields() []parquet.Field { return g.fields }
+
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f *parquetField) Name() string { return f.name }
+
+func (f *parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
+
+func (p *parquetTableEncoder) Write(row []interface{}) error {
+	if p.add(row) {
+		return p.flush()
+	}
+	return nil
+}
+
+func (p *parquetTableEncoder) flush() error {
+	rows, err := p.take()
+	if err != nil {
+		return err
+	}
+
+	if p.writer == nil {
+		if err := checkBinaryWriter(p.Writer, "parquet"); err != nil {
+			return err
+		}
+		group := &parquetGroup{Group: parquet.Group{}}
+		for i, name := range uniqueColumnNames(p.Columns) {
+			var node parquet.Node
+			switch p.types[i] {
+			case binaryColumnInt:
+				node = parquet.Int(64)
+			case binaryColumnFloat:
+				node = parquet.Leaf(parquet.DoubleType)
+			case binaryColumnBool:
+				node = parquet.Leaf(parquet.BooleanType)
+			case binaryColumnBlob:
+				node = parquet.Leaf(parquet.ByteArrayType)
+			case binaryColumnDateTime:
+				node = parquet.Timestamp(parquet.Microsecond)
+			case binaryColumnDate:
+				node = parquet.Date()
+			default:
+				node = parquet.String()
+			}
+			node = parquet.Optional(node)
+			group.Group[name] = node
+			group.fields = append(group.fields, &parquetField{Node: node, name: name})
+		}
+		p.writer = parquet.NewWriter(p.Writer, parquet.NewSchema("anyquery", group),
+			parquet.Compression(&parquet.Snappy), parquet.CreatedBy("anyquery", "", ""))
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	parquetRows := make([]parquet.Row, len(rows))
+	for i, row := range rows {
+		parquetRow := make(parquet.Row, len(row))
+		for j, value := range row {
+			var parquetValue parquet.Value
+			switch v := value.(type) {
+			case nil:
+				parquetRow[j] = parquet.NullValue().Level(0, 0, j)
+				continue
+			case int64:
+				parquetValue = parquet.Int64Value(v)
+			case float64:
+				parquetValue = parquet.DoubleValue(v)
+			case bool:
+				parquetValue = parquet.BooleanValue(v)
+			case []byte:
+				parquetValue = parquet.ByteArrayValue(v)
+			case string:
+				parquetValue = parquet.ByteArrayValue([]byte(v))
+			case time.Time:
+				if p.types[j] == binaryColumnDate {
+					parquetValue = parquet.Int32Value(daysSinceEpoch(v))
+				} else {
+					parquetValue = parquet.Int64Value(v.UnixMicro())
+				}
+			}
+			parquetRow[j] = parquetValue.Level(0, 1, j)
+		}
+		parquetRows[i] = parquetRow
+	}
+
+	_, err = p.writer.WriteRows(parquetRows)
+	if err != nil {
+		return err
+	}
+	// Each batch is a row group
+	return p.writer.Flush()
+}
+
+func (p *parquetTableEncoder) Close() error {
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.writer.Close()
+}
+
+/******************
+ * SQLITE ENCODER *
+ ******************/
+
+// sqliteTableEncoder writes the rows in a table of a SQLite database
+//
+// If the output is a file, the table is added to the database of the file (which is created if empty).
+// Otherwise (e.g. stdout redirected to a file), a new database is written to the output
+type sqliteTableEncoder struct {
+	binaryBatch
+	Writer io.Writer
+	// The name of the table to write the rows in. "results" by default
+	Table string
+
+	db   *sql.DB
+	tx   *sql.Tx
+	stmt *sql.Stmt
+	// The path of the temporary database copied to Writer on Close, if any
+	tempPath string
+}
+
+// sqliteOutputPath returns the path of the database file Writer is opened on
+// or an empty string if Writer is not a regular file
+func sqliteOutputPath(writer io.Writer) string {
+	file, ok := writer.(*os.File)
+	if !ok || file == os.Stdout || file == os.Stderr {
+		return ""
+	}
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	return file.Name()
+}
+
+func (s *sqliteTableEncoder) Write(row []interface{}) error {
+	if s.add(row) {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *sqliteTableEncoder) open() error {
+	path := sqliteOutputPath(s.Writer)
+	if path == "" {
+		if err := checkBinaryWriter(s.Writer, "sqlite"); err != nil {
+			return err
+		}
+		temp, err := os.CreateTemp("", "anyquery-*.db")
+		if err != nil {
+			return fmt.Errorf("could not create the temporary database: %w", err)
+		}
+		temp.Close()
+		path = temp.Name()
+		s.tempPath = path
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("could not open the database %s: %w", path, err)
+	}
+	s.db = db
+	s.tx, err = db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return fmt.Errorf("could not start the transaction: %w", err)
+	}
+
+	table := s.Table
+	if table == "" {
+		table = "results"
+	}
+	names := uniqueColumnNames(s.Columns)
+	definitions := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	for i, name := range names {
+		// The declared type is kept if any, so that the table has the same types as the result
+		declared := s.types[i].String()
+		if i < len(s.ColumnTypes) && s.ColumnTypes[i] != "" {
+			declared = s.ColumnTypes[i]
+		}
+		definitions[i] = quoteIdentifier(name) + " " + declared
+		names[i] = quoteIdentifier(name)
+		placeholders[i] = "?"
+	}
+
+	_, err = s.tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS main.%s (%s)", quoteIdentifier(table), strings.Join(definitions, ", ")))
+	if err != nil {
+		return fmt.Errorf("could not create the table %s: %w", table, err)
+	}
+	s.stmt, err = s.tx.Prepare(fmt.Sprintf("INSERT INTO main.%s (%s) VALUES (%s)",
+		quoteIdentifier(table), strings.Join(names, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return fmt.Errorf("could not prepare the insertion in %s: %w", table, err)
+	}
+	return nil
+}
+
+func (s *sqliteTableEncoder) flush() error {
+	rows, err := s.take()
+	if err != nil {
+		return err
+	}
+	if s.db == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		for i, value := range row {
+			// SQLite stores the dates as text
+			if t, ok := value.(time.Time); ok {
+				if s.types[i] == binaryColumnDate {
+					row[i] = t.Format(time.DateOnly)
+				} else {
+					row[i] = t.UTC().Format("2006-01-02 15:04:05.999999999")
+				}
+			}
+		}
+		if _, err := s.stmt.Exec(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteTableEncoder) Close() error {
+	err := s.flush()
+	if s.db == nil {
+		return err
+	}
+	if err == nil {
+		s.stmt.Close()
+		err = s.tx.Commit()
+	} else {
+		s.tx.Rollback()
+	}
+	err = errors.Join(err, s.db.Close())
+
+	if s.tempPath != "" {
+		defer os.Remove(s.tempPath)
+		if err == nil {
+			var content []byte
+			content, err = os.ReadFile(s.tempPath)
+			if err == nil {
+				_, err = io.Copy(s.Writer, bytes.NewReader(content))
+			}
+		}
+	}
+	return err
+}
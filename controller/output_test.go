@@ -2,9 +2,16 @@ package controller
 
 import (
 	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	flatbuffers "github.com/google/flatbuffers/go"
 	"github.com/julien040/anyquery/namespace"
+	"github.com/parquet-go/parquet-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -133,3 +140,132 @@ func TestOutputSQL(t *testing.T) {
 	output.Close()
 
 }
+
+func TestOutputBinary(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err, "The database should open")
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER, name TEXT, weight REAL, alive BOOLEAN, born DATE, seen DATETIME, avatar BLOB);
+	INSERT INTO test VALUES (1, 'John', 80.5, 1, '1999-01-02', '2024-05-06 07:08:09', x'0102'), (2, NULL, NULL, 0, NULL, NULL, NULL);`)
+	require.NoError(t, err, "The table should be created without errors")
+
+	// The columns without declared types are typed from their values
+	query := "SELECT *, count(*) OVER () AS total, 'x' AS name FROM test"
+	writeQuery := func(outputType outputTableType, writer *bytes.Buffer) {
+		rows, err := db.Query(query)
+		require.NoError(t, err, "The query should be executed without errors")
+		output := outputTable{Writer: writer, Type: outputType}
+		require.NoError(t, output.WriteSQLRows(rows), "The output should be written without errors")
+		require.NoError(t, output.Close(), "The output should be closed without errors")
+	}
+	columns := []string{"id", "name", "weight", "alive", "born", "seen", "avatar", "total", "name_2"}
+
+	t.Run("Parquet", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		writeQuery(outputTableTypeParquet, &buf)
+
+		file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err, "The parquet file should be valid")
+		require.Equal(t, int64(2), file.NumRows())
+
+		fields := file.Schema().Fields()
+		names := make([]string, len(fields))
+		for i, field := range fields {
+			names[i] = field.Name()
+		}
+		require.Equal(t, columns, names, "The columns should keep their order")
+		require.Equal(t, parquet.Int64Type.Kind(), fields[0].Type().Kind())
+		require.Equal(t, parquet.DoubleType.Kind(), fields[2].Type().Kind())
+		require.Equal(t, parquet.BooleanType.Kind(), fields[3].Type().Kind())
+		require.Equal(t, "DATE", fields[4].Type().String())
+		require.Equal(t, "TIMESTAMP(isAdjustedToUTC=true,unit=MICROS)", fields[5].Type().String())
+
+		rows := make([]parquet.Row, 2)
+		n, _ := file.RowGroups()[0].Rows().ReadRows(rows)
+		require.Equal(t, 2, n)
+		require.Equal(t, "John", rows[0][1].String())
+		require.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC).UnixMicro(), rows[0][5].Int64())
+		require.Equal(t, []byte{1, 2}, rows[0][6].ByteArray())
+		require.True(t, rows[1][1].IsNull(), "NULL should be kept")
+		require.Equal(t, int64(2), rows[1][7].Int64())
+	})
+
+	t.Run("Arrow", func(t *testing.T) {
+		buf := bytes.Buffer{}
+		writeQuery(outputTableTypeArrow, &buf)
+		content := buf.Bytes()
+		require.Equal(t, "ARROW1", string(content[:6]))
+		require.Equal(t, "ARROW1", string(content[len(content)-6:]))
+
+		// Read the schema and the record batches from the footer
+		footerSize := int(binary.LittleEndian.Uint32(content[len(content)-10:]))
+		footerBytes := content[len(content)-10-footerSize : len(content)-10]
+		footer := &flatbuffers.Table{Bytes: footerBytes, Pos: flatbuffers.GetUOffsetT(footerBytes)}
+		schema := &flatbuffers.Table{Bytes: footerBytes}
+		schema.Pos = footer.Indirect(footer.Pos + flatbuffers.UOffsetT(footer.Offset(6)))
+		fieldsVector := schema.Vector(flatbuffers.UOffsetT(schema.Offset(6)))
+		names := []string{}
+		types := []byte{}
+		for i := 0; i < schema.VectorLen(flatbuffers.UOffsetT(schema.Offset(6))); i++ {
+			field := &flatbuffers.Table{Bytes: footerBytes}
+			field.Pos = field.Indirect(fieldsVector + flatbuffers.UOffsetT(4*i))
+			names = append(names, string(field.ByteVector(flatbuffers.UOffsetT(field.Offset(4))+field.Pos)))
+			types = append(types, field.GetByte(field.Pos+flatbuffers.UOffsetT(field.Offset(8))))
+		}
+		require.Equal(t, columns, names)
+		require.Equal(t, []byte{arrowTypeInt, arrowTypeUtf8, arrowTypeFloatingPoint, arrowTypeBool, arrowTypeDate,
+			arrowTypeTimestamp, arrowTypeBinary, arrowTypeInt, arrowTypeUtf8}, types)
+		require.Equal(t, 1, footer.VectorLen(flatbuffers.UOffsetT(footer.Offset(10))), "The file should have one record batch")
+
+		buf.Reset()
+		writeQuery(outputTableTypeArrowStream, &buf)
+		content = buf.Bytes()
+		require.Equal(t, uint32(arrowContinuationMark), binary.LittleEndian.Uint32(content))
+		require.Equal(t, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}, content[len(content)-8:], "The stream should end with the end of stream marker")
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "export.db")
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		defer file.Close()
+
+		for _, table := range []string{"first", "second"} {
+			rows, err := db.Query(query)
+			require.NoError(t, err)
+			output := outputTable{Writer: file, Type: outputTableTypeSqlite, TableName: table}
+			require.NoError(t, output.WriteSQLRows(rows))
+			require.NoError(t, output.Close())
+		}
+
+		exported, err := sql.Open("sqlite3", path)
+		require.NoError(t, err)
+		defer exported.Close()
+		var count int
+		require.NoError(t, exported.QueryRow("SELECT count(*) FROM sqlite_schema WHERE name IN ('first', 'second')").Scan(&count))
+		require.Equal(t, 2, count, "Each result should be written in its table")
+		var name string
+		var total int64
+		var seen time.Time
+		require.NoError(t, exported.QueryRow("SELECT name, total, seen FROM second WHERE id = 1").Scan(&name, &total, &seen))
+		require.Equal(t, "John", name)
+		require.Equal(t, int64(2), total)
+		require.Equal(t, time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC), seen)
+
+		// Without a file, the database is written to the output
+		buf := bytes.Buffer{}
+		writeQuery(outputTableTypeSqlite, &buf)
+		require.Equal(t, "SQLite format 3\x00", buf.String()[:16])
+	})
+
+	t.Run("Reject values that don't match the type of the column", func(t *testing.T) {
+		output := newOutputTable([]string{"value"}, outputTableTypeParquet, &bytes.Buffer{})
+		output.ColumnTypes = []string{"INTEGER"}
+		for i := 0; i < binaryBatchSize; i++ {
+			require.NoError(t, output.Write([]interface{}{int64(i)}))
+		}
+		require.NoError(t, output.Write([]interface{}{"not a number"}))
+		require.Error(t, output.Close(), "The column was typed by the first batch")
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/julien040/anyquery/controller/config/model"
 	"github.com/julien040/anyquery/controller/config/registry"
+	"github.com/julien040/anyquery/rpc"
 	"github.com/spf13/cobra"
 )
 
@@ -192,6 +193,36 @@ func PluginInstall(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("the plugin is already installed")
 	}
 
+	// Show the capabilities of the plugin and ask the user to confirm them
+	_, _, version, err := registry.FindPluginCandidate(queries, registryName, pluginName)
+	if err != nil {
+		return fmt.Errorf("could not find the plugin: %w", err)
+	}
+	fmt.Println("The plugin", pluginName, "declares the following capabilities:")
+	for _, line := range version.Capabilities.Describe() {
+		fmt.Println("	- " + line)
+	}
+	fmt.Println(capabilitiesLimitation)
+	skipConfirmation, _ := cmd.Flags().GetBool("yes")
+	if !skipConfirmation {
+		// Without a terminal, the capabilities can't be confirmed
+		// and must be accepted explicitly with --yes
+		if !isSTDinAtty() || !isSTDoutAtty() {
+			return fmt.Errorf("the capabilities of the plugin must be confirmed, run the install again with --yes to accept them")
+		}
+		confirmed := false
+		err = huh.NewConfirm().Title("Do you want to install " + pluginName + "?").Value(&confirmed).Run()
+		if err != nil {
+			if err.Error() == "user aborted" {
+				return nil
+			}
+			return fmt.Errorf("could not run the form: %w", err)
+		}
+		if !confirmed {
+			return nil
+		}
+	}
+
 	// Get the plugin
 	s.Prefix = "Installing plugin " + pluginName + " "
 	if isSTDoutAtty() {
@@ -332,7 +363,53 @@ func PluginUninstall(cmd *cobra.Command, args []string) error {
 
 }
 
-func updateOnePlugin(queries *model.Queries, registryName string, plugin string) error {
+// capabilitiesLimitation is shown with the capabilities of a plugin
+// so that the user doesn't mistake them for a sandbox
+const capabilitiesLimitation = "Capabilities are not a sandbox: apart from a plugin without network access on Linux, " +
+	"a plugin can reach any host, read and write your files, and run programs. Only install plugins you trust."
+
+// confirmCapabilityChange shows the capabilities of the new version of a plugin
+// if they differ from the installed ones, and asks the user to confirm them.
+//
+// It reports whether the plugin can be updated. Without a terminal to ask the user,
+// the update is refused unless skipConfirmation is set
+func confirmCapabilityChange(plugin string, installed string, capabilities *rpc.PluginCapabilities, skipConfirmation bool) (bool, error) {
+	current, err := rpc.ParsePluginCapabilities(installed)
+	if err != nil {
+		return false, err
+	}
+	if current.Equal(capabilities) {
+		return true, nil
+	}
+
+	fmt.Println("The new version of the plugin", plugin, "changes its capabilities from:")
+	for _, line := range current.Describe() {
+		fmt.Println("	- " + line)
+	}
+	fmt.Println("to:")
+	for _, line := range capabilities.Describe() {
+		fmt.Println("	- " + line)
+	}
+	fmt.Println(capabilitiesLimitation)
+	if skipConfirmation {
+		return true, nil
+	}
+	if !isSTDinAtty() || !isSTDoutAtty() {
+		fmt.Println("Run the update again with --yes to accept them")
+		return false, nil
+	}
+	confirmed := false
+	err = huh.NewConfirm().Title("Do you want to update " + plugin + "?").Value(&confirmed).Run()
+	if err != nil {
+		if err.Error() == "user aborted" {
+			return false, nil
+		}
+		return false, fmt.Errorf("could not run the form: %w", err)
+	}
+	return confirmed, nil
+}
+
+func updateOnePlugin(queries *model.Queries, registryName string, plugin string, skipConfirmation bool) error {
 	// Get the plugin
 	pluginInfo, err := queries.GetPlugin(context.Background(), model.GetPluginParams{
 		Name:     plugin,
@@ -373,6 +450,16 @@ func updateOnePlugin(queries *model.Queries, registryName string, plugin string)
 		return nil
 	}
 
+	// The new version must not gain capabilities without the consent of the user
+	confirmed, err := confirmCapabilityChange(plugin, pluginInfo.Capabilities, version.Capabilities, skipConfirmation)
+	if err != nil {
+		return fmt.Errorf("could not check the capabilities of the plugin: %w", err)
+	}
+	if !confirmed {
+		fmt.Println("Plugin", plugin, "was not updated")
+		return nil
+	}
+
 	// Otherwise, update the plugin
 	s := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
 	s.Prefix = "Updating plugin " + plugin
@@ -411,14 +498,16 @@ func PluginUpdate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("could not update the registries: %w", err)
 	}
 
+	skipConfirmation, _ := cmd.Flags().GetBool("yes")
+
 	// If a registry and a plugin are specified, we update the plugin
 	if len(args) == 2 {
-		return updateOnePlugin(queries, args[0], args[1])
+		return updateOnePlugin(queries, args[0], args[1], skipConfirmation)
 	}
 
 	// If only a plugin is specified, we update the plugin from the default registry
 	if len(args) == 1 {
-		return updateOnePlugin(queries, "default", args[0])
+		return updateOnePlugin(queries, "default", args[0], skipConfirmation)
 	}
 
 	// Otherwise, we update all the plugins
@@ -428,7 +517,7 @@ func PluginUpdate(cmd *cobra.Command, args []string) error {
 	}
 
 	for _, plugin := range plugins {
-		err = updateOnePlugin(queries, plugin.Registry, plugin.Name)
+		err = updateOnePlugin(queries, plugin.Registry, plugin.Name, skipConfirmation)
 		if err != nil {
 			fmt.Println("Could not update the plugin", plugin.Name, "from the registry", plugin.Registry)
 			fmt.Println(err)
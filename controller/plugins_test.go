@@ -0,0 +1,30 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/julien040/anyquery/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmCapabilityChange(t *testing.T) {
+	installed := &rpc.PluginCapabilities{NetworkHosts: []string{"api.github.com"}}
+	wider := &rpc.PluginCapabilities{NetworkHosts: []string{"api.github.com", "*.example.com"}, Exec: true}
+
+	confirmed, err := confirmCapabilityChange("github", installed.String(), installed, false)
+	require.NoError(t, err)
+	require.True(t, confirmed, "an update keeping the capabilities must not be confirmed")
+
+	// The tests don't run in a terminal
+	confirmed, err = confirmCapabilityChange("github", installed.String(), wider, false)
+	require.NoError(t, err)
+	require.False(t, confirmed, "new capabilities must be refused without a terminal to confirm them")
+
+	confirmed, err = confirmCapabilityChange("github", "", wider, false)
+	require.NoError(t, err)
+	require.False(t, confirmed, "capabilities declared by an update must be confirmed")
+
+	confirmed, err = confirmCapabilityChange("github", installed.String(), wider, true)
+	require.NoError(t, err)
+	require.True(t, confirmed, "--yes must accept the new capabilities")
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/tree"
+	"github.com/julien040/anyquery/module"
+	"github.com/julien040/go-ternary"
+)
+
+// writeProfile prints the metrics of the cursors of a query once its output is written
+//
+// The JSON formats get a JSON object: a trailer of the JSON lines output, and a separate value
+// for json and json-pretty, that the shell writes to stderr so that the output stays valid JSON.
+// The other formats get a tree with a node per cursor
+func writeProfile(profile *module.Profile, outputType outputTableType, output io.Writer) {
+	cursors := profile.Cursors()
+	switch outputType {
+	case outputTableTypeJson, outputTableTypeJsonPretty, outputTableTypeJsonLines:
+		trailer := struct {
+			Profile []module.CursorProfile `json:"profile"`
+		}{cursors}
+		encoder := json.NewEncoder(output)
+		if outputType == outputTableTypeJsonPretty {
+			encoder.SetIndent("", "  ")
+		}
+		encoder.Encode(trailer)
+	default:
+		io.WriteString(output, profileTree(cursors, lipgloss.NewRenderer(output)))
+		fmt.Fprintln(output)
+	}
+}
+
+// profileTree renders the metrics of the cursors as a tree
+func profileTree(cursors []module.CursorProfile, renderer *lipgloss.Renderer) string {
+	titleStyle := renderer.NewStyle().Bold(true)
+	tableStyle := renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#6f42c1"))
+
+	var total time.Duration
+	for _, cursor := range cursors {
+		total += cursor.Duration
+	}
+	root := tree.Root(titleStyle.Render(fmt.Sprintf("Query profile (%d %s, %s in the virtual tables)",
+		len(cursors), ternary.If(len(cursors) == 1, "cursor", "cursors"), formatDuration(total))))
+	if len(cursors) == 0 {
+		root.Child("No virtual table was scanned")
+	}
+
+	for _, cursor := range cursors {
+		// The table functions (e.g. read_csv) are run on tables with a random name,
+		// so the module comes first
+		name := cursor.Table
+		if cursor.Module != "" && cursor.Module != cursor.Table {
+			name = fmt.Sprintf("%s (table %s)", cursor.Module, cursor.Table)
+		}
+		root.Child(tree.Root(tableStyle.Render(name)).Child(
+			fmt.Sprintf("time: %s", formatDuration(cursor.Duration)),
+			fmt.Sprintf("filter calls: %d", cursor.FilterCalls),
+			fmt.Sprintf("round-trips: %d", cursor.RoundTrips),
+			fmt.Sprintf("rows fetched: %d, emitted: %d", cursor.RowsFetched, cursor.RowsEmitted),
+			fmt.Sprintf("bytes transferred: %s", formatBytes(cursor.Bytes)),
+		))
+	}
+
+	return root.String()
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
+
+// formatBytes returns a human-readable size (e.g. 1.2 MB)
+func formatBytes(bytes int64) string {
+	const unit = 1000
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "kMGTPE"[exp])
+}
@@ -7,10 +7,12 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/julien040/anyquery/controller/config/model"
 	"github.com/julien040/anyquery/controller/config/registry"
+	"github.com/julien040/anyquery/rpc"
 	"github.com/spf13/cobra"
 )
 
@@ -930,3 +932,187 @@ func ProfileDelete(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func ProfileLimits(cmd *cobra.Command, args []string) error {
+	// Open the database on read-write mode
+	db, querier, err := requestDatabase(cmd.Flags(), false)
+	if err != nil {
+		return fmt.Errorf("could not open the database: %w", err)
+	}
+	defer db.Close()
+
+	var registry = "default"
+	var plugin = ""
+	var profile = ""
+
+	if len(args) == 3 {
+		registry = args[0]
+		plugin = args[1]
+		profile = args[2]
+	} else if len(args) == 2 {
+		plugin = args[0]
+		profile = args[1]
+	} else {
+		return fmt.Errorf("the plugin and the profile must be specified")
+	}
+
+	ctx := context.Background()
+	row, err := querier.GetProfile(ctx, model.GetProfileParams{
+		Registry:   registry,
+		Pluginname: plugin,
+		Name:       profile,
+	})
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return fmt.Errorf("a profile with the name %s for the plugin %s does not exist", profile, plugin)
+		}
+		return fmt.Errorf("could not get the profile: %w", err)
+	}
+
+	limits, err := rpc.ParseResourceLimits(row.Limits)
+	if err != nil {
+		return err
+	}
+
+	// Without any flag, we print the current limits
+	flags := cmd.Flags()
+	if !flags.Changed("max-memory") && !flags.Changed("max-cpu-time") && !flags.Changed("max-open-files") &&
+		!flags.Changed("call-timeout") && !flags.Changed("isolate") && !flags.Changed("isolate-network") {
+		output := outputTable{
+			Writer:  os.Stdout,
+			Columns: []string{"Limit", "Value"},
+		}
+		output.InferFlags(flags)
+		values := [][]interface{}{
+			{"max-memory (MiB)", limits.MaxMemoryMB},
+			{"max-cpu-time (s)", limits.MaxCPUSeconds},
+			{"max-open-files", limits.MaxOpenFiles},
+			{"call-timeout (s)", limits.CallTimeoutSeconds},
+			{"isolate", limits.Isolate},
+			{"isolate-network", limits.IsolateNetwork},
+		}
+		for _, value := range values {
+			err = output.Write(value)
+			if err != nil {
+				return fmt.Errorf("could not write the limits to the output: %w", err)
+			}
+		}
+		return output.Close()
+	}
+
+	if flags.Changed("max-memory") {
+		limits.MaxMemoryMB, _ = flags.GetInt64("max-memory")
+	}
+	if flags.Changed("max-cpu-time") {
+		limits.MaxCPUSeconds, _ = flags.GetInt64("max-cpu-time")
+	}
+	if flags.Changed("max-open-files") {
+		limits.MaxOpenFiles, _ = flags.GetInt64("max-open-files")
+	}
+	if flags.Changed("call-timeout") {
+		limits.CallTimeoutSeconds, _ = flags.GetInt64("call-timeout")
+	}
+	if flags.Changed("isolate") {
+		limits.Isolate, _ = flags.GetBool("isolate")
+	}
+	if flags.Changed("isolate-network") {
+		limits.IsolateNetwork, _ = flags.GetBool("isolate-network")
+	}
+	err = limits.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = querier.UpdateProfileLimits(ctx, model.UpdateProfileLimitsParams{
+		Limits:     limits.String(),
+		Name:       profile,
+		Pluginname: plugin,
+		Registry:   registry,
+	})
+	if err != nil {
+		return fmt.Errorf("could not update the limits of the profile: %w", err)
+	}
+
+	fmt.Println("✅ Successfully updated the limits of the profile", profile, "for the plugin", plugin)
+
+	return nil
+}
+
+func ProfileCache(cmd *cobra.Command, args []string) error {
+	// Open the database on read-write mode
+	db, querier, err := requestDatabase(cmd.Flags(), false)
+	if err != nil {
+		return fmt.Errorf("could not open the database: %w", err)
+	}
+	defer db.Close()
+
+	var registry = "default"
+	var plugin = ""
+	var profile = ""
+
+	if len(args) == 3 {
+		registry = args[0]
+		plugin = args[1]
+		profile = args[2]
+	} else if len(args) == 2 {
+		plugin = args[0]
+		profile = args[1]
+	} else {
+		return fmt.Errorf("the plugin and the profile must be specified")
+	}
+
+	ctx := context.Background()
+	row, err := querier.GetProfile(ctx, model.GetProfileParams{
+		Registry:   registry,
+		Pluginname: plugin,
+		Name:       profile,
+	})
+	if err != nil {
+		if err.Error() == "sql: no rows in result set" {
+			return fmt.Errorf("a profile with the name %s for the plugin %s does not exist", profile, plugin)
+		}
+		return fmt.Errorf("could not get the profile: %w", err)
+	}
+
+	// Without any flag, we print the current duration
+	flags := cmd.Flags()
+	if !flags.Changed("ttl") && !flags.Changed("disable") {
+		switch {
+		case row.Cachettl < 0:
+			fmt.Println("The rows of the profile are not cached")
+		case row.Cachettl == 0:
+			fmt.Println("The rows of the profile are cached for the duration set by the plugin (if any)")
+		default:
+			fmt.Println("The rows of the profile are cached for", time.Duration(row.Cachettl)*time.Second)
+		}
+		return nil
+	}
+
+	var ttl int64
+	if disable, _ := flags.GetBool("disable"); disable {
+		ttl = -1
+	} else {
+		duration, _ := flags.GetDuration("ttl")
+		if duration < 0 {
+			return fmt.Errorf("the duration of the cache cannot be negative. Use --disable to disable the cache")
+		}
+		if duration > 0 && duration < time.Second {
+			return fmt.Errorf("the duration of the cache must be at least one second")
+		}
+		ttl = int64(duration / time.Second)
+	}
+
+	err = querier.UpdateProfileCacheTTL(ctx, model.UpdateProfileCacheTTLParams{
+		Cachettl:   ttl,
+		Name:       profile,
+		Pluginname: plugin,
+		Registry:   registry,
+	})
+	if err != nil {
+		return fmt.Errorf("could not update the cache of the profile: %w", err)
+	}
+
+	fmt.Println("✅ Successfully updated the cache of the profile", profile, "for the plugin", plugin)
+
+	return nil
+}
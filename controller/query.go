@@ -57,6 +57,13 @@ func Query(cmd *cobra.Command, args []string) error {
 
 	devMode, _ = cmd.Flags().GetBool("dev")
 
+	// Export the spans of the queries if an OTLP endpoint is set
+	shutdownTracing, err := setupTracing(cmd)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer shutdownTracing()
+
 	// Create the logger
 	var outputLog io.Writer
 	logFile, _ := cmd.Flags().GetString("log-file")
@@ -131,15 +138,6 @@ func Query(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	// Listen for signals
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-signals
-		db.Close()
-		os.Exit(0)
-	}()
-
 	// Create the shell
 	shell := shell{
 		DB: db,
@@ -147,6 +145,7 @@ func Query(cmd *cobra.Command, args []string) error {
 			middlewareSlashCommand, middlewareDotCommand,
 			middlewarePRQL, middlewarePQL,
 			middlewareMySQL, middlewareFileQuery,
+			newMiddlewareAggregatePushdown(namespace),
 			middlewareQuery,
 		},
 		Config: middlewareConfiguration{
@@ -167,6 +166,35 @@ func Query(cmd *cobra.Command, args []string) error {
 		OutputFileDesc: os.Stdout,
 	}
 
+	shell.QueryTimeout, _ = cmd.Flags().GetDuration("query-timeout")
+
+	// The .materialize dot command records the views of the database in the config
+	shell.Config.SetString("configPath", anyqueryConfigPath)
+	if !inMemory {
+		shell.Config.SetString("databasePath", path)
+	}
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		shell.Config.SetBool("dryRun", true)
+	}
+	if profile, _ := cmd.Flags().GetBool("profile"); profile {
+		shell.Config.SetBool("profile", true)
+	}
+
+	// Listen for signals
+	//
+	// An interrupt cancels the running query if any. Otherwise, it exits the program
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig == os.Interrupt && shell.CancelQuery() {
+				continue
+			}
+			db.Close()
+			os.Exit(0)
+		}
+	}()
+
 	// Check if an alternative language is provided
 	language, _ := cmd.Flags().GetString("language")
 	if language == "prql" || language == "pql" {
@@ -195,6 +223,11 @@ func Query(cmd *cobra.Command, args []string) error {
 		}
 		shell.OutputFile = outputFile
 		shell.OutputFileDesc = file
+		// Otherwise, the shell falls back to stdout before running the queries
+		shell.Config["outputFile"] = outputFile
+	}
+	if outputTable, _ := cmd.Flags().GetString("output-table"); outputTable != "" {
+		shell.Config["outputTable"] = outputTable
 	}
 
 	// Check if the output file is a tty
@@ -517,6 +517,7 @@ func Run(cmd *cobra.Command, args []string) error {
 			middlewareSlashCommand, middlewareDotCommand,
 			middlewarePRQL, middlewarePQL,
 			middlewareMySQL, middlewareFileQuery,
+			newMiddlewareAggregatePushdown(namespace),
 			middlewareQuery,
 		},
 		Config: runShellConfig(),
@@ -46,11 +46,13 @@ func RestrictionsFromFlags(cmd *cobra.Command) *module.Restrictions {
 	allowRemote, _ := cmd.Flags().GetBool("allow-remote")
 	allowAttach, _ := cmd.Flags().GetBool("allow-attach")
 	allowDB, _ := cmd.Flags().GetBool("allow-db-connections")
+	allowWrite, _ := cmd.Flags().GetBool("allow-write")
 
 	return &module.Restrictions{
 		AllowedDirs:        allowDirs,
 		AllowRemote:        allowRemote,
 		AllowAttach:        allowAttach,
 		AllowDBConnections: allowDB,
+		AllowWrite:         allowWrite,
 	}
 }
@@ -11,6 +11,7 @@ func addTestSandboxFlags(c *cobra.Command, isServer bool) {
 	c.Flags().Bool("allow-remote", false, "")
 	c.Flags().Bool("allow-attach", false, "")
 	c.Flags().Bool("allow-db-connections", false, "")
+	c.Flags().Bool("allow-write", false, "")
 	if isServer {
 		c.Flags().Bool("no-sandbox", false, "")
 	} else {
@@ -60,7 +61,7 @@ func TestRestrictionsFromFlags(t *testing.T) {
 		if !r.AllowRemote {
 			t.Error("allow-remote not propagated")
 		}
-		if r.AllowAttach || r.AllowDBConnections {
+		if r.AllowAttach || r.AllowDBConnections || r.AllowWrite {
 			t.Error("unset relax flags should remain false")
 		}
 	})
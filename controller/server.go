@@ -1,7 +1,10 @@
 package controller
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
@@ -11,6 +14,7 @@ import (
 	"github.com/adrg/xdg"
 	"github.com/charmbracelet/log"
 	"github.com/hashicorp/go-hclog"
+	"github.com/julien040/anyquery/controller/config"
 	"github.com/julien040/anyquery/module"
 	"github.com/julien040/anyquery/namespace"
 	"github.com/spf13/cobra"
@@ -113,6 +117,13 @@ func Server(cmd *cobra.Command, args []string) error {
 
 	authfile, _ = cmd.Flags().GetString("auth-file")
 
+	// Export the spans of the queries if an OTLP endpoint is set
+	shutdownTracing, err := setupTracing(cmd)
+	if err != nil {
+		return fmt.Errorf("could not set up tracing: %w", err)
+	}
+	defer shutdownTracing()
+
 	// Set the logger for the plugin
 	loPlugin := lo.WithPrefix("plugin")
 
@@ -176,14 +187,44 @@ func Server(cmd *cobra.Command, args []string) error {
 	}
 	// defer db.Close()
 
+	// Refresh the materialized views of the database on their schedule
+	if !inMemory && !readOnly && path != ":memory:" {
+		databasePath, err := localDatabasePath(path)
+		if err != nil {
+			return err
+		}
+		configDB, queries, err := config.OpenDatabaseConnection(anyqueryConfigPath, false)
+		if err != nil {
+			return fmt.Errorf("could not open the config database: %w", err)
+		}
+		defer configDB.Close()
+
+		ctx, stopSchedule := context.WithCancel(context.Background())
+		defer stopSchedule()
+		go scheduleMaterializedViews(ctx, db, queries, databasePath, lo)
+	}
+
 	// We create the server
 	mySQLServer := namespace.MySQLServer{
 		Logger:                 lo,
 		DB:                     db,
+		Namespace:              instance,
 		MustCatchMySQLSpecific: true,
 		Address:                fmt.Sprintf("%s:%d", host, port),
 		AuthFile:               authfile,
 	}
+	mySQLServer.QueryTimeout, _ = cmd.Flags().GetDuration("query-timeout")
+
+	// Serve the Prometheus metrics if an address is set
+	if metricsAddress, _ := cmd.Flags().GetString("metrics-address"); metricsAddress != "" {
+		mySQLServer.Metrics = namespace.NewServerMetrics(instance)
+		metricsServer, err := serveMetrics(metricsAddress, mySQLServer.Metrics)
+		if err != nil {
+			return fmt.Errorf("could not serve the metrics: %w", err)
+		}
+		defer metricsServer.Close()
+		lo.Info("Serving the metrics", "address", "http://"+metricsServer.Addr+"/metrics")
+	}
 
 	dsn := ""
 	if authfile != "" {
@@ -232,3 +273,16 @@ func Server(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// serveMetrics serves the metrics of the server on /metrics until the returned server is closed
+func serveMetrics(address string, metrics *namespace.ServerMetrics) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	return server, nil
+}
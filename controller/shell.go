@@ -8,17 +8,22 @@
 package controller
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/elk-language/go-prompt"
 	"github.com/julien040/anyquery/module"
+	"github.com/julien040/anyquery/rpc"
+	"go.opentelemetry.io/otel/attribute"
 	"golang.org/x/term"
 )
 
@@ -54,6 +59,51 @@ type QueryData struct {
 	// The configuration that will be passed to the middlewares
 	// This is a reference to the configuration of the pipeline
 	Config middlewareConfiguration
+
+	// The context of the query
+	//
+	// If set, middlewareQuery runs the query on a dedicated connection
+	// bound to this context so that the query can be cancelled
+	Context context.Context
+
+	// The metrics of the cursors opened by the query if the profiler is enabled
+	// (see the .profile dot command). They are complete once Result is closed
+	Profile *module.Profile
+
+	// The sandbox policy of the shell, applied to the files written by COPY.
+	// A nil value means unrestricted
+	Restrictions *module.Restrictions
+
+	// The COPY statement the query was extracted from, if any (see copy.go)
+	copyTo *copyStatement
+
+	// The dedicated connection the query runs on when Context is set,
+	// and the function releasing the context and the profile bound to it.
+	// The shell closes them once the post exec queries are run
+	conn    *sql.Conn
+	release func()
+}
+
+// queryRunner runs queries on a *sql.DB or a *sql.Conn
+type queryRunner interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// queryErrorMessage returns the message to display for an error of a query run with ctx
+//
+// SQLite returns "interrupted" when the context of a query is done,
+// so we tell the user why it was interrupted
+func queryErrorMessage(ctx context.Context, err error) string {
+	if ctx != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			return "the query timed out"
+		case errors.Is(ctx.Err(), context.Canceled):
+			return "the query was cancelled"
+		}
+	}
+	return err.Error()
 }
 
 // Known configuration keys
@@ -65,6 +115,7 @@ type QueryData struct {
 //   - maxRows: int => the maximum number of rows to return
 //   - onceOutputFile: string => the file descriptor to output the result (if empty, fallback to fileOutput)
 //   - outputFile: string => the file to output the result
+//   - outputTable: string => the table the rows are written in by the sqlite output mode
 //   - separatorColumn: string => the separator to use for columns
 //   - separatorRow: string => the separator to use for rows
 type middlewareConfiguration map[string]interface{}
@@ -125,9 +176,9 @@ type shell struct {
 	// The configuration that will be passed to the middlewares
 	Config middlewareConfiguration
 
-	// The sandbox policy to apply to file-touching operations that bypass
-	// the middleware pipeline (currently only `.read`, see the handling in
-	// Run). A nil value means unrestricted, matching every other consumer
+	// The sandbox policy to apply to file-touching operations: `.read`, which
+	// bypasses the middleware pipeline (see the handling in Run), and the
+	// files written by COPY (passed to the middlewares in QueryData). A nil value means unrestricted, matching every other consumer
 	// of *module.Restrictions (see module/restrictions.go), so callers that
 	// never set this field (e.g. the interactive REPL) are unaffected.
 	Restrictions *module.Restrictions
@@ -135,15 +186,61 @@ type shell struct {
 	// Where to output the result
 	OutputFile     string
 	OutputFileDesc io.Writer
+	// Where to write the profile of a query when the result is a single JSON value (json and json-pretty),
+	// so that the output stays valid JSON. os.Stderr if nil
+	ProfileFileDesc io.Writer
 
 	// The history of the shell
 	History []string
+
+	// The maximum duration of a query. If zero, the queries have no time limit
+	QueryTimeout time.Duration
+
+	// The function cancelling the running query if any
+	cancelQuery context.CancelFunc
+	cancelMu    sync.Mutex
 }
 
 func (p *shell) AddMiddleware(m middleware) {
 	p.Middlewares = append(p.Middlewares, m)
 }
 
+// CancelQuery cancels the running query
+// and returns whether a query was running
+func (p *shell) CancelQuery() bool {
+	p.cancelMu.Lock()
+	defer p.cancelMu.Unlock()
+	if p.cancelQuery == nil {
+		return false
+	}
+	p.cancelQuery()
+	p.cancelQuery = nil
+	return true
+}
+
+// startQuery returns the context of a new query, honouring QueryTimeout,
+// and the function to call once the query is done
+func (p *shell) startQuery() (context.Context, func()) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if p.QueryTimeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), p.QueryTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	p.cancelMu.Lock()
+	p.cancelQuery = cancel
+	p.cancelMu.Unlock()
+
+	return ctx, func() {
+		p.cancelMu.Lock()
+		p.cancelQuery = nil
+		p.cancelMu.Unlock()
+		cancel()
+	}
+}
+
 // Run runs the pipeline on the query,
 // prints the result to the output file or stdout,
 // and returns a boolean indicating if the shell must exit
@@ -164,10 +261,11 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 
 	for i, query := range queries {
 		queryData := QueryData{
-			SQLQuery: query,
-			Config:   p.Config,
-			DB:       p.DB,
-			Args:     args,
+			SQLQuery:     query,
+			Config:       p.Config,
+			DB:           p.DB,
+			Args:         args,
+			Restrictions: p.Restrictions,
 		}
 
 		// If the query is .read, we read the file
@@ -183,6 +281,11 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 			return true
 		}
 
+		ctx, done := p.startQuery()
+		// The spans of the calls to the plugins are children of the span of the query
+		ctx, span := rpc.StartSpan(ctx, "anyquery.query", attribute.String("db.statement", query))
+		queryData.Context = ctx
+
 		s := spinner.New(spinner.CharSets[11], 50*time.Millisecond)
 		s.Prefix = "Running query... "
 
@@ -201,6 +304,10 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 		}
 		s.Stop()
 
+		// The dedicated connection of the query (if any) must be closed
+		// even if queryData is replaced below
+		conn, release, profile := queryData.conn, queryData.release, queryData.Profile
+
 		var tempOutput io.Writer = p.OutputFileDesc
 		/* tempOutputMustClose := false */
 
@@ -271,8 +378,9 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 		} else {
 			// Create an output table and print it to the specified output
 			table := outputTable{
-				Writer: tempOutput,
-				Type:   outputTableTypePretty,
+				Writer:    tempOutput,
+				Type:      outputTableTypePretty,
+				TableName: queryData.Config.GetString("outputTable", ""),
 			}
 
 			// Check if the output mode is one of the specified
@@ -280,10 +388,18 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 				table.Type = mode
 			}
 
+			// A Parquet or Arrow file can't be appended to, so each result overwrites the output file
+			// (the sqlite mode adds its table to the database of the file instead)
+			if isBinaryFormat(table.Type) && table.Type != outputTableTypeSqlite {
+				if fileDesc, ok := tempOutput.(*os.File); ok && fileDesc != os.Stdout {
+					fileDesc.Truncate(0)
+				}
+			}
+
 			// Write the SQL rows to the output
 			err := table.WriteSQLRows(queryData.Result)
 			if err != nil {
-				writeErrorMessage(err.Error(), tempOutput)
+				writeErrorMessage(queryErrorMessage(ctx, err), tempOutput)
 			}
 
 			err = table.Close()
@@ -293,9 +409,40 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 
 		}
 
+		if profile != nil {
+			outputType := outputTableTypePretty
+			if mode, ok := formatName[p.Config.GetString("outputMode", "")]; ok {
+				outputType = mode
+			}
+			profileOutput := tempOutput
+			if outputType == outputTableTypeJson || outputType == outputTableTypeJsonPretty {
+				profileOutput = p.ProfileFileDesc
+				if profileOutput == nil {
+					profileOutput = os.Stderr
+				}
+			}
+			writeProfile(profile, outputType, profileOutput)
+		}
+
+		// The post exec queries must run even if the query was cancelled
+		if release != nil {
+			release()
+		}
+		var queryErr error
+		if queryData.StatusCode >= 2 {
+			queryErr = errors.New(queryData.Message)
+		}
+		rpc.EndSpan(span, queryErr)
+		done()
+
 		// Run all the post exec queries
+		// on the connection of the query if any (e.g. to drop its temporary tables)
+		var postExecRunner queryRunner = queryData.DB
+		if conn != nil {
+			postExecRunner = conn
+		}
 		for _, postExec := range queryData.PostExec {
-			_, err := queryData.DB.Exec(postExec)
+			_, err := postExecRunner.ExecContext(context.Background(), postExec)
 			if err != nil {
 				// Skip the errors for "nu such table" because they're expected
 				// when a create table pre exec query fails
@@ -306,10 +453,13 @@ func (p *shell) Run(rawQuery string, args ...interface{}) bool {
 				}
 			}
 		}
+		if conn != nil {
+			conn.Close()
+		}
 
 		// We print a newline to separate the queries
-		// unless it's the last query
-		if i != len(queries)-1 {
+		// unless it's the last query or the output is a binary file
+		if i != len(queries)-1 && !isBinaryFormat(formatName[p.Config.GetString("outputMode", "")]) {
 			fmt.Fprintln(tempOutput)
 		}
 
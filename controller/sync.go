@@ -0,0 +1,445 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/julien040/anyquery/controller/config/model"
+	"github.com/spf13/cobra"
+)
+
+// This file implements the sync of a plugin table into a local table
+//
+// Unlike a materialized view, the destination table is never rebuilt. The rows of the source
+// are upserted by their key (the primary key of the plugin table by default), and the rows
+// missing from the source are kept with a tombstone (the column _anyquery_deleted_at).
+// This way, the destination keeps the rows deleted upstream, and knows when they were deleted.
+//
+// The state of a sync is recorded in the config database, keyed by the absolute path of the database
+// and the name of the destination table. If the sync has an updated-at column, the greatest value synced
+// so far is recorded as a cursor, and the next runs only request the rows updated since.
+// Because these runs can't see the deleted rows, the tombstones are only set by a full sync (see --full).
+
+const (
+	// The column holding the time a row was last inserted or updated by a sync
+	syncSyncedAtColumn = "_anyquery_synced_at"
+	// The column holding the time a row was found deleted from the source, NULL if it still exists
+	syncDeletedAtColumn = "_anyquery_deleted_at"
+)
+
+// syncResult holds the number of rows changed by a sync
+type syncResult struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+	// Whether only the rows updated since the last sync were requested
+	Incremental bool
+	// The column identifying a row
+	Key string
+	// The greatest value of the updated-at column synced so far
+	Cursor string
+}
+
+// syncColumn is a column of the source table
+type syncColumn struct {
+	name       string
+	columnType string
+	primaryKey bool
+}
+
+// sourceColumns returns the visible columns of the source table
+//
+// The parameters of a plugin table are hidden and therefore not returned
+func sourceColumns(ctx context.Context, tx *sql.Tx, source string) ([]syncColumn, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT name, type, pk FROM pragma_table_info(?)", source)
+	if err != nil {
+		return nil, fmt.Errorf("could not get the columns of %s: %w", source, err)
+	}
+	defer rows.Close()
+
+	columns := []syncColumn{}
+	for rows.Next() {
+		var column syncColumn
+		var pk int
+		if err := rows.Scan(&column.name, &column.columnType, &pk); err != nil {
+			return nil, err
+		}
+		column.primaryKey = pk > 0
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("the table %s does not exist or has no column", source)
+	}
+	return columns, nil
+}
+
+// syncKeyColumn returns the column identifying a row: key if set, the primary key of the source otherwise
+func syncKeyColumn(columns []syncColumn, source, key string) (string, error) {
+	for _, column := range columns {
+		if key == "" && column.primaryKey {
+			return column.name, nil
+		}
+		if key != "" && strings.EqualFold(column.name, key) {
+			return column.name, nil
+		}
+	}
+	if key != "" {
+		return "", fmt.Errorf("the column %s does not exist in %s", key, source)
+	}
+	return "", fmt.Errorf("the table %s has no primary key. Use --key to specify the column identifying a row", source)
+}
+
+// ensureSyncDestination creates the destination table if it doesn't exist,
+// and adds the columns that appeared in the source since the last sync
+func ensureSyncDestination(ctx context.Context, tx *sql.Tx, destination, key string, columns []syncColumn) error {
+	existing := map[string]bool{}
+	rows, err := tx.QueryContext(ctx, "SELECT lower(name) FROM pragma_table_info(?, 'main')", destination)
+	if err != nil {
+		return fmt.Errorf("could not get the columns of %s: %w", destination, err)
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(existing) == 0 {
+		definitions := make([]string, 0, len(columns)+3)
+		for _, column := range columns {
+			definitions = append(definitions, strings.TrimSpace(quoteIdentifier(column.name)+" "+column.columnType))
+		}
+		definitions = append(definitions,
+			quoteIdentifier(syncSyncedAtColumn)+" INTEGER",
+			quoteIdentifier(syncDeletedAtColumn)+" INTEGER",
+			fmt.Sprintf("UNIQUE (%s)", quoteIdentifier(key)),
+		)
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE main.%s (%s)", quoteIdentifier(destination), strings.Join(definitions, ", ")))
+		if err != nil {
+			return fmt.Errorf("could not create the table %s: %w", destination, err)
+		}
+		return nil
+	}
+
+	if !existing[strings.ToLower(syncDeletedAtColumn)] {
+		return fmt.Errorf("the table %s already exists and was not created by anyquery sync", destination)
+	}
+	for _, column := range columns {
+		if existing[strings.ToLower(column.name)] {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE main.%s ADD COLUMN %s", quoteIdentifier(destination),
+			strings.TrimSpace(quoteIdentifier(column.name)+" "+column.columnType)))
+		if err != nil {
+			return fmt.Errorf("could not add the column %s to %s: %w", column.name, destination, err)
+		}
+	}
+	return nil
+}
+
+// syncTable upserts the rows of the source table into the destination table, and tombstones the deleted ones
+//
+// If state has an updated-at column and a cursor, and full is false, only the rows updated since the cursor are requested
+func syncTable(ctx context.Context, db *sql.DB, state model.SyncState, full bool) (syncResult, error) {
+	result := syncResult{}
+
+	// The temporary table only exists on the connection that created it
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("could not start the transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	columns, err := sourceColumns(ctx, tx, state.Source)
+	if err != nil {
+		return result, err
+	}
+	state.Keycolumn, err = syncKeyColumn(columns, state.Source, state.Keycolumn)
+	if err != nil {
+		return result, err
+	}
+	result.Key = state.Keycolumn
+	if state.Updatedatcolumn != "" {
+		found := false
+		for _, column := range columns {
+			found = found || strings.EqualFold(column.name, state.Updatedatcolumn)
+		}
+		if !found {
+			return result, fmt.Errorf("the column %s does not exist in %s", state.Updatedatcolumn, state.Source)
+		}
+	}
+
+	err = ensureSyncDestination(ctx, tx, state.Destination, state.Keycolumn, columns)
+	if err != nil {
+		return result, err
+	}
+
+	table := "main." + quoteIdentifier(state.Destination)
+	staging := "temp." + quoteIdentifier("_anyquery_sync_"+state.Destination)
+	key := quoteIdentifier(state.Keycolumn)
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = quoteIdentifier(column.name)
+	}
+	columnList := strings.Join(names, ", ")
+
+	// Copy the rows of the source, so that the plugin is only queried once
+	// The constraint on the updated-at column is pushed down to the plugin if it supports it
+	result.Incremental = state.Updatedatcolumn != "" && state.Cursor != "" && !full
+	query := fmt.Sprintf("CREATE TEMP TABLE %s AS SELECT %s FROM %s", staging, columnList, quoteIdentifier(state.Source))
+	args := []interface{}{}
+	if result.Incremental {
+		// The rows of the cursor's timestamp are read again, as rows updated in the same second may have arrived since.
+		// Those that didn't change are not updated
+		query += fmt.Sprintf(" WHERE %s >= ?", quoteIdentifier(state.Updatedatcolumn))
+		args = append(args, updatedAtArg(state.Cursor))
+	}
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return result, fmt.Errorf("could not query %s: %w", state.Source, err)
+	}
+
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT count(*) FROM %s s WHERE NOT EXISTS (SELECT 1 FROM %s t WHERE t.%s = s.%s)",
+		staging, table, key, key)).Scan(&result.Inserted)
+	if err != nil {
+		return result, err
+	}
+
+	// Only the rows that changed (or came back after a deletion) are updated
+	updates := make([]string, 0, len(columns)+2)
+	changed := make([]string, 0, len(columns)+1)
+	for _, name := range names {
+		updates = append(updates, fmt.Sprintf("%s = excluded.%s", name, name))
+		changed = append(changed, fmt.Sprintf("%s.%s IS NOT excluded.%s", quoteIdentifier(state.Destination), name, name))
+	}
+	updates = append(updates,
+		fmt.Sprintf("%s = excluded.%s", quoteIdentifier(syncSyncedAtColumn), quoteIdentifier(syncSyncedAtColumn)),
+		fmt.Sprintf("%s = NULL", quoteIdentifier(syncDeletedAtColumn)),
+	)
+	changed = append(changed, fmt.Sprintf("%s.%s IS NOT NULL", quoteIdentifier(state.Destination), quoteIdentifier(syncDeletedAtColumn)))
+
+	// The WHERE true avoids the parsing ambiguity between the ON of a join and the ON CONFLICT of the upsert
+	upsert, err := tx.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (%s, %s, %s) SELECT %s, unixepoch(), NULL FROM %s WHERE true
+		ON CONFLICT (%s) DO UPDATE SET %s WHERE %s`,
+		table, columnList, quoteIdentifier(syncSyncedAtColumn), quoteIdentifier(syncDeletedAtColumn), columnList, staging,
+		key, strings.Join(updates, ", "), strings.Join(changed, " OR ")))
+	if err != nil {
+		return result, fmt.Errorf("could not upsert the rows into %s: %w", state.Destination, err)
+	}
+	changes, err := upsert.RowsAffected()
+	if err != nil {
+		return result, err
+	}
+	result.Updated = changes - result.Inserted
+
+	// A row missing from a full sync has been deleted from the source
+	if !result.Incremental {
+		tombstones, err := tx.ExecContext(ctx, fmt.Sprintf(`UPDATE %s SET %s = unixepoch()
+			WHERE %s IS NULL AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.%s = %s.%s)`,
+			table, quoteIdentifier(syncDeletedAtColumn), quoteIdentifier(syncDeletedAtColumn),
+			staging, key, quoteIdentifier(state.Destination), key))
+		if err != nil {
+			return result, fmt.Errorf("could not mark the deleted rows of %s: %w", state.Destination, err)
+		}
+		result.Deleted, err = tombstones.RowsAffected()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DROP TABLE "+staging)
+	if err != nil {
+		return result, err
+	}
+
+	if state.Updatedatcolumn != "" {
+		var greatest sql.NullString
+		err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT max(%s) FROM %s",
+			quoteIdentifier(state.Updatedatcolumn), table)).Scan(&greatest)
+		if err != nil {
+			return result, fmt.Errorf("could not get the greatest value of %s: %w", state.Updatedatcolumn, err)
+		}
+		result.Cursor = greatest.String
+	}
+
+	if err = tx.Commit(); err != nil {
+		return result, fmt.Errorf("could not commit the sync of %s: %w", state.Destination, err)
+	}
+	return result, nil
+}
+
+// syncAndRecord syncs the table and records its state in the config
+//
+// The state is only recorded once the sync succeeded, so that a failing first sync leaves no trace
+func syncAndRecord(ctx context.Context, db *sql.DB, queries *model.Queries, state model.SyncState, full bool) (syncResult, error) {
+	if state.Destination == "" || state.Source == "" {
+		return syncResult{}, fmt.Errorf("the source and the destination tables cannot be empty")
+	}
+	if strings.EqualFold(state.Destination, state.Source) {
+		return syncResult{}, fmt.Errorf("the destination table must be different from the source table")
+	}
+
+	previous, err := queries.GetSyncState(ctx, model.GetSyncStateParams{
+		Databasepath: state.Databasepath,
+		Destination:  state.Destination,
+	})
+	if err == nil {
+		if !strings.EqualFold(previous.Source, state.Source) {
+			return syncResult{}, fmt.Errorf("the table %s is already synced from %s", state.Destination, previous.Source)
+		}
+		// The flags of the first sync are kept unless they are overridden
+		if state.Keycolumn == "" {
+			state.Keycolumn = previous.Keycolumn
+		}
+		if state.Updatedatcolumn == "" {
+			state.Updatedatcolumn = previous.Updatedatcolumn
+		}
+		if strings.EqualFold(state.Updatedatcolumn, previous.Updatedatcolumn) {
+			state.Cursor = previous.Cursor
+		}
+	} else if err != sql.ErrNoRows {
+		return syncResult{}, fmt.Errorf("could not get the sync state of %s: %w", state.Destination, err)
+	}
+
+	result, err := syncTable(ctx, db, state, full)
+	if err != nil {
+		return result, err
+	}
+
+	err = queries.SetSyncState(ctx, model.SetSyncStateParams{
+		Databasepath:    state.Databasepath,
+		Destination:     state.Destination,
+		Source:          state.Source,
+		Keycolumn:       result.Key,
+		Updatedatcolumn: state.Updatedatcolumn,
+		Cursor:          result.Cursor,
+	})
+	if err != nil {
+		return result, fmt.Errorf("could not record the sync of %s: %w", state.Destination, err)
+	}
+	return result, nil
+}
+
+func Sync(cmd *cobra.Command, args []string) error {
+	full, _ := cmd.Flags().GetBool("full")
+	updatedAt, _ := cmd.Flags().GetString("updated-at")
+	key, _ := cmd.Flags().GetString("key")
+
+	db, queries, databasePath, closeDatabases, err := openLocalDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDatabases()
+
+	start := time.Now()
+	result, err := syncAndRecord(context.Background(), db, queries, model.SyncState{
+		Databasepath:    databasePath,
+		Source:          args[0],
+		Destination:     args[1],
+		Keycolumn:       key,
+		Updatedatcolumn: updatedAt,
+	}, full)
+	if err != nil {
+		return err
+	}
+
+	mode := "full"
+	if result.Incremental {
+		mode = "incremental"
+	}
+	fmt.Printf("✅ Synced %s into %s (%s): %d inserted, %d updated, %d deleted in %s\n", args[0], args[1], mode,
+		result.Inserted, result.Updated, result.Deleted, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func SyncList(cmd *cobra.Command, args []string) error {
+	path, _ := cmd.Flags().GetString("database")
+	databasePath, err := localDatabasePath(path)
+	if err != nil {
+		return err
+	}
+
+	db, queries, err := requestDatabase(cmd.Flags(), true)
+	if err != nil {
+		return fmt.Errorf("could not open the database: %w", err)
+	}
+	defer db.Close()
+
+	states, err := queries.GetSyncStates(context.Background(), databasePath)
+	if err != nil {
+		return fmt.Errorf("could not get the synced tables: %w", err)
+	}
+
+	output := outputTable{
+		Writer:  os.Stdout,
+		Columns: []string{"Destination", "Source", "Key", "Updated at", "Cursor", "Last synced"},
+	}
+	output.InferFlags(cmd.Flags())
+	for _, state := range states {
+		lastSynced := "never"
+		if state.Lastsynced > 0 {
+			lastSynced = time.Unix(state.Lastsynced, 0).Format(time.DateTime)
+		}
+		output.AddRow(state.Destination, state.Source, state.Keycolumn, state.Updatedatcolumn, state.Cursor, lastSynced)
+	}
+
+	return output.Close()
+}
+
+func SyncDelete(cmd *cobra.Command, args []string) error {
+	dropTable, _ := cmd.Flags().GetBool("drop")
+
+	db, queries, databasePath, closeDatabases, err := openLocalDatabases(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeDatabases()
+
+	ctx := context.Background()
+	_, err = queries.GetSyncState(ctx, model.GetSyncStateParams{
+		Databasepath: databasePath,
+		Destination:  args[0],
+	})
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("the table %s is not synced", args[0])
+	} else if err != nil {
+		return fmt.Errorf("could not get the sync state of %s: %w", args[0], err)
+	}
+
+	if dropTable {
+		_, err = db.ExecContext(ctx, "DROP TABLE IF EXISTS main."+quoteIdentifier(args[0]))
+		if err != nil {
+			return fmt.Errorf("could not drop the table %s: %w", args[0], err)
+		}
+	}
+
+	err = queries.DeleteSyncState(ctx, model.DeleteSyncStateParams{
+		Databasepath: databasePath,
+		Destination:  args[0],
+	})
+	if err != nil {
+		return fmt.Errorf("could not delete the sync state of %s: %w", args[0], err)
+	}
+
+	fmt.Printf("✅ The table %s is no longer synced\n", args[0])
+	return nil
+}
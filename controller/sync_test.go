@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/julien040/anyquery/controller/config"
+	"github.com/julien040/anyquery/controller/config/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	configDB, queries, err := config.OpenDatabaseConnection(filepath.Join(dir, "config.db"), false)
+	require.NoError(t, err, "The config should open")
+	defer configDB.Close()
+
+	databasePath := filepath.Join(dir, "anyquery.db")
+	db, err := sql.Open("sqlite3", databasePath)
+	require.NoError(t, err, "The database should open")
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE tasks (id INTEGER PRIMARY KEY, updated_at TEXT, title TEXT);
+	INSERT INTO tasks VALUES (1, '2024-01-01', 'first'), (2, '2024-02-01', 'second');
+	CREATE TABLE notes (title TEXT);`)
+	require.NoError(t, err)
+
+	countRows := func(query string) int {
+		var count int
+		require.NoError(t, db.QueryRow(query).Scan(&count))
+		return count
+	}
+	state := model.SyncState{
+		Databasepath:    databasePath,
+		Source:          "tasks",
+		Destination:     "mirror",
+		Updatedatcolumn: "updated_at",
+	}
+
+	t.Run("The first sync copies all the rows", func(t *testing.T) {
+		result, err := syncAndRecord(ctx, db, queries, state, false)
+		require.NoError(t, err)
+		require.False(t, result.Incremental)
+		require.Equal(t, syncResult{Inserted: 2, Key: "id", Cursor: "2024-02-01"}, result)
+		require.Equal(t, 2, countRows("SELECT count(*) FROM mirror WHERE _anyquery_deleted_at IS NULL AND _anyquery_synced_at IS NOT NULL"))
+
+		recorded, err := queries.GetSyncState(ctx, model.GetSyncStateParams{Databasepath: databasePath, Destination: "mirror"})
+		require.NoError(t, err)
+		require.Equal(t, "id", recorded.Keycolumn, "The primary key of the source should be recorded")
+		require.Equal(t, "2024-02-01", recorded.Cursor)
+		require.NotZero(t, recorded.Lastsynced)
+	})
+
+	t.Run("The next sync only pulls the changes", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE tasks SET updated_at = '2024-03-01', title = 'second (edited)' WHERE id = 2;
+		INSERT INTO tasks VALUES (3, '2024-04-01', 'third');
+		DELETE FROM tasks WHERE id = 1;`)
+		require.NoError(t, err)
+
+		// The flags of the first sync are remembered
+		result, err := syncAndRecord(ctx, db, queries, model.SyncState{Databasepath: databasePath, Source: "tasks", Destination: "mirror"}, false)
+		require.NoError(t, err)
+		require.True(t, result.Incremental)
+		require.Equal(t, int64(1), result.Inserted)
+		require.Equal(t, int64(1), result.Updated)
+		require.Zero(t, result.Deleted, "An incremental sync can't see the deleted rows")
+		require.Equal(t, "2024-04-01", result.Cursor)
+		require.Equal(t, 1, countRows("SELECT count(*) FROM mirror WHERE title = 'second (edited)'"))
+	})
+
+	t.Run("A row of the cursor's timestamp added later is pulled", func(t *testing.T) {
+		_, err := db.Exec(`INSERT INTO tasks VALUES (4, '2024-04-01', 'fourth')`)
+		require.NoError(t, err)
+		result, err := syncAndRecord(ctx, db, queries, state, false)
+		require.NoError(t, err)
+		require.True(t, result.Incremental)
+		require.Equal(t, int64(1), result.Inserted)
+		require.Zero(t, result.Updated, "The unchanged rows of the cursor's timestamp should not be updated")
+	})
+
+	t.Run("A full sync tombstones the deleted rows", func(t *testing.T) {
+		result, err := syncAndRecord(ctx, db, queries, state, true)
+		require.NoError(t, err)
+		require.Equal(t, syncResult{Deleted: 1, Key: "id", Cursor: "2024-04-01"}, result, "The unchanged rows should not be updated")
+		require.Equal(t, 4, countRows("SELECT count(*) FROM mirror"))
+		require.Equal(t, 1, countRows("SELECT count(*) FROM mirror WHERE id = 1 AND _anyquery_deleted_at IS NOT NULL"))
+
+		// A row coming back is restored
+		_, err = db.Exec(`INSERT INTO tasks VALUES (1, '2024-01-01', 'first')`)
+		require.NoError(t, err)
+		result, err = syncAndRecord(ctx, db, queries, state, true)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), result.Updated)
+		require.Equal(t, 0, countRows("SELECT count(*) FROM mirror WHERE _anyquery_deleted_at IS NOT NULL"))
+	})
+
+	t.Run("A new column of the source is added", func(t *testing.T) {
+		_, err := db.Exec(`ALTER TABLE tasks ADD COLUMN priority INTEGER DEFAULT 4`)
+		require.NoError(t, err)
+		result, err := syncAndRecord(ctx, db, queries, state, true)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), result.Updated)
+		require.Equal(t, 4, countRows("SELECT count(*) FROM mirror WHERE priority = 4"))
+	})
+
+	t.Run("Reject invalid syncs", func(t *testing.T) {
+		_, err := syncAndRecord(ctx, db, queries, model.SyncState{Databasepath: databasePath, Source: "notes", Destination: "notes_mirror"}, false)
+		require.Error(t, err, "A source without primary key needs a key column")
+
+		_, err = syncAndRecord(ctx, db, queries, model.SyncState{Databasepath: databasePath, Source: "tasks", Destination: "notes"}, false)
+		require.Error(t, err, "An existing table must not be overwritten")
+
+		_, err = syncAndRecord(ctx, db, queries, model.SyncState{Databasepath: databasePath, Source: "notes", Destination: "mirror", Keycolumn: "title"}, false)
+		require.Error(t, err, "A table synced from another source must be rejected")
+
+		_, err = queries.GetSyncState(ctx, model.GetSyncStateParams{Databasepath: databasePath, Destination: "notes_mirror"})
+		require.ErrorIs(t, err, sql.ErrNoRows, "A sync that fails should not be recorded")
+	})
+}
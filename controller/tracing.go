@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// setupTracing exports the spans of the queries to an OpenTelemetry collector over OTLP/HTTP
+//
+// The endpoint is the --otlp-endpoint flag (e.g. http://localhost:4318), or the standard
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and OTEL_EXPORTER_OTLP_ENDPOINT environment variables.
+// If none is set, the spans are discarded.
+//
+// The returned function flushes the spans not exported yet and must be called before exiting
+func setupTracing(cmd *cobra.Command) (shutdown func(), err error) {
+	endpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+	if endpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func() {}, nil
+	}
+
+	options := []otlptracehttp.Option{}
+	if endpoint != "" {
+		options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(context.Background(), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []attribute.KeyValue{attribute.String("service.name", "anyquery")}
+	if CurrentVersion != "" {
+		attributes = append(attributes, attribute.String("service.version", CurrentVersion))
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attributes...)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		provider.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,242 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/julien040/anyquery/rpc"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// AggregateModule is a virtual table returning the aggregates computed by a plugin
+// (see rpc.AggregateReaderInterface)
+//
+// It takes two arguments: the name of the plugin table and the JSON-encoded rpc.AggregateQuery.
+// The table has a column g_<i> for each column of AggregateQuery.GroupBy
+// followed by a column a_<i> for each aggregate of AggregateQuery.Aggregates
+//
+//	CREATE VIRTUAL TABLE tmp USING plugin_aggregate('github_my_issues', '{"GroupBy":[2],"Aggregates":[{"Function":"count","ColumnID":-1}]}')
+//
+// It is not meant to be used directly. The query rewriter of the namespace creates it
+// for the queries aggregating the rows of a plugin table
+type AggregateModule struct {
+	// The modules of the plugin tables, keyed by table name
+	Modules *map[string]*SQLiteModule
+}
+
+type AggregateTable struct {
+	module   *SQLiteModule
+	query    rpc.AggregateQuery
+	profiler cursorProfiler
+}
+
+type AggregateCursor struct {
+	table   *AggregateTable
+	rows    [][]interface{}
+	rowID   int
+	profile *CursorProfile
+}
+
+func (m *AggregateModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return m.Connect(c, args)
+}
+
+func (m *AggregateModule) DestroyModule() {}
+
+func (m *AggregateModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	if len(args) < 5 {
+		return nil, errors.New("missing arguments. Specify them as: CREATE VIRTUAL TABLE x USING plugin_aggregate('table', 'query')")
+	}
+
+	tableName := strings.Trim(args[3], "'\" ")
+	rawQuery := strings.Trim(strings.TrimSpace(args[4]), "'")
+	rawQuery = strings.ReplaceAll(rawQuery, "''", "'")
+
+	if m.Modules == nil {
+		return nil, errors.New("no plugin table is loaded")
+	}
+	mod, ok := (*m.Modules)[tableName]
+	if !ok {
+		return nil, fmt.Errorf("the table %s does not exist", tableName)
+	}
+
+	query, err := decodeAggregateQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the aggregate query: %w", err)
+	}
+
+	schema, err := mod.Schema()
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAggregateQuery(query, schema); err != nil {
+		return nil, err
+	}
+
+	// Declare a column for each group and each aggregate
+	columns := make([]string, 0, len(query.GroupBy)+len(query.Aggregates))
+	for i := range query.GroupBy {
+		columns = append(columns, "g_"+strconv.Itoa(i))
+	}
+	for i := range query.Aggregates {
+		columns = append(columns, "a_"+strconv.Itoa(i))
+	}
+	err = c.DeclareVTab("CREATE TABLE x(" + strings.Join(columns, ", ") + ")")
+	if err != nil {
+		return nil, fmt.Errorf("could not declare the virtual table: %w", err)
+	}
+
+	return &AggregateTable{
+		module:   mod,
+		query:    query,
+		profiler: newCursorProfiler(c, args),
+	}, nil
+}
+
+// decodeAggregateQuery decodes a JSON-encoded rpc.AggregateQuery
+//
+// encoding/json decodes every number as a float64, so the integers
+// of the constraints are converted back to int64
+func decodeAggregateQuery(raw string) (rpc.AggregateQuery, error) {
+	query := rpc.AggregateQuery{}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.UseNumber()
+	err := decoder.Decode(&query)
+	if err != nil {
+		return query, err
+	}
+
+	for i, cst := range query.Constraint.Columns {
+		query.Constraint.Columns[i].Value = convertJSONNumber(cst.Value)
+	}
+	query.Constraint.Limit = -1
+	query.Constraint.Offset = -1
+	return query, nil
+}
+
+// convertJSONNumber converts a json.Number to an int64 or a float64
+//
+// Any other value is returned as is
+func convertJSONNumber(value interface{}) interface{} {
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+	if val, err := number.Int64(); err == nil {
+		return val
+	}
+	if val, err := number.Float64(); err == nil {
+		return val
+	}
+	return value
+}
+
+// validateAggregateQuery checks that the plugin can compute the query
+func validateAggregateQuery(query rpc.AggregateQuery, schema rpc.DatabaseSchema) error {
+	if len(query.Aggregates) == 0 {
+		return errors.New("the query has no aggregate")
+	}
+	for _, agg := range query.Aggregates {
+		if !schema.SupportsAggregate(agg.Function) {
+			return fmt.Errorf("the plugin cannot compute %s", agg.Function)
+		}
+		if agg.ColumnID == -1 && agg.Function == rpc.AggregateCount {
+			continue
+		}
+		if agg.ColumnID < 0 || agg.ColumnID >= len(schema.Columns) {
+			return fmt.Errorf("invalid column %d for %s", agg.ColumnID, agg.Function)
+		}
+	}
+	for _, col := range query.GroupBy {
+		if col < 0 || col >= len(schema.Columns) {
+			return fmt.Errorf("invalid column %d in GROUP BY", col)
+		}
+	}
+	for _, cst := range query.Constraint.Columns {
+		if cst.ColumnID < 0 || cst.ColumnID >= len(schema.Columns) {
+			return fmt.Errorf("invalid column %d in WHERE", cst.ColumnID)
+		}
+	}
+	return nil
+}
+
+func (t *AggregateTable) Open() (sqlite3.VTabCursor, error) {
+	return t.profiler.wrap(&AggregateCursor{
+		table: t,
+	}), nil
+}
+
+func (t *AggregateTable) Disconnect() error {
+	return nil
+}
+
+func (t *AggregateTable) Destroy() error {
+	return nil
+}
+
+func (t *AggregateTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, info sqlite3.IndexInformation) (*sqlite3.IndexResult, error) {
+	return &sqlite3.IndexResult{
+		Used: make([]bool, len(cst)),
+	}, nil
+}
+
+func (c *AggregateCursor) setProfile(profile *CursorProfile) {
+	c.profile = profile
+}
+
+func (c *AggregateCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	c.rowID = 0
+	// The aggregates are computed once per cursor
+	if c.rows != nil {
+		return nil
+	}
+
+	mod := c.table.module
+	client, ok := mod.client.Plugin.(rpc.InternalAggregateInterface)
+	if !ok {
+		return errors.New("the plugin client cannot request aggregates")
+	}
+
+	c.profile.roundTrip()
+	rows, err := client.QueryAggregate(mod.ConnectionIndex, mod.TableIndex, c.table.query)
+	if err != nil {
+		return errors.Join(errors.New("could not request the aggregates from the plugin"), err)
+	}
+	if rows == nil {
+		rows = [][]interface{}{}
+	}
+	for _, row := range rows {
+		c.profile.fetched(row)
+	}
+	c.rows = rows
+	return nil
+}
+
+func (c *AggregateCursor) Next() error {
+	c.rowID++
+	return nil
+}
+
+func (c *AggregateCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	if c.rowID >= len(c.rows) || col >= len(c.rows[c.rowID]) {
+		context.ResultNull()
+		return nil
+	}
+	convertToSQLiteVal(c.rows[c.rowID][col], context)
+	return nil
+}
+
+func (c *AggregateCursor) EOF() bool {
+	return c.rowID >= len(c.rows)
+}
+
+func (c *AggregateCursor) Rowid() (int64, error) {
+	return int64(c.rowID), nil
+}
+
+func (c *AggregateCursor) Close() error {
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"gopkg.in/inf.v0"
 
 	gocql "github.com/apache/cassandra-gocql-driver/v2"
+	"github.com/julien040/anyquery/rpc"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
 
@@ -153,6 +154,7 @@ type CassandraTable struct {
 	connectionString            string
 	partitionClusteringKeyCount int // The number of clustering and partition keys in the table
 	clusteringKeyCount          int // The number of partition keys in the table
+	profiler                    cursorProfiler
 }
 
 type CassandraCursor struct {
@@ -166,6 +168,9 @@ type CassandraCursor struct {
 	rowsReturned  int64
 	limit         int64
 	query         cassandraSQLQueryToExecute
+	profile       *CursorProfile
+	// The SQLite connection the cursor is opened on, to trace its queries
+	sqliteConn *sqlite3.SQLiteConn
 }
 
 type cassandraSQLQueryToExecute struct {
@@ -436,6 +441,7 @@ func (m *CassandraModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3
 		connectionString:            connectionString,
 		partitionClusteringKeyCount: partitionClusteringKeyCount,
 		clusteringKeyCount:          clusteringKeyCount,
+		profiler:                    newCursorProfiler(c, args),
 	}, nil
 }
 
@@ -477,13 +483,14 @@ func (t *CassandraTable) Open() (sqlite3.VTabCursor, error) {
 		}
 	}
 
-	return &CassandraCursor{
+	return t.profiler.wrap(&CassandraCursor{
 		connection: session,
 		tableName:  t.tableName,
 		schema:     t.schema,
 		limit:      -1,
 		currentRow: values,
-	}, nil
+		sqliteConn: t.profiler.conn,
+	}), nil
 }
 
 func (t *CassandraTable) Disconnect() error {
@@ -750,6 +757,10 @@ func (t *CassandraCursor) resetCursor() error {
 	return nil
 }
 
+func (t *CassandraCursor) setProfile(profile *CursorProfile) {
+	t.profile = profile
+}
+
 func (t *CassandraCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Reset the cursor as Filter might be called multiple times
 	err := t.resetCursor()
@@ -801,12 +812,16 @@ func (t *CassandraCursor) Filter(idxNum int, idxStr string, vals []interface{})
 		t.limit = limit
 	}
 
-	cassandraQuery := t.connection.Query(query.Query, queryParams...)
+	t.profile.roundTrip()
+	ctx, span := startReaderSpan(t.sqliteConn, "cassandra", query.Query)
+	cassandraQuery := t.connection.Query(query.Query, queryParams...).WithContext(ctx)
 
 	t.iter = cassandraQuery.Iter()
 	if t.iter == nil {
+		rpc.EndSpan(span, fmt.Errorf("error creating the iterator for the query"))
 		return fmt.Errorf("error creating the iterator for the query")
 	}
+	rpc.EndSpan(span, nil)
 	return t.Next()
 }
 
@@ -831,6 +846,7 @@ func (t *CassandraCursor) Next() error {
 	}
 
 	t.currentRow = line.Values
+	t.profile.fetched(t.currentRow)
 
 	if t.columnsMapper == nil {
 		// Initialize the columns mapper
@@ -14,6 +14,7 @@ import (
 	"github.com/huandu/go-sqlbuilder"
 
 	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/julien040/anyquery/rpc"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
 
@@ -82,6 +83,7 @@ type ClickHouseTable struct {
 	schema           []databaseColumn
 	module           *ClickHouseModule
 	connectionString string
+	profiler         cursorProfiler
 }
 
 type ClickHouseCursor struct {
@@ -94,6 +96,9 @@ type ClickHouseCursor struct {
 	rowsReturned int64
 	limit        int64
 	query        SQLQueryToExecute
+	profile      *CursorProfile
+	// The SQLite connection the cursor is opened on, to trace its queries
+	sqliteConn *sqlite3.SQLiteConn
 }
 
 func (m *ClickHouseModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -302,6 +307,7 @@ row:
 		schema:           internalSchema,
 		module:           m,
 		connectionString: connectionString,
+		profiler:         newCursorProfiler(c, args),
 	}, nil
 }
 
@@ -343,13 +349,14 @@ func (t *ClickHouseTable) Open() (sqlite3.VTabCursor, error) {
 		}
 	}
 
-	return &ClickHouseCursor{
+	return t.profiler.wrap(&ClickHouseCursor{
 		connection: conn,
 		tableName:  t.tableName,
 		schema:     t.schema,
 		limit:      -1,
 		currentRow: values,
-	}, nil
+		sqliteConn: t.profiler.conn,
+	}), nil
 }
 
 func (t *ClickHouseTable) Disconnect() error {
@@ -513,6 +520,10 @@ func (t *ClickHouseCursor) resetCursor() error {
 	return nil
 }
 
+func (t *ClickHouseCursor) setProfile(profile *CursorProfile) {
+	t.profile = profile
+}
+
 func (t *ClickHouseCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Reset the cursor as Filter might be called multiple times
 	err := t.resetCursor()
@@ -555,7 +566,10 @@ func (t *ClickHouseCursor) Filter(idxNum int, idxStr string, vals []interface{})
 	}
 
 	// Execute the query
-	rows, err := t.connection.QueryContext(context.Background(), query.Query, queryParams...)
+	t.profile.roundTrip()
+	ctx, span := startReaderSpan(t.sqliteConn, "clickhouse", query.Query)
+	rows, err := t.connection.QueryContext(ctx, query.Query, queryParams...)
+	rpc.EndSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("error executing the query: %v", err)
 	}
@@ -592,6 +606,7 @@ func (t *ClickHouseCursor) Next() error {
 		if err != nil {
 			return fmt.Errorf("error scanning the row: %v", err)
 		}
+		t.profile.fetched(dest)
 
 	} else {
 		t.currentRow = nil
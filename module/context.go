@@ -0,0 +1,44 @@
+package module
+
+import (
+	"context"
+	"sync"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// This file binds the context of the query running on a SQLite connection
+// to the connection, so that the virtual tables of the plugins can stop
+// their in-flight calls once the query is cancelled or times out.
+//
+// SQLite does not forward any context to the virtual tables. Therefore, the caller
+// running a query on a connection must set the context with SetConnectionContext.
+
+// connectionContexts maps a *sqlite3.SQLiteConn to the context.Context of the query it runs
+var connectionContexts sync.Map
+
+// SetConnectionContext sets the context of the query running on the SQLite connection
+// until the returned function is called
+//
+// When ctx is done, the cursors of the plugins opened on this connection
+// stop waiting for the plugin and tell it to stop working on the query
+func SetConnectionContext(conn *sqlite3.SQLiteConn, ctx context.Context) (release func()) {
+	holder := &ctx
+	connectionContexts.Store(conn, holder)
+	return func() {
+		connectionContexts.CompareAndDelete(conn, holder)
+	}
+}
+
+// connectionContext returns the context of the query running on the SQLite connection
+//
+// If no context was set, it returns context.Background()
+func connectionContext(conn *sqlite3.SQLiteConn) context.Context {
+	if conn == nil {
+		return context.Background()
+	}
+	if holder, ok := connectionContexts.Load(conn); ok {
+		return *(holder.(*context.Context))
+	}
+	return context.Background()
+}
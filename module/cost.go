@@ -0,0 +1,74 @@
+package module
+
+import (
+	"github.com/julien040/anyquery/rpc"
+)
+
+// The number of rows assumed for a table that does not estimate it
+//
+// Most plugins query remote APIs, so an unknown table is assumed to be expensive to read
+const defaultEstimatedRows = 1_000_000
+
+// The ratio of rows kept by each equality constraint of a table without a matching rpc.ConstraintCost
+const equalitySelectivity = 10
+
+// estimateQuery returns the cost and the number of rows of a query on the table
+// from the estimates of the schema and the constraints passed to the plugin
+//
+// SQLite compares these estimates to choose the order of the tables in a join
+func estimateQuery(schema rpc.DatabaseSchema, constraints rpc.QueryConstraint) (cost float64, rows float64) {
+	rows = float64(schema.EstimatedRows)
+	if rows <= 0 {
+		rows = defaultEstimatedRows
+	}
+	cost = schema.EstimatedCost
+	if cost <= 0 {
+		cost = rows
+	}
+
+	constrained := make(map[int]bool)
+	for _, cst := range constraints.Columns {
+		if cst.Operator == rpc.OperatorEqual {
+			constrained[cst.ColumnID] = true
+		}
+	}
+	if len(constrained) == 0 {
+		return cost, rows
+	}
+
+	// Use the cheapest estimate declared by the plugin whose columns are all constrained
+	matched := false
+	for _, estimate := range schema.ConstraintCosts {
+		if len(estimate.Columns) == 0 || !allConstrained(estimate.Columns, constrained) {
+			continue
+		}
+		estimateRows := float64(max(estimate.EstimatedRows, 0))
+		estimateCost := estimate.EstimatedCost
+		if estimateCost <= 0 {
+			estimateCost = estimateRows
+		}
+		if !matched || estimateCost < cost {
+			cost, rows = estimateCost, estimateRows
+			matched = true
+		}
+	}
+	if matched {
+		return cost, rows
+	}
+
+	// Otherwise, assume each equality filters out most of the rows
+	for range constrained {
+		rows = max(rows/equalitySelectivity, 1)
+		cost = max(cost/equalitySelectivity, 1)
+	}
+	return cost, rows
+}
+
+func allConstrained(columns []int, constrained map[int]bool) bool {
+	for _, col := range columns {
+		if !constrained[col] {
+			return false
+		}
+	}
+	return true
+}
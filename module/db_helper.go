@@ -1,12 +1,26 @@
 package module
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/huandu/go-sqlbuilder"
+	"github.com/julien040/anyquery/rpc"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// startReaderSpan starts the span of a query a database reader sends to its database
+//
+// The span is a child of the span of the query running on the SQLite connection
+func startReaderSpan(conn *sqlite3.SQLiteConn, system string, statement string) (context.Context, trace.Span) {
+	return rpc.StartSpan(connectionContext(conn), "anyquery.reader.query",
+		attribute.String("db.system", system),
+		attribute.String("db.statement", statement),
+	)
+}
+
 type databaseColumn struct {
 	// The raw name of the column in the database, without identifier quoting
 	Realname string
@@ -0,0 +1,105 @@
+package module
+
+import (
+	"sync"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// This file implements the dry-run mode of a SQLite connection.
+//
+// In dry-run mode, the inserts, updates and deletes on the plugin tables are recorded
+// rather than buffered and sent to the plugin. The caller sets the mode with SetConnectionDryRun
+// and reads the recorded writes once the statement is run.
+
+// DryRunWrite is a write that would have been sent to a plugin
+type DryRunWrite struct {
+	// The name of the table in SQLite
+	Table string `json:"table"`
+	// INSERT, UPDATE or DELETE
+	Operation string `json:"operation"`
+	// The primary key of the row to update or delete (nil for an insert)
+	PrimaryKey interface{} `json:"primary_key"`
+	// The values of the row to insert or update as sent to the plugin (nil for a delete)
+	Values []interface{} `json:"values"`
+}
+
+// DryRun records the writes of the plugin tables of a connection in dry-run mode
+type DryRun struct {
+	mu     sync.Mutex
+	writes []DryRunWrite
+}
+
+// Writes returns the writes recorded so far
+func (d *DryRun) Writes() []DryRunWrite {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]DryRunWrite{}, d.writes...)
+}
+
+// record adds a write and returns its position in the writes, starting at 1
+func (d *DryRun) record(write DryRunWrite) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.writes = append(d.writes, write)
+	return int64(len(d.writes))
+}
+
+// connectionDryRuns maps a *sqlite3.SQLiteConn to the *DryRun recording its writes
+var connectionDryRuns sync.Map
+
+// SetConnectionDryRun records the writes of the plugin tables run on the SQLite connection in dryRun
+// rather than sending them to the plugins, until the returned function is called
+func SetConnectionDryRun(conn *sqlite3.SQLiteConn, dryRun *DryRun) (release func()) {
+	connectionDryRuns.Store(conn, dryRun)
+	return func() {
+		connectionDryRuns.CompareAndDelete(conn, dryRun)
+	}
+}
+
+// connectionDryRun returns the *DryRun of the connection, or nil if it is not in dry-run mode
+func connectionDryRun(conn *sqlite3.SQLiteConn) *DryRun {
+	if conn == nil {
+		return nil
+	}
+	if dryRun, ok := connectionDryRuns.Load(conn); ok {
+		return dryRun.(*DryRun)
+	}
+	return nil
+}
+
+// The writes of a connection are recorded in dry-run mode.
+// Otherwise, the table buffers them
+
+func (t *sqliteTableConnection) Insert(id any, vals []any) (int64, error) {
+	if dryRun := connectionDryRun(t.conn); dryRun != nil {
+		if err := t.checkWrite("INSERT"); err != nil {
+			return 0, err
+		}
+		// The row ID is the position of the write so that a dry run always returns the same result
+		return dryRun.record(DryRunWrite{Table: t.name, Operation: "INSERT", Values: vals}), nil
+	}
+	return t.SQLiteTable.Insert(id, vals)
+}
+
+func (t *sqliteTableConnection) Update(id any, vals []any) error {
+	if dryRun := connectionDryRun(t.conn); dryRun != nil {
+		if err := t.checkWrite("UPDATE"); err != nil {
+			return err
+		}
+		dryRun.record(DryRunWrite{Table: t.name, Operation: "UPDATE", PrimaryKey: id, Values: vals})
+		return nil
+	}
+	return t.SQLiteTable.Update(id, vals)
+}
+
+func (t *sqliteTableConnection) Delete(id any) error {
+	if dryRun := connectionDryRun(t.conn); dryRun != nil {
+		if err := t.checkWrite("DELETE"); err != nil {
+			return err
+		}
+		dryRun.record(DryRunWrite{Table: t.name, Operation: "DELETE", PrimaryKey: id})
+		return nil
+	}
+	return t.SQLiteTable.Delete(id)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/huandu/go-sqlbuilder"
 	"github.com/julien040/anyquery/other/duckdb"
+	"github.com/julien040/anyquery/rpc"
 
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
@@ -31,6 +32,7 @@ type DuckDBTable struct {
 	tableName        string
 	schema           []databaseColumn
 	connectionString string
+	profiler         cursorProfiler
 }
 
 type DuckDBCursor struct {
@@ -44,6 +46,10 @@ type DuckDBCursor struct {
 
 	rows   <-chan map[string]interface{}
 	rowErr <-chan error
+
+	profile *CursorProfile
+	// The SQLite connection the cursor is opened on, to trace its queries
+	sqliteConn *sqlite3.SQLiteConn
 }
 
 func (m *DuckDBModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -249,16 +255,18 @@ func (m *DuckDBModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VT
 		tableName:        table,
 		schema:           internalSchema,
 		connectionString: connectionString,
+		profiler:         newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *DuckDBTable) Open() (sqlite3.VTabCursor, error) {
-	return &DuckDBCursor{
+	return t.profiler.wrap(&DuckDBCursor{
 		tableName:        t.tableName,
 		schema:           t.schema,
 		limit:            -1,
 		connectionString: t.connectionString,
-	}, nil
+		sqliteConn:       t.profiler.conn,
+	}), nil
 }
 
 func (t *DuckDBTable) Disconnect() error {
@@ -345,6 +353,10 @@ func (t *DuckDBCursor) resetCursor() error {
 	return nil
 }
 
+func (t *DuckDBCursor) setProfile(profile *CursorProfile) {
+	t.profile = profile
+}
+
 func (t *DuckDBCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Reset the cursor as Filter might be called multiple times
 	t.resetCursor()
@@ -388,13 +400,17 @@ func (t *DuckDBCursor) Filter(idxNum int, idxStr string, vals []interface{}) err
 	}
 
 	// Run the query
+	t.profile.roundTrip()
+	_, span := startReaderSpan(t.sqliteConn, "duckdb", interpolatedQuery)
 	rows, rowErr := duckdb.RunDuckDBQuery(t.connectionString, interpolatedQuery)
 	if len(rowErr) > 0 {
 		rowError := <-rowErr
 		if rowError != nil {
+			rpc.EndSpan(span, rowError)
 			return fmt.Errorf("error running the query: %v", rowError)
 		}
 	}
+	rpc.EndSpan(span, nil)
 	t.rows = rows
 	t.rowErr = rowErr
 
@@ -423,6 +439,13 @@ func (t *DuckDBCursor) Next() error {
 			return nil // No more rows to read
 		}
 		t.currentRow = row
+		if t.profile != nil {
+			values := make([]interface{}, 0, len(row))
+			for _, value := range row {
+				values = append(values, value)
+			}
+			t.profile.fetched(values)
+		}
 		if t.limit != -1 && t.rowsReturned >= t.limit {
 			t.exhausted = true
 			t.rows = nil // Clear the rows channel to indicate exhaustion
@@ -2,6 +2,7 @@ package module
 
 import (
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -16,12 +17,15 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/adrg/xdg"
 	"github.com/edsrzf/mmap-go"
 	"github.com/hashicorp/go-hclog"
+	"github.com/julien040/anyquery/rpc"
 	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // defaultMaxDownloadSize is the default cap on a remote fetch or the stdin
@@ -58,6 +62,8 @@ type Fetcher struct {
 	MaxBytes     int64  // default defaultMaxDownloadSize, or maxDownloadSizeEnv
 	CacheDir     string // default xdg.CacheHome/anyquery/downloads
 	Restrictions *Restrictions
+	// The parent of the span of each remote fetch, default context.Background()
+	Context context.Context
 }
 
 // NewFetcher returns a Fetcher with the default transport, size cap, and
@@ -171,6 +177,13 @@ func (f *Fetcher) cacheDir() string {
 	return filepath.Join(xdg.CacheHome, "anyquery", "downloads")
 }
 
+func (f *Fetcher) context() context.Context {
+	if f.Context != nil {
+		return f.Context
+	}
+	return context.Background()
+}
+
 func (f *Fetcher) httpClient() *http.Client {
 	if f.HTTP != nil {
 		return f.HTTP
@@ -555,13 +568,40 @@ func writeCacheMeta(cachePath string, m cacheMeta) {
 // A fresh entry never touches the network. A stale entry is revalidated when
 // the previous response left validators behind: a 304 refreshes the entry's
 // freshness stamp and reuses the bytes already on disk.
-func (f *Fetcher) fetchToCache(s Source, ttl time.Duration) (string, error) {
+//
+// Each call is traced in a span holding the redacted URL, whether the cache
+// was used, and the size of the entry.
+func (f *Fetcher) fetchToCache(s Source, ttl time.Duration) (cachePath string, err error) {
+	ctx, span := rpc.StartSpan(f.context(), "anyquery.fetch",
+		attribute.String("http.request.method", http.MethodGet),
+		attribute.String("url.full", redactedURL(s.URL).String()),
+	)
+	cacheStatus := "miss"
+	defer func() {
+		if err == nil {
+			switch cacheStatus {
+			case "hit":
+				fetchStats.cacheHits.Add(1)
+			case "revalidated":
+				fetchStats.cacheRevalidated.Add(1)
+			default:
+				fetchStats.cacheMisses.Add(1)
+			}
+		}
+		span.SetAttributes(attribute.String("anyquery.cache", cacheStatus))
+		if info, statErr := os.Stat(cachePath); err == nil && statErr == nil {
+			span.SetAttributes(attribute.Int64("anyquery.bytes", info.Size()))
+		}
+		rpc.EndSpan(span, err)
+	}()
+
 	dir := f.cacheDir()
-	cachePath := filepath.Join(dir, f.cacheKey(s))
+	cachePath = filepath.Join(dir, f.cacheKey(s))
 
 	var validators cacheMeta
 	if info, err := os.Stat(cachePath); err == nil && info.Size() > 0 {
 		if time.Since(info.ModTime()) < ttl {
+			cacheStatus = "hit"
 			return cachePath, nil
 		}
 		validators = readCacheMeta(cachePath)
@@ -570,18 +610,19 @@ func (f *Fetcher) fetchToCache(s Source, ttl time.Duration) (string, error) {
 		return "", fmt.Errorf("fetch: creating cache directory: %w", err)
 	}
 
-	got, err := f.fetchHTTP(s.URL, validators)
+	got, err := f.fetchHTTP(ctx, s.URL, validators)
 	if err != nil {
 		return "", err
 	}
 	if got.notModified {
 		now := time.Now()
 		if err := os.Chtimes(cachePath, now, now); err == nil {
+			cacheStatus = "revalidated"
 			return cachePath, nil
 		}
 		// The entry disappeared between the stat above and here, so there is
 		// nothing left to revalidate against: download it outright.
-		got, err = f.fetchHTTP(s.URL, cacheMeta{})
+		got, err = f.fetchHTTP(ctx, s.URL, cacheMeta{})
 		if err != nil {
 			return "", err
 		}
@@ -592,6 +633,9 @@ func (f *Fetcher) fetchToCache(s Source, ttl time.Duration) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if info, err := os.Stat(rawPath); err == nil {
+		fetchStats.bytesDownloaded.Add(info.Size())
+	}
 
 	finalPath := rawPath
 	if got.codec != codecNone {
@@ -611,6 +655,36 @@ func (f *Fetcher) fetchToCache(s Source, ttl time.Duration) (string, error) {
 	return cachePath, nil
 }
 
+// The counters of the remote fetches of all the Fetchers (see FetchStats)
+var fetchStats struct {
+	cacheHits        atomic.Int64
+	cacheRevalidated atomic.Int64
+	cacheMisses      atomic.Int64
+	bytesDownloaded  atomic.Int64
+}
+
+// FetcherStats are the counters of the remote fetches, e.g. to export them as metrics
+type FetcherStats struct {
+	// The fetches served from a fresh cache entry, without any request
+	CacheHits int64
+	// The fetches whose stale cache entry was still valid (304 Not Modified)
+	CacheRevalidated int64
+	// The fetches that downloaded the source
+	CacheMisses int64
+	// The bytes received, before decompression
+	BytesDownloaded int64
+}
+
+// FetchStats returns the counters of the remote fetches since the program started
+func FetchStats() FetcherStats {
+	return FetcherStats{
+		CacheHits:        fetchStats.cacheHits.Load(),
+		CacheRevalidated: fetchStats.cacheRevalidated.Load(),
+		CacheMisses:      fetchStats.cacheMisses.Load(),
+		BytesDownloaded:  fetchStats.bytesDownloaded.Load(),
+	}
+}
+
 // writeTempCounted copies up to limit+1 bytes from src into a fresh 0600 temp
 // file in dir, fsyncs it, and returns its path; the caller renames it into
 // place (an atomic publish), or removes it on error. Exceeding
@@ -745,8 +819,8 @@ type httpFetch struct {
 // fetchHTTP performs the GET for a KindHTTP source — the only remote transport.
 // A non-empty validators makes it a conditional request, so an unchanged
 // resource comes back as a 304 with no body.
-func (f *Fetcher) fetchHTTP(u *url.URL, validators cacheMeta) (httpFetch, error) {
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+func (f *Fetcher) fetchHTTP(ctx context.Context, u *url.URL, validators cacheMeta) (httpFetch, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return httpFetch{}, fmt.Errorf("fetch: building request: %w", err)
 	}
@@ -0,0 +1,177 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/edsrzf/mmap-go"
+	"github.com/hashicorp/go-hclog"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// The csv_reader and jsonl_reader tables support INSERT when they read a single local file:
+// each row is appended to the file, which makes anyquery usable as the last step of an ETL
+// without a shell redirection. The file is opened through Restrictions.OpenLocalForWrite,
+// so a sandboxed shell must allow writes to its directory.
+//
+// The rows are buffered until SQLite commits the transaction (the statement in autocommit mode),
+// and discarded if it rolls back: the modules implement sqlite3.TransactionModule for this.
+// In dry-run mode (see SetConnectionDryRun), the rows are recorded instead.
+//
+// The rows written are read back on the next scan of the table once committed, the file being mapped again.
+// The former mapping is kept until the table is disconnected, because a scan of
+// an INSERT INTO t SELECT ... FROM t may still read it.
+
+// fileAppender appends the rows inserted into a table to the file it reads
+type fileAppender struct {
+	// The connection of the table and its name in SQLite, to record the rows of a dry run
+	conn  *sqlite3.SQLiteConn
+	table string
+	// The source of the table, and whether it names several files
+	source   string
+	multiple bool
+	// Whether the file ends with a newline when the table is connected.
+	// If not, one is written before the first row
+	endsWithNewline bool
+	restrictions    *Restrictions
+
+	file *os.File
+	// The rows inserted in the transaction, written on commit
+	pending []byte
+	// Whether rows were appended since the file was last mapped
+	written bool
+	// The former mappings of the file, unmapped on close
+	stale []mmap.MMap
+}
+
+// newFileAppender returns the appender of a table reading files from source
+func newFileAppender(conn *sqlite3.SQLiteConn, args []string, source string, files []sourceFile, multiple bool, r *Restrictions) *fileAppender {
+	content := files[0].content
+	return &fileAppender{
+		conn:            conn,
+		table:           vtabName(args),
+		source:          source,
+		multiple:        multiple,
+		endsWithNewline: len(content) == 0 || content[len(content)-1] == '\n',
+		restrictions:    r,
+	}
+}
+
+// open opens the file for appending on the first insert,
+// and returns an error if the source of the table can't be written
+func (a *fileAppender) open() error {
+	if a.file != nil {
+		return nil
+	}
+	if a.multiple {
+		return errors.New("cannot insert into a table reading several files")
+	}
+	s, err := ParseSource(a.source)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.Kind == KindStdin:
+		return errors.New("cannot insert into a table reading stdin")
+	case s.Kind != KindLocal:
+		return errors.New("cannot insert into a remote file")
+	case codecForPath(s.Path) != codecNone:
+		return errors.New("cannot insert into a compressed file")
+	}
+
+	file, err := a.restrictions.OpenLocalForWrite(s.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open the file for writing: %w", err)
+	}
+	a.file = file
+	return nil
+}
+
+// insert buffers p, the encoded row vals, until the transaction is committed.
+// In dry-run mode, the row is recorded instead
+func (a *fileAppender) insert(vals []any, p []byte) (int64, error) {
+	// The file is opened even in dry-run mode so that a write denied by the sandbox fails the same way
+	if err := a.open(); err != nil {
+		return 0, err
+	}
+	if dryRun := connectionDryRun(a.conn); dryRun != nil {
+		return dryRun.record(DryRunWrite{Table: a.table, Operation: "INSERT", Values: vals}), nil
+	}
+	a.pending = append(a.pending, p...)
+	return 0, nil
+}
+
+// commit appends the rows buffered in the transaction to the file
+//
+// SQLite ignores the errors of xCommit: if the rows can't be written, they are discarded and the error is logged
+func (a *fileAppender) commit() error {
+	if len(a.pending) == 0 {
+		return nil
+	}
+	pending := a.pending
+	a.pending = nil
+	if !a.endsWithNewline {
+		pending = append([]byte{'\n'}, pending...)
+	}
+	// Even a partial write changes the content of the file
+	a.written = true
+	if _, err := a.file.Write(pending); err != nil {
+		hclog.Default().Error("failed to append the inserted rows to the file, they are discarded", "file", a.source, "error", err)
+		return fmt.Errorf("failed to write to the file: %w", err)
+	}
+	a.endsWithNewline = true
+	return nil
+}
+
+// rollback discards the rows buffered in the transaction
+func (a *fileAppender) rollback() {
+	a.pending = nil
+}
+
+// refresh maps the file again once rows were appended, and returns its new content.
+// The former mapping is kept until close
+func (a *fileAppender) refresh(file sourceFile) (sourceFile, error) {
+	content, err := openMmapedFile(context.Background(), file.name, a.restrictions, 0)
+	if err != nil {
+		return file, fmt.Errorf("failed to read the file again after the insertions: %w", err)
+	}
+	if file.mmap != nil {
+		a.stale = append(a.stale, file.mmap)
+	}
+	a.written = false
+	return sourceFile{name: file.name, content: content, mmap: content}, nil
+}
+
+// close closes the file and unmaps its former mappings
+func (a *fileAppender) close() error {
+	for _, m := range a.stale {
+		m.Unmap()
+	}
+	a.stale = nil
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// formatAppendedValue formats a value inserted into a text file like CSV
+func formatAppendedValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
@@ -1,6 +1,7 @@
 package module
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"time"
@@ -11,13 +12,15 @@ import (
 // openMmapedFile parses src (see ParseSource) and returns a mmap of it,
 // fetching and caching it first when it is remote. ttl bounds the freshness of
 // that cache entry, and therefore only affects a remote source: local sources
-// bypass the cache entirely (see module/fetch.go).
-func openMmapedFile(src string, r *Restrictions, ttl time.Duration) (mmap.MMap, error) {
+// bypass the cache entirely (see module/fetch.go). The span of the fetch is a child of the span of ctx.
+func openMmapedFile(ctx context.Context, src string, r *Restrictions, ttl time.Duration) (mmap.MMap, error) {
 	s, err := ParseSource(src)
 	if err != nil {
 		return nil, err
 	}
-	return NewFetcher(r).OpenMmap(s, ttl)
+	fetcher := NewFetcher(r)
+	fetcher.Context = ctx
+	return fetcher.OpenMmap(s, ttl)
 }
 
 // To make the argument parsing more readable,
@@ -0,0 +1,313 @@
+package module
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// A directory of files written by Hive, Spark or most data lakes is partitioned by
+// the values of a few columns, each one being a key=value segment of the path:
+// events/year=2026/month=10/part-0.parquet.
+//
+// When a reader reads several files (see expandSource), these segments become the
+// partition columns of the table, declared after the columns of the files.
+// BestIndex passes the constraints on them to Filter, which skips the files
+// whose partition values can't match. SQLite still checks the constraints on each row,
+// so a file is only skipped when it's certain none of its rows match.
+
+// hiveDefaultPartition is the value Hive writes for a NULL partition value
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+type hivePartitionColumn struct {
+	// The name of the column, made a valid SQLite name
+	name string
+	// INTEGER if every value of the column is an integer, TEXT otherwise
+	sqlType string
+}
+
+// hivePartitions holds the partition columns of the files of a table
+type hivePartitions struct {
+	columns []hivePartitionColumn
+	// values[file][column] is the value (int64, string or nil) of a partition column
+	// for a file. It's nil when the path of the file doesn't have the key
+	values [][]interface{}
+}
+
+// hivePartitionConstraint is a constraint on a partition column passed by BestIndex to Filter.
+// Column is the index of the partition column, not of the table column,
+// and Arg the index of the value of the constraint in the values of Filter
+type hivePartitionConstraint struct {
+	Column int        `json:"column"`
+	Op     sqlite3.Op `json:"op"`
+	Arg    int        `json:"arg"`
+}
+
+// discoverHivePartitions returns the partition columns found in the key=value segments
+// of the directories of paths, or nil if there are none.
+//
+// The keys colliding with a column of the files (fileColumns) are skipped,
+// because the value stored in the file wins
+func discoverHivePartitions(paths []string, fileColumns []string) *hivePartitions {
+	keys := []string{}
+	rawValues := make([]map[string]string, len(paths))
+	for i, p := range paths {
+		rawValues[i] = map[string]string{}
+		for _, segment := range strings.Split(filepath.ToSlash(filepath.Dir(p)), "/") {
+			key, value, ok := strings.Cut(segment, "=")
+			if !ok || key == "" {
+				continue
+			}
+			// Hive escapes the special characters of the values, like a URL
+			if unescaped, err := url.PathUnescape(value); err == nil {
+				value = unescaped
+			}
+			name := transformSQLiteValidName(key)
+			if _, ok := rawValues[i][name]; !ok && !containsFold(keys, name) && !containsFold(fileColumns, name) {
+				keys = append(keys, name)
+			}
+			rawValues[i][name] = value
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	partitions := &hivePartitions{
+		columns: make([]hivePartitionColumn, len(keys)),
+		values:  make([][]interface{}, len(paths)),
+	}
+	for j, key := range keys {
+		partitions.columns[j] = hivePartitionColumn{name: key, sqlType: "INTEGER"}
+		for i := range paths {
+			value, ok := rawValues[i][key]
+			if !ok || value == hiveDefaultPartition {
+				continue
+			}
+			if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+				partitions.columns[j].sqlType = "TEXT"
+				break
+			}
+		}
+	}
+
+	for i := range paths {
+		partitions.values[i] = make([]interface{}, len(keys))
+		for j, col := range partitions.columns {
+			value, ok := rawValues[i][col.name]
+			if !ok || value == hiveDefaultPartition {
+				continue
+			}
+			if col.sqlType == "INTEGER" {
+				parsed, _ := strconv.ParseInt(value, 10, 64)
+				partitions.values[i][j] = parsed
+			} else {
+				partitions.values[i][j] = value
+			}
+		}
+	}
+	return partitions
+}
+
+// containsFold reports whether names holds name, case-insensitively like SQLite column names
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// declare writes the definitions of the partition columns, each one preceded by a comma
+func (p *hivePartitions) declare(b *strings.Builder) {
+	if p == nil {
+		return
+	}
+	for _, col := range p.columns {
+		b.WriteString(", `" + col.name + "` " + col.sqlType)
+	}
+}
+
+// names returns the names of the partition columns
+func (p *hivePartitions) names() []string {
+	if p == nil {
+		return nil
+	}
+	names := make([]string, len(p.columns))
+	for i, col := range p.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+// resultColumn sets the result of context to the value of the partition column col
+// for a file, and reports whether col is a partition column. firstColumn is the position
+// of the first partition column in the table
+func (p *hivePartitions) resultColumn(context *sqlite3.SQLiteContext, file int, col int, firstColumn int) bool {
+	if p == nil || col < firstColumn || col >= firstColumn+len(p.columns) {
+		return false
+	}
+	switch value := p.values[file][col-firstColumn].(type) {
+	case int64:
+		context.ResultInt64(value)
+	case string:
+		context.ResultText(value)
+	default:
+		context.ResultNull()
+	}
+	return true
+}
+
+// bestIndex picks the constraints on the partition columns that can skip files.
+// It returns nil if the table has no partition column
+func (p *hivePartitions) bestIndex(cst []sqlite3.InfoConstraint, firstColumn int) (*sqlite3.IndexResult, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	used := make([]bool, len(cst))
+	constraints := []hivePartitionConstraint{}
+	for i, c := range cst {
+		if p.usable(c, firstColumn) {
+			used[i] = true
+			constraints = append(constraints, hivePartitionConstraint{Column: c.Column - firstColumn, Op: c.Op, Arg: len(constraints)})
+		}
+	}
+
+	marshal, err := json.Marshal(constraints)
+	if err != nil {
+		return nil, errors.Join(errors.New("could not marshal the partition constraints"), err)
+	}
+	// A plan skipping files must be preferred over a full scan,
+	// e.g. for SQLite to run the scan once per value of an IN list
+	return &sqlite3.IndexResult{
+		IdxStr:        string(marshal),
+		Used:          used,
+		EstimatedCost: 1e6 / math.Pow(10, float64(len(constraints))),
+	}, nil
+}
+
+// usable reports whether the constraint c of BestIndex is a comparison of
+// a partition column that can skip files
+//
+// The comparisons of a TEXT column are left to SQLite: their collation (e.g. NOCASE)
+// isn't known to BestIndex, and a byte-wise comparison would skip files that match
+func (p *hivePartitions) usable(c sqlite3.InfoConstraint, firstColumn int) bool {
+	if p == nil || !c.Usable || c.Column < firstColumn || c.Column >= firstColumn+len(p.columns) {
+		return false
+	}
+	if p.columns[c.Column-firstColumn].sqlType != "INTEGER" {
+		return false
+	}
+	return isRangeOp(c.Op)
+}
+
+// selectFiles returns the indexes of the files matching the partition constraints
+// serialized by bestIndex in idxStr, with their values in vals.
+// All the files are returned if the table has no partition column
+func (p *hivePartitions) selectFiles(fileCount int, idxStr string, vals []interface{}) ([]int, error) {
+	constraints := []hivePartitionConstraint{}
+	if p != nil && idxStr != "" {
+		if err := json.Unmarshal([]byte(idxStr), &constraints); err != nil {
+			return nil, errors.Join(errors.New("could not unmarshal the partition constraints"), err)
+		}
+	}
+	return p.matchingFiles(fileCount, constraints, vals)
+}
+
+// matchingFiles returns the indexes of the files whose partition values may match constraints
+func (p *hivePartitions) matchingFiles(fileCount int, constraints []hivePartitionConstraint, vals []interface{}) ([]int, error) {
+	selected := make([]int, 0, fileCount)
+	for file := 0; file < fileCount; file++ {
+		matches := true
+		for _, c := range constraints {
+			if c.Arg >= len(vals) {
+				return nil, errors.New("missing values for the partition constraints")
+			}
+			value := p.values[file][c.Column]
+			if !rangeMayMatch(value, value, c.Op, vals[c.Arg]) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			selected = append(selected, file)
+		}
+	}
+	return selected, nil
+}
+
+// isRangeOp reports whether op is one of the comparisons checked by rangeMayMatch
+func isRangeOp(op sqlite3.Op) bool {
+	switch op {
+	case sqlite3.OpEQ, sqlite3.OpGT, sqlite3.OpGE, sqlite3.OpLT, sqlite3.OpLE:
+		return true
+	}
+	return false
+}
+
+// rangeMayMatch reports whether a value between min and max (int64 or float64)
+// may match the constraint "column op arg", min being equal to max for a single value.
+// It's true when it can't be decided, e.g. when the types differ
+func rangeMayMatch(min interface{}, max interface{}, op sqlite3.Op, arg interface{}) bool {
+	// A comparison with NULL is never true
+	if min == nil || max == nil || arg == nil {
+		return false
+	}
+
+	minOrder, ok := compareConstraintValue(min, arg)
+	if !ok {
+		return true
+	}
+	maxOrder, ok := compareConstraintValue(max, arg)
+	if !ok {
+		return true
+	}
+
+	switch op {
+	case sqlite3.OpEQ:
+		return minOrder <= 0 && maxOrder >= 0
+	case sqlite3.OpGT:
+		return maxOrder > 0
+	case sqlite3.OpGE:
+		return maxOrder >= 0
+	case sqlite3.OpLT:
+		return minOrder < 0
+	case sqlite3.OpLE:
+		return minOrder <= 0
+	}
+	return true
+}
+
+// compareConstraintValue compares value with the value of a constraint like SQLite would,
+// and reports whether it could
+func compareConstraintValue(value interface{}, arg interface{}) (int, bool) {
+	switch value := value.(type) {
+	case int64:
+		switch arg := arg.(type) {
+		case int64:
+			return cmp.Compare(value, arg), true
+		case float64:
+			return cmp.Compare(float64(value), arg), true
+		}
+	case float64:
+		if math.IsNaN(value) {
+			return 0, false
+		}
+		switch arg := arg.(type) {
+		case int64:
+			return cmp.Compare(value, float64(arg)), true
+		case float64:
+			return cmp.Compare(value, arg), true
+		}
+	}
+	return 0, false
+}
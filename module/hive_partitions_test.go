@@ -0,0 +1,87 @@
+package module
+
+import (
+	"path/filepath"
+	"testing"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverHivePartitions(t *testing.T) {
+	paths := []string{
+		filepath.Join("lake", "events", "year=2025", "month=12", "region=eu%2Fwest", "part-0.parquet"),
+		filepath.Join("lake", "events", "year=2026", "month=01", "region=us", "part-0.parquet"),
+		filepath.Join("lake", "events", "year=2026", "month=__HIVE_DEFAULT_PARTITION__", "part-0.parquet"),
+	}
+
+	partitions := discoverHivePartitions(paths, []string{"id", "Region"})
+	require.NotNil(t, partitions)
+	require.Equal(t, []hivePartitionColumn{
+		{name: "year", sqlType: "INTEGER"},
+		{name: "month", sqlType: "INTEGER"},
+	}, partitions.columns, "a key colliding with a column of the files must be skipped")
+	require.Equal(t, [][]interface{}{
+		{int64(2025), int64(12)},
+		{int64(2026), int64(1)},
+		{int64(2026), nil},
+	}, partitions.values)
+
+	partitions = discoverHivePartitions(paths, nil)
+	require.Equal(t, "TEXT", partitions.columns[2].sqlType)
+	require.Equal(t, "eu/west", partitions.values[0][2], "the values must be unescaped")
+	require.Nil(t, partitions.values[2][2], "a missing key must be NULL")
+
+	require.Nil(t, discoverHivePartitions([]string{filepath.Join("data", "a.csv"), filepath.Join("data", "b.csv")}, nil))
+}
+
+func TestHivePartitionsSelectFiles(t *testing.T) {
+	partitions := discoverHivePartitions([]string{
+		"/lake/year=2025/month=12/a.csv",
+		"/lake/year=2026/month=1/a.csv",
+		"/lake/year=2026/month=2/a.csv",
+		"/lake/year=2026/a.csv",
+	}, []string{"id"})
+	// The table is (id, year, month)
+	firstColumn := 1
+
+	res, err := partitions.bestIndex([]sqlite3.InfoConstraint{
+		{Column: 0, Op: sqlite3.OpEQ, Usable: true},
+		{Column: 1, Op: sqlite3.OpEQ, Usable: true},
+		{Column: 2, Op: sqlite3.OpGE, Usable: true},
+		{Column: 2, Op: sqlite3.OpLT, Usable: false},
+		{Column: 2, Op: sqlite3.OpLIKE, Usable: true},
+	}, firstColumn)
+	require.NoError(t, err)
+	require.Equal(t, []bool{false, true, true, false, false}, res.Used, "only the usable comparisons on a partition column are used")
+
+	tests := []struct {
+		name     string
+		vals     []interface{}
+		expected []int
+	}{
+		{"year = 2026 and month >= 2", []interface{}{int64(2026), int64(2)}, []int{2}},
+		{"year = 2026 and month >= 1", []interface{}{int64(2026), int64(1)}, []int{1, 2}},
+		{"year = 2024 and month >= 1", []interface{}{int64(2024), int64(1)}, []int{}},
+		{"float values", []interface{}{2025.5, 1.5}, []int{}},
+		{"text values can't be decided", []interface{}{"2026", "x"}, []int{0, 1, 2}},
+		{"comparisons with NULL are never true", []interface{}{nil, int64(1)}, []int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			selected, err := partitions.selectFiles(4, res.IdxStr, test.vals)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, selected)
+		})
+	}
+
+	text := discoverHivePartitions([]string{"/lake/region=eu/a.csv", "/lake/region=us/a.csv"}, []string{"id"})
+	res, err = text.bestIndex([]sqlite3.InfoConstraint{{Column: 1, Op: sqlite3.OpEQ, Usable: true}}, firstColumn)
+	require.NoError(t, err)
+	require.Equal(t, []bool{false}, res.Used, "a TEXT partition column must not skip files, its collation being unknown")
+
+	var none *hivePartitions
+	selected, err := none.selectFiles(3, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2}, selected, "all the files must be read without partitions")
+}
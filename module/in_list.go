@@ -0,0 +1,41 @@
+package module
+
+import (
+	"github.com/julien040/anyquery/rpc"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// # IN constraints
+//
+// SQLite splits a constraint like id IN (1, 2, 3) into three equal constraints
+// and calls Filter once per value, unless the virtual table asks for all the values at once (sqlite3_vtab_in).
+//
+// For the plugins setting rpc.DatabaseSchema.HandlesIn, BestIndex asks for the values of the IN constraints at once
+// (see sqlite3.IndexResult.In), and Filter sends them as a single rpc.OperatorIn constraint:
+// the plugin is queried once per list rather than once per value.
+// SQLite still evaluates the IN constraint on the returned rows.
+//
+// The values of an IN constraint on a required parameter are still sent one by one,
+// as the plugin expects a single value for it.
+
+// inConstraint reports whether the values of the IN constraint c are requested at once
+// and sent to the plugin as an rpc.OperatorIn constraint
+func inConstraint(c sqlite3.InfoConstraint, schema rpc.DatabaseSchema) bool {
+	if !c.In || !schema.HandlesIn || c.Column < 0 || c.Column >= len(schema.Columns) {
+		return false
+	}
+	return !schema.Columns[c.Column].IsRequired
+}
+
+// loadInValues sets the values of an rpc.OperatorIn constraint from the value passed by SQLite to Filter
+//
+// If SQLite passed a single value, the constraint is an rpc.OperatorEqual constraint
+func loadInValues(cst *rpc.ColumnConstraint, value interface{}) {
+	list, ok := value.(sqlite3.InValues)
+	if !ok {
+		cst.Operator = rpc.OperatorEqual
+		cst.Value = value
+		return
+	}
+	cst.Value = []interface{}(list)
+}
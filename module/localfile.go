@@ -175,3 +175,47 @@ func (r *Restrictions) ReadLocalFile(p string) ([]byte, error) {
 	defer f.Close()
 	return io.ReadAll(f)
 }
+
+// OpenLocalForWrite opens p for writing with flag and perm (see os.OpenFile),
+// confined to r.AllowedDirs like OpenLocal. r == nil means unrestricted.
+//
+// Writing needs r.AllowWrite on top of the containment, and the error
+// contract is OpenLocal's: only a refusal by the policy starts with
+// "sandbox: ". Creating p requires its parent directory to exist.
+func (r *Restrictions) OpenLocalForWrite(p string, flag int, perm os.FileMode) (*os.File, error) {
+	if r == nil {
+		return os.OpenFile(p, flag, perm)
+	}
+	if !r.AllowWrite {
+		return nil, fmt.Errorf("sandbox: writing to %q is not allowed (enable with --allow-write)", p)
+	}
+	if strings.TrimSpace(p) == "" {
+		return nil, fmt.Errorf("sandbox: empty file path is not allowed")
+	}
+	target, err := filepath.Abs(p)
+	if err != nil {
+		target = p
+	}
+	resolvedTarget := resolveHint(p)
+
+	var lastErr error
+	for _, d := range r.buildAllowedDirs() {
+		rel, ok := relWithin(d.given, target)
+		if !ok {
+			rel, ok = relWithin(d.resolved, resolvedTarget)
+		}
+		// The directory itself can't be written as a file
+		if !ok || rel == "." {
+			continue
+		}
+		f, err := d.root.OpenFile(rel, flag, perm)
+		if err == nil {
+			return f, nil
+		}
+		lastErr = err
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("sandbox: writing to %q is not allowed; permitted directories: %v", p, r.AllowedDirs)
+}
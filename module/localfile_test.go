@@ -311,3 +311,52 @@ func TestOpenLocalEmptyAllowedDirsLocksDown(t *testing.T) {
 		t.Fatalf("zero-value Restrictions permitted a local read")
 	}
 }
+
+func TestOpenLocalForWrite(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	target := filepath.Join(allowed, "out.csv")
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+
+	r := &Restrictions{AllowedDirs: []string{allowed}}
+	if _, err := r.OpenLocalForWrite(target, flag, 0o600); err == nil || !strings.HasPrefix(err.Error(), "sandbox: ") {
+		t.Fatalf("writing without AllowWrite must be denied by the policy, got: %v", err)
+	}
+
+	r = &Restrictions{AllowedDirs: []string{allowed}, AllowWrite: true}
+	f, err := r.OpenLocalForWrite(target, flag, 0o600)
+	if err != nil {
+		t.Fatalf("OpenLocalForWrite inside the allowed dir: %v", err)
+	}
+	if _, err := f.WriteString("id\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if content, _ := os.ReadFile(target); string(content) != "id\n" {
+		t.Fatalf("unexpected content: %q", content)
+	}
+
+	for _, p := range []string{filepath.Join(outside, "out.csv"), allowed} {
+		if _, err := r.OpenLocalForWrite(p, flag, 0o600); err == nil || !strings.HasPrefix(err.Error(), "sandbox: ") {
+			t.Fatalf("writing %q must be denied by the policy, got: %v", p, err)
+		}
+	}
+
+	// A missing parent directory is an OS error, not a refusal
+	_, err = r.OpenLocalForWrite(filepath.Join(allowed, "missing", "out.csv"), flag, 0o600)
+	if err == nil || strings.HasPrefix(err.Error(), "sandbox: ") {
+		t.Fatalf("expected an OS error for a missing parent directory, got: %v", err)
+	}
+
+	// A symlink planted inside the allowed dir can't be written through
+	if err := os.Symlink(filepath.Join(outside, "pwned"), filepath.Join(allowed, "link")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	if f, err := r.OpenLocalForWrite(filepath.Join(allowed, "link"), flag, 0o600); err == nil {
+		f.Close()
+		t.Fatalf("a symlink escaping the allowed dir was written through")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Fatalf("a file was created outside the allowed dir")
+	}
+}
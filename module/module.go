@@ -1,6 +1,7 @@
 package module
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,6 +40,11 @@ type SQLiteModule struct {
 	Logger          hclog.Logger
 	ConnectionPool  *rpc.ConnectionPool
 	Stderr          io.Writer
+	// The resource limits of the process of the plugin, set in its profile
+	Limits rpc.ResourceLimits
+	// How long the rows of a query are cached, set in the profile.
+	// If zero, the duration of the schema is used (rpc.DatabaseSchema.CacheTTL). If negative, the rows are not cached
+	CacheTTL time.Duration
 
 	// Metadata used to describe the table for LLMs
 	Metadata rpc.TableMetadata
@@ -74,6 +80,9 @@ type SQLiteTable struct {
 	mapColPositionColPlugin map[int]int // Map the position of the column in SQLite to the position of the column in the rows returned by the plugin
 	logger                  hclog.Logger
 	partialUpdate           bool
+	poolKey                 string        // The key of the client in the connection pool (see rpc.NewClientParams.Key)
+	cacheTTL                time.Duration // How long the rows of a query are cached (see query_cache.go)
+	cacheID                 string        // The key of the table in the query cache (see queryCacheTableID)
 }
 
 // SQLiteCursor holds the information needed for the Column, Filter, EOF and Next methods
@@ -89,6 +98,35 @@ type SQLiteCursor struct {
 	constraints             rpc.QueryConstraint
 	mapColPositionColPlugin map[int]int // Map the position of the column in SQLite to the position of the column in the rows returned by the plugin
 	logger                  hclog.Logger
+	stream                  rpc.RowStream       // The stream of rows if the table streams its rows (see rpc.DatabaseSchema.StreamRows)
+	conn                    *sqlite3.SQLiteConn // The SQLite connection running the query, used to get its context (see SetConnectionContext)
+	profile                 *CursorProfile      // The metrics of the cursor if its connection is profiled (see SetConnectionProfile)
+	name                    string              // The name of the table in SQLite
+	cacheTTL                time.Duration       // How long the rows of a query are cached, 0 if they are not
+	cacheID                 string              // The key of the table in the query cache
+	cacheKey                queryCacheKey       // The key of the current query in the query cache
+	cacheGeneration         uint64              // The generation of the query cache when the query started
+	cachedRows              [][]interface{}     // The rows read so far, stored in the query cache once the plugin returned all of them
+	caching                 bool                // Whether the rows of the current query are collected for the query cache
+}
+
+// sqliteTableConnection is the virtual table returned to a SQLite connection
+//
+// All the connections share the same SQLiteTable, but the cursors must know
+// on which connection they run to get the context of the query
+type sqliteTableConnection struct {
+	*SQLiteTable
+	conn     *sqlite3.SQLiteConn
+	name     string // The name of the table in SQLite
+	profiler cursorProfiler
+}
+
+func (t *sqliteTableConnection) Open() (sqlite3.VTabCursor, error) {
+	cursor, err := t.SQLiteTable.open(t.conn, t.name)
+	if err != nil {
+		return nil, err
+	}
+	return t.profiler.wrap(cursor), nil
 }
 
 type updateItem struct {
@@ -115,40 +153,93 @@ func (m *SQLiteModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 	if m.moduleInited {
 		m.Logger.Debug("Module already initialized")
 		c.DeclareVTab(m.schema)
-		return m.Table, nil
+		return &sqliteTableConnection{m.Table, c, vtabName(args), newCursorProfiler(c, args)}, nil
+	}
+
+	err := m.init(connectionContext(c))
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the schema in SQLite
+	err = c.DeclareVTab(m.schema)
+	if err != nil {
+		return nil, errors.Join(errors.New("could not declare the virtual table in SQLite"), err, errors.New("Schema: "+m.schema))
+	}
+
+	return &sqliteTableConnection{m.Table, c, vtabName(args), newCursorProfiler(c, args)}, nil
+}
+
+// vtabName returns the name of the virtual table from the arguments of Create
+//
+// SQLite passes the name of the module, the name of the database and the name of the table
+func vtabName(args []string) string {
+	if len(args) > 2 {
+		return args[2]
+	}
+	if len(args) > 0 {
+		return args[0]
+	}
+	return ""
+}
+
+// Schema returns the schema of the table, starting the plugin if needed
+func (m *SQLiteModule) Schema() (rpc.DatabaseSchema, error) {
+	err := m.init(context.Background())
+	if err != nil {
+		return rpc.DatabaseSchema{}, err
+	}
+	return m.Table.Schema, nil
+}
+
+// init starts the plugin and requests the schema of the table
+// unless the module is already initialized
+//
+// The span of the call to the plugin is a child of the span of ctx
+func (m *SQLiteModule) init(ctx context.Context) error {
+	if m.moduleInited {
+		return nil
 	}
+
 	// Create a new plugin instance
 	// and store the client in the module
-	rpcClient, err := m.ConnectionPool.NewClient(rpc.NewClientParams{
+	clientParams := rpc.NewClientParams{
 		ExecutableLocation: m.PluginPath,
 		ExecutableArg:      m.PluginArgs,
 		Logger:             m.Logger,
 		Stderr:             m.Stderr,
-	})
+		Protocol:           m.PluginManifest.Protocol,
+		Limits:             m.Limits,
+		Capabilities:       m.PluginManifest.Capabilities,
+	}
+	rpcClient, err := m.ConnectionPool.NewClient(clientParams)
 	if err != nil {
 		m.Logger.Error("could not create a new rpc client", "error", err, "plugin", m.PluginPath)
-		return nil, errors.Join(errors.New("could not create a new rpc client for "+m.PluginPath), err)
+		return errors.Join(errors.New("could not create a new rpc client for "+m.PluginPath), err)
 	}
 	m.client = rpcClient
 
 	// Request the schema of the table from the plugin
-	dbSchema, err := m.client.Plugin.Initialize(m.ConnectionIndex, m.TableIndex, m.UserConfig)
+	var dbSchema rpc.DatabaseSchema
+	if plugin, ok := m.client.Plugin.(rpc.InternalInitializeContextInterface); ok {
+		dbSchema, err = plugin.InitializeContext(ctx, m.ConnectionIndex, m.TableIndex, m.UserConfig)
+	} else {
+		dbSchema, err = m.client.Plugin.Initialize(m.ConnectionIndex, m.TableIndex, m.UserConfig)
+	}
 	if err != nil {
 		m.Logger.Error("could not request the schema of the table from the plugin", "error", err, "table", m.TableIndex, "connection", m.ConnectionIndex, "plugin", m.PluginPath)
-		return nil, errors.Join(errors.New("could not request the schema of the table from the plugin "+m.PluginPath), err)
+		return errors.Join(errors.New("could not request the schema of the table from the plugin "+m.PluginPath), err)
 	}
 
 	// Verify that the schema is correct
 	if len(dbSchema.Columns) == 0 {
 		m.Logger.Error("the schema of the table is empty", "table", m.TableIndex, "connection", m.ConnectionIndex, "plugin", m.PluginPath)
-		return nil, errors.New("the schema of the table is empty")
+		return errors.New("the schema of the table is empty")
 	}
 
-	// Create the schema in SQLite
 	stringSchema, err := createSQLiteSchema(dbSchema)
-	err = c.DeclareVTab(stringSchema)
 	if err != nil {
-		return nil, errors.Join(errors.New("could not declare the virtual table in SQLite"), err, errors.New("Schema: "+stringSchema))
+		return errors.Join(errors.New("could not create the schema of the table"), err)
 	}
 	m.schema = stringSchema
 
@@ -166,6 +257,12 @@ func (m *SQLiteModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		}
 	}
 
+	// The profile can override how long the rows are cached
+	cacheTTL := dbSchema.CacheTTL
+	if m.CacheTTL != 0 {
+		cacheTTL = m.CacheTTL
+	}
+
 	// Initialize a new table
 	table := &SQLiteTable{
 		m.PluginPath,
@@ -190,6 +287,9 @@ func (m *SQLiteModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		colMapper,
 		m.Logger,
 		dbSchema.PartialUpdate,
+		clientParams.Key(),
+		cacheTTL,
+		queryCacheTableID(m.PluginPath, m.ConnectionIndex, m.TableIndex, m.UserConfig),
 	}
 	m.Table = table
 	m.moduleInited = true
@@ -204,7 +304,7 @@ func (m *SQLiteModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		}
 	}()
 
-	return table, nil
+	return nil
 }
 
 // createSQLiteSchema creates the schema of the table in SQLite
@@ -327,7 +427,12 @@ func (t *SQLiteTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoO
 	// Used is a boolean array that tells SQLite which constraints are used
 	// and that must be passed to the Filter method in the vals field
 	used := make([]bool, len(cst))
-	parseConstraintsFromSQLite(cst, ob, &constraints, used, t.Schema)
+	// In tells SQLite which IN constraints must be passed at once (see in_list.go)
+	in := make([]bool, len(cst))
+	parseConstraintsFromSQLite(cst, ob, &constraints, used, in, t.Schema)
+
+	// Tell the plugin which columns SQLite reads so that it can skip the others
+	constraints.ColumnsUsed = columnsUsedFromMask(info.ColUsed, len(t.Schema.Columns))
 
 	// We store the constraints as JSON to be passed with IdxStr in IndexResult
 	marshal, err := json.Marshal(constraints)
@@ -335,10 +440,16 @@ func (t *SQLiteTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoO
 		return nil, errors.Join(errors.New("could not marshal the constraints"), err)
 	}
 
+	// Help SQLite to pick the order of the tables in a join
+	cost, rows := estimateQuery(t.Schema, constraints)
+
 	return &sqlite3.IndexResult{
-		IdxNum: 0,
-		IdxStr: string(marshal),
-		Used:   used,
+		IdxNum:        0,
+		IdxStr:        string(marshal),
+		Used:          used,
+		In:            in,
+		EstimatedCost: cost,
+		EstimatedRows: rows,
 	}, nil
 
 }
@@ -347,6 +458,11 @@ func (t *SQLiteTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoO
 //
 // It should return a new cursor
 func (t *SQLiteTable) Open() (sqlite3.VTabCursor, error) {
+	return t.open(nil, "")
+}
+
+// open creates a new cursor of the table named name, running its queries on the SQLite connection conn
+func (t *SQLiteTable) open(conn *sqlite3.SQLiteConn, name string) (*SQLiteCursor, error) {
 	// For coherence, we flush the buffers of inserts, updates and deletes
 	// before running any SELECT query
 	// If any of the flush fails, we return an error and therefore stop the query
@@ -380,6 +496,16 @@ func (t *SQLiteTable) Open() (sqlite3.VTabCursor, error) {
 		rpc.QueryConstraint{},
 		t.mapColPositionColPlugin,
 		t.logger,
+		nil,
+		conn,
+		nil,
+		name,
+		t.cacheTTL,
+		t.cacheID,
+		queryCacheKey{},
+		0,
+		nil,
+		false,
 	}
 	// We increment the cursor id for the next cursor by 1
 	// so that the next cursor will have a different id
@@ -447,13 +573,33 @@ func (t *SQLiteTable) FlushBuffers() error {
 	return nil
 }
 
-func (t *SQLiteTable) Insert(id any, vals []any) (int64, error) {
+// checkWrite returns an error if the table does not support the statement (INSERT, UPDATE or DELETE)
+func (t *SQLiteTable) checkWrite(statement string) error {
 	if t.Schema.PrimaryKey == -1 {
-		return 0, errors.New("the table does not support INSERT because it has no primary key")
+		return errors.New("the table does not support " + statement + " because it has no primary key")
 	}
 
-	if !t.Schema.HandlesInsert {
-		return 0, errors.New("the table does not support INSERT")
+	var handled bool
+	switch statement {
+	case "INSERT":
+		handled = t.Schema.HandlesInsert
+	case "UPDATE":
+		handled = t.Schema.HandlesUpdate
+	case "DELETE":
+		handled = t.Schema.HandlesDelete
+	}
+	if !handled {
+		return errors.New("the table does not support " + statement)
+	}
+	return nil
+}
+
+func (t *SQLiteTable) Insert(id any, vals []any) (int64, error) {
+	if err := t.checkWrite("INSERT"); err != nil {
+		return 0, err
+	}
+	if t.cacheTTL > 0 {
+		defaultQueryCache.purgeTable(t.cacheID)
 	}
 
 	// We add the row to the buffer
@@ -477,12 +623,11 @@ func (t *SQLiteTable) Insert(id any, vals []any) (int64, error) {
 }
 
 func (t *SQLiteTable) Update(id any, vals []any) error {
-	if t.Schema.PrimaryKey == -1 {
-		return errors.New("the table does not support UPDATE because it has no primary key")
+	if err := t.checkWrite("UPDATE"); err != nil {
+		return err
 	}
-
-	if !t.Schema.HandlesUpdate {
-		return errors.New("the table does not support UPDATE")
+	if t.cacheTTL > 0 {
+		defaultQueryCache.purgeTable(t.cacheID)
 	}
 
 	t.updateBuffer.PushBack(updateItem{id, vals})
@@ -500,12 +645,11 @@ func (t *SQLiteTable) Update(id any, vals []any) error {
 }
 
 func (t *SQLiteTable) Delete(id any) error {
-	if t.Schema.PrimaryKey == -1 {
-		return errors.New("the table does not support DELETE because it has no primary key")
+	if err := t.checkWrite("DELETE"); err != nil {
+		return err
 	}
-
-	if !t.Schema.HandlesDelete {
-		return errors.New("the table does not support DELETE")
+	if t.cacheTTL > 0 {
+		defaultQueryCache.purgeTable(t.cacheID)
 	}
 
 	t.deleteBuffer.PushBack(id)
@@ -605,7 +749,25 @@ func (t *SQLiteTable) flushDelete() error {
 }
 
 // Close is called when the cursor is no longer needed
-func (c *SQLiteCursor) Close() error { return nil }
+func (c *SQLiteCursor) Close() error {
+	// If the cursor is streaming rows, we tell the plugin to stop producing them
+	if c.stream != nil {
+		c.stream.Close()
+		c.stream = nil
+	}
+	c.closePluginCursor()
+	return nil
+}
+
+// closePluginCursor tells the client that the rows of the cursor on the plugin side won't be requested anymore
+func (c *SQLiteCursor) closePluginCursor() {
+	if c.client == nil {
+		return
+	}
+	if closer, ok := c.client.Plugin.(rpc.InternalCursorCloseInterface); ok {
+		closer.CloseCursor(c.connectionIndex, c.tableIndex, c.cursorIndex)
+	}
+}
 
 // These methods are not used in this plugin
 func (v *SQLiteTable) Disconnect() error {
@@ -616,7 +778,7 @@ func (v *SQLiteTable) Disconnect() error {
 	v.flushDelete()
 	time.Sleep(30 * time.Millisecond)
 	// We close the client
-	v.ConnectionPool.CloseConnection(v.PluginPath, v.connectionIndex)
+	v.ConnectionPool.CloseConnection(v.poolKey, v.connectionIndex)
 	return nil
 }
 func (v *SQLiteTable) Destroy() error {
@@ -625,7 +787,7 @@ func (v *SQLiteTable) Destroy() error {
 func (v *SQLiteModule) DestroyModule() {
 	// When a plugin was wrongly initialized and the module is destroyed
 	// destroyModule is called rather than Disconnect
-	v.ConnectionPool.CloseConnection(v.PluginPath, v.ConnectionIndex)
+	v.ConnectionPool.CloseConnection(rpc.NewClientParams{ExecutableLocation: v.PluginPath, Limits: v.Limits}.Key(), v.ConnectionIndex)
 }
 
 // Column is called when a column is queried
@@ -849,6 +1011,10 @@ func (c *SQLiteCursor) Rowid() (int64, error) {
 	return id, nil
 }
 
+func (c *SQLiteCursor) setProfile(profile *CursorProfile) {
+	c.profile = profile
+}
+
 func (c *SQLiteCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Filter can be called several times with the same cursor
 	// Each time, it is supposed to reset the cursor to the beginning
@@ -869,6 +1035,34 @@ func (c *SQLiteCursor) Filter(idxNum int, idxStr string, vals []interface{}) err
 		return errors.Join(errors.New("could not load the constraints"), err)
 	}
 
+	// If the table caches its rows, we answer the query from the cache when possible
+	if c.cacheTTL > 0 {
+		c.cacheKey = queryCacheKey{table: c.cacheID, hash: c.constraints.Hash()}
+		rows, generation, ok := defaultQueryCache.get(c.cacheKey, c.name)
+		if ok {
+			for _, row := range rows {
+				c.rows.PushBack(row)
+			}
+			c.noMoreRows = true
+			return nil
+		}
+		c.caching = true
+		c.cacheGeneration = generation
+	}
+
+	// If the plugin streams the rows of the table, we open a stream for the cursor
+	// and the rows will be read one by one from it
+	if c.schema.StreamRows {
+		if streamer, ok := c.client.Plugin.(rpc.InternalStreamInterface); ok {
+			c.profile.roundTrip()
+			c.stream, err = streamer.QueryStream(connectionContext(c.conn), c.connectionIndex, c.tableIndex, c.cursorIndex, c.constraints)
+			if err != nil {
+				c.logger.Error("could not open a stream of rows from the plugin", "error", err, "table", c.tableIndex, "connection", c.connectionIndex)
+				return errors.Join(errors.New("could not open a stream of rows from the plugin"), err)
+			}
+		}
+	}
+
 	// We request the rows from the plugin
 	_, err = c.requestRowsFromPlugin()
 	if err != nil {
@@ -888,25 +1082,25 @@ func (cursor *SQLiteCursor) requestRowsFromPlugin() (int, error) {
 		return 0, errors.New("requestRowsFromPlugin was called but plugin has no more rows")
 	}
 
+	if cursor.stream != nil {
+		return cursor.requestRowsFromStream()
+	}
+
+	ctx := connectionContext(cursor.conn)
+
 	// We request the rows from the plugin
-	rows, noMoreRows, err := cursor.client.Plugin.Query(cursor.connectionIndex, cursor.tableIndex, cursor.cursorIndex, cursor.constraints)
+	rows, noMoreRows, err := cursor.queryPlugin(ctx)
 	if err != nil {
-		if strings.Contains(err.Error(), "unexpected EOF") {
-			err = errors.New("the plugin process was killed or crashed. Please check the plugin logs for more information")
-		}
 		cursor.logger.Error("could not request the rows from the plugin", "error", err, "table", cursor.tableIndex, "connection", cursor.connectionIndex)
 		return 0, errors.Join(errors.New("could not request the rows from the plugin"), err)
 	}
 	// If the plugin did not return any rows, we retry
 	i := 0
 	for (!noMoreRows) && (len(rows) == 0 || rows == nil) && (i < maxRowsFetchingRetry) {
-		rows, noMoreRows, err = cursor.client.Plugin.Query(cursor.connectionIndex, cursor.tableIndex, cursor.cursorIndex, cursor.constraints)
+		rows, noMoreRows, err = cursor.queryPlugin(ctx)
 		i++
 		time.Sleep(10 * time.Millisecond)
 		if err != nil {
-			if strings.Contains(err.Error(), "unexpected EOF") {
-				err = errors.New("the plugin process was killed or crashed. Please check the plugin logs for more information")
-			}
 			return 0, errors.Join(errors.New("could not request the rows from the plugin"), err)
 		}
 	}
@@ -919,15 +1113,96 @@ func (cursor *SQLiteCursor) requestRowsFromPlugin() (int, error) {
 	for _, row := range rows {
 		cursor.rows.PushBack(row)
 	}
+	cursor.cacheRows(rows)
 
 	return len(rows), nil
 }
 
+// queryPlugin requests a page of rows from the plugin
+//
+// If the client supports it, the call is abandoned and the plugin is notified once ctx is done
+func (cursor *SQLiteCursor) queryPlugin(ctx context.Context) ([][]interface{}, bool, error) {
+	var rows [][]interface{}
+	var noMoreRows bool
+	var err error
+	cursor.profile.roundTrip()
+	if client, ok := cursor.client.Plugin.(rpc.InternalContextInterface); ok {
+		rows, noMoreRows, err = client.QueryContext(ctx, cursor.connectionIndex, cursor.tableIndex, cursor.cursorIndex, cursor.constraints)
+	} else {
+		rows, noMoreRows, err = cursor.client.Plugin.Query(cursor.connectionIndex, cursor.tableIndex, cursor.cursorIndex, cursor.constraints)
+	}
+
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		err = errors.New("the query was cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		err = errors.New("the query timed out")
+	case strings.Contains(err.Error(), "unexpected EOF"):
+		err = errors.New("the plugin process was killed or crashed. Please check the plugin logs for more information")
+	}
+	for _, row := range rows {
+		cursor.profile.fetched(row)
+	}
+	return rows, noMoreRows, err
+}
+
+// requestRowsFromStream reads the next row from the stream of the cursor
+//
+// It returns the number of rows read (0 or 1)
+func (cursor *SQLiteCursor) requestRowsFromStream() (int, error) {
+	row, err := cursor.stream.Next()
+	if err == io.EOF {
+		cursor.noMoreRows = true
+		cursor.stream = nil
+		cursor.cacheRows(nil)
+		return 0, nil
+	}
+	if err != nil {
+		// If the stream was closed because the query is done, we report why
+		if ctxErr := connectionContext(cursor.conn).Err(); errors.Is(ctxErr, context.Canceled) {
+			err = errors.New("the query was cancelled")
+		} else if errors.Is(ctxErr, context.DeadlineExceeded) {
+			err = errors.New("the query timed out")
+		}
+		cursor.logger.Error("could not read the rows streamed by the plugin", "error", err, "table", cursor.tableIndex, "connection", cursor.connectionIndex)
+		cursor.stream.Close()
+		cursor.stream = nil
+		return 0, errors.Join(errors.New("could not read the rows streamed by the plugin"), err)
+	}
+
+	cursor.profile.fetched(row)
+	cursor.rows.PushBack(row)
+	cursor.cacheRows([][]interface{}{row})
+	return 1, nil
+}
+
+// cacheRows collects the rows returned by the plugin for the query cache
+//
+// Once the plugin has returned all the rows of the query, they are stored in the cache
+func (cursor *SQLiteCursor) cacheRows(rows [][]interface{}) {
+	if !cursor.caching {
+		return
+	}
+	cursor.cachedRows = append(cursor.cachedRows, rows...)
+	if len(cursor.cachedRows) > maxQueryCacheEntryRows {
+		cursor.caching = false
+		cursor.cachedRows = nil
+		return
+	}
+	if cursor.noMoreRows {
+		defaultQueryCache.set(cursor.cacheKey, cursor.name, cursor.cachedRows, cursor.cacheTTL, cursor.cacheGeneration)
+		cursor.caching = false
+		cursor.cachedRows = nil
+	}
+}
+
 // parseConstraintsFromSQLite parses the constraints from SQLite and stores them in the QueryConstraint struct
 //
 // For the offset and limit constraints, we store their position in the vals field
-// so that we can pass them to the plugin
-func parseConstraintsFromSQLite(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, constraints *rpc.QueryConstraint, used []bool, schema rpc.DatabaseSchema) {
+// so that we can pass them to the plugin.
+// The IN constraints whose values are requested at once are marked in in (see in_list.go)
+func parseConstraintsFromSQLite(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, constraints *rpc.QueryConstraint, used []bool, in []bool, schema rpc.DatabaseSchema) {
 	/*
 		Internal notes:
 		- The usable constraints are the ones that are used in the query
@@ -965,6 +1240,10 @@ func parseConstraintsFromSQLite(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoO
 			// In all the other cases, we don't know the value yet
 			// so we store the constraint as is
 			default:
+				if inConstraint(c, schema) {
+					tempOp = rpc.OperatorIn
+					in[i] = true
+				}
 				constraints.Columns = append(constraints.Columns, rpc.ColumnConstraint{
 					ColumnID: c.Column, // The column index
 					Operator: tempOp,   // We convert the SQLite operator to our own operator
@@ -986,6 +1265,20 @@ func parseConstraintsFromSQLite(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoO
 	}
 }
 
+// columnsUsedFromMask converts the colUsed mask of SQLite
+// to the used columns of rpc.QueryConstraint
+//
+// The bit i of the mask is set if the column i is used.
+// The last bit is set if any column after the 63rd is used
+func columnsUsedFromMask(mask uint64, columnCount int) []bool {
+	used := make([]bool, columnCount)
+	for i := range used {
+		bit := min(i, 63)
+		used[i] = mask&(1<<bit) != 0
+	}
+	return used
+}
+
 // convertSQLiteOPtoOperator converts a SQLite operator to an Operator
 // known by anyquery
 func convertSQLiteOPtoOperator(op sqlite3.Op) rpc.Operator {
@@ -1027,6 +1320,10 @@ func loadConstraintsFromJSON(idxStr string, constraints *rpc.QueryConstraint, va
 			constraints.Columns[i].Operator = rpc.OperatorGlob
 			j++
 
+		case rpc.OperatorIn:
+			loadInValues(&constraints.Columns[i], vals[j])
+			j++
+
 		default:
 			// If the value is -1, it means SQL NULL
 			// so we fill it with nil
@@ -1060,8 +1357,16 @@ func convertLikeToGlobString(s string) string {
 //
 // It's useful when SQLite reuses the cursor
 func resetCursor(c *SQLiteCursor) {
+	if c.stream != nil {
+		c.stream.Close()
+		c.stream = nil
+	}
+	// The query of the former cursor on the plugin side is over
+	c.closePluginCursor()
 	c.noMoreRows = false
 	c.rows.Clear()
+	c.caching = false
+	c.cachedRows = nil
 	c.cursorIndex = *c.nextCursor
 	*c.nextCursor++
 
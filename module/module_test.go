@@ -1,10 +1,13 @@
 package module
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/jmoiron/sqlx"
@@ -340,6 +343,142 @@ func TestLibPlugin(t *testing.T) {
 
 }
 
+// Test a plugin streaming its rows
+// rather than returning them in batches
+func TestStreamPlugin(t *testing.T) {
+	t.Parallel()
+	// Build the stream plugin
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/streamplugin.out", "../test/streamplugin.go").Run()
+	if err != nil {
+		t.Fatalf("Can't build the plugin: %v", err)
+	}
+
+	// Register a db connection
+	pool := rpc.NewConnectionPool()
+	sql.Register("sqlite_custom_stream", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.CreateModule("test_stream", &SQLiteModule{
+				PluginPath:     "./_test/streamplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				TableIndex:     0,
+			})
+			if err != nil {
+				return err
+			}
+			return conn.CreateModule("test_stream_pages", &SQLiteModule{
+				PluginPath:     "./_test/streamplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				TableIndex:     1,
+			})
+		},
+	})
+
+	// Open a connection
+	db, err := sql.Open("sqlite_custom_stream", ":memory:")
+	require.NoError(t, err, "Can't open the database")
+	defer db.Close()
+
+	dbx := sqlx.NewDb(db, "sqlite_custom_stream")
+
+	t.Run("All the streamed rows are returned", func(t *testing.T) {
+		var count, sum int64
+		err := dbx.QueryRow("SELECT count(*), sum(id) FROM test_stream").Scan(&count, &sum)
+		require.NoError(t, err, "The query should work")
+		require.Equal(t, int64(5000), count, "The number of rows should be 5000")
+		require.Equal(t, int64(5000*5001/2), sum, "The rows should be in order and not duplicated")
+	})
+
+	t.Run("A stream can be closed before the plugin is done", func(t *testing.T) {
+		var ids []int64
+		err := dbx.Select(&ids, "SELECT id FROM test_stream LIMIT 3")
+		require.NoError(t, err, "The query should work")
+		require.Equal(t, []int64{1, 2, 3}, ids)
+
+		// The plugin must still answer once the previous stream is closed
+		var count int64
+		err = dbx.Get(&count, "SELECT count(*) FROM test_stream WHERE id > 4990")
+		require.NoError(t, err, "The query should work")
+		require.Equal(t, int64(10), count)
+	})
+
+	t.Run("The pages of a non-streaming reader are streamed", func(t *testing.T) {
+		var count int64
+		err := dbx.Get(&count, "SELECT count(*) FROM test_stream_pages")
+		require.NoError(t, err, "The query should work")
+		require.Equal(t, int64(300), count, "The number of rows should be 300")
+	})
+}
+
+func TestQueryTimeout(t *testing.T) {
+	t.Parallel()
+	// Build the slow plugin
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/slowplugin.out", "../test/slowplugin.go").Run()
+	if err != nil {
+		t.Fatalf("Can't build the plugin: %v", err)
+	}
+
+	// Register a db connection
+	pool := rpc.NewConnectionPool()
+	sql.Register("sqlite_custom_slow", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.CreateModule("test_slow", &SQLiteModule{
+				PluginPath:     "./_test/slowplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				TableIndex:     0,
+			})
+			if err != nil {
+				return err
+			}
+			return conn.CreateModule("test_slow_stream", &SQLiteModule{
+				PluginPath:     "./_test/slowplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				TableIndex:     1,
+			})
+		},
+	})
+
+	// Open a connection
+	db, err := sql.Open("sqlite_custom_slow", ":memory:")
+	require.NoError(t, err, "Can't open the database")
+	defer db.Close()
+
+	for _, table := range []string{"test_slow", "test_slow_stream"} {
+		t.Run("A query on "+table+" is stopped once its context times out", func(t *testing.T) {
+			conn, err := db.Conn(context.Background())
+			require.NoError(t, err, "Can't get a connection")
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			var release func()
+			err = conn.Raw(func(driverConn any) error {
+				release = SetConnectionContext(driverConn.(*sqlite3.SQLiteConn), ctx)
+				return nil
+			})
+			require.NoError(t, err, "Can't bind the context")
+			defer release()
+
+			start := time.Now()
+			rows, err := conn.QueryContext(ctx, "SELECT id FROM "+table)
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+				rows.Close()
+			}
+			require.Error(t, err, "The query should fail")
+			require.Less(t, time.Since(start), 10*time.Second, "The query should stop shortly after the timeout")
+		})
+	}
+}
+
 func TestOpCode(t *testing.T) {
 	t.Parallel()
 	// This test ensure that the go-sqlite3 keeps the same opcode
@@ -450,6 +589,137 @@ func TestXBestIndexConstraintsValidation(t *testing.T) {
 		require.NoError(t, err, "The query should work")
 	})
 
+	t.Run("The columns read by SQLite are passed to the plugin", func(t *testing.T) {
+		constraints := []sqlite3.InfoConstraint{
+			{
+				Column: 0,
+				Op:     sqlite3.OpEQ,
+				Usable: true,
+			},
+		}
+
+		res, err := table.BestIndex(constraints, []sqlite3.InfoOrderBy{}, sqlite3.IndexInformation{ColUsed: 0b10})
+		require.NoError(t, err, "The query should work")
+
+		var parsed rpc.QueryConstraint
+		err = loadConstraintsFromJSON(res.IdxStr, &parsed, []interface{}{int64(1)})
+		require.NoError(t, err, "The constraints should be parsed")
+		require.Equal(t, []bool{false, true}, parsed.ColumnsUsed)
+	})
+
+}
+
+func TestColumnsUsedFromMask(t *testing.T) {
+	require.Equal(t, []bool{true, false, true}, columnsUsedFromMask(0b101, 3))
+	require.Equal(t, []bool{}, columnsUsedFromMask(0b101, 0))
+
+	// The last bit covers every column after the 63rd
+	used := columnsUsedFromMask(1<<63, 70)
+	require.False(t, used[62])
+	require.True(t, used[63])
+	require.True(t, used[69])
+}
+
+func TestEstimateQuery(t *testing.T) {
+	equal := func(columns ...int) rpc.QueryConstraint {
+		constraints := rpc.QueryConstraint{}
+		for _, col := range columns {
+			constraints.Columns = append(constraints.Columns, rpc.ColumnConstraint{ColumnID: col, Operator: rpc.OperatorEqual})
+		}
+		return constraints
+	}
+
+	t.Run("A table without estimates is assumed to be large", func(t *testing.T) {
+		cost, rows := estimateQuery(rpc.DatabaseSchema{}, equal())
+		require.Equal(t, float64(defaultEstimatedRows), cost)
+		require.Equal(t, float64(defaultEstimatedRows), rows)
+
+		// An equality makes the query cheaper so that SQLite prefers passing the values of a join
+		constrainedCost, constrainedRows := estimateQuery(rpc.DatabaseSchema{}, equal(0))
+		require.Less(t, constrainedCost, cost)
+		require.Less(t, constrainedRows, rows)
+	})
+
+	schema := rpc.DatabaseSchema{
+		EstimatedRows: 50000,
+		EstimatedCost: 500000,
+		ConstraintCosts: []rpc.ConstraintCost{
+			{Columns: []int{0}, EstimatedRows: 1, EstimatedCost: 10},
+			{Columns: []int{1}, EstimatedRows: 200},
+			{Columns: []int{1, 2}, EstimatedRows: 5, EstimatedCost: 50},
+		},
+	}
+
+	t.Run("The estimates of the schema are used", func(t *testing.T) {
+		cost, rows := estimateQuery(schema, equal())
+		require.Equal(t, 500000.0, cost)
+		require.Equal(t, 50000.0, rows)
+
+		cost, rows = estimateQuery(schema, equal(0))
+		require.Equal(t, 10.0, cost)
+		require.Equal(t, 1.0, rows)
+
+		// The cost defaults to the number of rows
+		cost, rows = estimateQuery(schema, equal(1))
+		require.Equal(t, 200.0, cost)
+		require.Equal(t, 200.0, rows)
+	})
+
+	t.Run("The cheapest matching estimate is used", func(t *testing.T) {
+		cost, rows := estimateQuery(schema, equal(1, 2))
+		require.Equal(t, 50.0, cost)
+		require.Equal(t, 5.0, rows)
+	})
+
+	t.Run("Without a matching estimate, each equality divides the estimates", func(t *testing.T) {
+		cost, rows := estimateQuery(schema, equal(2))
+		require.Equal(t, 50000.0, cost)
+		require.Equal(t, 5000.0, rows)
+	})
+}
+
+func TestInConstraints(t *testing.T) {
+	schema := rpc.DatabaseSchema{
+		Columns: []rpc.DatabaseSchemaColumn{
+			{Name: "a"}, {Name: "b", IsRequired: true},
+		},
+		HandlesIn: true,
+	}
+
+	t.Run("The values of an IN constraint are requested at once", func(t *testing.T) {
+		require.True(t, inConstraint(sqlite3.InfoConstraint{Column: 0, Op: sqlite3.OpEQ, Usable: true, In: true}, schema))
+		require.False(t, inConstraint(sqlite3.InfoConstraint{Column: 0, Op: sqlite3.OpEQ, Usable: true}, schema),
+			"An equal constraint is not an IN constraint")
+		require.False(t, inConstraint(sqlite3.InfoConstraint{Column: 1, Op: sqlite3.OpEQ, Usable: true, In: true}, schema),
+			"The values of a required parameter must be sent one by one")
+		require.False(t, inConstraint(sqlite3.InfoConstraint{Column: -1, Op: sqlite3.OpEQ, Usable: true, In: true}, schema),
+			"The rowid is not a column of the plugin")
+		require.False(t, inConstraint(sqlite3.InfoConstraint{Column: 0, Op: sqlite3.OpEQ, Usable: true, In: true}, rpc.DatabaseSchema{
+			Columns: schema.Columns,
+		}), "The plugin must handle the IN constraints")
+	})
+
+	t.Run("The values are loaded in the constraint", func(t *testing.T) {
+		constraints := rpc.QueryConstraint{Limit: -1, Offset: -1}
+		used := make([]bool, 2)
+		in := make([]bool, 2)
+		parseConstraintsFromSQLite([]sqlite3.InfoConstraint{
+			{Column: 0, Op: sqlite3.OpEQ, Usable: true, In: true},
+			{Column: 1, Op: sqlite3.OpEQ, Usable: true, In: true},
+		}, nil, &constraints, used, in, schema)
+		require.Equal(t, []bool{true, false}, in)
+
+		marshalled, err := json.Marshal(constraints)
+		require.NoError(t, err)
+		loaded := rpc.QueryConstraint{}
+		err = loadConstraintsFromJSON(string(marshalled), &loaded,
+			[]interface{}{sqlite3.InValues{int64(1), 2.5, "it's"}, "b"})
+		require.NoError(t, err)
+		require.Equal(t, []rpc.ColumnConstraint{
+			{ColumnID: 0, Operator: rpc.OperatorIn, Value: []interface{}{int64(1), 2.5, "it's"}},
+			{ColumnID: 1, Operator: rpc.OperatorEqual, Value: "b"},
+		}, loaded.Columns)
+	})
 }
 
 func TestCUDOperations(t *testing.T) {
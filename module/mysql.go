@@ -16,6 +16,7 @@ import (
 	"github.com/twpayne/go-geom/encoding/wkt"
 
 	mysql "github.com/go-sql-driver/mysql"
+	"github.com/julien040/anyquery/rpc"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
 
@@ -199,6 +200,7 @@ type MySQLTable struct {
 	supportsUpdateDelete bool
 	primaryKeyColNames   []string
 	transactionStarted   bool // Whether a BEGIN has been called
+	profiler             cursorProfiler
 }
 
 type MySQLCursor struct {
@@ -210,6 +212,9 @@ type MySQLCursor struct {
 	currentRow   []interface{}
 	rowsReturned int64
 	limit        int64
+	profile      *CursorProfile
+	// The SQLite connection the cursor is opened on, to trace its queries
+	sqliteConn *sqlite3.SQLiteConn
 }
 
 func (m *MySQLModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -428,6 +433,7 @@ func (m *MySQLModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		connectionString:     connectionString,
 		supportsUpdateDelete: supportsUpdateDelete,
 		primaryKeyColNames:   primaryKeys,
+		profiler:             newCursorProfiler(c, args),
 	}, nil
 }
 
@@ -437,12 +443,13 @@ func (t *MySQLTable) Open() (sqlite3.VTabCursor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting a new connection: %v", err)
 	}
-	return &MySQLCursor{
+	return t.profiler.wrap(&MySQLCursor{
 		connection: conn,
 		tableName:  t.tableName,
 		schema:     t.schema,
 		limit:      -1,
-	}, nil
+		sqliteConn: t.profiler.conn,
+	}), nil
 }
 
 // Check if the table supports partial updates
@@ -698,6 +705,10 @@ func (t *MySQLCursor) resetCursor() error {
 	return nil
 }
 
+func (t *MySQLCursor) setProfile(profile *CursorProfile) {
+	t.profile = profile
+}
+
 func (t *MySQLCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Reset the cursor as Filter might be called multiple times
 	err := t.resetCursor()
@@ -737,7 +748,10 @@ func (t *MySQLCursor) Filter(idxNum int, idxStr string, vals []interface{}) erro
 	}
 
 	// Execute the query
-	rows, err := t.connection.QueryContext(context.Background(), query.Query, queryParams...)
+	t.profile.roundTrip()
+	ctx, span := startReaderSpan(t.sqliteConn, "mysql", query.Query)
+	rows, err := t.connection.QueryContext(ctx, query.Query, queryParams...)
+	rpc.EndSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("error executing the query: %v", err)
 	}
@@ -800,6 +814,7 @@ func (t *MySQLCursor) Next() error {
 			}
 			t.currentRow[i] = v
 		}
+		t.profile.fetched(t.currentRow)
 
 	} else {
 		t.currentRow = nil
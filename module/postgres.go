@@ -18,6 +18,7 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/julien040/anyquery/rpc"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
 
@@ -95,6 +96,7 @@ type PostgresTable struct {
 	supportsUpdateDelete bool
 	primaryKeyColNames   []string
 	transactionStarted   bool // Whether a BEGIN has been called
+	profiler             cursorProfiler
 }
 
 type PostgresCursor struct {
@@ -104,6 +106,9 @@ type PostgresCursor struct {
 	rows       pgx.Rows
 	exhausted  bool
 	currentRow []interface{}
+	profile    *CursorProfile
+	// The SQLite connection the cursor is opened on, to trace its queries
+	sqliteConn *sqlite3.SQLiteConn
 }
 
 func (m *PostgresModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -289,6 +294,7 @@ func (m *PostgresModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.
 		connectionString:     connectionString,
 		supportsUpdateDelete: supportsUpdateDelete,
 		primaryKeyColNames:   primaryKeys,
+		profiler:             newCursorProfiler(c, args),
 	}, nil
 }
 
@@ -298,11 +304,12 @@ func (t *PostgresTable) Open() (sqlite3.VTabCursor, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error getting a new connection: %v", err)
 	}
-	return &PostgresCursor{
+	return t.profiler.wrap(&PostgresCursor{
 		connection: conn,
 		tableName:  t.tableName,
 		schema:     t.schema,
-	}, nil
+		sqliteConn: t.profiler.conn,
+	}), nil
 }
 
 // Check if the table supports partial updates
@@ -609,6 +616,10 @@ func (t *PostgresCursor) resetCursor() error {
 	return nil
 }
 
+func (t *PostgresCursor) setProfile(profile *CursorProfile) {
+	t.profile = profile
+}
+
 func (t *PostgresCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	// Reset the cursor as Filter might be called multiple times
 	err := t.resetCursor()
@@ -647,7 +658,10 @@ func (t *PostgresCursor) Filter(idxNum int, idxStr string, vals []interface{}) e
 	}
 
 	// Execute the query
-	rows, err := t.connection.Query(context.Background(), query.Query, queryParams...)
+	t.profile.roundTrip()
+	ctx, span := startReaderSpan(t.sqliteConn, "postgresql", query.Query)
+	rows, err := t.connection.Query(ctx, query.Query, queryParams...)
+	rpc.EndSpan(span, err)
 	if err != nil {
 		return fmt.Errorf("error executing the query: %v", err)
 	}
@@ -671,6 +685,7 @@ func (t *PostgresCursor) Next() error {
 		if err != nil {
 			return fmt.Errorf("error getting the values of the row: %v", err)
 		}
+		t.profile.fetched(t.currentRow)
 	} else {
 		t.currentRow = nil
 	}
@@ -0,0 +1,236 @@
+package module
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// This file implements the profiler of the virtual table cursors.
+//
+// When a connection is profiled (see SetConnectionProfile), each cursor opened on it
+// is wrapped to count its Filter calls, the rows it hands to SQLite and the time spent in it.
+// The cursors fetching their rows from a plugin or a remote database also report
+// the round-trips they made, and the rows and bytes they received.
+
+// CursorProfile holds the metrics of a virtual table cursor
+type CursorProfile struct {
+	// The name of the table in SQLite
+	Table string `json:"table"`
+	// The name of the module of the table (e.g. csv_reader, postgres_reader or the table of a plugin)
+	Module string `json:"module"`
+	// The number of times SQLite called Filter (e.g. once per row of the outer table of a join)
+	FilterCalls int64 `json:"filter_calls"`
+	// The number of requests made to the plugin or the database
+	RoundTrips int64 `json:"round_trips"`
+	// The rows received from the source
+	RowsFetched int64 `json:"rows_fetched"`
+	// The rows handed to SQLite
+	RowsEmitted int64 `json:"rows_emitted"`
+	// The approximate size of the values received from the source
+	Bytes int64 `json:"bytes"`
+	// The time spent in the cursor (Filter, Next and Column)
+	Duration time.Duration `json:"-"`
+}
+
+func (c CursorProfile) MarshalJSON() ([]byte, error) {
+	type cursorProfile CursorProfile
+	return json.Marshal(struct {
+		cursorProfile
+		DurationMS float64 `json:"duration_ms"`
+	}{cursorProfile(c), float64(c.Duration.Microseconds()) / 1000})
+}
+
+// roundTrip records a request to the source of the cursor
+//
+// It is a no-op if c is nil, so that the cursors don't have to check if they are profiled
+func (c *CursorProfile) roundTrip() {
+	if c != nil {
+		c.RoundTrips++
+	}
+}
+
+// fetched records a row received from the source of the cursor
+func (c *CursorProfile) fetched(row []interface{}) {
+	if c == nil {
+		return
+	}
+	c.RowsFetched++
+	for _, value := range row {
+		c.Bytes += valueSize(value)
+	}
+}
+
+// valueSize returns the approximate number of bytes of a value received from a source
+func valueSize(value interface{}) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool, int8, uint8:
+		return 1
+	case int16, uint16:
+		return 2
+	case int32, uint32, float32:
+		return 4
+	case int, int64, uint, uint64, float64, time.Time:
+		return 8
+	case driver.Valuer:
+		// e.g. the sql.NullString scanned by the database readers
+		if val, err := v.Value(); err == nil {
+			return valueSize(val)
+		}
+		return 0
+	}
+	if ptr := reflect.ValueOf(value); ptr.Kind() == reflect.Pointer {
+		if ptr.IsNil() {
+			return 0
+		}
+		return valueSize(ptr.Elem().Interface())
+	}
+	return int64(len(fmt.Sprint(value)))
+}
+
+// Profile records the metrics of the cursors opened on a connection
+type Profile struct {
+	mu      sync.Mutex
+	cursors []*CursorProfile
+}
+
+// Cursors returns the metrics of the cursors in the order they were opened
+//
+// It must be called once the query is done (i.e. its rows are closed)
+func (p *Profile) Cursors() []CursorProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cursors := make([]CursorProfile, 0, len(p.cursors))
+	for _, cursor := range p.cursors {
+		cursors = append(cursors, *cursor)
+	}
+	return cursors
+}
+
+func (p *Profile) newCursor(table, module string) *CursorProfile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cursor := &CursorProfile{Table: table, Module: module}
+	p.cursors = append(p.cursors, cursor)
+	return cursor
+}
+
+// connectionProfiles maps a *sqlite3.SQLiteConn to the *Profile recording its cursors
+var connectionProfiles sync.Map
+
+// SetConnectionProfile records the metrics of the cursors opened on the SQLite connection in profile,
+// until the returned function is called
+func SetConnectionProfile(conn *sqlite3.SQLiteConn, profile *Profile) (release func()) {
+	connectionProfiles.Store(conn, profile)
+	return func() {
+		connectionProfiles.CompareAndDelete(conn, profile)
+	}
+}
+
+// connectionProfile returns the *Profile of the connection, or nil if it is not profiled
+func connectionProfile(conn *sqlite3.SQLiteConn) *Profile {
+	if conn == nil {
+		return nil
+	}
+	if profile, ok := connectionProfiles.Load(conn); ok {
+		return profile.(*Profile)
+	}
+	return nil
+}
+
+// cursorProfiler is held by a virtual table to profile the cursors it opens
+type cursorProfiler struct {
+	conn   *sqlite3.SQLiteConn
+	module string
+	table  string
+}
+
+// newCursorProfiler returns the profiler of a virtual table from the arguments of Connect
+func newCursorProfiler(conn *sqlite3.SQLiteConn, args []string) cursorProfiler {
+	profiler := cursorProfiler{conn: conn, table: vtabName(args)}
+	if len(args) > 0 {
+		profiler.module = args[0]
+	}
+	return profiler
+}
+
+// profiledSource is implemented by the cursors that report the requests
+// they make and the rows they receive
+type profiledSource interface {
+	setProfile(profile *CursorProfile)
+}
+
+// wrap returns the cursor unchanged if the connection is not profiled.
+// Otherwise, it returns a cursor recording its metrics in the profile of the connection
+func (p cursorProfiler) wrap(cursor sqlite3.VTabCursor) sqlite3.VTabCursor {
+	profile := connectionProfile(p.conn)
+	if profile == nil {
+		return cursor
+	}
+	profiled := &profiledCursor{VTabCursor: cursor, profile: profile.newCursor(p.table, p.module)}
+	if source, ok := cursor.(profiledSource); ok {
+		source.setProfile(profiled.profile)
+	} else {
+		// The rows of a local source are read as they are handed to SQLite
+		profiled.countFetched = true
+	}
+	return profiled
+}
+
+// profiledCursor wraps a cursor to record its metrics
+type profiledCursor struct {
+	sqlite3.VTabCursor
+	profile      *CursorProfile
+	countFetched bool // Whether the rows emitted are also counted as fetched
+	positioned   bool // Whether Filter or Next moved to a row not counted yet
+}
+
+func (c *profiledCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	start := time.Now()
+	err := c.VTabCursor.Filter(idxNum, idxStr, vals)
+	c.profile.Duration += time.Since(start)
+	c.profile.FilterCalls++
+	c.positioned = err == nil
+	return err
+}
+
+func (c *profiledCursor) Next() error {
+	start := time.Now()
+	err := c.VTabCursor.Next()
+	c.profile.Duration += time.Since(start)
+	c.positioned = err == nil
+	return err
+}
+
+func (c *profiledCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	start := time.Now()
+	err := c.VTabCursor.Column(context, col)
+	c.profile.Duration += time.Since(start)
+	return err
+}
+
+// EOF counts a row the first time SQLite checks the cursor is on it
+func (c *profiledCursor) EOF() bool {
+	eof := c.VTabCursor.EOF()
+	if c.positioned {
+		c.positioned = false
+		if !eof {
+			c.profile.RowsEmitted++
+			if c.countFetched {
+				c.profile.RowsFetched++
+			}
+		}
+	}
+	return eof
+}
@@ -0,0 +1,264 @@
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This file implements the cache of the rows returned by the plugins.
+//
+// A table opts in by setting rpc.DatabaseSchema.CacheTTL, and a profile can override the duration
+// (see SQLiteModule.CacheTTL). Once a cursor has read all the rows of a query, they are stored in memory,
+// keyed by the table, its profile and the hash of the constraints of the query (see rpc.QueryConstraint.Hash).
+// A later query with the same constraints is answered from the cache until the entry expires.
+//
+// The writes to a table purge its entries so that a query following an INSERT sees the new rows.
+// Because a cursor might read the rows of a table while they are modified (e.g. the scan of a DELETE),
+// the rows of a query started before a purge are not stored (see queryCache.generation).
+
+const (
+	// The approximate size of the cache. The entries expiring first are evicted beyond it
+	maxQueryCacheBytes = 256 << 20
+	// The rows of a query returning more rows than this are not cached
+	maxQueryCacheEntryRows = 100_000
+)
+
+// queryCacheKey identifies the rows of a query on a table
+type queryCacheKey struct {
+	// The plugin, the profile and the table (see queryCacheTableID)
+	table string
+	// The hash of the constraints of the query
+	hash string
+}
+
+type queryCacheEntry struct {
+	name    string // The name of the table in SQLite
+	rows    [][]interface{}
+	bytes   int64
+	expires time.Time
+}
+
+type queryCacheStats struct {
+	hits, misses int64
+}
+
+// queryCache is the cache of the rows returned by the plugins, shared by all the connections of the process
+type queryCache struct {
+	mu      sync.Mutex
+	entries map[queryCacheKey]*queryCacheEntry
+	bytes   int64
+	stats   map[string]*queryCacheStats // By table name
+	// Incremented on each purge. The rows of a query are only stored
+	// if no purge happened since the query started
+	generation uint64
+}
+
+var defaultQueryCache = &queryCache{
+	entries: make(map[queryCacheKey]*queryCacheEntry),
+	stats:   make(map[string]*queryCacheStats),
+}
+
+// queryCacheTableID identifies a table of a profile of a plugin
+//
+// The config of the profile is part of the ID so that two profiles
+// with different credentials never share their rows
+func queryCacheTableID(pluginPath string, connectionIndex, tableIndex int, config rpc.PluginConfig) string {
+	rawConfig, _ := json.Marshal(config)
+	hash := sha256.Sum256(rawConfig)
+	return fmt.Sprintf("%s\x00%d\x00%d\x00%s", pluginPath, connectionIndex, tableIndex, hex.EncodeToString(hash[:8]))
+}
+
+func (c *queryCache) statsOf(name string) *queryCacheStats {
+	stats, ok := c.stats[name]
+	if !ok {
+		stats = &queryCacheStats{}
+		c.stats[name] = stats
+	}
+	return stats
+}
+
+// get returns the rows of the query if they are cached and not expired
+//
+// On a miss, it returns the generation to pass to set once the rows are read
+func (c *queryCache) get(key queryCacheKey, name string) ([][]interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && time.Now().After(entry.expires) {
+		c.remove(key, entry)
+		ok = false
+	}
+	if !ok {
+		c.statsOf(name).misses++
+		return nil, c.generation, false
+	}
+	c.statsOf(name).hits++
+	return entry.rows, c.generation, true
+}
+
+// set stores the rows of the query for ttl, unless the cache was purged since generation
+func (c *queryCache) set(key queryCacheKey, name string, rows [][]interface{}, ttl time.Duration, generation uint64) {
+	entry := &queryCacheEntry{
+		name:    name,
+		rows:    rows,
+		expires: time.Now().Add(ttl),
+	}
+	for _, row := range rows {
+		for _, value := range row {
+			entry.bytes += valueSize(value)
+		}
+	}
+	if entry.bytes > maxQueryCacheBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	if previous, ok := c.entries[key]; ok {
+		c.remove(key, previous)
+	}
+	c.entries[key] = entry
+	c.bytes += entry.bytes
+	c.evict()
+}
+
+// evict removes the expired entries, and the entries expiring first while the cache is too large
+//
+// c.mu must be held
+func (c *queryCache) evict() {
+	if c.bytes <= maxQueryCacheBytes {
+		return
+	}
+
+	now := time.Now()
+	keys := make([]queryCacheKey, 0, len(c.entries))
+	for key, entry := range c.entries {
+		if now.After(entry.expires) {
+			c.remove(key, entry)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b queryCacheKey) int {
+		return c.entries[a].expires.Compare(c.entries[b].expires)
+	})
+	for _, key := range keys {
+		if c.bytes <= maxQueryCacheBytes {
+			break
+		}
+		c.remove(key, c.entries[key])
+	}
+}
+
+// remove deletes an entry. c.mu must be held
+func (c *queryCache) remove(key queryCacheKey, entry *queryCacheEntry) {
+	delete(c.entries, key)
+	c.bytes -= entry.bytes
+}
+
+// purgeTable removes the entries of a table (see queryCacheTableID)
+func (c *queryCache) purgeTable(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, entry := range c.entries {
+		if key.table == table {
+			c.remove(key, entry)
+		}
+	}
+}
+
+// QueryCacheTable describes the entries of the query cache for a table
+type QueryCacheTable struct {
+	// The name of the table in SQLite
+	Table string `json:"table"`
+	// The number of queries cached
+	Entries int `json:"entries"`
+	// The number of rows of these queries
+	Rows int `json:"rows"`
+	// The approximate size of the values of these rows
+	Bytes int64 `json:"bytes"`
+	// The number of queries answered from the cache, and the number of queries sent to the plugin
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	// When the last entry of the table expires
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// QueryCacheInfo returns the tables that cached their rows, sorted by name
+func QueryCacheInfo() []QueryCacheTable {
+	c := defaultQueryCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tables := make(map[string]*QueryCacheTable)
+	tableOf := func(name string) *QueryCacheTable {
+		table, ok := tables[name]
+		if !ok {
+			table = &QueryCacheTable{Table: name}
+			tables[name] = table
+		}
+		return table
+	}
+
+	now := time.Now()
+	for _, entry := range c.entries {
+		if now.After(entry.expires) {
+			continue
+		}
+		table := tableOf(entry.name)
+		table.Entries++
+		table.Rows += len(entry.rows)
+		table.Bytes += entry.bytes
+		if table.ExpiresAt == nil || entry.expires.After(*table.ExpiresAt) {
+			expires := entry.expires
+			table.ExpiresAt = &expires
+		}
+	}
+	for name, stats := range c.stats {
+		table := tableOf(name)
+		table.Hits = stats.hits
+		table.Misses = stats.misses
+	}
+
+	info := make([]QueryCacheTable, 0, len(tables))
+	for _, table := range tables {
+		info = append(info, *table)
+	}
+	slices.SortFunc(info, func(a, b QueryCacheTable) int {
+		return strings.Compare(a.Table, b.Table)
+	})
+	return info
+}
+
+// ClearQueryCache removes the cached rows of the table named name, or of all the tables if name is empty
+//
+// It returns the number of queries removed
+func ClearQueryCache(name string) int {
+	c := defaultQueryCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	removed := 0
+	for key, entry := range c.entries {
+		if name == "" || strings.EqualFold(entry.name, name) {
+			c.remove(key, entry)
+			removed++
+		}
+	}
+	return removed
+}
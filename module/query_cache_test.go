@@ -0,0 +1,130 @@
+package module
+
+import (
+	"database/sql"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/julien040/anyquery/rpc"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCacheEntries(t *testing.T) {
+	cache := &queryCache{
+		entries: make(map[queryCacheKey]*queryCacheEntry),
+		stats:   make(map[string]*queryCacheStats),
+	}
+	key := queryCacheKey{table: "plugin\x000\x000", hash: "abc"}
+	rows := [][]interface{}{{int64(1), "Alice"}, {int64(2), "Bob"}}
+
+	_, generation, ok := cache.get(key, "users")
+	require.False(t, ok, "An empty cache should miss")
+
+	cache.set(key, "users", rows, time.Minute, generation)
+	cached, _, ok := cache.get(key, "users")
+	require.True(t, ok, "The rows should be cached")
+	require.Equal(t, rows, cached)
+	require.Equal(t, int64(1), cache.stats["users"].hits)
+	require.Equal(t, int64(1), cache.stats["users"].misses)
+
+	// The rows of a query started before a purge must not be stored
+	_, generation, _ = cache.get(queryCacheKey{table: key.table, hash: "def"}, "users")
+	cache.purgeTable(key.table)
+	require.Empty(t, cache.entries, "The purge should remove the entries of the table")
+	cache.set(queryCacheKey{table: key.table, hash: "def"}, "users", rows, time.Minute, generation)
+	require.Empty(t, cache.entries, "Stale rows should not be stored")
+	require.Zero(t, cache.bytes)
+
+	// An expired entry is a miss
+	_, generation, _ = cache.get(key, "users")
+	cache.set(key, "users", rows, -time.Second, generation)
+	_, _, ok = cache.get(key, "users")
+	require.False(t, ok, "An expired entry should miss")
+	require.Empty(t, cache.entries, "The expired entry should be removed")
+}
+
+func TestQueryCache(t *testing.T) {
+	// Build the plugin
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/insertplugin.out", "../test/insertplugin.go").Run()
+	if err != nil {
+		t.Fatalf("Can't build the plugin: %v", err)
+	}
+
+	pool := rpc.NewConnectionPool()
+	sql.Register("sqlite_custom_query_cache", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			err := conn.CreateModule("test_cached", &SQLiteModule{
+				PluginPath:     "./_test/insertplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				CacheTTL:       time.Minute,
+			})
+			if err != nil {
+				return err
+			}
+			return conn.CreateModule("test_not_cached", &SQLiteModule{
+				PluginPath:     "./_test/insertplugin.out",
+				ConnectionPool: pool,
+				Logger:         hclog.NewNullLogger(),
+				TableIndex:     1,
+			})
+		},
+	})
+
+	db, err := sql.Open("sqlite_custom_query_cache", ":memory:")
+	require.NoError(t, err, "Can't open the database")
+	defer db.Close()
+
+	tableInfo := func(name string) QueryCacheTable {
+		for _, table := range QueryCacheInfo() {
+			if table.Table == name {
+				return table
+			}
+		}
+		return QueryCacheTable{Table: name}
+	}
+	countRows := func(query string) int {
+		var count int
+		require.NoError(t, db.QueryRow(query).Scan(&count))
+		return count
+	}
+
+	t.Run("The second query is answered from the cache", func(t *testing.T) {
+		require.Equal(t, 2, countRows("SELECT count(*) FROM test_cached"))
+		require.Equal(t, 2, countRows("SELECT count(*) FROM test_cached"))
+
+		info := tableInfo("test_cached")
+		require.Equal(t, 1, info.Entries)
+		require.Equal(t, 2, info.Rows)
+		require.Equal(t, int64(1), info.Hits)
+		require.Equal(t, int64(1), info.Misses)
+		require.NotNil(t, info.ExpiresAt)
+	})
+
+	t.Run("A write purges the cache of the table", func(t *testing.T) {
+		_, err := db.Exec("INSERT INTO test_cached(id, name, age, address) VALUES(3, 'Julien', 30, 'Paris')")
+		require.NoError(t, err, "The insert should work")
+		require.Equal(t, 3, countRows("SELECT count(*) FROM test_cached"), "The inserted row should be returned")
+
+		_, err = db.Exec("DELETE FROM test_cached WHERE id = 3")
+		require.NoError(t, err, "The delete should work")
+		require.Equal(t, 2, countRows("SELECT count(*) FROM test_cached"), "The deleted row should not be returned")
+	})
+
+	t.Run("A table without TTL is not cached", func(t *testing.T) {
+		require.Equal(t, 2, countRows("SELECT count(*) FROM test_not_cached"))
+		require.Equal(t, 2, countRows("SELECT count(*) FROM test_not_cached"))
+		require.Zero(t, tableInfo("test_not_cached").Entries)
+	})
+
+	t.Run("The cache of a table can be cleared", func(t *testing.T) {
+		require.NotZero(t, tableInfo("test_cached").Entries)
+		require.Equal(t, 1, ClearQueryCache("TEST_CACHED"))
+		require.Zero(t, tableInfo("test_cached").Entries)
+	})
+}
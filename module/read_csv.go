@@ -12,7 +12,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/edsrzf/mmap-go"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 	"github.com/julien040/go-ternary"
 	"vitess.io/vitess/go/vt/sqlparser"
@@ -26,18 +25,41 @@ type CsvTable struct {
 	fileOpened     *sync.Pool
 	useHeader      bool
 	fieldSeparator string
-	file           []byte
-	mmap           mmap.MMap
+	files          []csvFile
 	columns        []columnCsv
+	// The partition columns found in the paths of the files, declared after columns.
+	// nil if there are none
+	partitions *hivePartitions
+	// The position of the hidden filename column, -1 if the source is a single file
+	filenameColumn int
+	// Appends the inserted rows to the file (see file_append.go)
+	appender *fileAppender
+	profiler cursorProfiler
+}
+
+// csvFile is one of the files of a CSV table
+type csvFile struct {
+	sourceFile
+	// positions maps a column of the table to its index in the records of the file,
+	// or -1 if the file doesn't have the column
+	positions []int
 }
 
 type CsvCursor struct {
-	useHeader bool
-	tempRow   []string
-	reader    *csv.Reader
-	columns   []columnCsv
-	eof       bool
-	rowID     int64
+	useHeader  bool
+	tempRow    []string
+	reader     *csv.Reader
+	columns    []columnCsv
+	files      []csvFile
+	partitions *hivePartitions
+	// The indexes of the files to read, and the position of the current one in it
+	selected       []int
+	selectedIndex  int
+	fileIndex      int
+	fieldSeparator rune
+	filenameColumn int
+	eof            bool
+	rowID          int64
 }
 
 type columnCsv struct {
@@ -76,6 +98,10 @@ func (m *CsvModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 
 func (v *CsvModule) DestroyModule() {}
 
+// TransactionModule makes SQLite call the transaction methods of the tables,
+// which write the inserted rows on commit (see file_append.go)
+func (v *CsvModule) TransactionModule() {}
+
 var alphaNumRegexp *regexp.Regexp = regexp.MustCompile(`[^\p{L}\p{N} ]+`)
 
 func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -91,6 +117,7 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 	// file, which is never cached.
 	cacheTTL := "86400"
 	cacheTTLParsed := int64(86400)
+	hivePartitioning := "true"
 
 	if len(args) >= 4 {
 		fileName = strings.Trim(args[3], "' \"")
@@ -119,9 +146,15 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		{"cacheTTL", &cacheTTL},
 		{"ttl", &cacheTTL},
 		{"cache", &cacheTTL},
+		{"hive_partitioning", &hivePartitioning},
 	}
 	parseArgs(params, args)
 
+	useHivePartitioning, err := strconv.ParseBool(hivePartitioning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hive_partitioning: %s", err)
+	}
+
 	// An explicit header= wins over detection, whichever way it points.
 	headerSet := useHeaderStr != ""
 	if headerSet {
@@ -147,27 +180,34 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		return nil, fmt.Errorf("missing file argument. Check the validity of the arguments")
 	}
 
-	file := []byte{}
-	mmap := mmap.MMap{}
-	var err error
+	var files []sourceFile
+	multipleFiles := false
 	if fileName == "/dev/stdin" || fileName == "-" || fileName == "stdin" {
 		if !m.Restrictions.AllowStdin() {
 			return nil, fmt.Errorf("sandbox: reading from stdin is not allowed")
 		}
 		// Read from stdin
-		file, err = io.ReadAll(os.Stdin)
+		file, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read from stdin: %s", err)
 		}
+		files = []sourceFile{{name: "stdin", content: file}}
 	} else {
-		// Open the file and mmap it
-		mmap, err = openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		// Open the files and mmap them
+		files, multipleFiles, err = openSourceFiles(connectionContext(c), fileName, m.Restrictions,
+			time.Duration(cacheTTLParsed)*time.Second, []string{".csv", ".tsv", ".txt"})
 		if err != nil {
 			return nil, fmt.Errorf("failed to open the file: %s", err)
 		}
-		file = mmap
-
 	}
+	// The files are unmapped if the table can't be created
+	connected := false
+	defer func() {
+		if !connected {
+			unmapSourceFiles(files)
+		}
+	}()
+	file := files[0].content
 
 	columns := []columnCsv{}
 
@@ -192,10 +232,6 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 			return nil, fmt.Errorf("invalid schema provided")
 		}
 
-		if createTableStmt.TableSpec == nil {
-			return nil, fmt.Errorf("invalid schema provided")
-		}
-
 		for i, col := range createTableStmt.TableSpec.Columns {
 			lowerCaseType := strings.ToLower(col.Type.Type)
 			colType, ok := typeEquivalences[lowerCaseType]
@@ -239,6 +275,40 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		fieldSeparator = ","
 	}
 
+	// The columns of the other files are matched by name with the ones of the first file,
+	// and the columns missing from the first file are added to the table.
+	// With a schema, the columns are matched by position
+	tableFiles := make([]csvFile, len(files))
+	for i, source := range files {
+		tableFiles[i].sourceFile = source
+		if i == 0 || schema != "" {
+			tableFiles[i].positions = make([]int, len(columns))
+			for j := range columns {
+				tableFiles[i].positions[j] = j
+			}
+			continue
+		}
+
+		_, _, fileColumns := sniffCSV(source.content, rune(fieldSeparator[0]), true, true, useHeader)
+		columns = unifyCsvColumns(columns, fileColumns)
+		tableFiles[i].positions = make([]int, len(columns))
+		for j, col := range columns {
+			tableFiles[i].positions[j] = -1
+			for k, fileCol := range fileColumns {
+				if fileCol.name == col.name {
+					tableFiles[i].positions[j] = k
+					break
+				}
+			}
+		}
+	}
+	// The files opened before a column was added don't have it
+	for i := range tableFiles {
+		for len(tableFiles[i].positions) < len(columns) {
+			tableFiles[i].positions = append(tableFiles[i].positions, -1)
+		}
+	}
+
 	// Create the table
 	tableStatement := strings.Builder{}
 	tableStatement.WriteString("CREATE TABLE x(")
@@ -262,73 +332,212 @@ func (m *CsvModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 			tableStatement.WriteString("TEXT")
 		}
 	}
+	var partitions *hivePartitions
+	filenameColumn := -1
+	if multipleFiles {
+		names := make([]string, len(columns))
+		for i, col := range columns {
+			names[i] = transformSQLiteValidName(col.name)
+		}
+		if useHivePartitioning {
+			paths := make([]string, len(files))
+			for i, file := range files {
+				paths[i] = file.name
+			}
+			partitions = discoverHivePartitions(paths, names)
+			partitions.declare(&tableStatement)
+			names = append(names, partitions.names()...)
+		}
+		filenameColumn = len(names)
+		tableStatement.WriteString(", `" + sourceFilenameColumnName(names) + "` TEXT HIDDEN")
+	}
 	tableStatement.WriteString(")")
 
 	c.DeclareVTab(tableStatement.String())
 
+	connected = true
 	return &CsvTable{
 		useHeader:      useHeader,
 		columns:        columns,
-		mmap:           mmap,
-		file:           file,
+		files:          tableFiles,
+		partitions:     partitions,
+		filenameColumn: filenameColumn,
 		fieldSeparator: fieldSeparator,
+		appender:       newFileAppender(c, args, fileName, files, multipleFiles, m.Restrictions),
+		profiler:       newCursorProfiler(c, args),
 	}, nil
 }
 
+// unifyCsvColumns adds the columns of a file missing from columns,
+// and widens the type of the columns whose values differ in type
+func unifyCsvColumns(columns []columnCsv, fileColumns []columnCsv) []columnCsv {
+	for _, fileCol := range fileColumns {
+		found := false
+		for i, col := range columns {
+			if col.name != fileCol.name {
+				continue
+			}
+			found = true
+			if col.colType != fileCol.colType {
+				if (col.colType == "int" || col.colType == "float") && (fileCol.colType == "int" || fileCol.colType == "float") {
+					columns[i].colType = "float"
+				} else {
+					columns[i].colType = "string"
+				}
+			}
+			break
+		}
+		if !found {
+			columns = append(columns, fileCol)
+		}
+	}
+	return columns
+}
+
 func (t *CsvTable) Open() (sqlite3.VTabCursor, error) {
-	// Create a new reader
-	reader := csv.NewReader(bytes.NewReader(t.file))
-	reader.Comma = rune(t.fieldSeparator[0])
-	reader.LazyQuotes = true
-	reader.ReuseRecord = true
-
-	return &CsvCursor{
-		useHeader: t.useHeader,
-		reader:    reader,
-		columns:   t.columns,
-	}, nil
+	// The rows inserted since the last scan are read from the file mapped again.
+	// A cursor still reading the former mapping keeps the former slice of files
+	if t.appender.written {
+		source, err := t.appender.refresh(t.files[0].sourceFile)
+		if err != nil {
+			return nil, err
+		}
+		t.files = []csvFile{{sourceFile: source, positions: t.files[0].positions}}
+	}
+	return t.profiler.wrap(&CsvCursor{
+		useHeader:      t.useHeader,
+		columns:        t.columns,
+		files:          t.files,
+		partitions:     t.partitions,
+		fieldSeparator: rune(t.fieldSeparator[0]),
+		filenameColumn: t.filenameColumn,
+	}), nil
 }
 
 func (t *CsvTable) Disconnect() error {
-	// Unmap the file
-	if t.mmap != nil {
-		t.mmap.Unmap()
+	// Unmap the files
+	for _, file := range t.files {
+		if file.mmap != nil {
+			file.mmap.Unmap()
+		}
 	}
-	return nil
+	return t.appender.close()
 }
 
 func (t *CsvTable) Destroy() error {
 	return nil
 }
 
+// Insert appends a row to the file of the table once the transaction is committed
+func (t *CsvTable) Insert(id any, vals []any) (int64, error) {
+	record := make([]string, len(t.columns))
+	for i := range record {
+		if i < len(vals) {
+			record[i] = formatAppendedValue(vals[i])
+		}
+	}
+
+	buffer := bytes.Buffer{}
+	writer := csv.NewWriter(&buffer)
+	writer.Comma = rune(t.fieldSeparator[0])
+	if err := writer.Write(record); err != nil {
+		return 0, fmt.Errorf("failed to encode the row: %s", err)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return 0, fmt.Errorf("failed to encode the row: %s", err)
+	}
+
+	return t.appender.insert(vals, buffer.Bytes())
+}
+
+// Begin is called by SQLite before the first insert of a transaction
+func (t *CsvTable) Begin() error {
+	return nil
+}
+
+// Commit writes the rows inserted in the transaction to the file
+func (t *CsvTable) Commit() error {
+	return t.appender.commit()
+}
+
+// Rollback discards the rows inserted in the transaction
+func (t *CsvTable) Rollback() error {
+	t.appender.rollback()
+	return nil
+}
+
+func (t *CsvTable) Update(id any, vals []any) error {
+	return fmt.Errorf("UPDATE is not supported by CSV files, only INSERT")
+}
+
+func (t *CsvTable) Delete(id any) error {
+	return fmt.Errorf("DELETE is not supported by CSV files, only INSERT")
+}
+
+func (t *CsvTable) PartialUpdate() bool {
+	return false
+}
+
 func (t *CsvTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, info sqlite3.IndexInformation) (*sqlite3.IndexResult, error) {
+	// Skip the files whose partition values don't match the constraints
+	if t.partitions != nil {
+		return t.partitions.bestIndex(cst, len(t.columns))
+	}
 	return &sqlite3.IndexResult{
 		Used: make([]bool, len(cst)),
 	}, nil
 }
 
-func (t *CsvCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+// openFile creates the reader of the file at index, and skips its header
+func (t *CsvCursor) openFile(index int) error {
+	t.fileIndex = index
+	t.reader = csv.NewReader(bytes.NewReader(t.files[index].content))
+	t.reader.Comma = t.fieldSeparator
+	t.reader.LazyQuotes = true
+	t.reader.ReuseRecord = true
+
 	// Skip the first row if we have a header
 	if t.useHeader {
 		_, err := t.reader.Read()
-		if err == io.EOF {
-			t.eof = true
-			return nil
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return err
 		}
 	}
+	return nil
+}
 
+func (t *CsvCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	var err error
+	t.selected, err = t.partitions.selectFiles(len(t.files), idxStr, vals)
+	if err != nil {
+		return err
+	}
 	t.rowID = 0
-	t.eof = false
-	t.Next()
+	t.selectedIndex = 0
+	if len(t.selected) == 0 {
+		t.eof = true
+		return nil
+	}
+	if err := t.openFile(t.selected[0]); err != nil {
+		return err
+	}
 
-	return nil
+	t.eof = false
+	return t.Next()
 }
 
 func (t *CsvCursor) Next() error {
 	row, err := t.reader.Read()
 	if err == io.EOF {
+		// Continue with the next file, if any
+		if t.selectedIndex+1 < len(t.selected) {
+			t.selectedIndex++
+			if err := t.openFile(t.selected[t.selectedIndex]); err != nil {
+				return err
+			}
+			return t.Next()
+		}
 		t.eof = true
 		return nil
 	}
@@ -338,38 +547,57 @@ func (t *CsvCursor) Next() error {
 }
 
 func (t *CsvCursor) Column(context *sqlite3.SQLiteContext, col int) error {
-	if col >= len(t.tempRow) {
+	if col == t.filenameColumn {
+		context.ResultText(t.files[t.fileIndex].name)
+		return nil
+	}
+	if t.partitions.resultColumn(context, t.fileIndex, col, len(t.columns)) {
+		return nil
+	}
+	if col >= len(t.columns) {
+		context.ResultNull()
+		return nil
+	}
+
+	// The position of the column in the records of the current file
+	position := t.files[t.fileIndex].positions[col]
+	if position < 0 || position >= len(t.tempRow) {
 		context.ResultNull()
 		return nil
 	}
 
-	switch t.columns[col].colType {
+	resultCsvValue(context, t.columns[col].colType, t.tempRow[position])
+	return nil
+}
+
+// resultCsvValue returns the text of a cell converted to colType ("int", "float", "bool" or "string").
+// A cell that doesn't parse as its type is NULL
+func resultCsvValue(context *sqlite3.SQLiteContext, colType string, value string) {
+	switch colType {
 	case "int":
-		val, err := strconv.ParseInt(t.tempRow[col], 10, 64)
+		val, err := strconv.ParseInt(value, 10, 64)
 		if err != nil {
 			context.ResultNull()
 		} else {
 			context.ResultInt64(val)
 		}
 	case "float":
-		val, err := strconv.ParseFloat(t.tempRow[col], 64)
+		val, err := strconv.ParseFloat(value, 64)
 		if err != nil {
 			context.ResultNull()
 		} else {
 			context.ResultDouble(val)
 		}
 	case "bool":
-		val, err := strconv.ParseBool(t.tempRow[col])
+		val, err := strconv.ParseBool(value)
 		if err != nil {
 			context.ResultNull()
 		} else {
 			context.ResultInt(ternary.If(val, 1, 0))
 		}
 	default:
-		context.ResultText(t.tempRow[col])
+		context.ResultText(value)
 	}
-
-	return nil
 }
 
 func (t *CsvCursor) EOF() bool {
@@ -1,6 +1,7 @@
 package module
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
@@ -216,3 +217,178 @@ func TestCsvCacheTTLInvalid(t *testing.T) {
 	require.ErrorContains(t, err, "failed to parse the cache TTL")
 	require.Equal(t, int32(0), atomic.LoadInt32(hits), "an unparseable TTL must be rejected before any download")
 }
+
+// TestCsvMultipleFiles reads a directory of CSV files whose headers differ:
+// the columns are the union of the headers, and the hidden filename column
+// tells which file a row comes from.
+func TestCsvMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.csv"), []byte("id,name\n1,alice\n2,bob\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.csv"), []byte("id,age,name\n3.5,41,carol\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# not a csv\n"), 0o600))
+
+	name := "sqlite3-csv-multiple"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("csv_reader", &CsvModule{Restrictions: &Restrictions{AllowedDirs: []string{dir}}})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table shards using csv_reader('%s')", dir))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	rows, err := dbx.Query("select * from shards limit 1")
+	require.NoError(t, err, "querying must not fail")
+	columns, err := rows.Columns()
+	require.NoError(t, err, "getting columns must not fail")
+	require.NoError(t, rows.Close())
+	require.Equal(t, []string{"id", "name", "age"}, columns, "the columns must be the union of the headers, filename being hidden")
+
+	var rowCount int
+	require.NoError(t, dbx.Get(&rowCount, "select count(*) from shards"))
+	require.Equal(t, 3, rowCount, "the rows of both files must be read")
+
+	var idType string
+	require.NoError(t, dbx.Get(&idType, "select typeof(id) from shards where name = 'alice'"))
+	require.Equal(t, "real", idType, "an integer column that is a float in another file must be a float")
+
+	var file string
+	require.NoError(t, dbx.Get(&file, "select filename from shards where name = 'carol'"))
+	require.Equal(t, filepath.Join(dir, "b.csv"), file, "the filename column must hold the file of the row")
+
+	var missingAge sql.NullInt64
+	require.NoError(t, dbx.Get(&missingAge, "select age from shards where name = 'bob'"))
+	require.False(t, missingAge.Valid, "a column missing in a file must be NULL")
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table missing using csv_reader('%s')", filepath.Join(dir, "*.tsv")))
+	require.ErrorContains(t, err, "no file matches")
+}
+
+func TestCsvHivePartitions(t *testing.T) {
+	dir := t.TempDir()
+	for path, content := range map[string]string{
+		"country=fr/city=Paris/stores.csv":  "store,revenue\nRivoli,120\nBastille,80\n",
+		"country=fr/city=Lyon/stores.csv":   "store,revenue\nBellecour,95\n",
+		"country=de/city=Berlin/stores.csv": "store,revenue\nMitte,150\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0o600))
+	}
+
+	name := "sqlite3-csv-hive"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("csv_reader", &CsvModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table stores using csv_reader('%s')", filepath.Join(dir, "country=*", "city=*", "*.csv")))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	var revenue int
+	require.NoError(t, dbx.Get(&revenue, "select sum(revenue) from stores where country = 'fr'"))
+	require.Equal(t, 295, revenue, "only the stores of France must be summed")
+
+	var stores []string
+	require.NoError(t, dbx.Select(&stores, "select store from stores where country = 'fr' and city > 'M' order by store"))
+	require.Equal(t, []string{"Bastille", "Rivoli"}, stores)
+
+	stores = nil
+	require.NoError(t, dbx.Select(&stores, "select store from stores where city = 'PARIS' collate nocase order by store"))
+	require.Equal(t, []string{"Bastille", "Rivoli"}, stores, "the collation of a comparison must be honoured")
+
+	var cities []string
+	require.NoError(t, dbx.Select(&cities, "select distinct city from stores order by city"))
+	require.Equal(t, []string{"Berlin", "Lyon", "Paris"}, cities, "the partition columns must hold the values of the paths")
+}
+
+func TestCsvInsert(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.csv")
+	// The last line has no newline, which must be added before the first row
+	require.NoError(t, os.WriteFile(path, []byte("id;name\n1;alice"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.csv"), []byte("id;name\n9;zoe\n"), 0o600))
+
+	restrictions := &Restrictions{AllowedDirs: []string{dir}}
+	name := "sqlite3-csv-insert"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("csv_reader", &CsvModule{Restrictions: restrictions})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table people using csv_reader('%s')", path))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	_, err = db.Exec("insert into people values (2, 'bob')")
+	require.ErrorContains(t, err, "sandbox: ", "writing must be denied unless the policy allows it")
+
+	restrictions.AllowWrite = true
+	_, err = db.Exec("insert into people values (2, 'bob; jr'), (3, NULL)")
+	require.NoError(t, err, "inserting must not fail")
+
+	var names []sql.NullString
+	require.NoError(t, dbx.Select(&names, "select name from people order by id"))
+	require.Equal(t, []sql.NullString{{String: "alice", Valid: true}, {String: "bob; jr", Valid: true}, {String: "", Valid: true}}, names,
+		"the inserted rows must be read back")
+
+	_, err = db.Exec("insert into people select id + 10, name from people")
+	require.NoError(t, err, "inserting the rows of the table itself must not fail")
+	var rowCount int
+	require.NoError(t, dbx.Get(&rowCount, "select count(*) from people"))
+	require.Equal(t, 6, rowCount, "each row must be copied once")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "id;name\n1;alice\n2;\"bob; jr\"\n3;\n11;alice\n12;\"bob; jr\"\n13;\n", string(content))
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("insert into people values (4, 'carol')")
+	require.NoError(t, err, "inserting in a transaction must not fail")
+	require.NoError(t, tx.Rollback())
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	dryRun := &DryRun{}
+	release := func() {}
+	require.NoError(t, conn.Raw(func(driverConn any) error {
+		release = SetConnectionDryRun(driverConn.(*sqlite3.SQLiteConn), dryRun)
+		return nil
+	}))
+	_, err = conn.ExecContext(context.Background(), "insert into people values (5, 'dave')")
+	require.NoError(t, err, "inserting in dry-run mode must not fail")
+	release()
+	require.NoError(t, conn.Close())
+	require.Equal(t, []DryRunWrite{{Table: "people", Operation: "INSERT", Values: []any{int64(5), "dave"}}}, dryRun.Writes(),
+		"the row of a dry run must be recorded")
+
+	_, err = db.Exec("insert into people values (6, 'erin')")
+	require.NoError(t, err)
+	appended, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(content)+"6;erin\n", string(appended), "the rows of a rolled back transaction and of a dry run must not be written")
+
+	_, err = db.Exec("update people set name = 'x'")
+	require.ErrorContains(t, err, "UPDATE is not supported")
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table shards using csv_reader('%s')", filepath.Join(dir, "*.csv")))
+	require.NoError(t, err, "creating the virtual table must not fail")
+	_, err = db.Exec("insert into shards values (4, 'dan')")
+	require.ErrorContains(t, err, "several files")
+}
@@ -38,10 +38,11 @@ func parquetReader(r *Restrictions) sqlite3.Module { return &ParquetModule{Restr
 func tomlReader(r *Restrictions) sqlite3.Module    { return &TomlModule{Restrictions: r} }
 func yamlReader(r *Restrictions) sqlite3.Module    { return &YamlModule{Restrictions: r} }
 func htmlReader(r *Restrictions) sqlite3.Module    { return &HtmlModule{Restrictions: r} }
+func xlsxReader(r *Restrictions) sqlite3.Module    { return &XlsxModule{Restrictions: r} }
 
 // fileFormats holds every name accepted by format= and every extension
 // FileModule can infer a reader from. Aliases (jsonl/ndjson, yaml/yml,
-// html/htm, parquet/pq) are separate entries pointing at the same reader.
+// html/htm, parquet/pq, xlsx/xlsm/ods) are separate entries pointing at the same reader.
 var fileFormats = map[string]fileFormat{
 	"csv":     {newModule: csvReader},
 	"tsv":     {newModule: csvReader, tabSeparated: true},
@@ -55,6 +56,9 @@ var fileFormats = map[string]fileFormat{
 	"yml":     {newModule: yamlReader},
 	"html":    {newModule: htmlReader},
 	"htm":     {newModule: htmlReader},
+	"xlsx":    {newModule: xlsxReader},
+	"xlsm":    {newModule: xlsxReader},
+	"ods":     {newModule: xlsxReader},
 }
 
 // compressionExtensions are dropped before the format extension is read, so
@@ -135,6 +139,10 @@ func (m *FileModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 
 func (v *FileModule) DestroyModule() {}
 
+// TransactionModule forwards the transaction methods of SQLite
+// to the tables of the readers implementing them (csv_reader and jsonl_reader)
+func (v *FileModule) TransactionModule() {}
+
 func (m *FileModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
 	fileName := ""
 	format := ""
@@ -32,6 +32,8 @@ func TestFileModuleFormatDerivation(t *testing.T) {
 		{name: "yml alias", fileName: "/tmp/config.yml", want: "yml"},
 		{name: "htm alias", fileName: "/tmp/page.htm", want: "htm"},
 		{name: "pq alias", fileName: "/tmp/data.pq", want: "pq"},
+		{name: "xlsx extension", fileName: "/tmp/report.xlsx", want: "xlsx"},
+		{name: "ods extension", fileName: "/tmp/report.ods", want: "ods"},
 		{name: "uppercase extension", fileName: "/tmp/DATA.JSON", want: "json"},
 		{name: "gzip compressed csv", fileName: "/tmp/data.csv.gz", want: "csv"},
 		{name: "zstd compressed json", fileName: "/tmp/data.json.zst", want: "json"},
@@ -79,14 +81,14 @@ func TestFileModuleFormatDerivation(t *testing.T) {
 		},
 		{
 			name:     "unknown extension",
-			fileName: "/tmp/data.xlsx",
-			wantErr:  []string{"xlsx", "format=", "csv"},
+			fileName: "/tmp/data.docx",
+			wantErr:  []string{"docx", "format=", "csv"},
 		},
 		{
 			name:     "unsupported explicit format",
 			fileName: "/tmp/data.csv",
-			format:   "xlsx",
-			wantErr:  []string{"unsupported format", "xlsx", "csv"},
+			format:   "docx",
+			wantErr:  []string{"unsupported format", "docx", "csv"},
 		},
 	}
 
@@ -232,8 +234,8 @@ func TestFileModule(t *testing.T) {
 		},
 		{
 			name:          "unknown extension",
-			args:          fmt.Sprintf("'%s'", write("data.xlsx", csvContent)),
-			wantCreateErr: []string{"xlsx", "format=", supportedFormats()},
+			args:          fmt.Sprintf("'%s'", write("data.docx", csvContent)),
+			wantCreateErr: []string{"docx", "format=", supportedFormats()},
 		},
 	}
 
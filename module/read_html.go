@@ -19,10 +19,11 @@ type HtmlModule struct {
 }
 
 type HtmlTable struct {
-	table   *html.Node
-	file    io.ReadCloser
-	rows    *goquery.Selection
-	isTable bool // Whether the rows node is a table or not
+	table    *html.Node
+	file     io.ReadCloser
+	rows     *goquery.Selection
+	isTable  bool // Whether the rows node is a table or not
+	profiler cursorProfiler
 }
 
 type HtmlCursor struct {
@@ -92,7 +93,9 @@ func (m *HtmlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 	if err != nil {
 		return nil, err
 	}
-	file, err := NewFetcher(m.Restrictions).Open(source, time.Duration(cacheTTLParsed)*time.Second)
+	fetcher := NewFetcher(m.Restrictions)
+	fetcher.Context = connectionContext(c)
+	file, err := fetcher.Open(source, time.Duration(cacheTTLParsed)*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %s", err)
 	}
@@ -189,18 +192,19 @@ func (m *HtmlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 
 	closeFile = false
 	return &HtmlTable{
-		table:   document,
-		rows:    rows,
-		isTable: document.Data == "table",
-		file:    file,
+		table:    document,
+		rows:     rows,
+		isTable:  document.Data == "table",
+		file:     file,
+		profiler: newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *HtmlTable) Open() (sqlite3.VTabCursor, error) {
-	return &HtmlCursor{
+	return t.profiler.wrap(&HtmlCursor{
 		rows:    t.rows,
 		isTable: t.isTable,
-	}, nil
+	}), nil
 }
 
 func (t *HtmlTable) Disconnect() error {
@@ -26,6 +26,7 @@ type JSONTable struct {
 	tableShape jsonShape
 	columns    map[string]column
 	rowCount   int
+	profiler   cursorProfiler
 }
 
 type JSONCursor struct {
@@ -162,14 +163,28 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 			return nil, err
 		}
 	} else {
-		file, err := openMmapedFile(filepath, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		files, multipleFiles, err := openSourceFiles(connectionContext(c), filepath, m.Restrictions,
+			time.Duration(cacheTTLParsed)*time.Second, []string{".json"})
 		if err != nil {
 			return nil, err
 		}
-		m.fileContent = file
-		m.mmap = file
+		if multipleFiles {
+			return m.connectFiles(c, args, files, jsonPath)
+		}
+		m.fileContent = files[0].content
+		m.mmap = files[0].mmap
+	}
+
+	unmarshaled, err := unmarshalJSONFile(m.fileContent, jsonPath)
+	if err != nil {
+		return nil, err
 	}
 
+	return m.declareTable(c, args, unmarshaled, nil)
+}
+
+// unmarshalJSONFile decodes content, or the value at jsonPath in content if set
+func unmarshalJSONFile(content []byte, jsonPath string) (interface{}, error) {
 	var unmarshaled interface{}
 
 	if jsonPath != "" {
@@ -177,17 +192,87 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 		if err != nil {
 			return nil, fmt.Errorf("invalid JSON path: %s", err)
 		}
-		err = jsonPathStruct.Unmarshal(m.fileContent, &unmarshaled)
+		err = jsonPathStruct.Unmarshal(content, &unmarshaled)
 		if err != nil {
 			return nil, fmt.Errorf("invalid JSON path: %s", err)
 		}
 	} else {
-		err := json.Unmarshal(m.fileContent, &unmarshaled)
+		err := json.Unmarshal(content, &unmarshaled)
 		if err != nil {
 			return nil, fmt.Errorf("invalid JSON: %s", err)
 		}
 	}
+	return unmarshaled, nil
+}
+
+// connectFiles declares a table over several JSON files.
+//
+// Each file is decoded to rows according to its own shape, and the rows of all the files
+// are read as a single array. The files are unmapped once decoded
+func (m *JSONModule) connectFiles(c *sqlite3.SQLiteConn, args []string, files []sourceFile, jsonPath string) (sqlite3.VTab, error) {
+	defer unmapSourceFiles(files)
+
+	rows := []interface{}{}
+	rowFiles := []string{}
+	for _, file := range files {
+		unmarshaled, err := unmarshalJSONFile(file.content, jsonPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file.name, err)
+		}
+
+		fileRows := []interface{}{}
+		switch val := unmarshaled.(type) {
+		case []interface{}:
+			fileRows = val
+		case map[string]interface{}:
+			// A column-shaped object is transposed to one object per row
+			rowCount := 0
+			for _, v := range val {
+				values, ok := v.([]interface{})
+				if !ok {
+					rowCount = -1
+					break
+				}
+				if len(values) > rowCount {
+					rowCount = len(values)
+				}
+			}
+			if rowCount == -1 {
+				fileRows = append(fileRows, val)
+				break
+			}
+			for i := 0; i < rowCount; i++ {
+				row := make(map[string]interface{}, len(val))
+				for k, v := range val {
+					if values := v.([]interface{}); i < len(values) {
+						row[k] = values[i]
+					}
+				}
+				fileRows = append(fileRows, row)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unsupported JSON shape", file.name)
+		}
+
+		rows = append(rows, fileRows...)
+		for range fileRows {
+			rowFiles = append(rowFiles, file.name)
+		}
+	}
+
+	return m.declareTable(c, args, rows, rowFiles)
+}
 
+// jsonFilenameKey is the key of the hidden filename column in the columns of a table.
+// It can't collide with the key of a JSON object, because the NUL character is never
+// part of a column name
+const jsonFilenameKey = "\x00" + sourceFilenameColumn
+
+// declareTable declares the table of the rows in unmarshaled.
+//
+// rowFiles is the file of each row when the rows come from several files (unmarshaled is then
+// an array), and fills the hidden filename column. It's nil for a single file
+func (m *JSONModule) declareTable(c *sqlite3.SQLiteConn, args []string, unmarshaled interface{}, rowFiles []string) (sqlite3.VTab, error) {
 	columns := make(map[string]column)
 
 	rowCount := 0
@@ -197,23 +282,43 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 	switch val := unmarshaled.(type) {
 	case []interface{}:
 		m.tableShape = arrayJsonShape
-		// Find the columns
+		// Find the columns, in the first rows of each file
+		// when the rows come from several files
+		analysed := 0
 		for i, v := range val {
-			if i > maxRowsAnalyse {
-				break
+			if rowFiles != nil && i > 0 && rowFiles[i] != rowFiles[i-1] {
+				analysed = 0
+			}
+			analysed++
+			if analysed > maxRowsAnalyse+1 {
+				if rowFiles == nil {
+					break
+				}
+				continue
 			}
 			switch v.(type) {
 			case map[string]interface{}:
 				recursivelyFindCol("", columns, v.(map[string]interface{}))
 			}
 		}
+		if rowFiles != nil {
+			columns[jsonFilenameKey] = column{
+				typeCol: "string",
+				values:  []interface{}{},
+			}
+		}
 		// Fill the columns
 		i := 1
-		for _, v := range val {
+		for j, v := range val {
 			if _, ok := v.(map[string]interface{}); !ok {
 				continue
 			}
 			recursivelyFillValue("", columns, v.(map[string]interface{}))
+			if rowFiles != nil {
+				col := columns[jsonFilenameKey]
+				col.values = append(col.values, rowFiles[j])
+				columns[jsonFilenameKey] = col
+			}
 			// We check that all columns have the same number of values
 			for k, v := range columns {
 				if len(v.values) < i {
@@ -317,6 +422,24 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 		return nil, fmt.Errorf("no columns found")
 	}
 
+	colNames := make(map[string]string, len(columns))
+	for k := range columns {
+		colName := strings.ReplaceAll(k, "\x1e", ".")
+		colName = strings.ReplaceAll(colName, " ", "_")
+		colName = strings.ReplaceAll(colName, "-", "_")
+		colName = strings.ReplaceAll(colName, "\"", "")
+		colNames[k] = colName
+	}
+	if _, ok := columns[jsonFilenameKey]; ok {
+		others := make([]string, 0, len(colNames))
+		for k, colName := range colNames {
+			if k != jsonFilenameKey {
+				others = append(others, colName)
+			}
+		}
+		colNames[jsonFilenameKey] = sourceFilenameColumnName(others)
+	}
+
 	tableDefinition := strings.Builder{}
 	tableDefinition.WriteString("CREATE TABLE x(")
 
@@ -325,11 +448,7 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 		if i > 0 {
 			tableDefinition.WriteString(", ")
 		}
-		colName := strings.ReplaceAll(k, "\x1e", ".")
-		colName = strings.ReplaceAll(colName, " ", "_")
-		colName = strings.ReplaceAll(colName, "-", "_")
-		colName = strings.ReplaceAll(colName, "\"", "")
-		tableDefinition.WriteString("`" + colName + "`")
+		tableDefinition.WriteString("`" + colNames[k] + "`")
 		switch v.typeCol {
 		case "bool":
 			tableDefinition.WriteString(" BOOLEAN")
@@ -342,6 +461,9 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 		case "array":
 			tableDefinition.WriteString(" TEXT")
 		}
+		if k == jsonFilenameKey {
+			tableDefinition.WriteString(" HIDDEN")
+		}
 		// We store the position of the column
 		v.colPos = i
 		columns[k] = v
@@ -354,18 +476,24 @@ func (m *JSONModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 		return nil, err
 	}
 
+	var file mmap.MMap
+	if rowFiles == nil {
+		file = m.fileContent
+	}
+
 	return &JSONTable{
-		file:     m.fileContent,
+		file:     file,
 		columns:  columns,
 		rowCount: rowCount,
+		profiler: newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *JSONTable) Open() (sqlite3.VTabCursor, error) {
-	return &JSONCursor{
+	return t.profiler.wrap(&JSONCursor{
 		columns:  t.columns,
 		rowCount: t.rowCount,
-	}, nil
+	}), nil
 }
 
 func (t *JSONTable) Disconnect() error {
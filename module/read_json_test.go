@@ -2,6 +2,9 @@ package module
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -62,3 +65,38 @@ func TestJsonModule(t *testing.T) {
 	})
 
 }
+
+func TestJsonMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	// An array of objects, a column-shaped object, and a single object
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.json"), []byte(`[{"id": 1, "name": "alice"}, {"id": 2, "name": "bob"}]`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"id": [3, 4], "name": ["carol", "dave"]}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.json"), []byte(`{"id": 5, "name": "eve", "admin": true}`), 0o600))
+
+	name := "sqlite3-json-multiple"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("json_reader", &JSONModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table people using json_reader('%s')", dir))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	var names []string
+	require.NoError(t, dbx.Select(&names, "select name from people order by id"))
+	require.Equal(t, []string{"alice", "bob", "carol", "dave", "eve"}, names, "the rows of all the files must be read")
+
+	var admin bool
+	require.NoError(t, dbx.Get(&admin, "select admin from people where id = 5"))
+	require.True(t, admin, "a column of the last file only must be found")
+
+	var file string
+	require.NoError(t, dbx.Get(&file, "select filename from people where id = 4"))
+	require.Equal(t, filepath.Join(dir, "b.json"), file, "the filename column must hold the file of the row")
+}
@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/edsrzf/mmap-go"
 	"github.com/goccy/go-json"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 )
@@ -19,17 +18,24 @@ type JSONlModule struct {
 }
 
 type JSONlTable struct {
-	fileContent []byte
-	mmap        mmap.MMap
+	files       []sourceFile
 	colPosition map[int]string
+	// The position of the hidden filename column, -1 if the source is a single file
+	filenameColumn int
+	// Appends the inserted rows to the file (see file_append.go)
+	appender *fileAppender
+	profiler cursorProfiler
 }
 
 type JSONlCursor struct {
-	colPosition map[int]string
-	rowID       int64
-	reader      *json.Decoder
-	tempRow     map[string]interface{}
-	eof         bool
+	colPosition    map[int]string
+	filenameColumn int
+	rowID          int64
+	files          []sourceFile
+	fileIndex      int
+	reader         *json.Decoder
+	tempRow        map[string]interface{}
+	eof            bool
 }
 
 func (m *JSONlModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -38,6 +44,10 @@ func (m *JSONlModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 
 func (v *JSONlModule) DestroyModule() {}
 
+// TransactionModule makes SQLite call the transaction methods of the tables,
+// which write the inserted rows on commit (see file_append.go)
+func (v *JSONlModule) TransactionModule() {}
+
 func findCols(value map[string]interface{}, prefix string, cols map[string]string) {
 	for k, v := range value {
 		// Replace the special characters
@@ -104,29 +114,28 @@ func (m *JSONlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		}
 	}
 
-	fileContent := []byte{}
-	mmap := mmap.MMap{}
+	var files []sourceFile
+	multipleFiles := false
 	var err error
 
 	if fileName == "/dev/stdin" || fileName == "-" || fileName == "stdin" {
 		if !m.Restrictions.AllowStdin() {
 			return nil, fmt.Errorf("sandbox: reading from stdin is not allowed")
 		}
-		fileContent, err = io.ReadAll(os.Stdin)
+		fileContent, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read from stdin: %s", err)
 		}
+		files = []sourceFile{{name: "stdin", content: fileContent}}
 	} else {
-		file, err := openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		files, multipleFiles, err = openSourceFiles(connectionContext(c), fileName, m.Restrictions,
+			time.Duration(cacheTTLParsed)*time.Second, []string{".jsonl", ".ndjson", ".json"})
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %s", err)
 		}
-		mmap = file
-		fileContent = file
-
 	}
 
-	if len(fileContent) == 0 {
+	if len(files[0].content) == 0 {
 		return nil, fmt.Errorf("empty file")
 	}
 
@@ -135,32 +144,40 @@ func (m *JSONlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 
 	mapColPositionName := map[int]string{}
 
-	// Read the maxRowsAnalyse first values to get the columns
+	// Read the maxRowsAnalyse first values of each file to get the columns
 	i := 0
-	var tempValInterface interface{}
-	jsonReader := json.NewDecoder(bytes.NewReader(fileContent))
-	for {
-		if i >= maxRowsAnalyse {
-			break
-		}
-		err = jsonReader.Decode(&tempValInterface)
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return nil, fmt.Errorf("failed to read JSON at iteration %d: %s", i, err)
-		}
+	for _, file := range files {
+		i = 0
+		var tempValInterface interface{}
+		jsonReader := json.NewDecoder(bytes.NewReader(file.content))
+		for {
+			if i >= maxRowsAnalyse {
+				break
+			}
+			err = jsonReader.Decode(&tempValInterface)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				unmapSourceFiles(files)
+				if multipleFiles {
+					return nil, fmt.Errorf("failed to read JSON of %s at iteration %d: %s", file.name, i, err)
+				}
+				return nil, fmt.Errorf("failed to read JSON at iteration %d: %s", i, err)
+			}
 
-		switch tempValInterface.(type) {
-		case map[string]interface{}:
-			findCols(tempValInterface.(map[string]interface{}), "", mapColnameType)
-		default:
-			// If the row is not an object, we continue to the next row
-			continue
-		}
+			switch tempValInterface.(type) {
+			case map[string]interface{}:
+				findCols(tempValInterface.(map[string]interface{}), "", mapColnameType)
+			default:
+				// If the row is not an object, we continue to the next row
+				continue
+			}
 
-		i++
+			i++
+		}
 	}
 	if len(mapColnameType) == 0 {
+		unmapSourceFiles(files)
 		return nil, fmt.Errorf("no column found in the JSON file")
 	}
 
@@ -182,32 +199,117 @@ func (m *JSONlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTa
 		i++
 	}
 
+	filenameColumn := -1
+	if multipleFiles {
+		names := make([]string, 0, len(mapColnameType))
+		for k := range mapColnameType {
+			names = append(names, k)
+		}
+		filenameColumn = i
+		schema.WriteString(", `" + sourceFilenameColumnName(names) + "` TEXT HIDDEN")
+	}
+
 	schema.WriteString(")")
 	c.DeclareVTab(schema.String())
 
 	return &JSONlTable{
-		mmap:        mmap,
-		colPosition: mapColPositionName,
-		fileContent: fileContent,
+		files:          files,
+		colPosition:    mapColPositionName,
+		filenameColumn: filenameColumn,
+		appender:       newFileAppender(c, args, fileName, files, multipleFiles, m.Restrictions),
+		profiler:       newCursorProfiler(c, args),
 	}, nil
 
 }
 
 func (t *JSONlTable) Open() (sqlite3.VTabCursor, error) {
-	return &JSONlCursor{
-		colPosition: t.colPosition,
-		reader:      json.NewDecoder(bytes.NewReader(t.fileContent)),
-	}, nil
+	// The rows inserted since the last scan are read from the file mapped again.
+	// A cursor still reading the former mapping keeps the former slice of files
+	if t.appender.written {
+		source, err := t.appender.refresh(t.files[0])
+		if err != nil {
+			return nil, err
+		}
+		t.files = []sourceFile{source}
+	}
+	return t.profiler.wrap(&JSONlCursor{
+		colPosition:    t.colPosition,
+		filenameColumn: t.filenameColumn,
+		files:          t.files,
+	}), nil
 }
 
 func (t *JSONlTable) Disconnect() error {
-	return nil
+	unmapSourceFiles(t.files)
+	return t.appender.close()
 }
 
 func (t *JSONlTable) Destroy() error {
 	return nil
 }
 
+// Insert appends a row to the file of the table once the transaction is committed.
+// The columns of the nested objects (e.g. address.city) are written as nested objects
+func (t *JSONlTable) Insert(id any, vals []any) (int64, error) {
+	row := map[string]interface{}{}
+	for i := 0; i < len(t.colPosition) && i < len(vals); i++ {
+		value := vals[i]
+		if blob, ok := value.([]byte); ok {
+			value = string(blob)
+		}
+		setNestedJSONValue(row, t.colPosition[i], value)
+	}
+
+	line, err := json.Marshal(row)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode the row: %s", err)
+	}
+	return t.appender.insert(vals, append(line, '\n'))
+}
+
+// Begin is called by SQLite before the first insert of a transaction
+func (t *JSONlTable) Begin() error {
+	return nil
+}
+
+// Commit writes the rows inserted in the transaction to the file
+func (t *JSONlTable) Commit() error {
+	return t.appender.commit()
+}
+
+// Rollback discards the rows inserted in the transaction
+func (t *JSONlTable) Rollback() error {
+	t.appender.rollback()
+	return nil
+}
+
+// setNestedJSONValue sets the value of a column in row,
+// each dot of its name being a level of nested objects
+func setNestedJSONValue(row map[string]interface{}, name string, value interface{}) {
+	parts := strings.Split(name, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := row[part].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			row[part] = child
+		}
+		row = child
+	}
+	row[parts[len(parts)-1]] = value
+}
+
+func (t *JSONlTable) Update(id any, vals []any) error {
+	return fmt.Errorf("UPDATE is not supported by JSON lines files, only INSERT")
+}
+
+func (t *JSONlTable) Delete(id any) error {
+	return fmt.Errorf("DELETE is not supported by JSON lines files, only INSERT")
+}
+
+func (t *JSONlTable) PartialUpdate() bool {
+	return false
+}
+
 func (t *JSONlTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, info sqlite3.IndexInformation) (*sqlite3.IndexResult, error) {
 	return &sqlite3.IndexResult{
 		Used: make([]bool, len(cst)),
@@ -236,7 +338,12 @@ func (t *JSONlCursor) fillTempBuffer() error {
 	t.tempRow = map[string]interface{}{}
 	var mapVal interface{}
 	err := t.reader.Decode(&mapVal)
-	if err == io.EOF {
+	if err == io.EOF && t.fileIndex+1 < len(t.files) {
+		// Continue with the next file
+		t.fileIndex++
+		t.reader = json.NewDecoder(bytes.NewReader(t.files[t.fileIndex].content))
+		return t.fillTempBuffer()
+	} else if err == io.EOF {
 		t.eof = true
 	} else if err != nil {
 		return fmt.Errorf("failed to read JSON: %s", err)
@@ -256,6 +363,8 @@ func (t *JSONlCursor) fillTempBuffer() error {
 func (t *JSONlCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
 	t.rowID = 0
 	t.eof = false
+	t.fileIndex = 0
+	t.reader = json.NewDecoder(bytes.NewReader(t.files[0].content))
 	return t.fillTempBuffer()
 }
 
@@ -265,6 +374,11 @@ func (t *JSONlCursor) Next() error {
 }
 
 func (t *JSONlCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	if col == t.filenameColumn {
+		context.ResultText(t.files[t.fileIndex].name)
+		return nil
+	}
+
 	// Find the column name
 	colName, ok := t.colPosition[col]
 	if !ok {
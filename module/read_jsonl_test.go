@@ -2,6 +2,9 @@ package module
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
@@ -56,3 +59,76 @@ func TestJSONLModule(t *testing.T) {
 	})
 
 }
+
+func TestJSONLMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-01.jsonl"), []byte("{\"id\": 1, \"name\": \"alice\"}\n{\"id\": 2, \"name\": \"bob\"}\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2026-02.jsonl"), []byte("{\"id\": 3, \"city\": \"Paris\"}\n"), 0o600))
+
+	name := "sqlite3-jsonl-multiple"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("jsonl_reader", &JSONlModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table logs using jsonl_reader('%s')", filepath.Join(dir, "2026-*.jsonl")))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	var rowCount int
+	require.NoError(t, dbx.Get(&rowCount, "select count(*) from logs"))
+	require.Equal(t, 3, rowCount, "the rows of both files must be read")
+
+	var city string
+	require.NoError(t, dbx.Get(&city, "select city from logs where id = 3"))
+	require.Equal(t, "Paris", city, "a column of the second file only must be found")
+
+	var files []string
+	require.NoError(t, dbx.Select(&files, "select filename from logs order by id"))
+	require.Equal(t, []string{
+		filepath.Join(dir, "2026-01.jsonl"),
+		filepath.Join(dir, "2026-01.jsonl"),
+		filepath.Join(dir, "2026-02.jsonl"),
+	}, files, "the filename column must hold the file of each row")
+}
+
+func TestJSONLInsert(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\": 1, \"user\": {\"name\": \"alice\"}}\n"), 0o600))
+
+	name := "sqlite3-jsonl-insert"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("jsonl_reader", &JSONlModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table events using jsonl_reader('%s')", path))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	_, err = db.Exec("insert into events(id, `user.name`) values (2, 'bob')")
+	require.NoError(t, err, "inserting must not fail")
+
+	var names []string
+	require.NoError(t, dbx.Select(&names, "select `user.name` from events order by id"))
+	require.Equal(t, []string{"alice", "bob"}, names, "the inserted row must be read back")
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{\"id\": 1, \"user\": {\"name\": \"alice\"}}\n{\"id\":2,\"user\":{\"name\":\"bob\"}}\n", string(content),
+		"the nested columns must be written as nested objects")
+
+	_, err = db.Exec("delete from events")
+	require.ErrorContains(t, err, "DELETE is not supported")
+}
@@ -11,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/edsrzf/mmap-go"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 	"github.com/trivago/grok"
 )
@@ -24,20 +23,25 @@ type LogModule struct {
 }
 
 type LogTable struct {
-	file        []byte
-	mmap        mmap.MMap
+	files       []sourceFile
 	colPosition map[string]int
-	parser      *grok.CompiledGrok
+	// The position of the hidden filename column, -1 if the source is a single file
+	filenameColumn int
+	parser         *grok.CompiledGrok
+	profiler       cursorProfiler
 }
 
 type LogCursor struct {
-	reader      *bufio.Reader
-	eof         bool
-	currentRow  map[int]interface{}
-	colPosition map[string]int
-	parser      *grok.CompiledGrok
-	rowID       int64
-	pattern     string
+	reader         *bufio.Reader
+	files          []sourceFile
+	fileIndex      int
+	filenameColumn int
+	eof            bool
+	currentRow     map[int]interface{}
+	colPosition    map[string]int
+	parser         *grok.CompiledGrok
+	rowID          int64
+	pattern        string
 }
 
 func extractPatternsFromStr(grokTemplate string) map[string]string {
@@ -156,8 +160,8 @@ func (m *LogModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		}
 	}
 
-	file := []byte{}
-	mmap := mmap.MMap{}
+	var files []sourceFile
+	multipleFiles := false
 	var err error
 
 	if fileName == "/dev/stdin" || fileName == "-" || fileName == "stdin" {
@@ -165,19 +169,28 @@ func (m *LogModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 			return nil, fmt.Errorf("sandbox: reading from stdin is not allowed")
 		}
 		// Read from stdin
-		file, err = io.ReadAll(os.Stdin)
+		file, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read from stdin: %s", err)
 		}
+		files = []sourceFile{{name: "stdin", content: file}}
 	} else {
-		// Open the file and mmap it
-		mmap, err = openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		// Open the files and mmap them
+		files, multipleFiles, err = openSourceFiles(connectionContext(c), fileName, m.Restrictions,
+			time.Duration(cacheTTLParsed)*time.Second, nil)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open the file: %s", err)
 		}
-		file = mmap
 	}
 
+	// The files are unmapped if the table can't be created
+	connected := false
+	defer func() {
+		if !connected {
+			unmapSourceFiles(files)
+		}
+	}()
+
 	// The custom grok pattern file is read directly (it does not go through
 	// ParseSource/Fetcher), so it is read through the sandbox policy, which
 	// enforces the allowed directories on the same open it reads from.
@@ -211,6 +224,7 @@ func (m *LogModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 	builder := strings.Builder{}
 	builder.WriteString("CREATE TABLE log (")
 	colPosition := map[string]int{}
+	colNames := []string{}
 	// We incremenent i by ourselves here because we want to skip empty fields
 	// At the same time, it allows us to see if we have any fields at all
 	i := 0
@@ -225,15 +239,22 @@ func (m *LogModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 		builder.WriteString(" ")
 		builder.WriteString("UNKNOWN")
 		colPosition[colName] = i
+		colNames = append(colNames, colName)
 		i++
 	}
-	builder.WriteString(");")
 
 	// Fail if no fields were found
 	if i == 0 {
 		return nil, fmt.Errorf("no fields found in the pattern")
 	}
 
+	filenameColumn := -1
+	if multipleFiles {
+		filenameColumn = i
+		builder.WriteString(", `" + sourceFilenameColumnName(colNames) + "` TEXT HIDDEN")
+	}
+	builder.WriteString(");")
+
 	err = c.DeclareVTab(builder.String())
 
 	if err != nil {
@@ -241,25 +262,30 @@ func (m *LogModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab,
 	}
 
 	// Return the table
+	connected = true
 	return &LogTable{
-		file:        file,
-		mmap:        mmap,
-		colPosition: colPosition,
-		parser:      compiledParser,
+		files:          files,
+		colPosition:    colPosition,
+		filenameColumn: filenameColumn,
+		parser:         compiledParser,
+		profiler:       newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *LogTable) Open() (sqlite3.VTabCursor, error) {
-	return &LogCursor{
-		reader:      bufio.NewReader(bytes.NewReader(t.file)),
-		eof:         false,
-		colPosition: t.colPosition,
-		parser:      t.parser,
-		rowID:       0,
-	}, nil
+	return t.profiler.wrap(&LogCursor{
+		reader:         bufio.NewReader(bytes.NewReader(t.files[0].content)),
+		files:          t.files,
+		filenameColumn: t.filenameColumn,
+		eof:            false,
+		colPosition:    t.colPosition,
+		parser:         t.parser,
+		rowID:          0,
+	}), nil
 }
 
 func (t *LogTable) Disconnect() error {
+	unmapSourceFiles(t.files)
 	return nil
 }
 
@@ -281,7 +307,16 @@ func (t *LogCursor) fillCurrentRow() error {
 
 	// Read the next line
 	line, err := t.reader.ReadBytes('\n')
-	if err == io.EOF {
+	// The last line of a file may not end with a newline
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err == io.EOF && t.fileIndex+1 < len(t.files) {
+		// Continue with the next file
+		t.fileIndex++
+		t.reader = bufio.NewReader(bytes.NewReader(t.files[t.fileIndex].content))
+		return t.fillCurrentRow()
+	} else if err == io.EOF {
 		t.eof = true
 		t.currentRow = nil
 		return nil
@@ -317,6 +352,10 @@ func (t *LogCursor) Next() error {
 }
 
 func (t *LogCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	if col == t.filenameColumn {
+		context.ResultText(t.files[t.fileIndex].name)
+		return nil
+	}
 	if val, ok := t.currentRow[col]; ok {
 		switch identified := val.(type) {
 		case string:
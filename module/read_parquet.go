@@ -9,7 +9,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/edsrzf/mmap-go"
 	"github.com/gammazero/deque"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
 	"github.com/parquet-go/parquet-go"
@@ -20,15 +19,35 @@ type ParquetModule struct {
 }
 
 type ParquetTable struct {
-	mmap   mmap.MMap
+	files  []sourceFile
 	column map[int]parquetColumn
+	// The partition columns found in the paths of the files, declared after the columns
+	// of the files. nil if there are none
+	partitions *hivePartitions
+	// The position of the hidden filename column, -1 if the source is a single file
+	filenameColumn int
+	profiler       cursorProfiler
 }
 
 type ParquetCursor struct {
-	column    map[int]parquetColumn
-	reader    *parquet.GenericReader[any]
-	rowBuffer *deque.Deque[map[string]interface{}]
-	rowID     int64
+	column         map[int]parquetColumn
+	filenameColumn int
+	files          []sourceFile
+	partitions     *hivePartitions
+	// The plan of the query picked by BestIndex, and the values of its constraints
+	index parquetIndex
+	vals  []interface{}
+	// The indexes of the files to read, and the position in it of the file read by reader
+	selected      []int
+	selectedIndex int
+	// The schema of the file read, the columns to decode of its row groups,
+	// and the row groups left to read
+	fileSchema      *parquet.Schema
+	projectedSchema *parquet.Schema
+	rowGroups       []parquet.RowGroup
+	reader          *parquet.GenericReader[any]
+	rowBuffer       *deque.Deque[parquetRow]
+	rowID           int64
 
 	// If cursor.EOF() must return true
 	eof bool
@@ -43,6 +62,12 @@ type parquetColumn struct {
 	SubFields map[string]parquetColumn
 }
 
+// parquetRow is a row read from the file at index file
+type parquetRow struct {
+	values map[string]interface{}
+	file   int
+}
+
 const rowToRequestPerBatch = 16
 
 func (m *ParquetModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
@@ -62,6 +87,7 @@ func (m *ParquetModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.V
 	// file, which is never cached.
 	cacheTTL := "86400"
 	cacheTTLParsed := int64(86400)
+	hivePartitioning := "true"
 
 	params := []argParam{
 		{"file", &fileName},
@@ -76,105 +102,161 @@ func (m *ParquetModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.V
 		{"cacheTTL", &cacheTTL},
 		{"ttl", &cacheTTL},
 		{"cache", &cacheTTL},
+		{"hive_partitioning", &hivePartitioning},
 	}
 
 	parseArgs(params, args)
 
+	useHivePartitioning, err := strconv.ParseBool(hivePartitioning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse hive_partitioning: %s", err)
+	}
+
 	// Open the file
 	if fileName == "" {
 		return nil, fmt.Errorf("missing file to open. Specify it with SELECT * FROM read_parquet('file.parquet')")
 	}
 
 	if cacheTTL != "" {
-		var err error
 		cacheTTLParsed, err = strconv.ParseInt(cacheTTL, 10, 64)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse the cache TTL: %s", err)
 		}
 	}
 
-	// Open the file
-	var mmap mmap.MMap
-	var err error
-
-	mmap, err = openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+	// Open the files
+	var files []sourceFile
+	var multipleFiles bool
+	files, multipleFiles, err = openSourceFiles(connectionContext(c), fileName, m.Restrictions,
+		time.Duration(cacheTTLParsed)*time.Second, []string{".parquet", ".pq"})
 	if err != nil {
 		return nil, fmt.Errorf("failed to open the file: %s", err)
 	}
 
-	byteReader := bytes.NewReader(mmap)
+	column := make(map[int]parquetColumn)
+	// The SQLite type of each column
+	columnTypes := []string{}
+	columnNames := []string{}
+
+	// The columns of the table are the union of the columns of the files.
+	// A column whose type differs between files is a TEXT column
+	for _, file := range files {
+		// Read the schema of the parquet file
+		reader := parquet.NewGenericReader[any](bytes.NewReader(file.content))
+		for _, field := range reader.Schema().Fields() {
+			sqlType := parquetSQLType(field.Type().String())
+			position := -1
+			for i, name := range columnNames {
+				if name == field.Name() {
+					position = i
+					break
+				}
+			}
+			if position >= 0 {
+				if columnTypes[position] != sqlType {
+					columnTypes[position] = "TEXT"
+				}
+				continue
+			}
 
-	// Read the parquet file
-	reader := parquet.NewGenericReader[any](byteReader)
+			// Save the column name
+			col := parquetColumn{
+				Name: field.Name(),
+				Type: field.Type().String(),
+			}
 
-	column := make(map[int]parquetColumn)
+			// Get subfields if the field is a group
+			if field.Type().String() == "group" {
+				col.SubFields = make(map[string]parquetColumn)
+				for _, subField := range field.Fields() {
+					col.SubFields[subField.Name()] = parquetColumn{
+						Name: subField.Name(),
+						Type: subField.Type().String(),
+					}
+				}
+			}
+
+			// Save the column in the map
+			column[len(columnNames)] = col
+			columnNames = append(columnNames, field.Name())
+			columnTypes = append(columnTypes, sqlType)
+		}
+		reader.Close()
+	}
 
 	sqlSchema := strings.Builder{}
 	sqlSchema.WriteString("CREATE TABLE parquet (")
-	for i, field := range reader.Schema().Fields() {
+	validNames := make([]string, len(columnNames))
+	for i, name := range columnNames {
 		if i > 0 {
 			sqlSchema.WriteString(", ")
 		}
+		validNames[i] = transformSQLiteValidName(name)
 		sqlSchema.WriteRune('"')
-		sqlSchema.WriteString(transformSQLiteValidName(field.Name()))
+		sqlSchema.WriteString(validNames[i])
 		sqlSchema.WriteRune('"')
 		sqlSchema.WriteString(" ")
-		switch field.Type().String() {
-		case "BOOLEAN":
-			sqlSchema.WriteString("INTEGER")
-		case "INT32", "INT64", "INT96", "INT(64,true)", "INT(64,false)", "INT(96,true)", "INT(96,false)", "DATE":
-			sqlSchema.WriteString("INTEGER")
-		case "FLOAT", "DOUBLE":
-			sqlSchema.WriteString("REAL")
-		case "BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY", "STRING":
-			sqlSchema.WriteString("TEXT")
-		default:
-			sqlSchema.WriteString("TEXT")
-		}
-
-		// Save the column name
-		col := parquetColumn{
-			Name: field.Name(),
-			Type: field.Type().String(),
-		}
+		sqlSchema.WriteString(columnTypes[i])
+	}
 
-		// Get subfields if the field is a group
-		if field.Type().String() == "group" {
-			col.SubFields = make(map[string]parquetColumn)
-			for _, subField := range field.Fields() {
-				col.SubFields[subField.Name()] = parquetColumn{
-					Name: subField.Name(),
-					Type: subField.Type().String(),
-				}
+	var partitions *hivePartitions
+	filenameColumn := -1
+	if multipleFiles {
+		if useHivePartitioning {
+			paths := make([]string, len(files))
+			for i, file := range files {
+				paths[i] = file.name
 			}
+			partitions = discoverHivePartitions(paths, validNames)
+			partitions.declare(&sqlSchema)
+			validNames = append(validNames, partitions.names()...)
 		}
-
-		// Save the column in the map
-		column[i] = col
+		filenameColumn = len(validNames)
+		sqlSchema.WriteString(", \"" + sourceFilenameColumnName(validNames) + "\" TEXT HIDDEN")
 	}
 
 	sqlSchema.WriteString(");")
 	c.DeclareVTab(sqlSchema.String())
 
-	return &ParquetTable{mmap: mmap, column: column}, nil
+	return &ParquetTable{
+		files:          files,
+		column:         column,
+		partitions:     partitions,
+		filenameColumn: filenameColumn,
+		profiler:       newCursorProfiler(c, args),
+	}, nil
 }
 
-func (t *ParquetTable) Open() (sqlite3.VTabCursor, error) {
-	// Create a new reader
-	reader := parquet.NewGenericReader[any](bytes.NewReader(t.mmap))
+// parquetSQLType returns the SQLite type of a parquet type
+func parquetSQLType(parquetType string) string {
+	switch parquetType {
+	case "BOOLEAN":
+		return "INTEGER"
+	case "INT32", "INT64", "INT96", "INT(64,true)", "INT(64,false)", "INT(96,true)", "INT(96,false)", "DATE":
+		return "INTEGER"
+	case "FLOAT", "DOUBLE":
+		return "REAL"
+	case "BYTE_ARRAY", "FIXED_LEN_BYTE_ARRAY", "STRING":
+		return "TEXT"
+	default:
+		return "TEXT"
+	}
+}
 
-	return &ParquetCursor{
-		column:    t.column,
-		reader:    reader,
-		rowBuffer: new(deque.Deque[map[string]interface{}]),
-	}, nil
+func (t *ParquetTable) Open() (sqlite3.VTabCursor, error) {
+	// The reader is created by Filter, once the files to read are known
+	return t.profiler.wrap(&ParquetCursor{
+		column:         t.column,
+		filenameColumn: t.filenameColumn,
+		files:          t.files,
+		partitions:     t.partitions,
+		rowBuffer:      new(deque.Deque[parquetRow]),
+	}), nil
 }
 
 func (t *ParquetTable) Disconnect() error {
-	// Close the file if it was opened
-	if t.mmap != nil {
-		return t.mmap.Unmap()
-	}
+	// Close the files
+	unmapSourceFiles(t.files)
 	return nil
 }
 
@@ -182,34 +264,169 @@ func (t *ParquetTable) Destroy() error {
 	return nil
 }
 
+// A Parquet file can't be appended to, so the table is read-only.
+// Insert points to COPY, which writes a whole file
+func (t *ParquetTable) Insert(id any, vals []any) (int64, error) {
+	return 0, fmt.Errorf("a Parquet file can't be appended to. Write a new one with COPY (SELECT ...) TO 'file.parquet'")
+}
+
+func (t *ParquetTable) Update(id any, vals []any) error {
+	return fmt.Errorf("UPDATE is not supported by Parquet files")
+}
+
+func (t *ParquetTable) Delete(id any) error {
+	return fmt.Errorf("DELETE is not supported by Parquet files")
+}
+
+func (t *ParquetTable) PartialUpdate() bool {
+	return false
+}
+
 func (t *ParquetTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, info sqlite3.IndexInformation) (*sqlite3.IndexResult, error) {
+	index := parquetIndex{}
+	used := make([]bool, len(cst))
+	cost := 1e6
+	args := 0
+	for i, c := range cst {
+		col, isFileColumn := t.column[c.Column]
+		switch {
+		case t.partitions.usable(c, len(t.column)):
+			// Skip the files whose partition values don't match the constraints
+			index.Partitions = append(index.Partitions, hivePartitionConstraint{Column: c.Column - len(t.column), Op: c.Op, Arg: args})
+			cost /= 10
+		case c.Usable && isFileColumn && isRangeOp(c.Op) && parquetStatsTypes[col.Type]:
+			// Skip the row groups whose statistics don't match the constraints
+			index.Constraints = append(index.Constraints, parquetConstraint{Name: col.Name, Op: c.Op, Arg: args})
+			cost /= 2
+		default:
+			continue
+		}
+		used[i] = true
+		args++
+	}
+
+	// Only decode the columns read by SQLite
+	index.Projected = true
+	for i, isUsed := range columnsUsedFromMask(info.ColUsed, len(t.column)) {
+		if isUsed {
+			index.Columns = append(index.Columns, t.column[i].Name)
+		}
+	}
+
+	marshal, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal the parquet constraints: %w", err)
+	}
 	return &sqlite3.IndexResult{
-		IdxNum: 1,
-		Used:   make([]bool, len(cst)),
+		IdxNum:        1,
+		IdxStr:        string(marshal),
+		Used:          used,
+		EstimatedCost: cost,
 	}, nil
 }
 
 func (t *ParquetCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
-	return t.requestRows()
-}
+	var err error
+	t.index, err = parseParquetIndex(idxStr)
+	if err != nil {
+		return err
+	}
+	t.vals = vals
+	t.selected, err = t.partitions.matchingFiles(len(t.files), t.index.Partitions, vals)
+	if err != nil {
+		return err
+	}
 
-func (t *ParquetCursor) requestRows() error {
-	buffer := make([]any, rowToRequestPerBatch)
-	rowFound, err := t.reader.Read(buffer)
-	if err == io.EOF {
-		t.noMoreRows = true
-	} else if err != nil {
+	// Reset the cursor, Filter being called again for each value of an IN list
+	if t.reader != nil {
+		t.reader.Close()
+		t.reader = nil
+	}
+	t.rowBuffer.Clear()
+	t.selectedIndex = -1
+	t.rowGroups = nil
+	t.rowID = 0
+	t.noMoreRows = false
+	t.eof = false
+
+	if err := t.nextRowGroup(); err != nil {
 		return err
 	}
-	for i := 0; i < rowFound; i++ {
-		if mapVal, ok := buffer[i].(map[string]interface{}); ok {
-			t.rowBuffer.PushBack(mapVal)
+	if !t.noMoreRows {
+		if err := t.requestRows(); err != nil {
+			return err
 		}
 	}
-
+	t.eof = t.rowBuffer.Len() == 0
 	return nil
 }
 
+// nextRowGroup creates the reader of the next row group that may match the constraints,
+// opening the next selected file when the row groups of the current one are exhausted
+func (t *ParquetCursor) nextRowGroup() error {
+	if t.reader != nil {
+		t.reader.Close()
+		t.reader = nil
+	}
+	for {
+		for len(t.rowGroups) > 0 {
+			rowGroup := t.rowGroups[0]
+			t.rowGroups = t.rowGroups[1:]
+			if !parquetRowGroupMayMatch(t.fileSchema, rowGroup, t.index.Constraints, t.vals) {
+				continue
+			}
+			if t.projectedSchema != nil {
+				t.reader = parquet.NewGenericRowGroupReader[any](rowGroup, t.projectedSchema)
+			} else {
+				t.reader = parquet.NewGenericRowGroupReader[any](rowGroup)
+			}
+			return nil
+		}
+
+		if t.selectedIndex+1 >= len(t.selected) {
+			t.noMoreRows = true
+			return nil
+		}
+		// Continue with the next file
+		t.selectedIndex++
+		file := t.files[t.selected[t.selectedIndex]]
+		parquetFile, err := parquet.OpenFile(bytes.NewReader(file.content), int64(len(file.content)))
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", file.name, err)
+		}
+		t.fileSchema = parquetFile.Schema()
+		t.projectedSchema = projectParquetSchema(t.fileSchema, t.index)
+		t.rowGroups = parquetFile.RowGroups()
+	}
+}
+
+func (t *ParquetCursor) requestRows() error {
+	for {
+		buffer := make([]any, rowToRequestPerBatch)
+		rowFound, err := t.reader.Read(buffer)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		for i := 0; i < rowFound; i++ {
+			if mapVal, ok := buffer[i].(map[string]interface{}); ok {
+				t.rowBuffer.PushBack(parquetRow{values: mapVal, file: t.selected[t.selectedIndex]})
+			}
+		}
+
+		if err == io.EOF {
+			if err := t.nextRowGroup(); err != nil {
+				return err
+			}
+			if t.noMoreRows {
+				return nil
+			}
+		}
+		if rowFound > 0 {
+			return nil
+		}
+	}
+}
+
 func (t *ParquetCursor) Next() error {
 	if t.rowBuffer.Len() != 0 {
 		t.rowBuffer.PopFront()
@@ -235,12 +452,19 @@ func (t *ParquetCursor) Next() error {
 }
 
 func (t *ParquetCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	if col == t.filenameColumn {
+		context.ResultText(t.files[t.rowBuffer.Front().file].name)
+		return nil
+	}
+	if t.partitions.resultColumn(context, t.rowBuffer.Front().file, col, len(t.column)) {
+		return nil
+	}
 	colName, ok := t.column[col]
 	if !ok {
 		context.ResultNull()
 		return nil
 	}
-	val, ok := t.rowBuffer.Front()[colName.Name]
+	val, ok := t.rowBuffer.Front().values[colName.Name]
 	if !ok {
 		context.ResultNull()
 		return nil
@@ -322,5 +546,8 @@ func (t *ParquetCursor) Rowid() (int64, error) {
 }
 
 func (t *ParquetCursor) Close() error {
+	if t.reader == nil {
+		return nil
+	}
 	return t.reader.Close()
 }
@@ -0,0 +1,149 @@
+package module
+
+import (
+	"encoding/json"
+	"errors"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/parquet-go/parquet-go"
+)
+
+// A Parquet file is split in row groups, and the footer of the file stores the
+// min and max values of each column of each row group. BestIndex passes the comparisons
+// of the query (=, <, <=, >, >=) to Filter, which skips the row groups whose
+// statistics exclude them, and only decodes the columns SQLite reads.
+//
+// Like for the partitions (see hive_partitions.go), SQLite still checks the constraints
+// on each row, so a row group is only skipped when none of its rows can match.
+
+// parquetIndex is the plan picked by BestIndex, serialized in IdxStr for Filter
+type parquetIndex struct {
+	// The constraints on the partition columns, skipping whole files
+	Partitions []hivePartitionConstraint `json:"partitions,omitempty"`
+	// The constraints checked against the statistics of the row groups
+	Constraints []parquetConstraint `json:"constraints,omitempty"`
+	// The columns of the files read by the query. Nil means all of them
+	Columns []string `json:"columns,omitempty"`
+	// Whether Columns is set, an empty list meaning that no column of the files is read
+	// (e.g. SELECT count(*))
+	Projected bool `json:"projected,omitempty"`
+}
+
+// parquetConstraint is a comparison of a column of the files with the value at index Arg
+// in the values of Filter
+type parquetConstraint struct {
+	Name string     `json:"name"`
+	Op   sqlite3.Op `json:"op"`
+	Arg  int        `json:"arg"`
+}
+
+// parquetStatsTypes are the types whose statistics compare like the values
+// returned to SQLite. The other types (unsigned integers, dates, timestamps, decimals,
+// binary) are converted on the way, so their row groups are never skipped.
+// Strings are left out too: their statistics are compared byte-wise,
+// while a comparison in SQLite may use another collation (e.g. NOCASE)
+var parquetStatsTypes = map[string]bool{
+	"INT32":        true,
+	"INT64":        true,
+	"INT(8,true)":  true,
+	"INT(16,true)": true,
+	"INT(32,true)": true,
+	"INT(64,true)": true,
+	"FLOAT":        true,
+	"DOUBLE":       true,
+}
+
+// parseParquetIndex unmarshals the plan serialized by BestIndex
+func parseParquetIndex(idxStr string) (parquetIndex, error) {
+	index := parquetIndex{}
+	if idxStr == "" {
+		return index, nil
+	}
+	if err := json.Unmarshal([]byte(idxStr), &index); err != nil {
+		return index, errors.Join(errors.New("could not unmarshal the parquet constraints"), err)
+	}
+	return index, nil
+}
+
+// projectParquetSchema returns the schema of the columns of a file read by the query,
+// or nil to read all of them
+func projectParquetSchema(schema *parquet.Schema, index parquetIndex) *parquet.Schema {
+	if !index.Projected {
+		return nil
+	}
+	read := make(map[string]bool, len(index.Columns))
+	for _, name := range index.Columns {
+		read[name] = true
+	}
+
+	fields := schema.Fields()
+	group := parquet.Group{}
+	for _, field := range fields {
+		if read[field.Name()] {
+			group[field.Name()] = field
+		}
+	}
+	if len(group) == len(fields) {
+		return nil
+	}
+	// The rows must still be counted when no column is read
+	if len(group) == 0 && len(fields) > 0 {
+		group[fields[0].Name()] = fields[0]
+	}
+	return parquet.NewSchema(schema.Name(), group)
+}
+
+// parquetRowGroupMayMatch reports whether the statistics of a row group allow
+// one of its rows to match constraints. schema is the schema of the file
+func parquetRowGroupMayMatch(schema *parquet.Schema, rowGroup parquet.RowGroup, constraints []parquetConstraint, vals []interface{}) bool {
+	chunks := rowGroup.ColumnChunks()
+	for _, c := range constraints {
+		if c.Arg >= len(vals) {
+			return true
+		}
+		leaf, ok := schema.Lookup(c.Name)
+		if !ok || len(leaf.Path) != 1 || leaf.Node.Repeated() || leaf.ColumnIndex >= len(chunks) {
+			continue
+		}
+		if !parquetStatsTypes[leaf.Node.Type().String()] {
+			continue
+		}
+		chunk, ok := chunks[leaf.ColumnIndex].(interface {
+			Bounds() (min, max parquet.Value, ok bool)
+		})
+		if !ok {
+			continue
+		}
+		min, max, ok := chunk.Bounds()
+		if !ok {
+			continue
+		}
+		minValue, maxValue := parquetStatsValue(min), parquetStatsValue(max)
+		if minValue == nil || maxValue == nil {
+			continue
+		}
+		if !rangeMayMatch(minValue, maxValue, c.Op, vals[c.Arg]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parquetStatsValue converts a min or max value of the statistics of a column
+// to the type of the values returned to SQLite. It returns nil for the other kinds
+func parquetStatsValue(v parquet.Value) interface{} {
+	if v.IsNull() {
+		return nil
+	}
+	switch v.Kind() {
+	case parquet.Int32:
+		return int64(v.Int32())
+	case parquet.Int64:
+		return v.Int64()
+	case parquet.Float:
+		return float64(v.Float())
+	case parquet.Double:
+		return v.Double()
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package module
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/require"
+)
+
+type pushdownRow struct {
+	ID    int64   `parquet:"id"`
+	Score float64 `parquet:"score"`
+	Name  string  `parquet:"name"`
+	Flag  bool    `parquet:"flag"`
+}
+
+// pushdownRows returns 100 rows, written in row groups of 10 rows
+// whose ids are 0-9, 10-19, etc.
+func pushdownRows() []pushdownRow {
+	rows := make([]pushdownRow, 100)
+	for i := range rows {
+		rows[i] = pushdownRow{
+			ID:    int64(i),
+			Score: float64(i) / 2,
+			Name:  fmt.Sprintf("name-%02d", i),
+			Flag:  i%2 == 0,
+		}
+	}
+	return rows
+}
+
+func TestParquetRowGroupStatistics(t *testing.T) {
+	buffer := bytes.Buffer{}
+	require.NoError(t, parquet.Write(&buffer, pushdownRows(), parquet.MaxRowsPerRowGroup(10)))
+	file, err := parquet.OpenFile(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	require.Len(t, file.RowGroups(), 10)
+
+	tests := []struct {
+		name        string
+		constraints []parquetConstraint
+		vals        []interface{}
+		expected    []int
+	}{
+		{"id = 42", []parquetConstraint{{Name: "id", Op: sqlite3.OpEQ}}, []interface{}{int64(42)}, []int{4}},
+		{"id >= 85", []parquetConstraint{{Name: "id", Op: sqlite3.OpGE}}, []interface{}{int64(85)}, []int{8, 9}},
+		{"id < 10.5", []parquetConstraint{{Name: "id", Op: sqlite3.OpLT}}, []interface{}{10.5}, []int{0, 1}},
+		{"score > 45", []parquetConstraint{{Name: "score", Op: sqlite3.OpGT}}, []interface{}{int64(45)}, []int{9}},
+		{"name <= 'name-05' is left to SQLite", []parquetConstraint{{Name: "name", Op: sqlite3.OpLE}}, []interface{}{"name-05"}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{
+			"id > 20 and id < 40",
+			[]parquetConstraint{{Name: "id", Op: sqlite3.OpGT, Arg: 0}, {Name: "id", Op: sqlite3.OpLT, Arg: 1}},
+			[]interface{}{int64(20), int64(40)}, []int{2, 3},
+		},
+		{"id = NULL", []parquetConstraint{{Name: "id", Op: sqlite3.OpEQ}}, []interface{}{nil}, []int{}},
+		{"id = '42' can't be decided", []parquetConstraint{{Name: "id", Op: sqlite3.OpEQ}}, []interface{}{"42"}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"flag has no usable statistics", []parquetConstraint{{Name: "flag", Op: sqlite3.OpEQ}}, []interface{}{int64(2)}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"unknown column", []parquetConstraint{{Name: "missing", Op: sqlite3.OpEQ}}, []interface{}{int64(2)}, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matching := []int{}
+			for i, rowGroup := range file.RowGroups() {
+				if parquetRowGroupMayMatch(file.Schema(), rowGroup, test.constraints, test.vals) {
+					matching = append(matching, i)
+				}
+			}
+			require.Equal(t, test.expected, matching)
+		})
+	}
+}
+
+func TestProjectParquetSchema(t *testing.T) {
+	schema := parquet.SchemaOf(pushdownRow{})
+
+	require.Nil(t, projectParquetSchema(schema, parquetIndex{}), "all the columns must be read without projection")
+	require.Nil(t, projectParquetSchema(schema, parquetIndex{Projected: true, Columns: []string{"id", "score", "name", "flag"}}))
+
+	projected := projectParquetSchema(schema, parquetIndex{Projected: true, Columns: []string{"name", "unknown"}})
+	require.Len(t, projected.Fields(), 1)
+	require.Equal(t, "name", projected.Fields()[0].Name())
+
+	projected = projectParquetSchema(schema, parquetIndex{Projected: true})
+	require.Len(t, projected.Fields(), 1, "a column must be kept to count the rows")
+}
+
+func TestParquetPushdownSQL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scores.parquet")
+	require.NoError(t, parquet.WriteFile(path, pushdownRows(), parquet.MaxRowsPerRowGroup(10)))
+
+	name := "sqlite3-parquet-pushdown"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("parquet_reader", &ParquetModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table scores using parquet_reader('%s')", path))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	tests := []struct {
+		query    string
+		expected []int64
+	}{
+		{"select id from scores where id = 42", []int64{42}},
+		{"select id from scores where id between 18 and 21 order by id", []int64{18, 19, 20, 21}},
+		{"select id from scores where id in (5, 95) order by id", []int64{5, 95}},
+		{"select id from scores where score > 48.5 and name like 'name-%' order by id", []int64{98, 99}},
+		{"select id from scores where name >= 'name-97' order by id", []int64{97, 98, 99}},
+		{"select id from scores where name = 'NAME-05' collate nocase", []int64{5}},
+		{"select id from scores where id = '7'", []int64{7}},
+		{"select id from scores where id > 1000", []int64{}},
+		{"select count(*) from scores where flag", []int64{50}},
+		{"select count(*) from scores", []int64{100}},
+	}
+	for _, test := range tests {
+		ids := []int64{}
+		require.NoError(t, dbx.Select(&ids, test.query), test.query)
+		require.Equal(t, test.expected, ids, test.query)
+	}
+
+	var row struct {
+		Name  string  `db:"name"`
+		Score float64 `db:"score"`
+	}
+	require.NoError(t, dbx.Get(&row, "select name, score from scores where id = 64"))
+	require.Equal(t, "name-64", row.Name)
+	require.Equal(t, 32.0, row.Score)
+}
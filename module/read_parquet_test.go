@@ -2,10 +2,14 @@ package module
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jmoiron/sqlx"
 	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/parquet-go/parquet-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -56,3 +60,123 @@ func TestParquetModule(t *testing.T) {
 	})
 
 }
+
+func TestParquetMultipleFiles(t *testing.T) {
+	type partA struct {
+		ID   int64  `parquet:"id"`
+		Name string `parquet:"name"`
+	}
+	type partB struct {
+		ID    int64   `parquet:"id"`
+		Name  string  `parquet:"name"`
+		Score float64 `parquet:"score"`
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "shards"), 0o700))
+	require.NoError(t, parquet.WriteFile(filepath.Join(dir, "shards", "part-0.parquet"), []partA{{1, "alice"}, {2, "bob"}}))
+	require.NoError(t, parquet.WriteFile(filepath.Join(dir, "shards", "part-1.parquet"), []partB{{3, "carol", 9.5}}))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "shards", "_SUCCESS"), []byte("done"), 0o600))
+
+	name := "sqlite3-parquet-multiple"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("parquet_reader", &ParquetModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table shards using parquet_reader('%s')", filepath.Join(dir, "shards")))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	var names []string
+	require.NoError(t, dbx.Select(&names, "select name from shards order by id"))
+	require.Equal(t, []string{"alice", "bob", "carol"}, names, "the rows of both files must be read")
+
+	var score float64
+	require.NoError(t, dbx.Get(&score, "select score from shards where id = 3"))
+	require.Equal(t, 9.5, score, "a column of the second file only must be found")
+
+	var files []string
+	require.NoError(t, dbx.Select(&files, "select distinct filename from shards order by filename"))
+	require.Equal(t, []string{
+		filepath.Join(dir, "shards", "part-0.parquet"),
+		filepath.Join(dir, "shards", "part-1.parquet"),
+	}, files, "the filename column must hold the file of each row")
+}
+
+func TestParquetHivePartitions(t *testing.T) {
+	type event struct {
+		ID   int64  `parquet:"id"`
+		Kind string `parquet:"kind"`
+	}
+
+	dir := t.TempDir()
+	for _, partition := range []struct {
+		path   string
+		events []event
+	}{
+		{"year=2025/month=12", []event{{1, "click"}}},
+		{"year=2026/month=1", []event{{2, "click"}, {3, "view"}}},
+		{"year=2026/month=2", []event{{4, "view"}}},
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, partition.path), 0o700))
+		require.NoError(t, parquet.WriteFile(filepath.Join(dir, partition.path, "part-0.parquet"), partition.events))
+	}
+
+	name := "sqlite3-parquet-hive"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("parquet_reader", &ParquetModule{})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table events using parquet_reader('%s')", dir))
+	require.NoError(t, err, "creating the virtual table must not fail")
+
+	rows, err := dbx.Query("select * from events limit 1")
+	require.NoError(t, err, "querying must not fail")
+	columns, err := rows.Columns()
+	require.NoError(t, err, "getting columns must not fail")
+	require.NoError(t, rows.Close())
+	require.Equal(t, []string{"id", "kind", "year", "month"}, columns, "the partition keys must be columns")
+
+	tests := []struct {
+		query    string
+		expected []int64
+	}{
+		{"select id from events where year = 2026 order by id", []int64{2, 3, 4}},
+		{"select id from events where year = 2026 and month = 1 and kind = 'view'", []int64{3}},
+		{"select id from events where month in (2, 12) order by id", []int64{1, 4}},
+		{"select id from events where year < 2026 or month > 1 order by id", []int64{1, 4}},
+		{"select id from events where year = '2026' and month >= 2", []int64{4}},
+		{"select id from events where year = 2024", []int64{}},
+	}
+	for _, test := range tests {
+		ids := []int64{}
+		require.NoError(t, dbx.Select(&ids, test.query), test.query)
+		require.Equal(t, test.expected, ids, test.query)
+	}
+
+	var month int64
+	require.NoError(t, dbx.Get(&month, "select month from events where id = 4"))
+	require.Equal(t, int64(2), month, "a partition column must hold the value of the path of the file")
+
+	_, err = db.Exec(fmt.Sprintf("create virtual table raw_events using parquet_reader('%s', hive_partitioning=false)", dir))
+	require.NoError(t, err, "creating the virtual table must not fail")
+	rows, err = dbx.Query("select * from raw_events limit 1")
+	require.NoError(t, err, "querying must not fail")
+	columns, err = rows.Columns()
+	require.NoError(t, err, "getting columns must not fail")
+	require.NoError(t, rows.Close())
+	require.Equal(t, []string{"id", "kind"}, columns, "hive_partitioning=false must not add the partition columns")
+}
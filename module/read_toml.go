@@ -19,8 +19,9 @@ type TomlModule struct {
 }
 
 type TomlTable struct {
-	rows []interfaceRow
-	mmap mmap.MMap
+	rows     []interfaceRow
+	mmap     mmap.MMap
+	profiler cursorProfiler
 }
 
 type TomlCursor struct {
@@ -89,7 +90,7 @@ func (m *TomlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 			return nil, fmt.Errorf("failed to read from stdin: %s", err)
 		}
 	} else {
-		content, err = openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		content, err = openMmapedFile(connectionContext(c), fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %s", err)
 		}
@@ -109,15 +110,16 @@ func (m *TomlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 	c.DeclareVTab("CREATE TABLE x(key TEXT, value TEXT)")
 
 	return &TomlTable{
-		rows: rows,
-		mmap: mmap,
+		rows:     rows,
+		mmap:     mmap,
+		profiler: newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *TomlTable) Open() (sqlite3.VTabCursor, error) {
-	return &TomlCursor{
+	return t.profiler.wrap(&TomlCursor{
 		rows: t.rows,
-	}, nil
+	}), nil
 }
 
 func (t *TomlTable) Disconnect() error {
@@ -0,0 +1,354 @@
+package module
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// XlsxModule reads a sheet of an Excel (.xlsx) or OpenDocument (.ods) spreadsheet
+// (see spreadsheet.go)
+type XlsxModule struct {
+	Restrictions *Restrictions
+}
+
+type XlsxTable struct {
+	columns  []columnCsv
+	records  [][]string
+	profiler cursorProfiler
+}
+
+type XlsxCursor struct {
+	columns []columnCsv
+	records [][]string
+	rowID   int64
+}
+
+func (m *XlsxModule) Create(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	return m.Connect(c, args)
+}
+
+func (v *XlsxModule) DestroyModule() {}
+
+func (m *XlsxModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab, error) {
+	fileName := ""
+	sheet := ""
+	cellRange := ""
+	// Empty means that the header is detected like for a CSV file
+	useHeaderStr := ""
+	// Freshness of the remote download cache, in seconds. Ignored for a local
+	// file, which is never cached.
+	cacheTTL := "86400"
+	cacheTTLParsed := int64(86400)
+
+	if len(args) > 3 {
+		fileName = strings.Trim(args[3], "'\" ")
+	}
+
+	params := []argParam{
+		{"file", &fileName},
+		{"sheet", &sheet},
+		{"range", &cellRange},
+		{"header", &useHeaderStr},
+		{"headers", &useHeaderStr},
+		// Alias
+		{"use_header", &useHeaderStr},
+		{"sheet_name", &sheet},
+		// RANGE is a reserved word of the parser rewriting read_xlsx(...),
+		// so range= must be quoted as a whole there ('range=A1:D20')
+		{"cell_range", &cellRange},
+		{"file_name", &fileName},
+		{"filename", &fileName},
+		{"src", &fileName},
+		{"path", &fileName},
+		{"file_path", &fileName},
+		{"filepath", &fileName},
+		{"url", &fileName},
+		{"cache_ttl", &cacheTTL},
+		{"cacheTTL", &cacheTTL},
+		{"ttl", &cacheTTL},
+		{"cache", &cacheTTL},
+	}
+	parseArgs(params, args)
+
+	if fileName == "" {
+		return nil, fmt.Errorf("missing file argument. Specify it as: SELECT * FROM read_xlsx('report.xlsx', sheet='Sales');")
+	}
+
+	headerSet := useHeaderStr != ""
+	useHeader := false
+	if headerSet {
+		var err error
+		useHeader, err = strconv.ParseBool(useHeaderStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse header: %s", err)
+		}
+	}
+
+	bounds, err := parseSpreadsheetRange(cellRange)
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheTTL != "" {
+		cacheTTLParsed, err = strconv.ParseInt(cacheTTL, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the cache TTL: %s", err)
+		}
+	}
+
+	// The whole sheet is read when the table is connected, so the file is unmapped right away
+	var records [][]string
+	if fileName == "stdin" || fileName == "/dev/stdin" || fileName == "-" {
+		if !m.Restrictions.AllowStdin() {
+			return nil, fmt.Errorf("sandbox: reading from stdin is not allowed")
+		}
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from stdin: %s", err)
+		}
+		records, err = readSpreadsheet(content, sheet)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		content, err := openMmapedFile(connectionContext(c), fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %s", err)
+		}
+		records, err = readSpreadsheet(content, sheet)
+		content.Unmap()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	records = bounds.crop(records)
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the sheet is empty")
+	}
+
+	// The types are inferred from the head of the sheet, like for a CSV file
+	if !headerSet {
+		useHeader = detectCsvHeader(records[:min(len(records), csvSniffMaxRecords)])
+	}
+	var header []string
+	if useHeader {
+		header = records[0]
+		records = records[1:]
+	}
+	width := bounds.width(header, records)
+	if width == 0 {
+		return nil, fmt.Errorf("the sheet is empty")
+	}
+
+	sample := records[:min(len(records), csvSniffMaxRecords)]
+	columns := make([]columnCsv, width)
+	used := map[string]bool{}
+	for i := range columns {
+		name := ""
+		if i < len(header) {
+			name = transformSQLiteValidName(header[i])
+		}
+		if name == "" {
+			name = fmt.Sprintf("col%d", i)
+		}
+		// A sheet often has several columns with the same title (or none),
+		// which SQLite refuses
+		for unique, n := name, 2; ; n++ {
+			if !used[strings.ToLower(unique)] {
+				name = unique
+				break
+			}
+			unique = fmt.Sprintf("%s_%d", name, n)
+		}
+		used[strings.ToLower(name)] = true
+		columns[i] = columnCsv{
+			name:    name,
+			colType: detectCsvColumnType(sample, i),
+		}
+	}
+
+	tableStatement := strings.Builder{}
+	tableStatement.WriteString("CREATE TABLE x(")
+	for i, col := range columns {
+		if i > 0 {
+			tableStatement.WriteString(", ")
+		}
+		tableStatement.WriteString("`" + col.name + "`")
+		switch col.colType {
+		case "int", "bool":
+			tableStatement.WriteString(" INTEGER")
+		case "float":
+			tableStatement.WriteString(" REAL")
+		default:
+			tableStatement.WriteString(" TEXT")
+		}
+	}
+	tableStatement.WriteString(")")
+
+	if err := c.DeclareVTab(tableStatement.String()); err != nil {
+		return nil, fmt.Errorf("failed to declare the table: %s", err)
+	}
+
+	return &XlsxTable{
+		columns:  columns,
+		records:  records,
+		profiler: newCursorProfiler(c, args),
+	}, nil
+}
+
+// spreadsheetRange is the range of cells read from a sheet, in the A1 notation
+// (A1:D20, B3, B3:D, A:C, 2:10). The rows and columns start at 0,
+// and the last ones are -1 when the range is open
+type spreadsheetRange struct {
+	firstRow, firstCol int
+	lastRow, lastCol   int
+}
+
+// parseSpreadsheetRange parses the range argument of xlsx_reader.
+// An empty range is the whole sheet
+func parseSpreadsheetRange(s string) (spreadsheetRange, error) {
+	bounds := spreadsheetRange{lastRow: -1, lastCol: -1}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return bounds, nil
+	}
+	start, end, hasEnd := strings.Cut(s, ":")
+
+	col, row, err := parseCellReference(strings.TrimSpace(start))
+	if err != nil {
+		return bounds, fmt.Errorf("failed to parse range: %s", err)
+	}
+	bounds.firstCol, bounds.firstRow = max(col, 0), max(row, 0)
+	if !hasEnd {
+		return bounds, nil
+	}
+
+	bounds.lastCol, bounds.lastRow, err = parseCellReference(strings.TrimSpace(end))
+	if err != nil {
+		return bounds, fmt.Errorf("failed to parse range: %s", err)
+	}
+	if (bounds.lastCol >= 0 && bounds.lastCol < bounds.firstCol) || (bounds.lastRow >= 0 && bounds.lastRow < bounds.firstRow) {
+		return bounds, fmt.Errorf("invalid range %q: the end is before the start", s)
+	}
+	return bounds, nil
+}
+
+// crop returns the cells of records within the range, without the empty rows ending the sheet
+func (r spreadsheetRange) crop(records [][]string) [][]string {
+	if r.firstRow >= len(records) {
+		return nil
+	}
+	if r.lastRow >= 0 && r.lastRow+1 < len(records) {
+		records = records[:r.lastRow+1]
+	}
+	records = records[r.firstRow:]
+
+	cropped := make([][]string, len(records))
+	for i, record := range records {
+		if r.firstCol >= len(record) {
+			continue
+		}
+		if r.lastCol >= 0 && r.lastCol+1 < len(record) {
+			record = record[:r.lastCol+1]
+		}
+		cropped[i] = record[r.firstCol:]
+	}
+
+	for len(cropped) > 0 && isEmptyRecord(cropped[len(cropped)-1]) {
+		cropped = cropped[:len(cropped)-1]
+	}
+	return cropped
+}
+
+// width returns the number of columns of the table: the width of the range if it ends
+// with a column, or the last column with a value otherwise
+func (r spreadsheetRange) width(header []string, records [][]string) int {
+	if r.lastCol >= 0 {
+		return r.lastCol - r.firstCol + 1
+	}
+	width := 0
+	for _, record := range append([][]string{header}, records...) {
+		for i := len(record); i > width; i-- {
+			if record[i-1] != "" {
+				width = i
+				break
+			}
+		}
+	}
+	return width
+}
+
+func isEmptyRecord(record []string) bool {
+	for _, cell := range record {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *XlsxTable) Open() (sqlite3.VTabCursor, error) {
+	return t.profiler.wrap(&XlsxCursor{
+		columns: t.columns,
+		records: t.records,
+	}), nil
+}
+
+func (t *XlsxTable) Disconnect() error {
+	return nil
+}
+
+func (t *XlsxTable) Destroy() error {
+	return nil
+}
+
+func (t *XlsxTable) BestIndex(cst []sqlite3.InfoConstraint, ob []sqlite3.InfoOrderBy, info sqlite3.IndexInformation) (*sqlite3.IndexResult, error) {
+	return &sqlite3.IndexResult{
+		Used: make([]bool, len(cst)),
+	}, nil
+}
+
+func (t *XlsxCursor) Filter(idxNum int, idxStr string, vals []interface{}) error {
+	t.rowID = 0
+	return nil
+}
+
+func (t *XlsxCursor) Next() error {
+	t.rowID++
+	return nil
+}
+
+func (t *XlsxCursor) Column(context *sqlite3.SQLiteContext, col int) error {
+	if t.rowID >= int64(len(t.records)) || col >= len(t.columns) {
+		context.ResultNull()
+		return nil
+	}
+
+	// An empty cell is NULL, whatever the type of its column
+	record := t.records[t.rowID]
+	if col >= len(record) || record[col] == "" {
+		context.ResultNull()
+		return nil
+	}
+	resultCsvValue(context, t.columns[col].colType, record[col])
+	return nil
+}
+
+func (t *XlsxCursor) EOF() bool {
+	return t.rowID >= int64(len(t.records))
+}
+
+func (t *XlsxCursor) Rowid() (int64, error) {
+	return t.rowID, nil
+}
+
+func (t *XlsxCursor) Close() error {
+	return nil
+}
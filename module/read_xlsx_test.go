@@ -0,0 +1,278 @@
+package module
+
+import (
+	"archive/zip"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+	"github.com/stretchr/testify/require"
+)
+
+// writeZipFixture writes a zip archive holding files (name -> content) at path
+func writeZipFixture(t *testing.T, path string, files [][2]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	for _, f := range files {
+		w, err := archive.Create(f[0])
+		require.NoError(t, err)
+		_, err = w.Write([]byte(f[1]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+}
+
+// xlsxFixture has a sheet "Sales" with a header, a gap and every type of cell,
+// and a sheet "Notes" whose table starts at B3 below a title
+var xlsxFixture = [][2]string{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8"?><Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"/>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+	<workbookPr/>
+	<sheets>
+		<sheet name="Sales" sheetId="1" r:id="rId1"/>
+		<sheet name="Notes" sheetId="2" r:id="rId2"/>
+	</sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+	<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+	<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`},
+	{"xl/sharedStrings.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+	<si><t>Region</t></si>
+	<si><t>Amount</t></si>
+	<si><t>Date</t></si>
+	<si><t>Paid</t></si>
+	<si><r><rPr><b/></rPr><t>Nor</t></r><r><t>th</t></r></si>
+</sst>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+	<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd hh:mm"/></numFmts>
+	<cellXfs count="3">
+		<xf numFmtId="0"/>
+		<xf numFmtId="14"/>
+		<xf numFmtId="164"/>
+	</cellXfs>
+</styleSheet>`},
+	{"xl/worksheets/sheet1.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+	<sheetData>
+		<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>
+		<row r="2"><c r="A2" t="s"><v>4</v></c><c r="B2"><v>12.5</v></c><c r="C2" s="1"><v>45306</v></c><c r="D2" t="b"><v>1</v></c></row>
+		<row r="3"><c r="A3" t="inlineStr"><is><t>South</t></is></c><c r="B3"><v>7</v></c><c r="C3" s="2"><v>45306.5</v></c><c r="D3" t="b"><v>0</v></c></row>
+		<row r="5"><c r="A5" t="str"><v>East</v></c><c r="B5" t="e"><v>#DIV/0!</v></c><c r="D5" t="b"><v>1</v></c><c r="F5" s="1"/></row>
+	</sheetData>
+</worksheet>`},
+	{"xl/worksheets/sheet2.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+	<sheetData>
+		<row r="1"><c r="A1" t="inlineStr"><is><t>Quarterly notes</t></is></c></row>
+		<row r="3"><c r="B3" t="inlineStr"><is><t>id</t></is></c><c r="C3" t="inlineStr"><is><t>note</t></is></c></row>
+		<row r="4"><c r="B4"><v>1</v></c><c r="C4" t="inlineStr"><is><t>first</t></is></c></row>
+		<row r="5"><c r="B5"><v>2</v></c><c r="C5" t="inlineStr"><is><t>second</t></is></c><c r="D5"><v>99</v></c></row>
+	</sheetData>
+</worksheet>`},
+}
+
+// odsFixture has a sheet "Empty" and a sheet "Scores" using the repeated rows and cells of ODS
+var odsFixture = [][2]string{
+	{"mimetype", "application/vnd.oasis.opendocument.spreadsheet"},
+	{"content.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content
+	xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet>
+	<table:table table:name="Empty"><table:table-row table:number-rows-repeated="1048576"><table:table-cell table:number-columns-repeated="1024"/></table:table-row></table:table>
+	<table:table table:name="Scores">
+		<table:table-row>
+			<table:table-cell office:value-type="string"><text:p>name</text:p></table:table-cell>
+			<table:table-cell office:value-type="string"><text:p>score</text:p></table:table-cell>
+			<table:table-cell office:value-type="string"><text:p>played</text:p></table:table-cell>
+			<table:table-cell office:value-type="string"><text:p>duration</text:p></table:table-cell>
+			<table:table-cell office:value-type="string"><text:p>won</text:p></table:table-cell>
+			<table:table-cell table:number-columns-repeated="16378"/>
+		</table:table-row>
+		<table:table-row>
+			<table:table-cell office:value-type="string"><text:p>Ada</text:p><text:p>Lovelace<text:s text:c="2"/>!</text:p><office:annotation><text:p>a comment</text:p></office:annotation></table:table-cell>
+			<table:table-cell office:value-type="float" office:value="0.1"><text:p>0.10</text:p></table:table-cell>
+			<table:table-cell office:value-type="date" office:date-value="2024-03-01T08:30:00"><text:p>03/01/24</text:p></table:table-cell>
+			<table:table-cell office:value-type="time" office:time-value="PT01H05M30S"><text:p>01:05</text:p></table:table-cell>
+			<table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell>
+		</table:table-row>
+		<table:table-row table:number-rows-repeated="2">
+			<table:table-cell office:value-type="string"><text:p>Bob</text:p></table:table-cell>
+			<table:table-cell office:value-type="percentage" office:value="2" table:number-columns-repeated="1"><text:p>200%</text:p></table:table-cell>
+			<table:table-cell/>
+			<table:covered-table-cell/>
+			<table:table-cell office:value-type="boolean" office:boolean-value="false"><text:p>FALSE</text:p></table:table-cell>
+		</table:table-row>
+		<table:table-row table:number-rows-repeated="1048572"><table:table-cell table:number-columns-repeated="16384"/></table:table-row>
+	</table:table>
+</office:spreadsheet></office:body>
+</office:document-content>`},
+}
+
+func TestXlsxModule(t *testing.T) {
+	dir := t.TempDir()
+	xlsxPath := filepath.Join(dir, "report.xlsx")
+	odsPath := filepath.Join(dir, "scores.ods")
+	writeZipFixture(t, xlsxPath, xlsxFixture)
+	writeZipFixture(t, odsPath, odsFixture)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fake.xlsx"), []byte("a,b\n1,2\n"), 0o600))
+
+	name := "sqlite3-xlsx"
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.CreateModule("xlsx_reader", &XlsxModule{Restrictions: nil})
+		},
+	})
+	db, err := sql.Open(name, ":memory:")
+	require.NoError(t, err, "opening connection must not fail")
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+	dbx := sqlx.NewDb(db, name)
+
+	columns := func(t *testing.T, table string) []string {
+		rows, err := dbx.Query("select * from " + table + " limit 1")
+		require.NoError(t, err, "querying must not fail")
+		defer rows.Close()
+		columns, err := rows.Columns()
+		require.NoError(t, err, "getting columns must not fail")
+		return columns
+	}
+
+	t.Run("The first sheet is read with its header and types", func(t *testing.T) {
+		_, err := db.Exec(fmt.Sprintf("create virtual table sales using xlsx_reader('%s')", xlsxPath))
+		require.NoError(t, err, "creating the virtual table must not fail")
+		require.Equal(t, []string{"Region", "Amount", "Date", "Paid"}, columns(t, "sales"))
+
+		type row struct {
+			Region sql.NullString  `db:"Region"`
+			Amount sql.NullFloat64 `db:"Amount"`
+			Date   sql.NullString  `db:"Date"`
+			Paid   sql.NullInt64   `db:"Paid"`
+		}
+		rows := []row{}
+		require.NoError(t, dbx.Select(&rows, "select * from sales"))
+		require.Equal(t, []row{
+			{sql.NullString{String: "North", Valid: true}, sql.NullFloat64{Float64: 12.5, Valid: true}, sql.NullString{String: "2024-01-15", Valid: true}, sql.NullInt64{Int64: 1, Valid: true}},
+			{sql.NullString{String: "South", Valid: true}, sql.NullFloat64{Float64: 7, Valid: true}, sql.NullString{String: "2024-01-15 12:00:00", Valid: true}, sql.NullInt64{Int64: 0, Valid: true}},
+			{},
+			{sql.NullString{String: "East", Valid: true}, sql.NullFloat64{}, sql.NullString{}, sql.NullInt64{Int64: 1, Valid: true}},
+		}, rows, "the empty row and the cells with an error must be NULL")
+	})
+
+	t.Run("A sheet is selected by name or position, and a range by its cells", func(t *testing.T) {
+		_, err := db.Exec(fmt.Sprintf("create virtual table notes using xlsx_reader('%s', sheet='Notes', range='B3:C5')", xlsxPath))
+		require.NoError(t, err, "creating the virtual table must not fail")
+		require.Equal(t, []string{"id", "note"}, columns(t, "notes"))
+
+		var notes []string
+		require.NoError(t, dbx.Select(&notes, "select note from notes where id >= 1 order by id"))
+		require.Equal(t, []string{"first", "second"}, notes)
+
+		_, err = db.Exec(fmt.Sprintf("create virtual table second using xlsx_reader('%s', sheet=2, range='B3', header=false)", xlsxPath))
+		require.NoError(t, err, "creating the virtual table must not fail")
+		require.Equal(t, []string{"col0", "col1", "col2"}, columns(t, "second"))
+
+		var count int
+		require.NoError(t, dbx.Get(&count, "select count(*) from second"))
+		require.Equal(t, 3, count, "the header row must be returned as data")
+	})
+
+	t.Run("An ODS sheet is read with its repeated cells", func(t *testing.T) {
+		_, err := db.Exec(fmt.Sprintf("create virtual table scores using xlsx_reader('%s', sheet='Scores')", odsPath))
+		require.NoError(t, err, "creating the virtual table must not fail")
+		require.Equal(t, []string{"name", "score", "played", "duration", "won"}, columns(t, "scores"))
+
+		type row struct {
+			Name     string         `db:"name"`
+			Score    float64        `db:"score"`
+			Played   sql.NullString `db:"played"`
+			Duration sql.NullString `db:"duration"`
+			Won      bool           `db:"won"`
+		}
+		rows := []row{}
+		require.NoError(t, dbx.Select(&rows, "select * from scores"))
+		require.Equal(t, []row{
+			{"Ada\nLovelace  !", 0.1, sql.NullString{String: "2024-03-01 08:30:00", Valid: true}, sql.NullString{String: "01:05:30", Valid: true}, true},
+			{"Bob", 2, sql.NullString{}, sql.NullString{}, false},
+			{"Bob", 2, sql.NullString{}, sql.NullString{}, false},
+		}, rows, "the trailing empty rows must be dropped")
+	})
+
+	t.Run("Invalid arguments are reported", func(t *testing.T) {
+		_, err := db.Exec(fmt.Sprintf("create virtual table missing using xlsx_reader('%s', sheet='Budget')", xlsxPath))
+		require.ErrorContains(t, err, "Sales, Notes", "the error must list the sheets")
+
+		_, err = db.Exec(fmt.Sprintf("create virtual table empty using xlsx_reader('%s', sheet=1)", odsPath))
+		require.ErrorContains(t, err, "empty")
+
+		_, err = db.Exec(fmt.Sprintf("create virtual table badrange using xlsx_reader('%s', range='C3:A1')", xlsxPath))
+		require.ErrorContains(t, err, "range")
+
+		_, err = db.Exec(fmt.Sprintf("create virtual table fake using xlsx_reader('%s')", filepath.Join(dir, "fake.xlsx")))
+		require.ErrorContains(t, err, "not an XLSX or ODS spreadsheet")
+	})
+}
+
+func TestSpreadsheetValues(t *testing.T) {
+	dateFormats := map[string]bool{
+		"General":                 false,
+		"0.00":                    false,
+		"#,##0.00 \"days\"":       false,
+		"0.00;[Red]-0.00":         false,
+		"_(* #,##0_);_(* (#,##0)": false,
+		"yyyy-mm-dd":              true,
+		"[$-409]mmmm d, yyyy":     true,
+		"[h]:mm":                  true,
+		"hh:mm:ss AM/PM":          true,
+	}
+	for code, want := range dateFormats {
+		require.Equal(t, want, isDateNumberFormat(code), code)
+	}
+
+	serials := []struct {
+		serial   float64
+		date1904 bool
+		want     string
+	}{
+		{45306, false, "2024-01-15"},
+		{45306.75, false, "2024-01-15 18:00:00"},
+		{0.5, false, "12:00:00"},
+		{1, false, "1899-12-31"},
+		{0, true, "00:00:00"},
+		{43844, true, "2024-01-15"},
+	}
+	for _, test := range serials {
+		require.Equal(t, test.want, formatSpreadsheetSerial(test.serial, test.date1904), test.serial)
+	}
+
+	ranges := map[string]spreadsheetRange{
+		"":         {lastRow: -1, lastCol: -1},
+		"B3":       {firstRow: 2, firstCol: 1, lastRow: -1, lastCol: -1},
+		"b3:d":     {firstRow: 2, firstCol: 1, lastRow: -1, lastCol: 3},
+		"A:C":      {lastRow: -1, lastCol: 2},
+		"2:10":     {firstRow: 1, lastRow: 9, lastCol: -1},
+		"AA1:AB20": {firstCol: 26, lastRow: 19, lastCol: 27},
+	}
+	for s, want := range ranges {
+		got, err := parseSpreadsheetRange(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, got, s)
+	}
+	for _, s := range []string{"1A", "B0", "A1:", "XFE1", "C3:A1"} {
+		_, err := parseSpreadsheetRange(s)
+		require.Error(t, err, s)
+	}
+}
@@ -24,8 +24,9 @@ type YamlModule struct {
 }
 
 type YamlTable struct {
-	rows []interfaceRow
-	mmap mmap.MMap
+	rows     []interfaceRow
+	mmap     mmap.MMap
+	profiler cursorProfiler
 }
 
 type YamlCursor struct {
@@ -130,7 +131,7 @@ func (m *YamlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 			return nil, fmt.Errorf("failed to read from stdin: %s", err)
 		}
 	} else {
-		content, err = openMmapedFile(fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
+		content, err = openMmapedFile(connectionContext(c), fileName, m.Restrictions, time.Duration(cacheTTLParsed)*time.Second)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open file: %s", err)
 		}
@@ -150,15 +151,16 @@ func (m *YamlModule) Connect(c *sqlite3.SQLiteConn, args []string) (sqlite3.VTab
 	c.DeclareVTab("CREATE TABLE x(key TEXT, value TEXT)")
 
 	return &YamlTable{
-		rows: rows,
-		mmap: mmap,
+		rows:     rows,
+		mmap:     mmap,
+		profiler: newCursorProfiler(c, args),
 	}, nil
 }
 
 func (t *YamlTable) Open() (sqlite3.VTabCursor, error) {
-	return &YamlCursor{
+	return t.profiler.wrap(&YamlCursor{
 		rows: t.rows,
-	}, nil
+	}), nil
 }
 
 func (t *YamlTable) Disconnect() error {
@@ -14,8 +14,8 @@ import (
 // A nil *Restrictions means "no restrictions" — the default for local CLI use,
 // where the operator is trusted. A non-nil value enforces the policy; its zero
 // value is maximally restrictive (no readable directories, no remote fetches,
-// no on-disk ATTACH, and the database reader modules disabled). Every method is
-// safe to call on a nil receiver.
+// no on-disk ATTACH, no file writes, and the database reader modules
+// disabled). Every method is safe to call on a nil receiver.
 //
 // The policy is enforced in two layers that share this object:
 //   - the SQLite authorizer (namespace package) gates ATTACH / VACUUM INTO,
@@ -48,6 +48,11 @@ type Restrictions struct {
 	// RCE) vector.
 	AllowDBConnections bool
 
+	// AllowWrite permits writing files: INSERT into a csv_reader/jsonl_reader
+	// table appends to its file, and COPY ... TO creates one. The paths are
+	// still confined to AllowedDirs, through OpenLocalForWrite.
+	AllowWrite bool
+
 	// dirsOnce/dirs back OpenLocal (localfile.go): AllowedDirs resolved into
 	// os.Root handles, built lazily on first use and cached for the lifetime
 	// of this Restrictions value. Do not copy a Restrictions after it has
@@ -0,0 +1,278 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/edsrzf/mmap-go"
+)
+
+// A local source of a reader may name several files: a glob pattern
+// (read_csv('logs/2026-*/*.csv')) or a directory (read_parquet('shards/')).
+// The files are read one after the other as a single table, whose columns are
+// the union of the columns of the files, and the hidden column filename tells
+// which file a row comes from.
+//
+// Listing the files is confined to Restrictions.AllowedDirs like opening them
+// (see GlobLocal and WalkLocal), and every match is still opened through
+// OpenLocal by the Fetcher.
+
+// maxSourceFiles caps the files of a source, because each one is memory-mapped
+// for as long as the table lives.
+const maxSourceFiles = 10000
+
+// sourceFilenameColumn is the hidden column holding the file of a row
+// when the source names several files
+const sourceFilenameColumn = "filename"
+
+// sourceFile is one of the files named by the source of a reader
+type sourceFile struct {
+	// The path of the file, returned by the filename column
+	name    string
+	content []byte
+	// The mapping of content, unmapped when the table is disconnected.
+	// It is nil when content was read from stdin
+	mmap mmap.MMap
+}
+
+// hasGlobMeta reports whether p holds one of the special characters of filepath.Match
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// expandSource returns the files named by src, and whether src names several files.
+//
+// A remote source, stdin or a path to a file is returned as is. A glob pattern is
+// expanded (a file named literally like the pattern wins), and a directory is walked
+// recursively for the files whose extension is one of extensions (all of them if empty).
+// The hidden files and the files starting with an underscore (e.g. _SUCCESS) are skipped,
+// as well as the empty files.
+func expandSource(src string, r *Restrictions, extensions []string) ([]string, bool, error) {
+	s, err := ParseSource(src)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.Kind != KindLocal {
+		return []string{src}, false, nil
+	}
+
+	var matches []string
+	file, openErr := r.OpenLocal(s.Path)
+	if openErr == nil {
+		info, err := file.Stat()
+		file.Close()
+		if err != nil {
+			return nil, false, err
+		}
+		if !info.IsDir() {
+			return []string{src}, false, nil
+		}
+		matches, err = r.WalkLocal(s.Path)
+		if err != nil {
+			return nil, false, err
+		}
+		matches = filterSourceFiles(matches, extensions)
+	} else if hasGlobMeta(s.Path) {
+		matches, err = r.GlobLocal(s.Path)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		// Let the Fetcher report why the file can't be opened
+		return []string{src}, false, nil
+	}
+
+	if len(matches) == 0 {
+		return nil, false, fmt.Errorf("no file matches %q", src)
+	}
+	if len(matches) > maxSourceFiles {
+		return nil, false, fmt.Errorf("%q matches %d files, more than the maximum of %d", src, len(matches), maxSourceFiles)
+	}
+	return matches, true, nil
+}
+
+// filterSourceFiles keeps the files of a directory whose extension is one of extensions,
+// ignoring the compression extension (e.g. data.csv.gz is a .csv file)
+func filterSourceFiles(files []string, extensions []string) []string {
+	if len(extensions) == 0 {
+		return files
+	}
+	filtered := files[:0]
+	for _, file := range files {
+		name := strings.ToLower(file)
+		for _, suffix := range []string{".gz", ".zst", ".zstd"} {
+			name = strings.TrimSuffix(name, suffix)
+		}
+		for _, extension := range extensions {
+			if strings.HasSuffix(name, extension) {
+				filtered = append(filtered, file)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// isIgnoredSourceFile reports whether a file or directory found while expanding a source
+// is skipped: hidden files, and the markers written by Spark and Hadoop (_SUCCESS, _temporary)
+func isIgnoredSourceFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// openSourceFiles expands src (see expandSource) and memory-maps each of its files.
+// The second value reports whether src names several files
+func openSourceFiles(ctx context.Context, src string, r *Restrictions, ttl time.Duration, extensions []string) ([]sourceFile, bool, error) {
+	names, multiple, err := expandSource(src, r, extensions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	files := make([]sourceFile, 0, len(names))
+	for _, name := range names {
+		content, err := openMmapedFile(ctx, name, r, ttl)
+		if err != nil {
+			unmapSourceFiles(files)
+			if multiple {
+				return nil, false, fmt.Errorf("%s: %w", name, err)
+			}
+			return nil, false, err
+		}
+		files = append(files, sourceFile{name: name, content: content, mmap: content})
+	}
+	return files, multiple, nil
+}
+
+// unmapSourceFiles unmaps the files opened by openSourceFiles
+func unmapSourceFiles(files []sourceFile) {
+	for _, file := range files {
+		if file.mmap != nil {
+			file.mmap.Unmap()
+		}
+	}
+}
+
+// sourceFilenameColumnName returns the name of the hidden filename column,
+// prefixed by underscores until it doesn't collide with one of the columns of the files
+func sourceFilenameColumnName(columns []string) string {
+	name := sourceFilenameColumn
+	for {
+		collides := false
+		for _, column := range columns {
+			if strings.EqualFold(column, name) {
+				collides = true
+				break
+			}
+		}
+		if !collides {
+			return name
+		}
+		name = "_" + name
+	}
+}
+
+// localFS returns the file system p is confined to, the directory it is rooted at,
+// and the path of p relative to it (with forward slashes, as io/fs expects).
+//
+// Under a policy, it's the os.Root of the allowed directory containing p,
+// so that listing files can't escape it any more than opening them (see OpenLocal).
+func (r *Restrictions) localFS(p string) (fs.FS, string, string, error) {
+	target, err := filepath.Abs(p)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if r == nil {
+		root := filepath.VolumeName(target) + string(os.PathSeparator)
+		rel, err := filepath.Rel(root, target)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return os.DirFS(root), root, filepath.ToSlash(rel), nil
+	}
+
+	if strings.TrimSpace(p) == "" {
+		return nil, "", "", fmt.Errorf("sandbox: empty file path is not allowed")
+	}
+	resolvedTarget := resolveHint(p)
+	for _, d := range r.buildAllowedDirs() {
+		base := d.given
+		rel, ok := relWithin(d.given, target)
+		if !ok {
+			base = d.resolved
+			rel, ok = relWithin(d.resolved, resolvedTarget)
+		}
+		if ok {
+			return d.root.FS(), base, filepath.ToSlash(rel), nil
+		}
+	}
+	return nil, "", "", fmt.Errorf("sandbox: access to %q is not allowed; permitted directories: %v", p, r.AllowedDirs)
+}
+
+// GlobLocal returns the regular files matching pattern (see path.Match), sorted by name.
+// r == nil means unrestricted; otherwise, the files are listed inside r.AllowedDirs only.
+// The hidden files and the empty files are skipped
+func (r *Restrictions) GlobLocal(pattern string) ([]string, error) {
+	fsys, base, rel, err := r.localFS(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := fs.Glob(fsys, rel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+
+	files := []string{}
+	for _, match := range matches {
+		if isIgnoredSourceFile(filepath.Base(match)) {
+			continue
+		}
+		info, err := fs.Stat(fsys, match)
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			continue
+		}
+		files = append(files, filepath.Join(base, filepath.FromSlash(match)))
+	}
+	return files, nil
+}
+
+// WalkLocal returns the regular files below dir, recursively and sorted by path.
+// r == nil means unrestricted; otherwise, dir must be inside r.AllowedDirs.
+// The hidden files and directories, and the empty files are skipped
+func (r *Restrictions) WalkLocal(dir string) ([]string, error) {
+	fsys, base, rel, err := r.localFS(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	err = fs.WalkDir(fsys, rel, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != rel && isIgnoredSourceFile(entry.Name()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		// The entry of a symlink describes the link, not its target
+		info, err := fs.Stat(fsys, p)
+		if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+			return nil
+		}
+		files = append(files, filepath.Join(base, filepath.FromSlash(p)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
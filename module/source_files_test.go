@@ -0,0 +1,150 @@
+package module
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSourceTree creates the files of a partitioned dataset under a temp dir:
+// two CSV shards, a nested one, and the files a reader must skip.
+func writeSourceTree(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, sub := range []string{"2026-01", "2026-02", "2026-02/late", ".git", "_temporary"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTempFile(t, dir, "2026-01/a.csv", "id\n1\n")
+	writeTempFile(t, dir, "2026-02/b.csv", "id\n2\n")
+	writeTempFile(t, dir, "2026-02/late/c.csv", "id\n3\n")
+	writeTempFile(t, dir, "2026-02/notes.md", "not a csv")
+	writeTempFile(t, dir, "2026-02/empty.csv", "")
+	writeTempFile(t, dir, "2026-02/.hidden.csv", "id\n4\n")
+	writeTempFile(t, dir, "_SUCCESS.csv", "id\n5\n")
+	writeTempFile(t, dir, ".git/config.csv", "id\n6\n")
+	writeTempFile(t, dir, "_temporary/part.csv", "id\n7\n")
+	return dir
+}
+
+func relPaths(t *testing.T, dir string, files []string) []string {
+	t.Helper()
+	rel := make([]string, len(files))
+	for i, file := range files {
+		r, err := filepath.Rel(dir, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel[i] = filepath.ToSlash(r)
+	}
+	return rel
+}
+
+func TestExpandSource(t *testing.T) {
+	dir := writeSourceTree(t)
+	extensions := []string{".csv"}
+
+	for _, r := range []*Restrictions{nil, {AllowedDirs: []string{dir}}} {
+		t.Run("glob", func(t *testing.T) {
+			files, multiple, err := expandSource(filepath.Join(dir, "2026-*", "*.csv"), r, extensions)
+			if err != nil {
+				t.Fatalf("expandSource: %v", err)
+			}
+			if !multiple {
+				t.Fatal("a glob must name several files")
+			}
+			got := strings.Join(relPaths(t, dir, files), ",")
+			if got != "2026-01/a.csv,2026-02/b.csv" {
+				t.Fatalf("unexpected matches: %s", got)
+			}
+		})
+
+		t.Run("directory", func(t *testing.T) {
+			files, multiple, err := expandSource(dir, r, extensions)
+			if err != nil {
+				t.Fatalf("expandSource: %v", err)
+			}
+			if !multiple {
+				t.Fatal("a directory must name several files")
+			}
+			got := strings.Join(relPaths(t, dir, files), ",")
+			if got != "2026-01/a.csv,2026-02/b.csv,2026-02/late/c.csv" {
+				t.Fatalf("unexpected files: %s", got)
+			}
+		})
+
+		t.Run("single file", func(t *testing.T) {
+			path := filepath.Join(dir, "2026-01", "a.csv")
+			files, multiple, err := expandSource(path, r, extensions)
+			if err != nil {
+				t.Fatalf("expandSource: %v", err)
+			}
+			if multiple || len(files) != 1 || files[0] != path {
+				t.Fatalf("a file must be returned as is, got %v", files)
+			}
+		})
+
+		t.Run("no match", func(t *testing.T) {
+			_, _, err := expandSource(filepath.Join(dir, "*.parquet"), r, extensions)
+			if err == nil || !strings.Contains(err.Error(), "no file matches") {
+				t.Fatalf("expected a no match error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("remote sources are not expanded", func(t *testing.T) {
+		files, multiple, err := expandSource("https://example.com/data/*.csv", nil, extensions)
+		if err != nil || multiple || len(files) != 1 {
+			t.Fatalf("expected the URL as is, got %v, %v", files, err)
+		}
+	})
+}
+
+func TestExpandSourceSandbox(t *testing.T) {
+	dir := writeSourceTree(t)
+	outside := t.TempDir()
+	writeTempFile(t, outside, "secret.csv", "id\n42\n")
+
+	r := &Restrictions{AllowedDirs: []string{dir}}
+
+	for _, src := range []string{
+		filepath.Join(outside, "*.csv"),
+		outside,
+		filepath.Join(dir, "..", filepath.Base(outside), "*.csv"),
+	} {
+		_, _, err := openSourceFiles(context.Background(), src, r, 0, []string{".csv"})
+		if err == nil || !strings.Contains(err.Error(), "sandbox:") {
+			t.Fatalf("expanding %q outside the allowed dirs must be denied, got %v", src, err)
+		}
+	}
+
+	// A symlink inside the allowed dir must not list the files it points to
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	files, _, err := expandSource(filepath.Join(dir, "escape", "*.csv"), r, []string{".csv"})
+	if err == nil {
+		t.Fatalf("a glob through a symlink escaping the allowed dir must fail, got %v", files)
+	}
+	files, _, err = expandSource(dir, r, []string{".csv"})
+	if err != nil {
+		t.Fatalf("expandSource: %v", err)
+	}
+	for _, file := range files {
+		if strings.Contains(file, "secret") {
+			t.Fatalf("walking the allowed dir must not follow a symlink out of it, got %v", files)
+		}
+	}
+}
+
+func TestSourceFilenameColumnName(t *testing.T) {
+	if got := sourceFilenameColumnName([]string{"id", "name"}); got != "filename" {
+		t.Fatalf("expected filename, got %s", got)
+	}
+	if got := sourceFilenameColumnName([]string{"FileName", "_filename"}); got != "__filename" {
+		t.Fatalf("expected __filename, got %s", got)
+	}
+}
@@ -0,0 +1,623 @@
+package module
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A spreadsheet is a zip archive of XML files: the Office Open XML format of Excel (.xlsx)
+// or the OpenDocument format of LibreOffice (.ods).
+//
+// A sheet is read into records of text cells, like the records of a CSV file, so that
+// xlsx_reader infers the types of its columns like csv_reader (see read_csv_sniff.go).
+// The numbers are written in their shortest form, the booleans as true or false,
+// and the dates in the ISO 8601 format (2006-01-02 15:04:05).
+
+// The limits of a sheet in Excel. A sheet referencing a cell beyond them is rejected
+// rather than filling the memory with empty cells
+const (
+	maxSpreadsheetRows    = 1 << 20
+	maxSpreadsheetColumns = 1 << 14
+)
+
+// readSpreadsheet returns the records of a sheet of the XLSX or ODS file in content.
+// sheet is the name of the sheet or its position starting at 1. If empty, the first sheet is read
+func readSpreadsheet(content []byte, sheet string) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("the file is not an XLSX or ODS spreadsheet: %w", err)
+	}
+	if zipFile(archive, "xl/workbook.xml") != nil {
+		return readXlsxSheet(archive, sheet)
+	}
+	if zipFile(archive, "content.xml") != nil {
+		return readOdsSheet(archive, sheet)
+	}
+	return nil, errors.New("the file is not an XLSX or ODS spreadsheet")
+}
+
+// zipFile returns the file of archive at name, or nil if there is none
+func zipFile(archive *zip.Reader, name string) *zip.File {
+	for _, file := range archive.File {
+		if file.Name == name {
+			return file
+		}
+	}
+	return nil
+}
+
+// decodeZipXML unmarshals the XML file of archive at name into v.
+// A missing file leaves v untouched
+func decodeZipXML(archive *zip.Reader, name string, v interface{}) error {
+	file := zipFile(archive, name)
+	if file == nil {
+		return nil
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(reader).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return nil
+}
+
+// selectSheet returns the index in names of the sheet named sheet, or at the position sheet
+func selectSheet(names []string, sheet string) (int, error) {
+	if len(names) == 0 {
+		return 0, errors.New("the spreadsheet has no sheet")
+	}
+	if sheet == "" {
+		return 0, nil
+	}
+	for i, name := range names {
+		if name == sheet {
+			return i, nil
+		}
+	}
+	if position, err := strconv.Atoi(sheet); err == nil && position >= 1 && position <= len(names) {
+		return position - 1, nil
+	}
+	return 0, fmt.Errorf("sheet %q not found. The sheets are %s", sheet, strings.Join(names, ", "))
+}
+
+// appendCell sets the cell at col of record, padding it with empty cells
+func appendCell(record []string, col int, value string) []string {
+	for len(record) < col {
+		record = append(record, "")
+	}
+	return append(record, value)
+}
+
+// formatSpreadsheetNumber writes a number stored in a spreadsheet in its shortest form
+// (Excel stores 0.1 as 0.10000000000000001)
+func formatSpreadsheetNumber(value string) string {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(parsed, 0) || math.IsNaN(parsed) {
+		return value
+	}
+	return strconv.FormatFloat(parsed, 'f', -1, 64)
+}
+
+// formatSpreadsheetDate writes an ISO 8601 date (2006-01-02T15:04:05) with a space
+// between the date and the time, like the SQLite date functions
+func formatSpreadsheetDate(value string) string {
+	value = strings.TrimSuffix(value, "Z")
+	return strings.Replace(value, "T", " ", 1)
+}
+
+// XLSX
+
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is a string made of a text or of runs of rich text
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	builder := strings.Builder{}
+	builder.WriteString(t.Text)
+	for _, run := range t.Runs {
+		builder.WriteString(run.Text)
+	}
+	return builder.String()
+}
+
+type xlsxStyles struct {
+	NumberFormats []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellFormats []struct {
+		NumberFormatID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxRow struct {
+	Index int        `xml:"r,attr"`
+	Cells []xlsxCell `xml:"c"`
+}
+
+type xlsxCell struct {
+	Reference string   `xml:"r,attr"`
+	Type      string   `xml:"t,attr"`
+	Style     int      `xml:"s,attr"`
+	Value     string   `xml:"v"`
+	Inline    xlsxText `xml:"is"`
+}
+
+// xlsxBuiltinDateFormats are the built-in number formats of Excel displaying a date or a time
+var xlsxBuiltinDateFormats = map[int]bool{
+	14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true,
+	27: true, 28: true, 29: true, 30: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	45: true, 46: true, 47: true,
+	50: true, 51: true, 52: true, 53: true, 54: true, 55: true, 56: true, 57: true, 58: true,
+}
+
+// isDateNumberFormat reports whether the code of a number format displays a date or a time,
+// skipping its literal texts ("text", \c, _c, *c) and its colors and conditions ([Red])
+func isDateNumberFormat(code string) bool {
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; c {
+		case '"':
+			end := strings.IndexByte(code[i+1:], '"')
+			if end < 0 {
+				return false
+			}
+			i += end + 1
+		case '\\', '_', '*':
+			i++
+		case '[':
+			// [h], [mm] and [ss] are elapsed times
+			if i+1 < len(code) && strings.IndexByte("hHmMsS", code[i+1]) >= 0 {
+				return true
+			}
+			end := strings.IndexByte(code[i:], ']')
+			if end < 0 {
+				return false
+			}
+			i += end
+		case 'y', 'Y', 'm', 'M', 'd', 'D', 'h', 'H', 's', 'S':
+			return true
+		}
+	}
+	return false
+}
+
+// formatSpreadsheetSerial writes the serial number of a date in Excel (the days since 1899-12-30,
+// or since 1904-01-01 with the 1904 date system) as a date, a time, or both
+func formatSpreadsheetSerial(serial float64, date1904 bool) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 86400)
+	date := epoch.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	switch {
+	case days == 0:
+		return date.Format(time.TimeOnly)
+	case seconds == 0:
+		return date.Format(time.DateOnly)
+	default:
+		return date.Format(time.DateTime)
+	}
+}
+
+// parseCellReference parses a reference in the A1 notation. The column and the row start at 0,
+// and are -1 when the reference doesn't have them (e.g. A or 12)
+func parseCellReference(reference string) (int, int, error) {
+	col, row := -1, -1
+	i := 0
+	for ; i < len(reference); i++ {
+		c := reference[i] | 0x20 // lowercase
+		if c < 'a' || c > 'z' {
+			break
+		}
+		col = (col+1)*26 + int(c-'a')
+		if col >= maxSpreadsheetColumns {
+			return 0, 0, fmt.Errorf("invalid cell reference %q: the column is out of range", reference)
+		}
+	}
+	if i < len(reference) {
+		parsed, err := strconv.Atoi(reference[i:])
+		if err != nil || parsed < 1 || parsed > maxSpreadsheetRows {
+			return 0, 0, fmt.Errorf("invalid cell reference %q", reference)
+		}
+		row = parsed - 1
+	}
+	if col < 0 && row < 0 {
+		return 0, 0, fmt.Errorf("invalid cell reference %q", reference)
+	}
+	return col, row, nil
+}
+
+// readXlsxSheet returns the records of a sheet of an XLSX file
+func readXlsxSheet(archive *zip.Reader, sheet string) ([][]string, error) {
+	workbook := xlsxWorkbook{}
+	if err := decodeZipXML(archive, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(workbook.Sheets))
+	for i, s := range workbook.Sheets {
+		names[i] = s.Name
+	}
+	index, err := selectSheet(names, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	// The path of the sheet is in the relationships of the workbook
+	relationships := xlsxRelationships{}
+	if err := decodeZipXML(archive, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, err
+	}
+	sheetPath := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == workbook.Sheets[index].ID {
+			if strings.HasPrefix(relationship.Target, "/") {
+				sheetPath = strings.TrimPrefix(relationship.Target, "/")
+			} else {
+				sheetPath = path.Join("xl", relationship.Target)
+			}
+			break
+		}
+	}
+	sheetFile := zipFile(archive, sheetPath)
+	if sheetFile == nil {
+		return nil, fmt.Errorf("the file of the sheet %q is missing", names[index])
+	}
+
+	sharedStrings := xlsxSharedStrings{}
+	if err := decodeZipXML(archive, "xl/sharedStrings.xml", &sharedStrings); err != nil {
+		return nil, err
+	}
+
+	// The cells whose style has a date format hold the serial number of a date
+	styles := xlsxStyles{}
+	if err := decodeZipXML(archive, "xl/styles.xml", &styles); err != nil {
+		return nil, err
+	}
+	customFormats := map[int]string{}
+	for _, format := range styles.NumberFormats {
+		customFormats[format.ID] = format.Code
+	}
+	dateStyles := make([]bool, len(styles.CellFormats))
+	for i, format := range styles.CellFormats {
+		if code, ok := customFormats[format.NumberFormatID]; ok {
+			dateStyles[i] = isDateNumberFormat(code)
+		} else {
+			dateStyles[i] = xlsxBuiltinDateFormats[format.NumberFormatID]
+		}
+	}
+
+	reader, err := sheetFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the sheet %q: %w", names[index], err)
+	}
+	defer reader.Close()
+
+	// The rows are decoded one by one, a sheet being much larger than its records
+	records := [][]string{}
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse the sheet %q: %w", names[index], err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		row := xlsxRow{}
+		if err := decoder.DecodeElement(&row, &start); err != nil {
+			return nil, fmt.Errorf("failed to parse the sheet %q: %w", names[index], err)
+		}
+
+		rowIndex := len(records)
+		if row.Index > 0 {
+			rowIndex = row.Index - 1
+		}
+		if rowIndex < len(records) || rowIndex >= maxSpreadsheetRows {
+			return nil, fmt.Errorf("invalid row %d in the sheet %q", row.Index, names[index])
+		}
+		for len(records) < rowIndex {
+			records = append(records, nil)
+		}
+
+		record := []string{}
+		for _, cell := range row.Cells {
+			col := len(record)
+			if cell.Reference != "" {
+				col, _, err = parseCellReference(cell.Reference)
+				if err != nil || col < len(record) {
+					return nil, fmt.Errorf("invalid cell %q in the sheet %q", cell.Reference, names[index])
+				}
+			}
+			value := ""
+			switch cell.Type {
+			case "s":
+				i, err := strconv.Atoi(cell.Value)
+				if err == nil && i >= 0 && i < len(sharedStrings.Items) {
+					value = sharedStrings.Items[i].String()
+				}
+			case "inlineStr":
+				value = cell.Inline.String()
+			case "str":
+				value = cell.Value
+			case "b":
+				value = strconv.FormatBool(cell.Value == "1")
+			case "d":
+				value = formatSpreadsheetDate(cell.Value)
+			case "e":
+				// The errors of the formulas (#DIV/0!, #N/A) are NULL
+			default:
+				if cell.Style >= 0 && cell.Style < len(dateStyles) && dateStyles[cell.Style] {
+					if serial, err := strconv.ParseFloat(cell.Value, 64); err == nil {
+						value = formatSpreadsheetSerial(serial, workbook.Properties.Date1904)
+						break
+					}
+				}
+				value = formatSpreadsheetNumber(cell.Value)
+			}
+			record = appendCell(record, col, value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ODS
+
+const (
+	odsTableNamespace  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNamespace = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNamespace   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// odsDurationRegexp parses the duration of a time cell (PT13H05M30S)
+var odsDurationRegexp = regexp.MustCompile(`^PT(\d+)H(\d+)M(\d+(?:\.\d+)?)S$`)
+
+// xmlAttr returns the value of the attribute of start in the namespace space
+func xmlAttr(start xml.StartElement, space string, local string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == space && attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns the count of a number-*-repeated attribute, 1 if it's missing
+func odsRepeat(start xml.StartElement, local string) int {
+	count, err := strconv.Atoi(xmlAttr(start, odsTableNamespace, local))
+	if err != nil || count < 1 {
+		return 1
+	}
+	return count
+}
+
+// odsCellValue returns the value of a cell from the attributes of its element,
+// and false if the value is the text of the cell
+func odsCellValue(start xml.StartElement) (string, bool) {
+	switch xmlAttr(start, odsOfficeNamespace, "value-type") {
+	case "float", "percentage", "currency":
+		return formatSpreadsheetNumber(xmlAttr(start, odsOfficeNamespace, "value")), true
+	case "date":
+		return formatSpreadsheetDate(xmlAttr(start, odsOfficeNamespace, "date-value")), true
+	case "time":
+		value := xmlAttr(start, odsOfficeNamespace, "time-value")
+		if matches := odsDurationRegexp.FindStringSubmatch(value); matches != nil {
+			hours, _ := strconv.Atoi(matches[1])
+			minutes, _ := strconv.Atoi(matches[2])
+			seconds, _ := strconv.ParseFloat(matches[3], 64)
+			return fmt.Sprintf("%02d:%02d:%02d", hours, minutes, int(math.Round(seconds))), true
+		}
+		return value, true
+	case "boolean":
+		return xmlAttr(start, odsOfficeNamespace, "boolean-value"), true
+	}
+	return "", false
+}
+
+// readOdsSheet returns the records of a sheet of an ODS file
+func readOdsSheet(archive *zip.Reader, sheet string) ([][]string, error) {
+	reader, err := zipFile(archive, "content.xml").Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open content.xml: %w", err)
+	}
+	defer reader.Close()
+
+	// The sheets are read in a single pass, so the records of the sheet at the position sheet
+	// are kept until it's known that no sheet is named sheet
+	position, _ := strconv.Atoi(sheet)
+	names := []string{}
+	var byPosition [][]string
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Space != odsTableNamespace || start.Name.Local != "table" {
+			continue
+		}
+		name := xmlAttr(start, odsTableNamespace, "name")
+		names = append(names, name)
+
+		matchesName := sheet != "" && name == sheet
+		matchesPosition := (sheet == "" && len(names) == 1) || len(names) == position
+		if !matchesName && !matchesPosition {
+			if err := decoder.Skip(); err != nil {
+				return nil, fmt.Errorf("failed to parse content.xml: %w", err)
+			}
+			continue
+		}
+		records, err := readOdsTable(decoder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the sheet %q: %w", name, err)
+		}
+		if matchesName || sheet == "" {
+			return records, nil
+		}
+		byPosition = records
+	}
+
+	if byPosition != nil {
+		return byPosition, nil
+	}
+	_, err = selectSheet(names, sheet)
+	if err == nil {
+		err = fmt.Errorf("sheet %q not found", sheet)
+	}
+	return nil, err
+}
+
+// readOdsTable reads the rows of the table:table element the decoder is in.
+//
+// A row or a cell repeated several times (table:number-rows-repeated) is written once.
+// The empty ones are only added when followed by a value, because a sheet usually ends
+// with an empty row repeated up to the last row of the sheet
+func readOdsTable(decoder *xml.Decoder) ([][]string, error) {
+	records := [][]string{}
+	var record []string
+	emptyRows, emptyCells := 0, 0
+	rowRepeat, cellRepeat := 1, 1
+
+	inCell := false
+	paragraphs := 0
+	text := strings.Builder{}
+	value, hasValue := "", false
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch {
+			case token.Name.Space == odsTableNamespace && token.Name.Local == "table-row":
+				record = nil
+				emptyCells = 0
+				rowRepeat = odsRepeat(token, "number-rows-repeated")
+			case token.Name.Space == odsTableNamespace && (token.Name.Local == "table-cell" || token.Name.Local == "covered-table-cell"):
+				inCell = true
+				paragraphs = 0
+				text.Reset()
+				cellRepeat = odsRepeat(token, "number-columns-repeated")
+				value, hasValue = odsCellValue(token)
+			case token.Name.Space == odsOfficeNamespace && token.Name.Local == "annotation",
+				token.Name.Space == odsTableNamespace && token.Name.Local == "table":
+				// The comments and the tables nested in a cell are not part of its value
+				if err := decoder.Skip(); err != nil {
+					return nil, err
+				}
+			case inCell && token.Name.Space == odsTextNamespace:
+				switch token.Name.Local {
+				case "p", "h":
+					if paragraphs > 0 {
+						text.WriteByte('\n')
+					}
+					paragraphs++
+				case "s":
+					count, err := strconv.Atoi(xmlAttr(token, odsTextNamespace, "c"))
+					if err != nil || count < 1 {
+						count = 1
+					}
+					text.WriteString(strings.Repeat(" ", min(count, maxSpreadsheetColumns)))
+				case "tab":
+					text.WriteByte('\t')
+				case "line-break":
+					text.WriteByte('\n')
+				}
+			}
+
+		case xml.CharData:
+			if inCell {
+				text.Write(token)
+			}
+
+		case xml.EndElement:
+			switch {
+			case token.Name.Space == odsTableNamespace && token.Name.Local == "table":
+				return records, nil
+			case token.Name.Space == odsTableNamespace && (token.Name.Local == "table-cell" || token.Name.Local == "covered-table-cell"):
+				inCell = false
+				if !hasValue {
+					value = text.String()
+				}
+				if value == "" {
+					emptyCells += cellRepeat
+					break
+				}
+				if len(record)+emptyCells+cellRepeat > maxSpreadsheetColumns {
+					return nil, fmt.Errorf("a row has more than %d cells", maxSpreadsheetColumns)
+				}
+				for ; emptyCells > 0; emptyCells-- {
+					record = append(record, "")
+				}
+				for i := 0; i < cellRepeat; i++ {
+					record = append(record, value)
+				}
+			case token.Name.Space == odsTableNamespace && token.Name.Local == "table-row":
+				if len(record) == 0 {
+					emptyRows += rowRepeat
+					break
+				}
+				if len(records)+emptyRows+rowRepeat > maxSpreadsheetRows {
+					return nil, fmt.Errorf("the sheet has more than %d rows", maxSpreadsheetRows)
+				}
+				for ; emptyRows > 0; emptyRows-- {
+					records = append(records, nil)
+				}
+				for i := 0; i < rowRepeat; i++ {
+					records = append(records, record)
+				}
+			}
+		}
+	}
+}
@@ -51,6 +51,7 @@ func TestStdinDeniedUnderSandbox(t *testing.T) {
 		{"toml", "toml_reader", func(r *Restrictions) sqlite3.Module { return &TomlModule{Restrictions: r} }},
 		{"yaml", "yaml_reader", func(r *Restrictions) sqlite3.Module { return &YamlModule{Restrictions: r} }},
 		{"html", "html_reader", func(r *Restrictions) sqlite3.Module { return &HtmlModule{Restrictions: r} }},
+		{"xlsx", "xlsx_reader", func(r *Restrictions) sqlite3.Module { return &XlsxModule{Restrictions: r} }},
 	}
 
 	for _, rd := range readers {
@@ -0,0 +1,327 @@
+package module
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/julien040/anyquery/rpc"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
+
+// This file forwards the transactions of a SQLite connection to the plugin tables
+// whose schema sets rpc.DatabaseSchema.HandlesTransactions.
+//
+// SQLite only calls the transaction methods (xBegin, xCommit, xRollback) of the tables that are not eponymous.
+// The plugin tables declaring rpc.TableMetadata.HandlesTransactions are therefore registered with
+// SQLiteTransactionModule, and created in the temp schema of each connection (see namespace.LoadAnyqueryPlugin).
+// SQLite calls Begin before the first write of such a table in a transaction (each write statement in autocommit mode),
+// and Commit or Rollback once the transaction ends.
+//
+// SQLite ignores the errors of xCommit. The tables are therefore committed earlier, by the commit hook of the connection
+// (see CommitTransaction): a hook that fails turns the commit into a rollback, and COMMIT returns an error.
+//
+// The driver does not expose the savepoint methods (xSavepoint, xRelease, xRollbackTo).
+// Instead, the authorizer of the connection reports the savepoint statements with HandleTransactionStatement
+// when they are prepared, and they are forwarded to the tables written in the transaction.
+
+// SQLiteTransactionModule is a SQLiteModule whose tables receive the transaction methods of SQLite
+//
+// Its tables are not eponymous: they must be created with CREATE VIRTUAL TABLE
+type SQLiteTransactionModule struct {
+	*SQLiteModule
+}
+
+// TransactionModule marks the module as implementing the transaction methods of SQLite
+func (m *SQLiteTransactionModule) TransactionModule() {}
+
+// connectionTransaction is the transaction opened on a SQLite connection
+type connectionTransaction struct {
+	// The savepoints opened in the transaction, the innermost last
+	savepoints []string
+	// The tables written in the transaction
+	tables []*SQLiteTable
+}
+
+// connectionTransactions maps a *sqlite3.SQLiteConn to its *connectionTransaction
+// while a transaction is open
+var connectionTransactions sync.Map
+
+// HandleTransactionStatement forwards a savepoint statement prepared on the SQLite connection
+// to the plugin tables written in the transaction
+//
+// action, arg1 and arg2 are the arguments of the authorizer callback of SQLite
+// for the SQLITE_TRANSACTION and SQLITE_SAVEPOINT actions. Any other action is ignored.
+// If it returns an error, the statement must be denied so that SQLite and the plugins stay in the same state
+func HandleTransactionStatement(conn *sqlite3.SQLiteConn, action int, arg1 string, arg2 string) error {
+	switch action {
+	case sqlite3.SQLITE_TRANSACTION:
+		// COMMIT and ROLLBACK reach the tables through their transaction methods
+		if arg1 == "BEGIN" {
+			forgetEndedTransaction(conn)
+		}
+	case sqlite3.SQLITE_SAVEPOINT:
+		switch arg1 {
+		case "BEGIN":
+			forgetEndedTransaction(conn)
+			return openSavepoint(conn, arg2)
+		case "RELEASE":
+			return releaseSavepoint(conn, arg2)
+		case "ROLLBACK":
+			return rollbackToSavepoint(conn, arg2)
+		}
+	}
+	return nil
+}
+
+// forgetEndedTransaction forgets the savepoints of a transaction that ended without any write
+// (no table received Commit or Rollback). It's a no-op while a transaction is open
+func forgetEndedTransaction(conn *sqlite3.SQLiteConn) {
+	if conn.AutoCommit() {
+		connectionTransactions.Delete(conn)
+	}
+}
+
+func openSavepoint(conn *sqlite3.SQLiteConn, name string) error {
+	value, _ := connectionTransactions.LoadOrStore(conn, &connectionTransaction{})
+	tx := value.(*connectionTransaction)
+
+	// The buffered writes belong to the state before the savepoint
+	for _, table := range tx.tables {
+		if err := table.FlushBuffers(); err != nil {
+			return err
+		}
+		if err := table.Savepoint(name); err != nil {
+			return err
+		}
+	}
+	tx.savepoints = append(tx.savepoints, name)
+	return nil
+}
+
+func releaseSavepoint(conn *sqlite3.SQLiteConn, name string) error {
+	value, ok := connectionTransactions.Load(conn)
+	if !ok {
+		return nil
+	}
+	tx := value.(*connectionTransaction)
+	i := tx.savepointIndex(name)
+	if i == -1 {
+		return nil
+	}
+
+	// Releasing the savepoint opening the transaction commits it, and SQLite then calls Commit
+	for _, table := range tx.tables {
+		if err := table.Release(name); err != nil {
+			return err
+		}
+	}
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+func rollbackToSavepoint(conn *sqlite3.SQLiteConn, name string) error {
+	value, ok := connectionTransactions.Load(conn)
+	if !ok {
+		return nil
+	}
+	tx := value.(*connectionTransaction)
+	i := tx.savepointIndex(name)
+	if i == -1 {
+		return nil
+	}
+
+	var err error
+	for _, table := range tx.tables {
+		table.ClearBuffers()
+		err = errors.Join(err, table.RollbackTo(name))
+	}
+	// The savepoint stays open
+	tx.savepoints = tx.savepoints[:i+1]
+	return err
+}
+
+// savepointIndex returns the index of the innermost savepoint named name, or -1
+//
+// Like SQLite, the names are case-insensitive
+func (tx *connectionTransaction) savepointIndex(name string) int {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(tx.savepoints[i], name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CommitTransaction sends the buffered writes of the plugin tables written in the transaction of the connection,
+// and commits them. It must be called by the commit hook of the connection, before SQLite commits.
+//
+// If a table fails to commit, the error is returned and the hook must return non-zero:
+// SQLite then rolls back the transaction, and the tables not committed yet with it.
+// The plugins can't commit atomically together, so the tables committed before the failing one stay committed
+func CommitTransaction(conn *sqlite3.SQLiteConn) error {
+	value, ok := connectionTransactions.Load(conn)
+	if !ok {
+		return nil
+	}
+	tx := value.(*connectionTransaction)
+
+	// A table failing to receive its writes fails the commit before any table commits
+	for _, table := range tx.tables {
+		if err := table.FlushBuffers(); err != nil {
+			return err
+		}
+	}
+	for len(tx.tables) > 0 {
+		if err := tx.tables[0].Commit(); err != nil {
+			return err
+		}
+		// The committed tables leave the transaction, so that Commit and Rollback don't reach them
+		tx.tables = tx.tables[1:]
+	}
+	connectionTransactions.CompareAndDelete(conn, tx)
+	return nil
+}
+
+// joinTransaction adds the table to the transaction open on the connection
+// before the table receives its first write of the transaction
+func (t *SQLiteTable) joinTransaction(conn *sqlite3.SQLiteConn) error {
+	if conn == nil || !t.Schema.HandlesTransactions {
+		return nil
+	}
+	// In autocommit mode, the transaction is the statement being run
+	if conn.AutoCommit() {
+		connectionTransactions.Delete(conn)
+	}
+	value, _ := connectionTransactions.LoadOrStore(conn, &connectionTransaction{})
+	tx := value.(*connectionTransaction)
+	if slices.Contains(tx.tables, t) {
+		return nil
+	}
+
+	// The writes buffered before the transaction don't belong to it
+	if err := t.FlushBuffers(); err != nil {
+		return err
+	}
+	if err := t.Begin(); err != nil {
+		return err
+	}
+	for _, name := range tx.savepoints {
+		if err := t.Savepoint(name); err != nil {
+			return errors.Join(err, t.Rollback())
+		}
+	}
+	tx.tables = append(tx.tables, t)
+	return nil
+}
+
+// endTransaction runs end (committing or rolling back) on the table if it was written in the transaction of the connection,
+// and removes it from the transaction
+func (t *SQLiteTable) endTransaction(conn *sqlite3.SQLiteConn, end func() error) error {
+	value, ok := connectionTransactions.Load(conn)
+	if !ok {
+		return nil
+	}
+	tx := value.(*connectionTransaction)
+	i := slices.Index(tx.tables, t)
+	if i == -1 {
+		return nil
+	}
+	// The transaction is over for SQLite, even if end fails
+	tx.tables = slices.Delete(tx.tables, i, i+1)
+	if len(tx.tables) == 0 {
+		connectionTransactions.CompareAndDelete(conn, tx)
+	}
+	return end()
+}
+
+// The transaction methods of SQLite (see SQLiteTransactionModule)
+
+// Begin opens a transaction on the table before its first write of the transaction of SQLite
+func (t *sqliteTableConnection) Begin() error {
+	// The writes of a dry run never reach the plugin
+	if connectionDryRun(t.conn) != nil {
+		return nil
+	}
+	return t.joinTransaction(t.conn)
+}
+
+// Commit applies the writes of the table once SQLite commits the transaction
+//
+// The table is usually committed by CommitTransaction, and this is a no-op.
+// Otherwise (e.g. the connection has no commit hook), SQLite ignores the errors of xCommit and commits anyway:
+// if the plugin fails to commit, its transaction is rolled back so that the next one can start, and the error is logged
+func (t *sqliteTableConnection) Commit() error {
+	var commitErr error
+	t.endTransaction(t.conn, func() error {
+		// Send the buffered writes before committing
+		commitErr = t.FlushBuffers()
+		if commitErr == nil {
+			commitErr = t.SQLiteTable.Commit()
+		}
+		if commitErr != nil {
+			t.ClearBuffers()
+			return errors.Join(commitErr, t.SQLiteTable.Rollback())
+		}
+		return nil
+	})
+	if commitErr != nil {
+		t.logger.Error("could not commit the transaction of the plugin, its writes are discarded", "error", commitErr, "table", t.name, "plugin", t.PluginPath)
+	}
+	return commitErr
+}
+
+// Rollback discards the writes of the table once SQLite rolls back the transaction
+func (t *sqliteTableConnection) Rollback() error {
+	return t.endTransaction(t.conn, func() error {
+		t.ClearBuffers()
+		return t.SQLiteTable.Rollback()
+	})
+}
+
+// transaction runs a transaction operation on the table of the plugin
+func (t *SQLiteTable) transaction(operation rpc.TransactionOperation, savepoint string) error {
+	if !t.Schema.HandlesTransactions {
+		return nil
+	}
+	client, ok := t.client.Plugin.(rpc.InternalTransactionInterface)
+	if !ok {
+		return rpc.ErrTransactionNotSupported
+	}
+	err := client.Transaction(t.connectionIndex, t.tableIndex, operation, savepoint)
+	if err != nil {
+		return errors.Join(errors.New("the plugin could not run the "+string(operation)+" operation of the transaction"), err)
+	}
+	return nil
+}
+
+// Begin opens a transaction on the table of the plugin
+func (t *SQLiteTable) Begin() error {
+	return t.transaction(rpc.TransactionBegin, "")
+}
+
+// Commit applies the writes of the transaction
+func (t *SQLiteTable) Commit() error {
+	return t.transaction(rpc.TransactionCommit, "")
+}
+
+// Rollback discards the writes of the transaction
+func (t *SQLiteTable) Rollback() error {
+	return t.transaction(rpc.TransactionRollback, "")
+}
+
+// Savepoint marks the current state of the transaction
+func (t *SQLiteTable) Savepoint(name string) error {
+	return t.transaction(rpc.TransactionSavepoint, name)
+}
+
+// Release forgets a savepoint of the transaction
+func (t *SQLiteTable) Release(name string) error {
+	return t.transaction(rpc.TransactionRelease, name)
+}
+
+// RollbackTo discards the writes made since a savepoint of the transaction
+func (t *SQLiteTable) RollbackTo(name string) error {
+	return t.transaction(rpc.TransactionRollbackTo, name)
+}
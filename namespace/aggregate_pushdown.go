@@ -0,0 +1,552 @@
+package namespace
+
+import (
+	"encoding/json"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"github.com/julien040/anyquery/other/sqlparser"
+	"github.com/julien040/anyquery/rpc"
+)
+
+// # Aggregate pushdown
+//
+// SQLite computes the aggregates itself, so a query like
+//
+//	SELECT state, count(*) FROM github_my_issues GROUP BY state
+//
+// requests every row of the table from the plugin.
+// When the plugin can compute the aggregates (see rpc.DatabaseSchema.Aggregates),
+// the query is rewritten to read a plugin_aggregate virtual table (see module.AggregateModule)
+// whose rows are computed by the plugin:
+//
+//	CREATE VIRTUAL TABLE agg USING plugin_aggregate('github_my_issues', '{...}');
+//	SELECT g_0 AS `state`, a_0 AS `count(*)` FROM agg;
+//	DROP TABLE agg;
+//
+// Only the simple queries are rewritten: a single plugin table, a WHERE clause made of comparisons
+// between a column and a literal joined by AND, and aggregates on columns of the table.
+// Any other query runs as usual. The text of the query is edited rather than printed back
+// from its syntax tree (see query_tokens.go), and the columns keep the names SQLite gives them.
+//
+// The queries of the shell and of the MySQL server (see MySQLServer.Namespace) are rewritten.
+
+// AggregatePushdown is a query rewritten by RewriteAggregateQuery
+type AggregatePushdown struct {
+	// The statement creating the virtual table to run before Query
+	PreExec string
+	// The rewritten query
+	Query string
+	// The statement dropping the virtual table to run once the rows of Query are read
+	PostExec string
+}
+
+// RewriteAggregateQuery rewrites a query aggregating the rows of a plugin table
+// so that the aggregates are computed by the plugin
+//
+// It returns false if the query cannot be pushed down
+func (n *Namespace) RewriteAggregateQuery(query string) (AggregatePushdown, bool) {
+	tokens, ok := tokenizeQuery(query)
+	if !ok {
+		return AggregatePushdown{}, false
+	}
+
+	_, stmt, err := GetQueryType(query)
+	if err != nil || stmt == nil {
+		return AggregatePushdown{}, false
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || sel.Distinct || sel.With != nil || sel.Into != nil || sel.Lock != sqlparser.NoLock ||
+		len(sel.Windows) > 0 || len(sel.From) != 1 || (sel.GroupBy != nil && sel.GroupBy.WithRollup) {
+		return AggregatePushdown{}, false
+	}
+
+	// Find the plugin table
+	tableExpr, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok || len(tableExpr.Partitions) > 0 || len(tableExpr.Hints) > 0 || len(tableExpr.Columns) > 0 {
+		return AggregatePushdown{}, false
+	}
+	tableName, ok := tableExpr.Expr.(sqlparser.TableName)
+	if !ok || len(tableName.Args) > 0 || (!tableName.Qualifier.IsEmpty() && tableName.Qualifier.String() != "main") {
+		return AggregatePushdown{}, false
+	}
+	mod, ok := n.anyqueryPlugins[tableName.Name.String()]
+	if !ok {
+		return AggregatePushdown{}, false
+	}
+	schema, err := mod.Schema()
+	if err != nil || len(schema.Aggregates) == 0 {
+		return AggregatePushdown{}, false
+	}
+
+	virtualTable := "anyquery_aggregate_" + strconv.FormatUint(rand.Uint64(), 36)
+	rewriter := aggregateRewriter{
+		schema:       schema,
+		tableName:    tableName.Name.String(),
+		alias:        tableExpr.As.String(),
+		query:        query,
+		tokens:       tokens,
+		virtualTable: virtualTable,
+	}
+	aggregateQuery, ok := rewriter.rewrite(sel)
+	if !ok {
+		return AggregatePushdown{}, false
+	}
+
+	// Check that the edits are where they were meant to be
+	rewritten := applyEdits(query, rewriter.edits)
+	if !sameStatement(rewritten, sel) {
+		return AggregatePushdown{}, false
+	}
+
+	rawQuery, err := json.Marshal(aggregateQuery)
+	if err != nil {
+		return AggregatePushdown{}, false
+	}
+
+	return AggregatePushdown{
+		PreExec: "CREATE VIRTUAL TABLE " + virtualTable + " USING plugin_aggregate(" +
+			quoteSQLString(rewriter.tableName) + ", " + quoteSQLString(string(rawQuery)) + ");",
+		Query:    rewritten,
+		PostExec: "DROP TABLE " + virtualTable + ";",
+	}, true
+}
+
+// quoteSQLString returns s as a SQL string literal
+func quoteSQLString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// aggregateRewriter rewrites a SELECT statement on a plugin table
+// into a statement on a plugin_aggregate table
+//
+// Both the syntax tree of the statement and the text of the query (as edits) are rewritten
+type aggregateRewriter struct {
+	schema    rpc.DatabaseSchema
+	tableName string
+	alias     string
+
+	query        string
+	tokens       []queryToken
+	virtualTable string
+	edits        []queryEdit
+
+	aggregateQuery rpc.AggregateQuery
+	// The index of each aggregate in aggregateQuery.Aggregates
+	aggregates map[rpc.Aggregate]int
+	// The nodes replaced by a column of the plugin_aggregate table, in the order they were visited
+	replaced []aggregateReplacement
+	failed   bool
+}
+
+// aggregateReplacement is an aggregate or a grouped column replaced by a column of the plugin_aggregate table
+type aggregateReplacement struct {
+	node   sqlparser.SQLNode
+	column string
+}
+
+func (r *aggregateRewriter) rewrite(sel *sqlparser.Select) (rpc.AggregateQuery, bool) {
+	r.aggregateQuery = rpc.AggregateQuery{
+		Constraint: rpc.QueryConstraint{
+			Limit:  -1,
+			Offset: -1,
+		},
+		GroupBy:    []int{},
+		Aggregates: []rpc.Aggregate{},
+	}
+	r.aggregates = make(map[rpc.Aggregate]int)
+
+	// The columns the rows are grouped by
+	if sel.GroupBy != nil {
+		for _, expr := range sel.GroupBy.Exprs {
+			colName, ok := expr.(*sqlparser.ColName)
+			if !ok {
+				return r.aggregateQuery, false
+			}
+			col := r.column(colName)
+			if col == -1 || r.schema.Columns[col].IsParameter {
+				return r.aggregateQuery, false
+			}
+			r.aggregateQuery.GroupBy = append(r.aggregateQuery.GroupBy, col)
+		}
+	}
+
+	// The constraints of the rows to aggregate
+	if sel.Where != nil {
+		for _, expr := range splitAndExpr(sel.Where.Expr) {
+			cst, ok := r.constraint(expr)
+			if !ok {
+				return r.aggregateQuery, false
+			}
+			r.aggregateQuery.Constraint.Columns = append(r.aggregateQuery.Constraint.Columns, cst)
+		}
+	}
+
+	clauses := topLevelClauses(r.tokens)
+	selectStart, okSelect := clauses[sqlparser.SELECT]
+	fromStart, okFrom := clauses[sqlparser.FROM]
+	if !okSelect || !okFrom {
+		return r.aggregateQuery, false
+	}
+
+	// Replace the aggregates and the grouped columns of the SELECT, HAVING and ORDER BY clauses
+	selectExprs := splitSelectExprs(r.tokens, selectStart+1, fromStart)
+	if len(selectExprs) != len(sel.SelectExprs) {
+		return r.aggregateQuery, false
+	}
+	for i, selectExpr := range sel.SelectExprs {
+		aliased, ok := selectExpr.(*sqlparser.AliasedExpr)
+		if !ok {
+			return r.aggregateQuery, false
+		}
+		first, end := selectExprs[i][0], selectExprs[i][1]
+		if !aliased.As.IsEmpty() {
+			// Leave the alias out of the expression
+			end--
+			if end > first && r.tokens[end-1].typ == sqlparser.AS {
+				end--
+			}
+		}
+		if end <= first {
+			return r.aggregateQuery, false
+		}
+		// Keep the name SQLite gives to the column
+		// (the declared name of the column for a column, the expression as written otherwise)
+		if aliased.As.IsEmpty() {
+			name := r.query[r.tokens[first].start:r.tokens[end-1].end]
+			if colName, ok := aliased.Expr.(*sqlparser.ColName); ok {
+				if col := r.column(colName); col != -1 {
+					name = r.schema.Columns[col].Name
+				}
+			}
+			aliased.As = sqlparser.NewIdentifierCI(name)
+			r.insert(r.tokens[end-1].end, " AS "+quoteSQLIdentifier(name))
+		}
+		sel.SelectExprs[i] = r.rewriteClause(aliased, first, end).(sqlparser.SelectExpr)
+	}
+	if len(r.aggregateQuery.Aggregates) == 0 {
+		return r.aggregateQuery, false
+	}
+	if sel.Having != nil {
+		having := clauses[sqlparser.HAVING]
+		sel.Having.Expr = r.rewriteClause(sel.Having.Expr, having+1, clauseEnd(r.tokens, clauses, having)).(sqlparser.Expr)
+	}
+	if len(sel.OrderBy) > 0 {
+		order := clauses[sqlparser.ORDER]
+		end := clauseEnd(r.tokens, clauses, order)
+		for i, orderBy := range sel.OrderBy {
+			sel.OrderBy[i] = r.rewriteClause(orderBy, order+1, end).(*sqlparser.Order)
+		}
+	}
+	if r.failed {
+		return r.aggregateQuery, false
+	}
+
+	// The rows are read from the plugin_aggregate table
+	sel.From[0] = &sqlparser.AliasedTableExpr{
+		Expr: sqlparser.NewTableName(r.virtualTable),
+	}
+	r.replaceClause(clauses, sqlparser.FROM, "FROM "+r.virtualTable)
+
+	// The plugin returns one row per group, so the HAVING clause filters the rows of the virtual table
+	sel.GroupBy = nil
+	r.replaceClause(clauses, sqlparser.GROUP, "")
+	sel.Where = nil
+	r.replaceClause(clauses, sqlparser.WHERE, "")
+	if sel.Having != nil {
+		sel.Where = sqlparser.NewWhere(sqlparser.WhereClause, sel.Having.Expr)
+		sel.Having = nil
+		having := r.tokens[clauses[sqlparser.HAVING]]
+		r.edits = append(r.edits, queryEdit{start: having.start, end: having.end, text: "WHERE"})
+	}
+
+	return r.aggregateQuery, !r.failed
+}
+
+// rewriteClause replaces the aggregates and the grouped columns of node,
+// written in the tokens first to end of the query
+//
+// The nodes replaced must appear in the text of the query in the order they are visited
+func (r *aggregateRewriter) rewriteClause(node sqlparser.SQLNode, first, end int) sqlparser.SQLNode {
+	r.replaced = r.replaced[:0]
+	node = sqlparser.Rewrite(node, r.replace, nil)
+
+	next := first
+	for _, replaced := range r.replaced {
+		var from, to int
+		switch replacedNode := replaced.node.(type) {
+		case *sqlparser.ColName:
+			from, to = findColumn(r.tokens, next, end, replacedNode)
+		default:
+			from, to = findAggregate(r.tokens, next, end, aggregateName(replacedNode))
+		}
+		if from == -1 {
+			r.failed = true
+			return node
+		}
+		r.edits = append(r.edits, queryEdit{start: r.tokens[from].start, end: r.tokens[to].end, text: replaced.column})
+		next = to + 1
+	}
+	return node
+}
+
+// replaceClause replaces the text of a clause of the query (e.g. sqlparser.WHERE), if it has one
+func (r *aggregateRewriter) replaceClause(clauses map[int]int, clause int, text string) {
+	first, ok := clauses[clause]
+	if !ok {
+		return
+	}
+	end := clauseEnd(r.tokens, clauses, first)
+	if end <= first {
+		r.failed = true
+		return
+	}
+	r.edits = append(r.edits, queryEdit{start: r.tokens[first].start, end: r.tokens[end-1].end, text: text})
+}
+
+// insert adds text at the byte offset of the query
+func (r *aggregateRewriter) insert(offset int, text string) {
+	r.edits = append(r.edits, queryEdit{start: offset, end: offset, text: text})
+}
+
+// splitSelectExprs returns the first token and the token following each expression
+// of the SELECT clause written in the tokens first to end
+func splitSelectExprs(tokens []queryToken, first, end int) [][2]int {
+	exprs := [][2]int{}
+	depth := 0
+	for i := first; i < end; i++ {
+		switch tokens[i].typ {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				exprs = append(exprs, [2]int{first, i})
+				first = i + 1
+			}
+		}
+	}
+	return append(exprs, [2]int{first, end})
+}
+
+// findAggregate returns the indexes of the first and last tokens of the first call to the aggregate function
+// in tokens[from:to], or -1 if there is none
+func findAggregate(tokens []queryToken, from, to int, name string) (int, int) {
+	for i := from; i+1 < to; i++ {
+		if tokens[i].typ != sqlparser.STRING && strings.EqualFold(tokens[i].val, name) && tokens[i+1].typ == '(' {
+			closing := matchingParen(tokens, i+1)
+			if closing == -1 || closing >= to {
+				return -1, -1
+			}
+			return i, closing
+		}
+	}
+	return -1, -1
+}
+
+// aggregateName returns the name of the function of an aggregate replaced by aggregateRewriter.replace
+func aggregateName(node sqlparser.SQLNode) string {
+	switch node.(type) {
+	case *sqlparser.CountStar, *sqlparser.Count:
+		return "count"
+	case *sqlparser.Sum:
+		return "sum"
+	case *sqlparser.Min:
+		return "min"
+	case *sqlparser.Max:
+		return "max"
+	case *sqlparser.Avg:
+		return "avg"
+	}
+	return ""
+}
+
+// replace is a sqlparser.ApplyFunc replacing the aggregates and the grouped columns
+// by the columns of the plugin_aggregate table
+func (r *aggregateRewriter) replace(cursor *sqlparser.Cursor) bool {
+	var agg rpc.Aggregate
+	var arg sqlparser.Expr
+	switch node := cursor.Node().(type) {
+	case *sqlparser.CountStar:
+		agg = rpc.Aggregate{Function: rpc.AggregateCount, ColumnID: -1}
+	case *sqlparser.Count:
+		if node.Distinct || node.OverClause != nil || len(node.Args) != 1 {
+			r.failed = true
+			return false
+		}
+		agg.Function, arg = rpc.AggregateCount, node.Args[0]
+	case *sqlparser.Sum:
+		if node.Distinct || node.OverClause != nil {
+			r.failed = true
+			return false
+		}
+		agg.Function, arg = rpc.AggregateSum, node.Arg
+	case *sqlparser.Min:
+		if node.Distinct || node.OverClause != nil {
+			r.failed = true
+			return false
+		}
+		agg.Function, arg = rpc.AggregateMin, node.Arg
+	case *sqlparser.Max:
+		if node.Distinct || node.OverClause != nil {
+			r.failed = true
+			return false
+		}
+		agg.Function, arg = rpc.AggregateMax, node.Arg
+	case *sqlparser.Avg:
+		if node.Distinct || node.OverClause != nil {
+			r.failed = true
+			return false
+		}
+		agg.Function, arg = rpc.AggregateAvg, node.Arg
+	case sqlparser.AggrFunc, *sqlparser.Subquery:
+		// Other aggregates (e.g. group_concat) cannot be computed from the groups
+		r.failed = true
+		return false
+	case *sqlparser.ColName:
+		// A column outside of an aggregate must be grouped
+		col := r.column(node)
+		for i, grouped := range r.aggregateQuery.GroupBy {
+			if grouped == col {
+				column := "g_" + strconv.Itoa(i)
+				r.replaced = append(r.replaced, aggregateReplacement{node: node, column: column})
+				cursor.Replace(sqlparser.NewColName(column))
+				return false
+			}
+		}
+		r.failed = true
+		return false
+	default:
+		return true
+	}
+
+	if arg != nil {
+		colName, ok := arg.(*sqlparser.ColName)
+		if !ok {
+			r.failed = true
+			return false
+		}
+		agg.ColumnID = r.column(colName)
+		if agg.ColumnID == -1 || r.schema.Columns[agg.ColumnID].IsParameter {
+			r.failed = true
+			return false
+		}
+	}
+	if !r.schema.SupportsAggregate(agg.Function) {
+		r.failed = true
+		return false
+	}
+
+	index, ok := r.aggregates[agg]
+	if !ok {
+		index = len(r.aggregateQuery.Aggregates)
+		r.aggregates[agg] = index
+		r.aggregateQuery.Aggregates = append(r.aggregateQuery.Aggregates, agg)
+	}
+	column := "a_" + strconv.Itoa(index)
+	r.replaced = append(r.replaced, aggregateReplacement{node: cursor.Node(), column: column})
+	cursor.Replace(sqlparser.NewColName(column))
+	return false
+}
+
+// column returns the index of the column in the schema, or -1 if it is not a column of the table
+func (r *aggregateRewriter) column(colName *sqlparser.ColName) int {
+	if !colName.Qualifier.IsEmpty() {
+		qualifier := colName.Qualifier.Name.String()
+		if qualifier != r.tableName && qualifier != r.alias {
+			return -1
+		}
+	}
+	for i, col := range r.schema.Columns {
+		if colName.Name.EqualString(col.Name) {
+			return i
+		}
+	}
+	return -1
+}
+
+var comparisonOperators = map[sqlparser.ComparisonExprOperator]rpc.Operator{
+	sqlparser.EqualOp:        rpc.OperatorEqual,
+	sqlparser.NotEqualOp:     rpc.OperatorNotEqual,
+	sqlparser.LessThanOp:     rpc.OperatorLess,
+	sqlparser.LessEqualOp:    rpc.OperatorLessOrEqual,
+	sqlparser.GreaterThanOp:  rpc.OperatorGreater,
+	sqlparser.GreaterEqualOp: rpc.OperatorGreaterOrEqual,
+	sqlparser.LikeOp:         rpc.OperatorLike,
+}
+
+// The operator to use when the literal is on the left side of the comparison
+var flippedOperators = map[rpc.Operator]rpc.Operator{
+	rpc.OperatorEqual:          rpc.OperatorEqual,
+	rpc.OperatorNotEqual:       rpc.OperatorNotEqual,
+	rpc.OperatorLess:           rpc.OperatorGreater,
+	rpc.OperatorLessOrEqual:    rpc.OperatorGreaterOrEqual,
+	rpc.OperatorGreater:        rpc.OperatorLess,
+	rpc.OperatorGreaterOrEqual: rpc.OperatorLessOrEqual,
+}
+
+// constraint converts a comparison between a column and a literal into a constraint
+func (r *aggregateRewriter) constraint(expr sqlparser.Expr) (rpc.ColumnConstraint, bool) {
+	comparison, ok := expr.(*sqlparser.ComparisonExpr)
+	if !ok || comparison.Escape != nil || comparison.Modifier != 0 {
+		return rpc.ColumnConstraint{}, false
+	}
+	operator, ok := comparisonOperators[comparison.Operator]
+	if !ok {
+		return rpc.ColumnConstraint{}, false
+	}
+
+	colName, isColumn := comparison.Left.(*sqlparser.ColName)
+	literal, isLiteral := comparison.Right.(*sqlparser.Literal)
+	if !isColumn || !isLiteral {
+		colName, isColumn = comparison.Right.(*sqlparser.ColName)
+		literal, isLiteral = comparison.Left.(*sqlparser.Literal)
+		operator, ok = flippedOperators[operator]
+		if !isColumn || !isLiteral || !ok {
+			return rpc.ColumnConstraint{}, false
+		}
+	}
+
+	col := r.column(colName)
+	if col == -1 {
+		return rpc.ColumnConstraint{}, false
+	}
+
+	value, ok := literalValue(literal)
+	if !ok {
+		return rpc.ColumnConstraint{}, false
+	}
+
+	return rpc.ColumnConstraint{
+		ColumnID: col,
+		Operator: operator,
+		Value:    value,
+	}, true
+}
+
+// literalValue returns the value of a string or number literal
+func literalValue(literal *sqlparser.Literal) (interface{}, bool) {
+	var value interface{}
+	var err error
+	switch literal.Type {
+	case sqlparser.StrVal:
+		value = literal.Val
+	case sqlparser.IntVal:
+		value, err = strconv.ParseInt(literal.Val, 10, 64)
+	case sqlparser.FloatVal, sqlparser.DecimalVal:
+		value, err = strconv.ParseFloat(literal.Val, 64)
+	default:
+		return nil, false
+	}
+	return value, err == nil
+}
+
+// splitAndExpr returns the expressions joined by AND
+func splitAndExpr(expr sqlparser.Expr) []sqlparser.Expr {
+	if and, ok := expr.(*sqlparser.AndExpr); ok {
+		return append(splitAndExpr(and.Left), splitAndExpr(and.Right)...)
+	}
+	return []sqlparser.Expr{expr}
+}
@@ -0,0 +1,134 @@
+package namespace
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julien040/anyquery/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregatePushdown(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/aggregateplugin.out", "../test/aggregateplugin.go").Run()
+	require.NoError(t, err, "The plugin should build")
+
+	namespace, err := NewNamespace(NamespaceConfig{
+		InMemory: true,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+
+	err = namespace.LoadAnyqueryPlugin("_test/aggregateplugin.out", rpc.PluginManifest{
+		Name:   "aggregate",
+		Tables: []string{"items"},
+	}, nil, 0)
+	require.NoError(t, err, "The plugin should load")
+
+	db, err := namespace.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	// query runs a query and returns its rows as strings
+	query := func(t *testing.T, pushdown AggregatePushdown) [][]string {
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		defer conn.Close()
+
+		if pushdown.PreExec != "" {
+			_, err = conn.ExecContext(context.Background(), pushdown.PreExec)
+			require.NoError(t, err, "The virtual table should be created")
+			defer conn.ExecContext(context.Background(), pushdown.PostExec)
+		}
+
+		rows, err := conn.QueryContext(context.Background(), pushdown.Query)
+		require.NoError(t, err, "The query should work")
+		defer rows.Close()
+
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		result := [][]string{columns}
+		for rows.Next() {
+			values := make([]string, len(columns))
+			pointers := make([]interface{}, len(columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			require.NoError(t, rows.Scan(pointers...))
+			result = append(result, values)
+		}
+		require.NoError(t, rows.Err())
+		return result
+	}
+
+	t.Run("The aggregates are computed by the plugin", func(t *testing.T) {
+		queries := []string{
+			"SELECT state, count(*) FROM items GROUP BY state ORDER BY state",
+			"SELECT count(*) AS total, sum(amount) FROM items",
+			"SELECT i.state, sum(i.amount) AS s FROM items i WHERE id > 2 AND state = 'open' GROUP BY i.state",
+			"SELECT state, count(id) + 1, sum(amount) FROM items WHERE 8 > id GROUP BY state HAVING count(*) > 3 ORDER BY sum(amount) DESC",
+			"SELECT COUNT(*), Sum( amount ), State FROM items WHERE state != 'it''s' GROUP BY state ORDER BY 2;",
+		}
+		for _, sql := range queries {
+			pushdown, ok := namespace.RewriteAggregateQuery(sql)
+			require.True(t, ok, "The query should be pushed down: %s", sql)
+
+			expected := query(t, AggregatePushdown{Query: sql})
+			require.Equal(t, expected, query(t, pushdown), "The pushed down query should return the same rows: %s", sql)
+		}
+	})
+
+	t.Run("The aggregates of the queries of the MySQL server are computed by the plugin", func(t *testing.T) {
+		logs := &bytes.Buffer{}
+		logger := log.New(logs)
+		logger.SetLevel(log.DebugLevel)
+		const addr = "127.0.0.1:8013"
+		server := MySQLServer{
+			DB:                     db,
+			Namespace:              namespace,
+			MustCatchMySQLSpecific: true,
+			Address:                addr,
+			Logger:                 logger,
+		}
+		go server.Start()
+		defer server.Stop()
+		time.Sleep(200 * time.Millisecond)
+
+		client, err := sql.Open("mysql", "testuser:aa@tcp("+addr+")/main")
+		require.NoError(t, err)
+		defer client.Close()
+
+		var count, sum int
+		err = client.QueryRow("SELECT count(*), sum(amount) FROM items WHERE state = 'open'").Scan(&count, &sum)
+		require.NoError(t, err, "The query should work")
+		require.Equal(t, 5, count)
+		require.Equal(t, 300, sum)
+		require.Contains(t, logs.String(), "The aggregates of the query are computed by the plugin")
+	})
+
+	t.Run("The unsupported queries are not rewritten", func(t *testing.T) {
+		queries := []string{
+			"SELECT * FROM items",
+			"SELECT state FROM items GROUP BY state",
+			"SELECT min(amount) FROM items",
+			"SELECT id, count(*) FROM items GROUP BY state",
+			"SELECT count(DISTINCT state) FROM items",
+			"SELECT group_concat(state) FROM items",
+			"SELECT count(*) FROM items WHERE id > 2 OR state = 'open'",
+			"SELECT count(*) FROM items, items AS b",
+			"SELECT count(*) FROM unknown_table",
+			"SELECT count(*) FROM items WHERE state = \"open\"",
+			"SELECT state || 'x', count(*) FROM items GROUP BY state",
+			"SELECT count(*) FROM items WHERE state = 'a\\b'",
+		}
+		for _, sql := range queries {
+			_, ok := namespace.RewriteAggregateQuery(sql)
+			require.False(t, ok, "The query should not be pushed down: %s", sql)
+		}
+	})
+}
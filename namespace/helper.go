@@ -1,6 +1,14 @@
 package namespace
 
-import "strings"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/julien040/anyquery/module"
+	sqlite3 "github.com/julien040/go-sqlite3-anyquery"
+)
 
 // GetTableName returns the table name for a given plugin, table and profile
 //
@@ -17,3 +25,57 @@ func GetTableName(plugin string, table string, profile string) string {
 
 	return builder.String()
 }
+
+// BindContext sets ctx as the context of the queries run on conn
+//
+// database/sql interrupts SQLite once the context of a query is done,
+// but the plugins never see it. Binding the context lets the plugin tables
+// stop waiting for the plugin and tell it to stop working on the query.
+//
+// The returned function must be called once the rows of the query are closed
+func BindContext(ctx context.Context, conn *sql.Conn) (release func(), err error) {
+	release = func() {}
+	err = conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected connection type %T", driverConn)
+		}
+		release = module.SetConnectionContext(sqliteConn, ctx)
+		return nil
+	})
+	return release, err
+}
+
+// BindDryRun records the writes of the plugin tables run on conn in dryRun
+// rather than sending them to the plugins (see module.SetConnectionDryRun)
+//
+// The returned function must be called once the statement is run
+func BindDryRun(conn *sql.Conn, dryRun *module.DryRun) (release func(), err error) {
+	release = func() {}
+	err = conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected connection type %T", driverConn)
+		}
+		release = module.SetConnectionDryRun(sqliteConn, dryRun)
+		return nil
+	})
+	return release, err
+}
+
+// BindProfile records the metrics of the virtual table cursors opened on conn in profile
+// (see module.SetConnectionProfile)
+//
+// The returned function must be called once the rows of the query are closed
+func BindProfile(conn *sql.Conn, profile *module.Profile) (release func(), err error) {
+	release = func() {}
+	err = conn.Raw(func(driverConn any) error {
+		sqliteConn, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected connection type %T", driverConn)
+		}
+		release = module.SetConnectionProfile(sqliteConn, profile)
+		return nil
+	})
+	return release, err
+}
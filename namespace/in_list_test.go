@@ -0,0 +1,90 @@
+package namespace
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/julien040/anyquery/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInConstraints(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/inplugin.out", "../test/inplugin.go").Run()
+	require.NoError(t, err, "The plugin should build")
+
+	namespace, err := NewNamespace(NamespaceConfig{
+		InMemory: true,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+
+	err = namespace.LoadAnyqueryPlugin("_test/inplugin.out", rpc.PluginManifest{
+		Name:   "in",
+		Tables: []string{"ids"},
+	}, nil, 0)
+	require.NoError(t, err, "The plugin should load")
+
+	db, err := namespace.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	// query returns the rows of the query as id:received strings
+	// (received is how the plugin received the constraint on id)
+	query := func(t *testing.T, db *sql.DB, sql string, args ...interface{}) []string {
+		rows, err := db.Query(sql, args...)
+		require.NoError(t, err, "The query should work: %s", sql)
+		defer rows.Close()
+
+		result := []string{}
+		for rows.Next() {
+			var id, received string
+			require.NoError(t, rows.Scan(&id, &received))
+			result = append(result, id+":"+received)
+		}
+		require.NoError(t, rows.Err())
+		return result
+	}
+
+	t.Run("The plugin receives the IN list once", func(t *testing.T) {
+		require.Equal(t, []string{"2:in:3", "4:in:3", "8:in:3"},
+			query(t, db, "SELECT id, received FROM ids WHERE id IN (2, 4, 8) ORDER BY id"))
+		require.Equal(t, []string{"2:in:2", "4:in:2"},
+			query(t, db, "SELECT id, received FROM ids WHERE id IN (?, ?) ORDER BY id", 2, 4))
+		require.Equal(t, []string{"1:in:2", "3:in:2"},
+			query(t, db, "SELECT id, received FROM ids WHERE id IN (SELECT 1 UNION SELECT 3) ORDER BY id"))
+	})
+
+	t.Run("SQLite still filters the rows", func(t *testing.T) {
+		require.Equal(t, []string{"1:in:3"},
+			query(t, db, "SELECT i.id, i.received FROM ids AS i WHERE i.id IN (1, 3, 'x\"y') AND id < 3"))
+	})
+
+	t.Run("An equal constraint is sent as is", func(t *testing.T) {
+		require.Equal(t, []string{"5:equal"}, query(t, db, "SELECT id, received FROM ids WHERE id = 5 AND id != 3"))
+	})
+
+	t.Run("The queries of the MySQL server send the IN list once", func(t *testing.T) {
+		const addr = "127.0.0.1:8014"
+		server := MySQLServer{
+			DB:                     db,
+			MustCatchMySQLSpecific: true,
+			Address:                addr,
+			Logger:                 log.New(io.Discard),
+		}
+		go server.Start()
+		defer server.Stop()
+		time.Sleep(200 * time.Millisecond)
+
+		client, err := sql.Open("mysql", "testuser:aa@tcp("+addr+")/main")
+		require.NoError(t, err)
+		defer client.Close()
+
+		require.Equal(t, []string{"2:in:2", "4:in:2"},
+			query(t, client, "SELECT id, received FROM ids WHERE id IN (2, 4) ORDER BY id"))
+	})
+}
@@ -0,0 +1,152 @@
+package namespace
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julien040/anyquery/module"
+	"github.com/julien040/anyquery/other/sqlparser"
+	"github.com/julien040/anyquery/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// This file implements the Prometheus metrics of the MySQL server.
+//
+// The server records its connections and queries. The other metrics are read
+// from the counters of the plugin processes (rpc.ProcessStats), of the remote fetches
+// (module.FetchStats) and of the sandbox of the namespace (Namespace.SandboxDenials)
+// when the metrics are scraped.
+
+// ServerMetrics holds the Prometheus metrics of a MySQL server
+//
+// A nil *ServerMetrics records nothing, so that the handler doesn't have to check if the metrics are enabled
+type ServerMetrics struct {
+	registry      *prometheus.Registry
+	connections   prometheus.Gauge
+	queries       *prometheus.CounterVec
+	queryDuration *prometheus.HistogramVec
+}
+
+// NewServerMetrics creates the metrics of a MySQL server querying the namespace n
+//
+// If n is nil, the sandbox denials are not reported
+func NewServerMetrics(n *Namespace) *ServerMetrics {
+	m := &ServerMetrics{
+		registry: prometheus.NewRegistry(),
+		connections: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "anyquery_mysql_connections",
+			Help: "The number of open MySQL connections",
+		}),
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "anyquery_queries_total",
+			Help: "The number of queries run, by statement type and status (ok or error)",
+		}, []string{"statement", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "anyquery_query_duration_seconds",
+			Help:    "The duration of the queries, by statement type",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10), // 1ms to ~4min
+		}, []string{"statement"}),
+	}
+
+	m.registry.MustRegister(
+		m.connections, m.queries, m.queryDuration,
+		statsCollector{namespace: n},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus text format
+func (m *ServerMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *ServerMetrics) connectionOpened() {
+	if m != nil {
+		m.connections.Inc()
+	}
+}
+
+func (m *ServerMetrics) connectionClosed() {
+	if m != nil {
+		m.connections.Dec()
+	}
+}
+
+// observeQuery records a query that ran for duration
+func (m *ServerMetrics) observeQuery(query string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	// The statement types are a fixed set, so the number of series stays bounded
+	statement := strings.ToLower(sqlparser.Preview(query).String())
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	m.queries.WithLabelValues(statement, status).Inc()
+	m.queryDuration.WithLabelValues(statement).Observe(duration.Seconds())
+}
+
+var (
+	pluginProcessesDesc = prometheus.NewDesc("anyquery_plugin_processes",
+		"The number of running plugin processes", nil, nil)
+	pluginStartsDesc = prometheus.NewDesc("anyquery_plugin_processes_started_total",
+		"The number of plugin processes started, restarts included", nil, nil)
+	pluginRestartsDesc = prometheus.NewDesc("anyquery_plugin_restarts_total",
+		"The number of times a plugin was restarted because its process exited", nil, nil)
+	fetchesDesc = prometheus.NewDesc("anyquery_fetches_total",
+		"The number of remote files fetched, by cache result (hit, revalidated or miss)", []string{"cache"}, nil)
+	fetchCacheHitRatioDesc = prometheus.NewDesc("anyquery_fetch_cache_hit_ratio",
+		"The ratio of the remote fetches served by the cache (hit or revalidated)", nil, nil)
+	fetchBytesDesc = prometheus.NewDesc("anyquery_fetch_downloaded_bytes_total",
+		"The number of bytes downloaded by the remote fetches, before decompression", nil, nil)
+	sandboxDenialsDesc = prometheus.NewDesc("anyquery_sandbox_denials_total",
+		"The number of statements denied by the sandbox, by action (attach, function or pragma)", []string{"action"}, nil)
+)
+
+// statsCollector reports the counters maintained by the rpc and module packages, and by the namespace
+type statsCollector struct {
+	namespace *Namespace
+}
+
+func (c statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pluginProcessesDesc
+	ch <- pluginStartsDesc
+	ch <- pluginRestartsDesc
+	ch <- fetchesDesc
+	ch <- fetchCacheHitRatioDesc
+	ch <- fetchBytesDesc
+	if c.namespace != nil {
+		ch <- sandboxDenialsDesc
+	}
+}
+
+func (c statsCollector) Collect(ch chan<- prometheus.Metric) {
+	processes := rpc.ProcessStats()
+	ch <- prometheus.MustNewConstMetric(pluginProcessesDesc, prometheus.GaugeValue, float64(processes.Running))
+	ch <- prometheus.MustNewConstMetric(pluginStartsDesc, prometheus.CounterValue, float64(processes.Started))
+	ch <- prometheus.MustNewConstMetric(pluginRestartsDesc, prometheus.CounterValue, float64(processes.Restarts))
+
+	fetches := module.FetchStats()
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(fetches.CacheHits), "hit")
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(fetches.CacheRevalidated), "revalidated")
+	ch <- prometheus.MustNewConstMetric(fetchesDesc, prometheus.CounterValue, float64(fetches.CacheMisses), "miss")
+	ratio := 0.0
+	if total := fetches.CacheHits + fetches.CacheRevalidated + fetches.CacheMisses; total > 0 {
+		ratio = float64(fetches.CacheHits+fetches.CacheRevalidated) / float64(total)
+	}
+	ch <- prometheus.MustNewConstMetric(fetchCacheHitRatioDesc, prometheus.GaugeValue, ratio)
+	ch <- prometheus.MustNewConstMetric(fetchBytesDesc, prometheus.CounterValue, float64(fetches.BytesDownloaded))
+
+	if c.namespace != nil {
+		denials := c.namespace.SandboxDenials()
+		ch <- prometheus.MustNewConstMetric(sandboxDenialsDesc, prometheus.CounterValue, float64(denials.Attach), "attach")
+		ch <- prometheus.MustNewConstMetric(sandboxDenialsDesc, prometheus.CounterValue, float64(denials.Function), "function")
+		ch <- prometheus.MustNewConstMetric(sandboxDenialsDesc, prometheus.CounterValue, float64(denials.Pragma), "pragma")
+	}
+}
@@ -0,0 +1,63 @@
+package namespace
+
+import (
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jmoiron/sqlx"
+	"github.com/julien040/anyquery/module"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMetrics(t *testing.T) {
+	ns, err := NewNamespace(NamespaceConfig{
+		InMemory:     true,
+		Restrictions: &module.Restrictions{AllowedDirs: []string{t.TempDir()}},
+	})
+	require.NoError(t, err)
+	db, err := ns.Register("metricsdb")
+	require.NoError(t, err)
+
+	const addr = "127.0.0.1:8012"
+	server := MySQLServer{
+		DB:                     db,
+		MustCatchMySQLSpecific: true,
+		Address:                addr,
+		Logger:                 log.New(io.Discard),
+		Metrics:                NewServerMetrics(ns),
+	}
+	go func() {
+		_ = server.Start()
+		db.Close()
+	}()
+	defer server.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	conn, err := sqlx.Open("mysql", "testuser:aa@tcp("+addr+")/metricsdb")
+	require.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	defer conn.Close()
+
+	var n int
+	require.NoError(t, conn.Get(&n, "SELECT 1 FROM dual"))
+	_, err = conn.Exec("SELECT * FROM a_table_that_does_not_exist")
+	require.Error(t, err)
+	_, err = conn.Exec("ATTACH DATABASE '" + filepath.Join(t.TempDir(), "denied.db") + "' AS denied")
+	require.Error(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.Metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	require.Contains(t, body, "anyquery_mysql_connections 1")
+	require.Contains(t, body, `anyquery_queries_total{statement="select",status="ok"} 1`)
+	require.Contains(t, body, `anyquery_queries_total{statement="select",status="error"} 1`)
+	require.Contains(t, body, `anyquery_query_duration_seconds_count{statement="select"} 2`)
+	require.Contains(t, body, `anyquery_sandbox_denials_total{action="attach"} 1`)
+	require.Contains(t, body, "anyquery_plugin_processes ")
+	require.Contains(t, body, `anyquery_fetches_total{cache="hit"}`)
+}
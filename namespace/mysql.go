@@ -75,8 +75,23 @@ type MySQLServer struct {
 	// and it is the responsibility of the caller to close it
 	DB *sql.DB
 
+	// The namespace DB is registered from, if any.
+	// If set, the aggregates of the queries on its plugin tables are computed by the plugins
+	// when they can (see Namespace.RewriteAggregateQuery)
+	Namespace *Namespace
+
 	// The logger used by the server
 	Logger *log.Logger
+
+	// The maximum duration of a query
+	//
+	// Once it is exceeded, the query is interrupted and the plugins are told to stop.
+	// If zero, the queries have no time limit
+	QueryTimeout time.Duration
+
+	// The Prometheus metrics of the server (see NewServerMetrics).
+	// If nil, the server does not record them
+	Metrics *ServerMetrics
 }
 
 func convertUserEntriesToVitessAuthFile(users map[string][]UserEntry) (string, error) {
@@ -143,8 +158,11 @@ func (s *MySQLServer) Start() error {
 	// We create a new handler with the database connection
 	s.handler = handler{
 		DB:                  s.DB,
+		Namespace:           s.Namespace,
 		RewriteMySQLQueries: s.MustCatchMySQLSpecific,
 		Logger:              s.Logger,
+		QueryTimeout:        s.QueryTimeout,
+		Metrics:             s.Metrics,
 	}
 
 	// We create a new listener with the auth server
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package namespace
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect is a no-op: the socket can't be peeked on this platform,
+// so a query only stops on a disconnect once it has returned (see handler.ConnectionClosed)
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) func() {
+	return func() {}
+}
@@ -0,0 +1,62 @@
+//go:build unix
+
+package namespace
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tcpPair returns both ends of a loopback TCP connection
+func tcpPair(t *testing.T) (server net.Conn, client net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	client, err = net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	server, err = listener.Accept()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, client
+}
+
+func TestWatchDisconnect(t *testing.T) {
+	t.Run("A disconnect cancels the query", func(t *testing.T) {
+		server, client := tcpPair(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := watchDisconnect(server, cancel)
+		defer stop()
+
+		client.Close()
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+			t.Fatal("the context was not canceled after the client disconnected")
+		}
+	})
+
+	t.Run("The next command of the client is left to the server", func(t *testing.T) {
+		server, client := tcpPair(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		stop := watchDisconnect(server, cancel)
+
+		_, err := client.Write([]byte("next"))
+		require.NoError(t, err)
+		time.Sleep(50 * time.Millisecond)
+		stop()
+		require.NoError(t, ctx.Err(), "a connected client must not cancel the query")
+
+		buf := make([]byte, 4)
+		_, err = io.ReadFull(server, buf)
+		require.NoError(t, err, "the deadline of the watcher must be reset")
+		require.Equal(t, "next", string(buf))
+	})
+}
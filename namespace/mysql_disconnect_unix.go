@@ -0,0 +1,55 @@
+//go:build unix
+
+package namespace
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// watchDisconnect calls cancel if the client of conn disconnects before the returned function is called.
+//
+// The MySQL protocol is request-response: a client sends nothing while its query runs,
+// so the end of the stream means that it's gone. The socket is peeked rather than read,
+// so that the bytes of a client sending its next command early are left to the server.
+// A TLS connection is not watched, its records can't be peeked
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) func() {
+	sysConn, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	raw, err := sysConn.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 1)
+		disconnected := false
+		err := raw.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK)
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				// Wait until the socket is readable
+				return false
+			}
+			disconnected = err != nil || n == 0
+			return true
+		})
+		if disconnected || (err != nil && !errors.Is(err, os.ErrDeadlineExceeded)) {
+			cancel()
+		}
+	}()
+
+	return func() {
+		// The deadline wakes up the watcher. Vitess doesn't set any, so it's reset to none
+		conn.SetReadDeadline(time.Now())
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
@@ -16,6 +16,8 @@ import (
 	"vitess.io/vitess/go/sqltypes"
 
 	"github.com/julien040/anyquery/other/sqlparser"
+	"github.com/julien040/anyquery/rpc"
+	"go.opentelemetry.io/otel/attribute"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	"vitess.io/vitess/go/vt/vtenv"
 
@@ -40,8 +42,11 @@ var emptyResultSet = &sqltypes.Result{
 type handler struct {
 	env                 *vtenv.Environment
 	DB                  *sql.DB
+	Namespace           *Namespace
 	RewriteMySQLQueries bool
 	Logger              *log.Logger
+	QueryTimeout        time.Duration
+	Metrics             *ServerMetrics
 	// Allow each MySQL connection to have its own SQLite connection
 	connectionMapperSQLite map[uint32]*sql.Conn
 
@@ -59,12 +64,28 @@ type handler struct {
 	// To conclude, leaving MySQL connections open will result in zombie processes, butterfly effect in action
 	// An afternoon was lost to find this bug
 	connections []*mysql.Conn
+
+	// The context of each MySQL connection, parent of the contexts of its queries.
+	// It's canceled when the client disconnects, so that its in-flight query stops.
+	// Protected by mutexConnectionMapperSQLite
+	connectionContexts map[uint32]connectionContext
+}
+
+type connectionContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func (h *handler) NewConnection(c *mysql.Conn) {
 	h.mutexConnectionMapperSQLite.Lock()
 	defer h.mutexConnectionMapperSQLite.Unlock()
 	h.Logger.Info("New connection", "connectionID", c.ConnectionID, "username", c.User, "charset", c.CharacterSet)
+	if h.connectionContexts == nil {
+		h.connectionContexts = make(map[uint32]connectionContext)
+	}
+	connCtx, cancel := context.WithCancel(context.Background())
+	h.connectionContexts[c.ConnectionID] = connectionContext{ctx: connCtx, cancel: cancel}
+
 	// We create a new connection for the MySQL connection
 	// This is useful to have a separate connection for each MySQL connection
 	// so that BEGIN and COMMIT can be used
@@ -82,6 +103,7 @@ func (h *handler) NewConnection(c *mysql.Conn) {
 	}
 
 	h.connectionMapperSQLite[c.ConnectionID] = conn
+	h.Metrics.connectionOpened()
 
 	// We append the MySQL connection to the list of connections
 	h.connections = append(h.connections, c)
@@ -122,9 +144,15 @@ func (h *handler) ConnectionClosed(c *mysql.Conn) {
 	// (probably related to a global database/sql variable because test clients and the test server are using sql.register)
 	// Also, because conn.Close() can take some time, we don't want to block the other goroutines.
 	h.mutexConnectionMapperSQLite.Lock()
+	// Stop the query the client didn't wait for
+	if connCtx, ok := h.connectionContexts[c.ConnectionID]; ok {
+		connCtx.cancel()
+		delete(h.connectionContexts, c.ConnectionID)
+	}
 	// Close the connection associated with the MySQL connection
 	if conn, ok := h.connectionMapperSQLite[c.ConnectionID]; ok {
 		h.mutexConnectionMapperSQLite.Unlock()
+		h.Metrics.connectionClosed()
 		// Return the connection to the pool
 		err := conn.Close()
 		if err != nil {
@@ -185,7 +213,9 @@ func (h *handler) ComStmtExecute(c *mysql.Conn, f *mysql.PrepareData, callback f
 		}
 
 	}
-	res, err := h.runQuery(c.ConnectionID, f.PrepareStmt, values...)
+	ctx, stop := h.queryContext(c)
+	defer stop()
+	res, err := h.runQuery(ctx, c.ConnectionID, f.PrepareStmt, values...)
 	if err != nil {
 		return err
 	}
@@ -215,7 +245,9 @@ func (h *handler) Env() *vtenv.Environment {
 
 func (h *handler) ComQuery(c *mysql.Conn, query string, callback func(*sqltypes.Result) error) error {
 	h.Logger.Debug("Received query: ", "query", query, "connectionID", c.ConnectionID, "username", c.User)
-	res, err := h.runQuery(c.ConnectionID, query)
+	ctx, stop := h.queryContext(c)
+	defer stop()
+	res, err := h.runQuery(ctx, c.ConnectionID, query)
 	if err != nil {
 		h.Logger.Debug("Error running query", "err", err, "query", query, "connectionID", c.ConnectionID, "username", c.User)
 		return err
@@ -225,6 +257,27 @@ func (h *handler) ComQuery(c *mysql.Conn, query string, callback func(*sqltypes.
 
 }
 
+// queryContext returns the context of a query of the MySQL connection c,
+// canceled when the client disconnects, even while the query runs.
+//
+// The returned function must be called once the query has run,
+// before the server reads the next command of the client
+func (h *handler) queryContext(c *mysql.Conn) (context.Context, func()) {
+	h.mutexConnectionMapperSQLite.Lock()
+	connCtx, ok := h.connectionContexts[c.ConnectionID]
+	h.mutexConnectionMapperSQLite.Unlock()
+	if !ok {
+		return context.Background(), func() {}
+	}
+	// Vitess only calls ConnectionClosed once the query has returned,
+	// so the socket is watched while it runs. The bytes already buffered
+	// are the next command of the client, which is still connected
+	if c.Buffered() > 0 {
+		return connCtx.ctx, func() {}
+	}
+	return connCtx.ctx, watchDisconnect(c.GetRawConn(), connCtx.cancel)
+}
+
 func (h *handler) ComQueryMulti(c *mysql.Conn, sql string, callback func(qr sqltypes.QueryResponse, more bool, firstPacket bool) error) error {
 	return fmt.Errorf("multi queries are not supported. Open an issue if you need this feature")
 }
@@ -246,12 +299,27 @@ func (h *handler) ConnectionReady(c *mysql.Conn) {}
 
 // Run a SQL query and return the result as a sqltypes.Result
 //
-// If specified, the query will be rewritten to be compatible with MySQL
-func (h *handler) runQuery(connectionID uint32, query string, args ...interface{}) (*sqltypes.Result, error) {
+// If specified, the query will be rewritten to be compatible with MySQL.
+// The query is traced in a span, parent of the spans of the calls to the plugins
+func (h *handler) runQuery(ctx context.Context, connectionID uint32, query string, args ...interface{}) (res *sqltypes.Result, err error) {
+	ctx, span := rpc.StartSpan(ctx, "anyquery.query",
+		attribute.String("db.system", "sqlite"),
+		attribute.String("db.statement", query),
+		attribute.Int64("anyquery.connection_id", int64(connectionID)),
+	)
+	start := time.Now()
+	defer func() {
+		h.Metrics.observeQuery(query, time.Since(start), err)
+		if res != nil {
+			span.SetAttributes(attribute.Int("anyquery.rows", len(res.Rows)))
+		}
+		rpc.EndSpan(span, err)
+	}()
+
 	if !h.RewriteMySQLQueries {
-		return h.runSimpleQuery(connectionID, query, args...)
+		return h.runSimpleQuery(ctx, connectionID, query, args...)
 	} else {
-		return h.runQueryWithMySQLSpecific(connectionID, query, args...)
+		return h.runQueryWithMySQLSpecific(ctx, connectionID, query, args...)
 	}
 
 }
@@ -276,7 +344,7 @@ var prefixExec = []string{
 
 // Run a SQL query to the h.DB connection, bypasing the MySQL compatibility layer,
 // convert the result to a sqltypes.Result and return it
-func (h *handler) runSimpleQuery(connectionID uint32, query string, args ...any) (*sqltypes.Result, error) {
+func (h *handler) runSimpleQuery(ctx context.Context, connectionID uint32, query string, args ...any) (*sqltypes.Result, error) {
 	h.Logger.Debug("Running query: ", "query", query)
 
 	// Retrieve the connection associated with the MySQL connection
@@ -313,8 +381,39 @@ func (h *handler) runSimpleQuery(connectionID uint32, query string, args ...any)
 		runWithQuery = false
 	}
 
+	// Bind the context of the query to the connection
+	// so that the plugins stop working on it once it times out
+	if h.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.QueryTimeout)
+		defer cancel()
+	}
+	release, err := BindContext(ctx, conn)
+	if err != nil {
+		h.Logger.Warn("could not bind the context of the query", "err", err, "connectionID", connectionID)
+	}
+	defer release()
+
 	if runWithQuery {
-		rows, err := conn.QueryContext(context.Background(), query, args...)
+		// Let the plugins compute the aggregates of the query if they can.
+		// A query with arguments is not rewritten, as its arguments might be moved
+		if h.Namespace != nil && len(args) == 0 {
+			if pushdown, ok := h.Namespace.RewriteAggregateQuery(query); ok {
+				h.Logger.Debug("The aggregates of the query are computed by the plugin", "query", pushdown.Query)
+				if _, err := conn.ExecContext(ctx, pushdown.PreExec); err != nil {
+					return nil, err
+				}
+				// Deferred before closing the rows, so that it runs once they are read
+				defer func() {
+					if _, err := conn.ExecContext(context.Background(), pushdown.PostExec); err != nil {
+						h.Logger.Warn("could not drop the table of the aggregates", "err", err, "connectionID", connectionID)
+					}
+				}()
+				query = pushdown.Query
+			}
+		}
+
+		rows, err := conn.QueryContext(ctx, query, args...)
 		if err != nil {
 			return nil, err
 		}
@@ -322,7 +421,7 @@ func (h *handler) runSimpleQuery(connectionID uint32, query string, args ...any)
 
 		return convertSQLRowsToSQLResult(rows)
 	} else {
-		res, err := conn.ExecContext(context.Background(), query, args...)
+		res, err := conn.ExecContext(ctx, query, args...)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package namespace
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -276,7 +277,7 @@ WHERE FALSE`
 
 // Run a query on the database
 // but rewrite, or provide special handling for MySQL specific queries
-func (h *handler) runQueryWithMySQLSpecific(connectionID uint32, query string, args ...interface{}) (*sqltypes.Result, error) {
+func (h *handler) runQueryWithMySQLSpecific(ctx context.Context, connectionID uint32, query string, args ...interface{}) (*sqltypes.Result, error) {
 
 	// Find the type of the query and parse it
 	queryType, parsedQuery, err := GetQueryType(query)
@@ -288,7 +289,7 @@ func (h *handler) runQueryWithMySQLSpecific(connectionID uint32, query string, a
 	switch queryType {
 	case sqlparser.StmtShow:
 		query, args := RewriteShowStatement(parsedQuery.(*sqlparser.Show))
-		return h.runSimpleQuery(connectionID, query, args...)
+		return h.runSimpleQuery(ctx, connectionID, query, args...)
 	case sqlparser.StmtUse:
 		return emptyResultSet, nil
 	case sqlparser.StmtSet:
@@ -302,26 +303,26 @@ func (h *handler) runQueryWithMySQLSpecific(connectionID uint32, query string, a
 			h.Logger.Warnf("Unexpected type for EXPLAIN statement: %T", parsedQuery)
 			return emptyResultSet, nil
 		}
-		return h.runSimpleQuery(connectionID, showColumnsQuery, val.Table.Name.String(), "%")
+		return h.runSimpleQuery(ctx, connectionID, showColumnsQuery, val.Table.Name.String(), "%")
 
 	case sqlparser.StmtSelect:
 		// We rewrite the query to be SQLite compatible
 		rewriteSelectStatement(&parsedQuery)
-		return h.runSimpleQuery(connectionID, sqlparser.String(parsedQuery), args...)
+		return h.runSimpleQuery(ctx, connectionID, sqlparser.String(parsedQuery), args...)
 	case sqlparser.StmtDDL:
 		// We run the DDL statement as is without any modification
 		// For example, create index will be rewritten to alter table
 		// and we don't want that. So we run the query as is
-		return h.runSimpleQuery(connectionID, query, args...)
+		return h.runSimpleQuery(ctx, connectionID, query, args...)
 
 	case sqlparser.StmtUnknown:
 		// If the query is not recognized (e.g. syntax error), we run it as is
-		return h.runSimpleQuery(connectionID, query, args...)
+		return h.runSimpleQuery(ctx, connectionID, query, args...)
 
 	// However, for all the other cases, we run the parsed query
 	// For instance, it helps transforming START TRANSACTION into BEGIN
 	default:
-		return h.runSimpleQuery(connectionID, sqlparser.String(parsedQuery), args...)
+		return h.runSimpleQuery(ctx, connectionID, sqlparser.String(parsedQuery), args...)
 	}
 
 }
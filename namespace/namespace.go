@@ -12,6 +12,8 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/julien040/anyquery/controller/config"
@@ -163,6 +165,32 @@ type Namespace struct {
 
 	// The sandboxing policy (nil means no restrictions). See module.Restrictions.
 	restrictions *module.Restrictions
+
+	// The statements denied by the sandbox authorizer (see SandboxDenials)
+	denials struct {
+		attach   atomic.Int64
+		function atomic.Int64
+		pragma   atomic.Int64
+	}
+}
+
+// SandboxDenialStats counts the statements denied by the sandbox authorizer, by action
+type SandboxDenialStats struct {
+	// ATTACH DATABASE and VACUUM INTO outside the allowed directories
+	Attach int64
+	// The calls to the functions reading files or mutating the cache
+	Function int64
+	// The pragmas that are not read-only
+	Pragma int64
+}
+
+// SandboxDenials returns the number of statements the sandbox denied since the namespace was registered
+func (n *Namespace) SandboxDenials() SandboxDenialStats {
+	return SandboxDenialStats{
+		Attach:   n.denials.attach.Load(),
+		Function: n.denials.function.Load(),
+		Pragma:   n.denials.pragma.Load(),
+	}
 }
 
 type sharedObjectExtension struct {
@@ -288,6 +316,12 @@ func (n *Namespace) LoadSharedExtension(path string, entrypoint string) error {
 //
 // In the manifest, any zeroed string of table name will be ignored
 func (n *Namespace) LoadAnyqueryPlugin(path string, manifest rpc.PluginManifest, userConfig rpc.PluginConfig, connectionID int) error {
+	return n.LoadAnyqueryPluginWithLimits(path, manifest, userConfig, connectionID, rpc.ResourceLimits{})
+}
+
+// LoadAnyqueryPluginWithLimits is like LoadAnyqueryPlugin but the process of the plugin
+// is started with the resource limits of the profile
+func (n *Namespace) LoadAnyqueryPluginWithLimits(path string, manifest rpc.PluginManifest, userConfig rpc.PluginConfig, connectionID int, limits rpc.ResourceLimits) error {
 	if path == "" {
 		return errors.New("the path of the plugin cannot be empty")
 	}
@@ -307,6 +341,7 @@ func (n *Namespace) LoadAnyqueryPlugin(path string, manifest rpc.PluginManifest,
 		if metadata, ok := manifest.TablesMetadata[table]; ok {
 			tableMetadata.Description = metadata.Description
 			tableMetadata.Examples = metadata.Examples
+			tableMetadata.HandlesTransactions = metadata.HandlesTransactions
 		}
 
 		plugin := &module.SQLiteModule{
@@ -318,8 +353,17 @@ func (n *Namespace) LoadAnyqueryPlugin(path string, manifest rpc.PluginManifest,
 			UserConfig:      userConfig,
 			Logger:          n.logger,
 			Metadata:        tableMetadata,
+			Limits:          limits,
+		}
+		if tableMetadata.HandlesTransactions {
+			// SQLite only calls the transaction methods of the tables that are not eponymous
+			// (see module/transaction.go), so the table is created on each connection
+			n.LoadGoPlugin(&module.SQLiteTransactionModule{SQLiteModule: plugin}, table)
+			n.execStatements = append(n.execStatements, "CREATE VIRTUAL TABLE temp."+quoteSQLIdentifier(table)+" USING "+quoteSQLIdentifier(table))
+			n.execArgs = append(n.execArgs, nil)
+		} else {
+			n.LoadGoPlugin(plugin, table)
 		}
-		n.LoadGoPlugin(plugin, table)
 		if n.anyqueryPlugins == nil {
 			n.anyqueryPlugins = make(map[string]*module.SQLiteModule)
 		}
@@ -420,6 +464,9 @@ func (n *Namespace) Register(registerName string) (*sql.DB, error) {
 			conn.RegisterFunc("clear_buffers", bufferFlusher.Clear, false)
 			conn.RegisterFunc("flush_buffers", bufferFlusher.Flush, false)
 
+			// Register the module computing the aggregates of the plugins (see RewriteAggregateQuery)
+			conn.CreateModule("plugin_aggregate", &module.AggregateModule{Modules: &n.anyqueryPlugins})
+
 			// Register JSON and CSV modules.
 			// Each reader receives the sandbox policy (nil = unrestricted) so it
 			// confines local file reads to the allowed directories and rejects
@@ -432,6 +479,7 @@ func (n *Namespace) Register(registerName string) (*sql.DB, error) {
 			conn.CreateModule("toml_reader", &module.TomlModule{Restrictions: n.restrictions})
 			conn.CreateModule("jsonl_reader", &module.JSONlModule{Restrictions: n.restrictions})
 			conn.CreateModule("log_reader", &module.LogModule{Restrictions: n.restrictions})
+			conn.CreateModule("xlsx_reader", &module.XlsxModule{Restrictions: n.restrictions})
 			// file_reader only picks one of the readers above from the file
 			// extension (or the format= argument) and forwards the arguments to
 			// it, so it exposes nothing they don't and gets the same policy.
@@ -493,13 +541,42 @@ func (n *Namespace) Register(registerName string) (*sql.DB, error) {
 			// as arg1 — are confined to in-memory databases and the allowed
 			// directories. An empty/unparsed path (e.g. a parameterized ATTACH,
 			// authorized at prepare time before its value is bound) is denied.
-			if n.restrictions != nil {
+			//
+			// The authorizer also forwards the savepoint statements (SAVEPOINT, RELEASE, ROLLBACK TO)
+			// to the plugin tables handling transactions (see module.HandleTransactionStatement)
+			if len(n.anyqueryPlugins) > 0 {
+				// The plugin tables handling transactions are committed before SQLite,
+				// so that COMMIT fails and rolls back if a plugin can't commit (see module.CommitTransaction)
+				conn.RegisterCommitHook(func() int {
+					if err := module.CommitTransaction(conn); err != nil {
+						n.logger.Error("could not commit the transaction of the plugins, it is rolled back", "error", err)
+						return 1
+					}
+					return 0
+				})
+			}
+			if n.restrictions != nil || len(n.anyqueryPlugins) > 0 {
 				conn.RegisterAuthorizer(func(op int, arg1, arg2, arg3 string) int {
+					if op == sqlite3.SQLITE_TRANSACTION || op == sqlite3.SQLITE_SAVEPOINT {
+						err := module.HandleTransactionStatement(conn, op, arg1, arg2)
+						// A rollback must always reach SQLite, even if a plugin fails to roll back
+						if err != nil && arg1 != "ROLLBACK" {
+							n.logger.Error("could not forward the transaction statement to the plugins", "statement", arg1, "savepoint", arg2, "error", err)
+							return sqlite3.SQLITE_DENY
+						} else if err != nil {
+							n.logger.Error("could not roll back the transaction of the plugins", "savepoint", arg2, "error", err)
+						}
+					}
+					if n.restrictions == nil {
+						return sqlite3.SQLITE_OK
+					}
+
 					switch op {
 					case sqlite3.SQLITE_ATTACH:
 						if n.restrictions.AllowAttachPath(arg1) {
 							return sqlite3.SQLITE_OK
 						}
+						n.denials.attach.Add(1)
 						return sqlite3.SQLITE_DENY
 					case sqlite3.SQLITE_FUNCTION:
 						// Defense in depth on top of the per-function checks:
@@ -507,6 +584,7 @@ func (n *Namespace) Register(registerName string) (*sql.DB, error) {
 						// on-disk cache outright. For SQLITE_FUNCTION, arg2 is the
 						// function name.
 						if deniedSandboxFunctions[strings.ToLower(arg2)] {
+							n.denials.function.Add(1)
 							return sqlite3.SQLITE_DENY
 						}
 						return sqlite3.SQLITE_OK
@@ -521,6 +599,7 @@ func (n *Namespace) Register(registerName string) (*sql.DB, error) {
 						if allowedSandboxPragmas[strings.ToLower(arg1)] {
 							return sqlite3.SQLITE_OK
 						}
+						n.denials.pragma.Add(1)
 						return sqlite3.SQLITE_DENY
 					default:
 						return sqlite3.SQLITE_OK
@@ -594,8 +673,15 @@ func getManifestFromRow(row model.PluginInstalled) (rpc.PluginManifest, error) {
 		}
 
 	}
+	manifest.Protocol = row.Protocol
+	capabilities, err := rpc.ParsePluginCapabilities(row.Capabilities)
+	if err != nil {
+		return manifest, err
+	}
+	manifest.Capabilities = capabilities
+
 	// Unmarshal the plugin config manifes
-	err := json.Unmarshal([]byte(row.Config), &manifest.UserConfig)
+	err = json.Unmarshal([]byte(row.Config), &manifest.UserConfig)
 	if err != nil {
 		return manifest, fmt.Errorf("could not unmarshal the plugin config: %w", err)
 	}
@@ -720,13 +806,29 @@ func (n *Namespace) LoadAsAnyqueryCLI(path string) error {
 				continue
 			}
 
+			limits, err := rpc.ParseResourceLimits(profile.Limits)
+			if err != nil {
+				logger.Error("could not load the resource limits of the profile", "profile", profile.Name, "plugin", plugin.Name, "error", err)
+				continue
+			}
+
 			logger.Debug("loading the profile", "profile", profile.Name, "plugin", plugin.Name, "registry", plugin.Registry,
-				"table count", len(localManifest.Tables), "plugin path", pluginPath, "connection", connectionID)
+				"table count", len(localManifest.Tables), "plugin path", pluginPath, "connection", connectionID, "limits", limits.String())
 
 			// We load the plugin
-			err = n.LoadAnyqueryPlugin(pluginPath, localManifest, userConfig, connectionID)
+			err = n.LoadAnyqueryPluginWithLimits(pluginPath, localManifest, userConfig, connectionID, limits)
 			if err != nil {
 				logger.Error("could not load the plugin", "plugin", plugin.Name, "error", err)
+				continue
+			}
+
+			// The profile can override how long the rows of its tables are cached
+			if profile.Cachettl != 0 {
+				for _, table := range localManifest.Tables {
+					if loaded, ok := n.anyqueryPlugins[table]; ok {
+						loaded.CacheTTL = time.Duration(profile.Cachettl) * time.Second
+					}
+				}
 			}
 
 		}
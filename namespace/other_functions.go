@@ -1,6 +1,7 @@
 package namespace
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	pathlib "path"
@@ -33,6 +34,20 @@ func registerOtherFunctions(conn *sqlite3.SQLiteConn, restrictions *module.Restr
 		}
 		return clear_plugin_cache(plugin)
 	}
+	// The query cache is shared by all the connections of the process,
+	// and its tables might not be visible to a sandboxed client
+	clearQueryCache := func(table ...string) string {
+		if sandboxed {
+			return "sandbox: clear_query_cache is disabled"
+		}
+		return clear_query_cache(table...)
+	}
+	queryCacheInfo := func() string {
+		if sandboxed {
+			return "sandbox: query_cache_info is disabled"
+		}
+		return query_cache_info()
+	}
 
 	var otherFunctions = []struct {
 		name     string
@@ -41,6 +56,8 @@ func registerOtherFunctions(conn *sqlite3.SQLiteConn, restrictions *module.Restr
 	}{
 		{"clear_file_cache", clearFileCache, true},
 		{"clear_plugin_cache", clearPluginCache, true},
+		{"clear_query_cache", clearQueryCache, false},
+		{"query_cache_info", queryCacheInfo, false},
 		{"convert_unit", convert_unit, true},
 		{"format_unit", format_unit, true},
 	}
@@ -92,6 +109,29 @@ func clear_plugin_cache(plugin string) string {
 	return ""
 }
 
+// clear_query_cache removes the cached rows of a table, or of all the tables if none is specified
+func clear_query_cache(table ...string) string {
+	if len(table) > 1 {
+		return "clear_query_cache takes at most one table name"
+	}
+	name := ""
+	if len(table) == 1 {
+		name = table[0]
+	}
+
+	removed := module.ClearQueryCache(name)
+	return fmt.Sprintf("%d queries removed from the cache", removed)
+}
+
+// query_cache_info returns the tables of the query cache as a JSON array
+func query_cache_info() string {
+	marshalled, err := json.Marshal(module.QueryCacheInfo())
+	if err != nil {
+		return err.Error()
+	}
+	return string(marshalled)
+}
+
 type bufferFlusher struct {
 	modules *map[string]*module.SQLiteModule
 }
@@ -0,0 +1,188 @@
+package namespace
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/julien040/anyquery/other/sqlparser"
+)
+
+// # Rewriting the text of a query
+//
+// The parser follows MySQL, and printing back the syntax tree of a query
+// can change its meaning for SQLite: "a" || "b" is the concatenation of two columns for SQLite,
+// while MySQL reads an OR between two strings. The rewrites of the queries on the plugins
+// therefore edit the text of the query at the positions of its tokens,
+// and parse the result to check that it is the statement they meant to write.
+
+// queryToken is a token of a query and its position in the text of the query
+type queryToken struct {
+	typ int
+	val string
+	// The byte offsets of the token in the query
+	start int
+	end   int
+}
+
+// tokenizeQuery returns the tokens of a query
+//
+// It returns false if the query can't be tokenized, or if MySQL and SQLite read some of its tokens differently:
+// a double-quoted string (an identifier for SQLite), || (the concatenation for SQLite)
+// and a string holding a backslash (an escape character for MySQL only)
+func tokenizeQuery(query string) ([]queryToken, bool) {
+	// The tokens of a MySQL specific comment are not positioned in the query
+	if strings.Contains(query, "/*!") {
+		return nil, false
+	}
+	parser, err := sqlparser.New(sqlparser.Options{
+		MySQLServerVersion: "8.0.30",
+	})
+	if err != nil {
+		return nil, false
+	}
+
+	tokenizer := parser.NewStringTokenizer(query)
+	tokens := []queryToken{}
+	for {
+		start := tokenizer.Pos
+		typ, val := tokenizer.Scan()
+		if typ == 0 {
+			return tokens, true
+		}
+		for start < len(query) && strings.IndexByte(" \n\r\t", query[start]) != -1 {
+			start++
+		}
+		token := queryToken{typ: typ, val: val, start: start, end: tokenizer.Pos}
+		text := query[token.start:token.end]
+		switch {
+		case typ == sqlparser.LEX_ERROR,
+			typ == sqlparser.OR && text == "||",
+			strings.HasPrefix(text, "\""),
+			typ == sqlparser.STRING && strings.Contains(text, "\\"):
+			return nil, false
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// matchingParen returns the index of the parenthesis closing the one at index i, or -1
+func matchingParen(tokens []queryToken, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		switch tokens[j].typ {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// topLevelClauses returns the index of the first token of each clause of a SELECT statement
+// (SELECT, FROM, WHERE, GROUP, HAVING, ORDER, LIMIT), skipping the ones of its subqueries
+func topLevelClauses(tokens []queryToken) map[int]int {
+	clauses := make(map[int]int)
+	depth := 0
+	for i, token := range tokens {
+		switch token.typ {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sqlparser.SELECT, sqlparser.FROM, sqlparser.WHERE, sqlparser.GROUP,
+			sqlparser.HAVING, sqlparser.ORDER, sqlparser.LIMIT:
+			if _, ok := clauses[token.typ]; !ok && depth == 0 {
+				clauses[token.typ] = i
+			}
+		}
+	}
+	return clauses
+}
+
+// clauseEnd returns the index of the token following the clause starting at index i
+func clauseEnd(tokens []queryToken, clauses map[int]int, i int) int {
+	end := len(tokens)
+	for _, j := range clauses {
+		if j > i && j < end {
+			end = j
+		}
+	}
+	// Keep the trailing semicolon out of the clause
+	if end == len(tokens) && end > 0 && tokens[end-1].typ == ';' {
+		end--
+	}
+	return end
+}
+
+// findColumn returns the indexes of the first and last tokens of the first reference to the column
+// in tokens[from:to], or -1 if there is none
+//
+// The column is qualified if the reference is
+func findColumn(tokens []queryToken, from, to int, colName *sqlparser.ColName) (int, int) {
+	for i := from; i < to; i++ {
+		token := tokens[i]
+		if token.typ == sqlparser.STRING || !strings.EqualFold(token.val, colName.Name.String()) {
+			continue
+		}
+		// A function call
+		if i+1 < len(tokens) && tokens[i+1].typ == '(' {
+			continue
+		}
+		qualified := i >= 2 && tokens[i-1].typ == '.'
+		if colName.Qualifier.IsEmpty() {
+			if !qualified {
+				return i, i
+			}
+			continue
+		}
+		if qualified && tokens[i-2].val == colName.Qualifier.Name.String() {
+			return i - 2, i
+		}
+	}
+	return -1, -1
+}
+
+// queryEdit replaces the bytes start to end of a query by text
+type queryEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyEdits returns the query with the edits applied
+//
+// The edits must not overlap
+func applyEdits(query string, edits []queryEdit) string {
+	edits = slices.Clone(edits)
+	slices.SortStableFunc(edits, func(a, b queryEdit) int {
+		return a.start - b.start
+	})
+
+	var builder strings.Builder
+	last := 0
+	for _, edit := range edits {
+		builder.WriteString(query[last:edit.start])
+		builder.WriteString(edit.text)
+		last = edit.end
+	}
+	builder.WriteString(query[last:])
+	return builder.String()
+}
+
+// sameStatement reports whether query is parsed as the statement stmt
+func sameStatement(query string, stmt sqlparser.Statement) bool {
+	_, parsed, err := GetQueryType(query)
+	if err != nil || parsed == nil {
+		return false
+	}
+	return sqlparser.String(parsed) == sqlparser.String(stmt)
+}
+
+// quoteSQLIdentifier returns s as a quoted SQL identifier
+func quoteSQLIdentifier(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
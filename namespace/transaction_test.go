@@ -0,0 +1,122 @@
+package namespace
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/julien040/anyquery/rpc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPluginTransactions(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	err := exec.Command("go", "build", "-o", "_test/transactionplugin.out", "../test/transactionplugin.go").Run()
+	require.NoError(t, err, "The plugin should build")
+
+	namespace, err := NewNamespace(NamespaceConfig{
+		InMemory: true,
+	})
+	require.NoError(t, err, "The namespace should be initialized")
+
+	err = namespace.LoadAnyqueryPlugin("_test/transactionplugin.out", rpc.PluginManifest{
+		Name:   "transaction",
+		Tables: []string{"notes"},
+		TablesMetadata: map[string]rpc.TableMetadata{
+			"notes": {HandlesTransactions: true},
+		},
+	}, nil, 0)
+	require.NoError(t, err, "The plugin should load")
+
+	db, err := namespace.Register("")
+	require.NoError(t, err, "The connection should be registered")
+	defer db.Close()
+
+	// The transactions are bound to a SQLite connection
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	exec := func(t *testing.T, statements ...string) {
+		for _, statement := range statements {
+			_, err := conn.ExecContext(context.Background(), statement)
+			require.NoError(t, err, "The statement should work: %s", statement)
+		}
+	}
+	titles := func(t *testing.T) []string {
+		rows, err := conn.QueryContext(context.Background(), "SELECT title FROM notes ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		result := []string{}
+		for rows.Next() {
+			var title string
+			require.NoError(t, rows.Scan(&title))
+			result = append(result, title)
+		}
+		require.NoError(t, rows.Err())
+		return result
+	}
+
+	t.Run("The writes outside a transaction are applied", func(t *testing.T) {
+		exec(t, "INSERT INTO notes VALUES (1, 'autocommit')")
+		require.Equal(t, []string{"autocommit"}, titles(t))
+	})
+
+	t.Run("The writes of a transaction are applied on commit", func(t *testing.T) {
+		exec(t, "BEGIN", "INSERT INTO notes VALUES (2, 'committed')")
+		require.Equal(t, []string{"autocommit"}, titles(t), "The staged row should not be visible")
+		exec(t, "COMMIT")
+		require.Equal(t, []string{"autocommit", "committed"}, titles(t))
+	})
+
+	t.Run("The writes of a transaction are discarded on rollback", func(t *testing.T) {
+		exec(t, "BEGIN", "INSERT INTO notes VALUES (3, 'rolled back')", "ROLLBACK")
+		require.Equal(t, []string{"autocommit", "committed"}, titles(t))
+	})
+
+	t.Run("The writes made since a savepoint are discarded", func(t *testing.T) {
+		exec(t,
+			"BEGIN",
+			"INSERT INTO notes VALUES (4, 'before savepoint')",
+			"SAVEPOINT s1",
+			"INSERT INTO notes VALUES (5, 'after savepoint')",
+			"ROLLBACK TO s1",
+			"RELEASE s1",
+			"COMMIT",
+		)
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint"}, titles(t))
+	})
+
+	t.Run("Releasing the savepoint opening the transaction commits it", func(t *testing.T) {
+		exec(t, "SAVEPOINT outer", "INSERT INTO notes VALUES (6, 'released')", "RELEASE outer")
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint", "released"}, titles(t))
+	})
+
+	t.Run("A failed commit returns an error and rolls back", func(t *testing.T) {
+		exec(t, "BEGIN", "INSERT INTO notes VALUES (7, 'fail')")
+		_, err := conn.ExecContext(context.Background(), "COMMIT")
+		require.Error(t, err, "The commit of the plugin should fail")
+		// The transaction is over
+		_, err = conn.ExecContext(context.Background(), "ROLLBACK")
+		require.Error(t, err, "No transaction should be open")
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint", "released"}, titles(t))
+
+		exec(t, "BEGIN", "INSERT INTO notes VALUES (8, 'next')", "COMMIT")
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint", "released", "next"}, titles(t))
+
+		// In autocommit mode, the statement itself fails
+		_, err = conn.ExecContext(context.Background(), "INSERT INTO notes VALUES (10, 'fail')")
+		require.Error(t, err, "The commit of the plugin should fail")
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint", "released", "next"}, titles(t))
+	})
+
+	t.Run("A prepared COMMIT that is not run does not commit", func(t *testing.T) {
+		exec(t, "BEGIN", "INSERT INTO notes VALUES (9, 'prepared')")
+		stmt, err := conn.PrepareContext(context.Background(), "COMMIT")
+		require.NoError(t, err)
+		require.NoError(t, stmt.Close())
+		exec(t, "ROLLBACK")
+		require.Equal(t, []string{"autocommit", "committed", "before savepoint", "released", "next"}, titles(t))
+	})
+}
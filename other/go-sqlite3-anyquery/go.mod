@@ -0,0 +1,3 @@
+module github.com/julien040/go-sqlite3-anyquery
+
+go 1.22.1
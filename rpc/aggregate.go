@@ -0,0 +1,174 @@
+package rpc
+
+// This file implements the pushdown of aggregates (count, sum, min, max, avg)
+// grouped by columns of the table.
+//
+// SQLite does not forward aggregates to the virtual tables. Therefore, the main program rewrites
+// the queries like SELECT status, count(*) FROM table GROUP BY status
+// into a query on a virtual table whose rows are computed by the plugin (see QueryAggregate).
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AggregateFunction is an aggregate function that a plugin can compute
+type AggregateFunction string
+
+const (
+	AggregateCount AggregateFunction = "count"
+	AggregateSum   AggregateFunction = "sum"
+	AggregateMin   AggregateFunction = "min"
+	AggregateMax   AggregateFunction = "max"
+	AggregateAvg   AggregateFunction = "avg"
+)
+
+// Aggregate is an aggregate function applied to a column
+type Aggregate struct {
+	Function AggregateFunction
+	// The index of the column in the schema
+	//
+	// For count(*), it is set to -1
+	ColumnID int
+}
+
+// AggregateQuery describes the aggregates requested by the main program
+type AggregateQuery struct {
+	// The constraints of the rows to aggregate
+	//
+	// Unlike Query, the constraints cannot be skipped: SQLite does not see the rows
+	// and cannot filter them. Limit, Offset and OrderBy are never set
+	Constraint QueryConstraint
+
+	// The indexes of the columns to group the rows by
+	//
+	// If empty, all the rows are aggregated in a single row
+	GroupBy []int
+
+	// The aggregates to compute for each group
+	Aggregates []Aggregate
+}
+
+// ErrAggregateNotSupported can be returned by AggregateReaderInterface.QueryAggregate
+// when the plugin cannot compute the requested aggregates
+var ErrAggregateNotSupported = errors.New("the plugin cannot compute these aggregates")
+
+// AggregateReaderInterface can be implemented by a reader in addition to ReaderInterface
+// to compute aggregates server-side rather than returning every row
+//
+// It is only used for tables whose schema lists the functions in Aggregates
+type AggregateReaderInterface interface {
+	ReaderInterface
+
+	// QueryAggregate returns one row per group
+	//
+	// Each row holds the values of the GroupBy columns followed by the value of each aggregate,
+	// in the order of the query. Every constraint of the query must be applied
+	QueryAggregate(query AggregateQuery) ([][]interface{}, error)
+}
+
+// InternalAggregateInterface is implemented by the clients that can request aggregates from the plugin
+type InternalAggregateInterface interface {
+	QueryAggregate(connectionID int, tableIndex int, query AggregateQuery) ([][]interface{}, error)
+}
+
+// internalAggregateServer is implemented by the plugin-side InternalExchangeInterface
+// that can compute aggregates
+type internalAggregateServer interface {
+	QueryAggregate(connectionID int, tableIndex int, query AggregateQuery) ([][]interface{}, error)
+}
+
+// AggregateArgs is a struct that holds the arguments for the QueryAggregate method (see InitializeArgs)
+type AggregateArgs struct {
+	ConnectionID int
+	TableIndex   int
+	Query        AggregateQuery
+	// Whether the main program reads AggregateReturn.TypedRows (see QueryArgs)
+	TypedValues bool
+}
+
+// AggregateReturn is a struct that holds the return values for the QueryAggregate method
+type AggregateReturn struct {
+	Rows [][]interface{}
+	// The rows as typed values, sent instead of Rows if AggregateArgs.TypedValues is set
+	TypedRows [][]Value
+}
+
+// SupportsAggregate returns whether the schema lists the aggregate function
+func (s DatabaseSchema) SupportsAggregate(function AggregateFunction) bool {
+	for _, f := range s.Aggregates {
+		if f == function {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *PluginRPCClient) QueryAggregate(connectionID int, tableIndex int, query AggregateQuery) ([][]interface{}, error) {
+	var resp AggregateReturn
+	err := m.call("QueryAggregate", connectionID, tableIndex, &AggregateArgs{
+		ConnectionID: connectionID,
+		TableIndex:   tableIndex,
+		Query:        query,
+		TypedValues:  true,
+	}, &resp)
+	if resp.TypedRows != nil {
+		return decodeRows(resp.TypedRows), err
+	}
+	return resp.Rows, err
+}
+
+func (m *PluginRPCServer) QueryAggregate(args *AggregateArgs, resp *AggregateReturn) error {
+	impl, ok := m.Impl.(internalAggregateServer)
+	if !ok {
+		return ErrAggregateNotSupported
+	}
+	rows, err := impl.QueryAggregate(args.ConnectionID, args.TableIndex, args.Query)
+	if err == nil && args.TypedValues {
+		resp.TypedRows, err = encodeRows(rows)
+		return err
+	}
+	resp.Rows = rows
+	return err
+}
+
+func (i *internalInterface) QueryAggregate(connectionIndex int, tableIndex int, query AggregateQuery) (rows [][]interface{}, err error) {
+	// Catch the panic and return it as an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panicked while running QueryAggregate: %v", r)
+		}
+	}()
+
+	// We check if the table is registered
+	table, ok := i.plugin.tableConnection[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}]
+	if !ok {
+		return nil, fmt.Errorf("main program did not initialize the table before querying it")
+	}
+
+	// An aggregate is computed once, so we don't store the reader in the cursors map
+	reader, ok := table.CreateReader().(AggregateReaderInterface)
+	if !ok {
+		return nil, ErrAggregateNotSupported
+	}
+	rows, err = reader.QueryAggregate(query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The grouped columns are converted to their type. The type of the aggregates depends on the values
+	schema := i.plugin.schemas[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}]
+	for index, row := range rows {
+		for j, value := range row {
+			if j < len(query.GroupBy) && query.GroupBy[j] >= 0 && query.GroupBy[j] < len(schema.Columns) {
+				row[j], err = ConvertValue(value, schema.Columns[query.GroupBy[j]].Type)
+			} else {
+				row[j], err = convertUntyped(value)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("the row %d returned by the plugin does not match the schema of the table: %w", index, err)
+			}
+		}
+	}
+	return rows, nil
+}
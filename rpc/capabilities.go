@@ -0,0 +1,261 @@
+package rpc
+
+// This file implements the capabilities a plugin declares in its manifest.
+//
+// Only a plugin that declares no host is restricted: it runs in a new network namespace on Linux,
+// so it cannot reach the network at all.
+// The requests of a plugin declaring hosts go through a proxy (HTTP_PROXY and HTTPS_PROXY)
+// that only reaches these hosts. The proxy is advisory: a plugin ignoring HTTP_PROXY connects directly.
+// It asks for a password given in the URL of the proxy, so that the other processes of the machine can't use it.
+// The filesystem paths and the execution of programs are declarations shown to the user, they are not enforced.
+// Capabilities are therefore not a sandbox, and Describe says which of them are enforced.
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// PluginCapabilities are the permissions a plugin requires, declared in its manifest
+//
+// A nil *PluginCapabilities means the plugin does not declare them, and it is not restricted
+type PluginCapabilities struct {
+	// The hosts the plugin can send requests to (e.g. api.github.com).
+	// A leading "*." also matches the subdomains (e.g. *.googleapis.com)
+	NetworkHosts []string `json:"network_hosts,omitempty"`
+	// The files and directories the plugin reads or writes
+	FilesystemPaths []string `json:"filesystem_paths,omitempty"`
+	// Whether the plugin runs other programs
+	Exec bool `json:"exec,omitempty"`
+}
+
+// ParsePluginCapabilities parses the capabilities stored as a JSON string
+//
+// An empty string means the plugin does not declare them, and nil is returned
+func ParsePluginCapabilities(raw string) (*PluginCapabilities, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	capabilities := &PluginCapabilities{}
+	err := json.Unmarshal([]byte(raw), capabilities)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the capabilities of the plugin: %w", err)
+	}
+	return capabilities, nil
+}
+
+// String returns the capabilities as a JSON string, or an empty string if c is nil
+func (c *PluginCapabilities) String() string {
+	if c == nil {
+		return ""
+	}
+	raw, _ := json.Marshal(c)
+	return string(raw)
+}
+
+// Describe returns a human-readable line for each capability, saying whether it is enforced
+func (c *PluginCapabilities) Describe() []string {
+	if c == nil {
+		return []string{"The plugin does not declare its capabilities: it has full network and filesystem access"}
+	}
+	lines := []string{}
+	if len(c.NetworkHosts) == 0 {
+		lines = append(lines, "Network: none (enforced on Linux only)")
+	} else {
+		lines = append(lines, "Network: "+strings.Join(c.NetworkHosts, ", ")+
+			" (not enforced: the plugin's HTTP proxy only reaches these hosts, but the plugin can connect directly)")
+	}
+	if len(c.FilesystemPaths) == 0 {
+		lines = append(lines, "Filesystem: none (not enforced)")
+	} else {
+		lines = append(lines, "Filesystem: "+strings.Join(c.FilesystemPaths, ", ")+" (not enforced)")
+	}
+	if c.Exec {
+		lines = append(lines, "Run other programs: yes")
+	} else {
+		lines = append(lines, "Run other programs: no (not enforced)")
+	}
+	return lines
+}
+
+// Equal reports whether c and other declare the same capabilities
+func (c *PluginCapabilities) Equal(other *PluginCapabilities) bool {
+	return c.String() == other.String()
+}
+
+// AllowsHost reports whether the plugin can send requests to host
+//
+// host must not contain a port
+func (c *PluginCapabilities) AllowsHost(host string) bool {
+	if c == nil {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, allowed := range c.NetworkHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilityProxy is the HTTP proxy the requests of a plugin go through
+//
+// It tunnels the HTTPS requests (CONNECT) and forwards the HTTP ones
+// if their host is declared in the capabilities of the plugin
+type capabilityProxy struct {
+	capabilities *PluginCapabilities
+	logger       hclog.Logger
+	listener     net.Listener
+	// The password of the proxy, given in its URL
+	password  string
+	server    *http.Server
+	transport *http.Transport
+	closeOnce sync.Once
+}
+
+// startCapabilityProxy starts a proxy listening on the loopback interface
+func startCapabilityProxy(capabilities *PluginCapabilities, logger hclog.Logger) (*capabilityProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("could not start the proxy of the plugin: %w", err)
+	}
+	proxy := &capabilityProxy{
+		capabilities: capabilities,
+		logger:       logger,
+		listener:     listener,
+		password:     rand.Text(),
+		// The proxy must not use the proxy of the main program
+		transport: &http.Transport{Proxy: nil},
+	}
+	proxy.server = &http.Server{Handler: proxy, ReadHeaderTimeout: 30 * time.Second}
+	go proxy.server.Serve(listener)
+	return proxy, nil
+}
+
+// url returns the URL of the proxy, holding its credentials
+func (p *capabilityProxy) url() *url.URL {
+	return &url.URL{Scheme: "http", User: url.UserPassword("anyquery", p.password), Host: p.listener.Addr().String()}
+}
+
+// authorized reports whether the request holds the credentials of the proxy
+func (p *capabilityProxy) authorized(r *http.Request) bool {
+	expected := "Basic " + base64.StdEncoding.EncodeToString([]byte("anyquery:"+p.password))
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Proxy-Authorization")), []byte(expected)) == 1
+}
+
+// env returns the environment variables that make the plugin use the proxy
+func (p *capabilityProxy) env() []string {
+	url := p.url().String()
+	return []string{
+		"HTTP_PROXY=" + url, "HTTPS_PROXY=" + url, "http_proxy=" + url, "https_proxy=" + url,
+		"NO_PROXY=", "no_proxy=",
+	}
+}
+
+func (p *capabilityProxy) Close() error {
+	p.closeOnce.Do(func() {
+		p.server.Close()
+		p.transport.CloseIdleConnections()
+	})
+	return nil
+}
+
+func (p *capabilityProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !p.authorized(r) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="anyquery"`)
+		http.Error(w, "anyquery: the proxy of the plugin requires its credentials", http.StatusProxyAuthRequired)
+		return
+	}
+
+	host := r.URL.Hostname()
+	if r.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(r.Host)
+	}
+	if !p.capabilities.AllowsHost(host) {
+		p.logger.Warn("blocked a request of the plugin to a host not declared in its capabilities", "host", host)
+		http.Error(w, "anyquery: the plugin is not allowed to reach "+host, http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "anyquery: this is a proxy, the URL must be absolute", http.StatusBadRequest)
+		return
+	}
+	request := r.Clone(r.Context())
+	request.RequestURI = ""
+	request.Header.Del("Proxy-Connection")
+	request.Header.Del("Proxy-Authorization")
+	response, err := p.transport.RoundTrip(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for key, values := range response.Header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
+
+// tunnel connects the plugin to the host of a CONNECT request
+func (p *capabilityProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "anyquery: the proxy cannot tunnel the connection", http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	upstream, err := (&net.Dialer{}).DialContext(ctx, "tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	_, err = client.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	if err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+
+	go func() {
+		// The client might have sent bytes before the tunnel was established
+		io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	io.Copy(client, upstream)
+	client.Close()
+}
@@ -0,0 +1,146 @@
+package rpc
+
+// This file implements the cancellation of the calls to the plugin.
+//
+// net/rpc has no notion of context. Therefore, when the context of a query is done,
+// the main program stops waiting for the call and sends a Cancel call to the plugin.
+// The plugin library then cancels the context passed to the reader of the cursor.
+
+import (
+	"context"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// InternalContextInterface is implemented by the clients that can cancel
+// the calls to the plugin once the context of the query is done
+type InternalContextInterface interface {
+	// QueryContext is the same as InternalExchangeInterface.Query
+	// but returns ctx.Err() as soon as ctx is done and tells the plugin to stop working on the cursor
+	//
+	// If ctx has a deadline, it is forwarded to the plugin
+	QueryContext(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) ([][]interface{}, bool, error)
+}
+
+// ContextReaderInterface can be implemented by a reader in addition to ReaderInterface
+// to be notified when the query is cancelled (e.g. the user hit Ctrl-C, the MySQL client disconnected,
+// or the query timed out)
+//
+// If a reader implements it, QueryContext is called instead of Query
+type ContextReaderInterface interface {
+	ReaderInterface
+
+	// QueryContext is the same as ReaderInterface.Query
+	//
+	// ctx is cancelled when the main program is not interested in the rows anymore.
+	// The reader should pass it to its API calls and return as soon as possible
+	QueryContext(ctx context.Context, constraint QueryConstraint) ([][]interface{}, bool, error)
+}
+
+// internalCancelServer is implemented by the plugin-side InternalExchangeInterface
+// that can cancel an in-flight query
+type internalCancelServer interface {
+	Cancel(connectionID int, tableIndex int, cursorIndex int) error
+	queryDeadline(connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint, deadline time.Time, traceContext TraceContext) ([][]interface{}, bool, error)
+}
+
+// CancelArgs is a struct that holds the arguments for the Cancel method (see InitializeArgs)
+type CancelArgs struct {
+	ConnectionID int
+	TableIndex   int
+	CursorIndex  int
+}
+
+func (m *PluginRPCClient) QueryContext(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (rows [][]interface{}, noMoreRows bool, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	ctx, span := startPluginSpan(ctx, "Query", connectionID, tableIndex)
+	defer func() {
+		span.SetAttributes(attribute.Int("anyquery.rows", len(rows)))
+		EndSpan(span, err)
+	}()
+
+	args := &QueryArgs{
+		ConnectionID: connectionID,
+		TableIndex:   tableIndex,
+		CursorIndex:  cursorIndex,
+		Constraint:   constraint,
+		TypedValues:  true,
+		TraceContext: traceContextFrom(ctx),
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		args.Deadline = deadline
+	}
+
+	var resp QueryReturn
+	call := m.client.Go("Plugin.Query", args, &resp, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return resp.rows(), resp.NoMoreRows, call.Error
+	case <-ctx.Done():
+		// We don't wait for the plugin to acknowledge the cancellation.
+		// Old plugins don't know the Cancel method and will return an error that we ignore
+		m.client.Go("Plugin.Cancel", &CancelArgs{
+			ConnectionID: connectionID,
+			TableIndex:   tableIndex,
+			CursorIndex:  cursorIndex,
+		}, new(struct{}), make(chan *rpc.Call, 1))
+		return nil, false, ctx.Err()
+	}
+}
+
+func (m *PluginRPCServer) Cancel(args *CancelArgs, resp *struct{}) error {
+	impl, ok := m.Impl.(internalCancelServer)
+	if !ok {
+		return nil
+	}
+	return impl.Cancel(args.ConnectionID, args.TableIndex, args.CursorIndex)
+}
+
+// cursorContexts holds the cancel functions of the in-flight queries of a plugin
+type cursorContexts struct {
+	mu      sync.Mutex
+	cancels map[cursorKey]context.CancelFunc
+}
+
+// start returns the context of an in-flight query of the cursor
+//
+// The returned function must be called once the query returns
+func (c *cursorContexts) start(key cursorKey, deadline time.Time, traceContext TraceContext) (context.Context, func()) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if deadline.IsZero() {
+		ctx, cancel = context.WithCancel(traceContext.Context(context.Background()))
+	} else {
+		ctx, cancel = context.WithDeadline(traceContext.Context(context.Background()), deadline)
+	}
+
+	c.mu.Lock()
+	if c.cancels == nil {
+		c.cancels = make(map[cursorKey]context.CancelFunc)
+	}
+	c.cancels[key] = cancel
+	c.mu.Unlock()
+
+	return ctx, func() {
+		c.mu.Lock()
+		delete(c.cancels, key)
+		c.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancel cancels the in-flight query of the cursor if any
+func (c *cursorContexts) cancel(key cursorKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, ok := c.cancels[key]; ok {
+		cancel()
+		delete(c.cancels, key)
+	}
+}
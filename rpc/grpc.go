@@ -0,0 +1,698 @@
+package rpc
+
+// This file implements the gRPC transport of the plugin protocol.
+//
+// It is an alternative to net/rpc and gob for the plugins whose manifest sets Protocol to ProtocolGRPC.
+// Because the messages are described in proto/plugin.proto, plugins can be written in any language
+// with a gRPC library. The Go plugins select it by calling Plugin.ServeGRPC rather than Plugin.Serve.
+//
+// Unlike net/rpc, gRPC forwards the cancellation and the deadline of the calls,
+// and streams the rows natively (no MuxBroker connection is needed).
+
+//go:generate protoc -I proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative proto/plugin.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	go_plugin "github.com/hashicorp/go-plugin"
+	pb "github.com/julien040/anyquery/rpc/proto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// The transports a plugin can be served with (see PluginManifest.Protocol)
+const (
+	// ProtocolNetRPC is the default transport (net/rpc with gob encoding)
+	ProtocolNetRPC = "netrpc"
+	// ProtocolGRPC is the gRPC transport described in rpc/proto/plugin.proto
+	ProtocolGRPC = "grpc"
+)
+
+// allowedProtocols returns the go-plugin protocols the main program accepts
+// for a plugin whose manifest sets protocol
+//
+// An empty protocol (e.g. a development plugin) accepts both transports
+func allowedProtocols(protocol string) ([]go_plugin.Protocol, error) {
+	switch protocol {
+	case "":
+		return []go_plugin.Protocol{go_plugin.ProtocolNetRPC, go_plugin.ProtocolGRPC}, nil
+	case ProtocolNetRPC:
+		return []go_plugin.Protocol{go_plugin.ProtocolNetRPC}, nil
+	case ProtocolGRPC:
+		return []go_plugin.Protocol{go_plugin.ProtocolGRPC}, nil
+	}
+	return nil, fmt.Errorf("unknown plugin protocol %q (expected %q or %q)", protocol, ProtocolNetRPC, ProtocolGRPC)
+}
+
+func (p *InternalPlugin) GRPCServer(b *go_plugin.GRPCBroker, s *grpc.Server) error {
+	pb.RegisterPluginServer(s, &PluginGRPCServer{Impl: p.Impl})
+	return nil
+}
+
+func (p *InternalPlugin) GRPCClient(ctx context.Context, b *go_plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	return &PluginGRPCClient{client: pb.NewPluginClient(c)}, nil
+}
+
+// PluginGRPCClient is the gRPC counterpart of PluginRPCClient
+type PluginGRPCClient struct {
+	client pb.PluginClient
+}
+
+// PluginGRPCServer is the gRPC counterpart of PluginRPCServer
+type PluginGRPCServer struct {
+	pb.UnimplementedPluginServer
+	Impl InternalExchangeInterface
+}
+
+// -- Conversion between the Go values and the protobuf messages --
+
+func valueToProto(value interface{}) (*pb.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return &pb.Value{}, nil
+	case int:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case int8:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case int16:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case int32:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case int64:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: v}}, nil
+	case uint:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case uint8:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case uint16:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case uint32:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case uint64:
+		return &pb.Value{Kind: &pb.Value_IntValue{IntValue: int64(v)}}, nil
+	case float32:
+		return &pb.Value{Kind: &pb.Value_FloatValue{FloatValue: float64(v)}}, nil
+	case float64:
+		return &pb.Value{Kind: &pb.Value_FloatValue{FloatValue: v}}, nil
+	case string:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: v}}, nil
+	case []byte:
+		return &pb.Value{Kind: &pb.Value_BytesValue{BytesValue: v}}, nil
+	case bool:
+		return &pb.Value{Kind: &pb.Value_BoolValue{BoolValue: v}}, nil
+	case time.Time:
+		return &pb.Value{Kind: &pb.Value_StringValue{StringValue: v.Format(time.RFC3339)}}, nil
+	case []interface{}:
+		return listToProto(v)
+	case []string:
+		return listToProto(v)
+	case []int64:
+		return listToProto(v)
+	case []float64:
+		return listToProto(v)
+	case []bool:
+		return listToProto(v)
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", value)
+}
+
+func listToProto[T any](values []T) (*pb.Value, error) {
+	list := &pb.ValueList{Values: make([]*pb.Value, len(values))}
+	for i, value := range values {
+		var err error
+		list.Values[i], err = valueToProto(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &pb.Value{Kind: &pb.Value_ListValue{ListValue: list}}, nil
+}
+
+func valueFromProto(value *pb.Value) interface{} {
+	switch v := value.GetKind().(type) {
+	case *pb.Value_IntValue:
+		return v.IntValue
+	case *pb.Value_FloatValue:
+		return v.FloatValue
+	case *pb.Value_StringValue:
+		return v.StringValue
+	case *pb.Value_BytesValue:
+		return v.BytesValue
+	case *pb.Value_BoolValue:
+		return v.BoolValue
+	case *pb.Value_ListValue:
+		return valuesFromProto(v.ListValue.GetValues())
+	}
+	return nil
+}
+
+func valuesToProto(values []interface{}) ([]*pb.Value, error) {
+	converted := make([]*pb.Value, len(values))
+	for i, value := range values {
+		var err error
+		converted[i], err = valueToProto(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+func valuesFromProto(values []*pb.Value) []interface{} {
+	converted := make([]interface{}, len(values))
+	for i, value := range values {
+		converted[i] = valueFromProto(value)
+	}
+	return converted
+}
+
+func rowsToProto(rows [][]interface{}) ([]*pb.Row, error) {
+	converted := make([]*pb.Row, len(rows))
+	for i, row := range rows {
+		values, err := valuesToProto(row)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+		converted[i] = &pb.Row{Values: values}
+	}
+	return converted, nil
+}
+
+func rowsFromProto(rows []*pb.Row) [][]interface{} {
+	converted := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		converted[i] = valuesFromProto(row.GetValues())
+	}
+	return converted
+}
+
+func configToProto(config PluginConfig) (map[string]*pb.Value, error) {
+	converted := make(map[string]*pb.Value, len(config))
+	for key, value := range config {
+		var err error
+		converted[key], err = valueToProto(value)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %w", key, err)
+		}
+	}
+	return converted, nil
+}
+
+func configFromProto(config map[string]*pb.Value) PluginConfig {
+	converted := make(PluginConfig, len(config))
+	for key, value := range config {
+		converted[key] = valueFromProto(value)
+	}
+	return converted
+}
+
+func constraintToProto(constraint QueryConstraint) (*pb.QueryConstraint, error) {
+	converted := &pb.QueryConstraint{
+		Columns:     make([]*pb.ColumnConstraint, len(constraint.Columns)),
+		Limit:       int64(constraint.Limit),
+		Offset:      int64(constraint.Offset),
+		OrderBy:     make([]*pb.OrderConstraint, len(constraint.OrderBy)),
+		ColumnsUsed: constraint.ColumnsUsed,
+	}
+	for i, column := range constraint.Columns {
+		value, err := valueToProto(column.Value)
+		if err != nil {
+			return nil, fmt.Errorf("constraint on column %d: %w", column.ColumnID, err)
+		}
+		converted.Columns[i] = &pb.ColumnConstraint{
+			ColumnId: int64(column.ColumnID),
+			Operator: int32(column.Operator),
+			Value:    value,
+		}
+	}
+	for i, order := range constraint.OrderBy {
+		converted.OrderBy[i] = &pb.OrderConstraint{
+			ColumnId:   int64(order.ColumnID),
+			Descending: order.Descending,
+		}
+	}
+	return converted, nil
+}
+
+func constraintFromProto(constraint *pb.QueryConstraint) QueryConstraint {
+	converted := QueryConstraint{
+		Limit:  int(constraint.GetLimit()),
+		Offset: int(constraint.GetOffset()),
+	}
+	for _, column := range constraint.GetColumns() {
+		converted.Columns = append(converted.Columns, ColumnConstraint{
+			ColumnID: int(column.GetColumnId()),
+			Operator: Operator(column.GetOperator()),
+			Value:    valueFromProto(column.GetValue()),
+		})
+	}
+	for _, order := range constraint.GetOrderBy() {
+		converted.OrderBy = append(converted.OrderBy, OrderConstraint{
+			ColumnID:   int(order.GetColumnId()),
+			Descending: order.GetDescending(),
+		})
+	}
+	// An empty list means that the main program did not send the used columns
+	if len(constraint.GetColumnsUsed()) > 0 {
+		converted.ColumnsUsed = constraint.GetColumnsUsed()
+	}
+	return converted
+}
+
+func schemaToProto(schema DatabaseSchema) *pb.DatabaseSchema {
+	converted := &pb.DatabaseSchema{
+		PrimaryKey:          int64(schema.PrimaryKey),
+		HandlesInsert:       schema.HandlesInsert,
+		HandlesUpdate:       schema.HandlesUpdate,
+		HandlesDelete:       schema.HandlesDelete,
+		HandlesTransactions: schema.HandlesTransactions,
+		HandleOffset:        schema.HandleOffset,
+		HandlesIn:           schema.HandlesIn,
+		BufferInsert:        uint64(schema.BufferInsert),
+		BufferUpdate:        uint64(schema.BufferUpdate),
+		BufferDelete:        uint64(schema.BufferDelete),
+		PartialUpdate:       schema.PartialUpdate,
+		StreamRows:          schema.StreamRows,
+		EstimatedRows:       schema.EstimatedRows,
+		EstimatedCost:       schema.EstimatedCost,
+		Description:         schema.Description,
+		CacheTtl:            int64(schema.CacheTTL),
+	}
+	for _, column := range schema.Columns {
+		converted.Columns = append(converted.Columns, &pb.DatabaseSchemaColumn{
+			Name:        column.Name,
+			Type:        pb.ColumnType(column.Type),
+			IsParameter: column.IsParameter,
+			IsRequired:  column.IsRequired,
+			Description: column.Description,
+		})
+	}
+	for _, function := range schema.Aggregates {
+		converted.Aggregates = append(converted.Aggregates, string(function))
+	}
+	for _, cost := range schema.ConstraintCosts {
+		columns := make([]int64, len(cost.Columns))
+		for i, column := range cost.Columns {
+			columns[i] = int64(column)
+		}
+		converted.ConstraintCosts = append(converted.ConstraintCosts, &pb.ConstraintCost{
+			Columns:       columns,
+			EstimatedRows: cost.EstimatedRows,
+			EstimatedCost: cost.EstimatedCost,
+		})
+	}
+	return converted
+}
+
+func schemaFromProto(schema *pb.DatabaseSchema) DatabaseSchema {
+	converted := DatabaseSchema{
+		PrimaryKey:          int(schema.GetPrimaryKey()),
+		HandlesInsert:       schema.GetHandlesInsert(),
+		HandlesUpdate:       schema.GetHandlesUpdate(),
+		HandlesDelete:       schema.GetHandlesDelete(),
+		HandlesTransactions: schema.GetHandlesTransactions(),
+		HandleOffset:        schema.GetHandleOffset(),
+		HandlesIn:           schema.GetHandlesIn(),
+		BufferInsert:        uint(schema.GetBufferInsert()),
+		BufferUpdate:        uint(schema.GetBufferUpdate()),
+		BufferDelete:        uint(schema.GetBufferDelete()),
+		PartialUpdate:       schema.GetPartialUpdate(),
+		StreamRows:          schema.GetStreamRows(),
+		EstimatedRows:       schema.GetEstimatedRows(),
+		EstimatedCost:       schema.GetEstimatedCost(),
+		Description:         schema.GetDescription(),
+		CacheTTL:            time.Duration(schema.GetCacheTtl()),
+	}
+	for _, column := range schema.GetColumns() {
+		converted.Columns = append(converted.Columns, DatabaseSchemaColumn{
+			Name:        column.GetName(),
+			Type:        ColumnType(column.GetType()),
+			IsParameter: column.GetIsParameter(),
+			IsRequired:  column.GetIsRequired(),
+			Description: column.GetDescription(),
+		})
+	}
+	for _, function := range schema.GetAggregates() {
+		converted.Aggregates = append(converted.Aggregates, AggregateFunction(function))
+	}
+	for _, cost := range schema.GetConstraintCosts() {
+		columns := make([]int, len(cost.GetColumns()))
+		for i, column := range cost.GetColumns() {
+			columns[i] = int(column)
+		}
+		converted.ConstraintCosts = append(converted.ConstraintCosts, ConstraintCost{
+			Columns:       columns,
+			EstimatedRows: cost.GetEstimatedRows(),
+			EstimatedCost: cost.GetEstimatedCost(),
+		})
+	}
+	return converted
+}
+
+// grpcError converts an error returned by a gRPC call to the error returned by the plugin
+//
+// The cancellation and the deadline of the call are returned as context.Canceled and context.DeadlineExceeded
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch s.Code() {
+	case codes.Canceled:
+		return context.Canceled
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	case codes.Unavailable:
+		// The process of the plugin might have exited (see restart.go)
+		return fmt.Errorf("%w: %s", errPluginUnreachable, s.Message())
+	}
+	return errors.New(s.Message())
+}
+
+// -- Implementation of the gRPC methods --
+
+// startSpan starts the span of a call to the plugin, and adds its trace context to the metadata of the call
+func (m *PluginGRPCClient) startSpan(ctx context.Context, method string, connectionID int, tableIndex int) (context.Context, trace.Span) {
+	ctx, span := startPluginSpan(ctx, method, connectionID, tableIndex)
+	return outgoingTraceContext(ctx), span
+}
+
+func (m *PluginGRPCClient) Initialize(connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error) {
+	return m.InitializeContext(context.Background(), connectionID, tableIndex, config)
+}
+
+func (m *PluginGRPCClient) InitializeContext(ctx context.Context, connectionID int, tableIndex int, config PluginConfig) (schema DatabaseSchema, err error) {
+	protoConfig, err := configToProto(config)
+	if err != nil {
+		return DatabaseSchema{}, err
+	}
+	ctx, span := m.startSpan(ctx, "Initialize", connectionID, tableIndex)
+	defer func() { EndSpan(span, err) }()
+	resp, err := m.client.Initialize(ctx, &pb.InitializeRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		Config:       protoConfig,
+	})
+	if err != nil {
+		return DatabaseSchema{}, grpcError(err)
+	}
+	return schemaFromProto(resp), nil
+}
+
+func (m *PluginGRPCClient) Query(connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) ([][]interface{}, bool, error) {
+	return m.QueryContext(context.Background(), connectionID, tableIndex, cursorIndex, constraint)
+}
+
+func (m *PluginGRPCClient) QueryContext(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (rows [][]interface{}, noMoreRows bool, err error) {
+	protoConstraint, err := constraintToProto(constraint)
+	if err != nil {
+		return nil, false, err
+	}
+	ctx, span := m.startSpan(ctx, "Query", connectionID, tableIndex)
+	defer func() {
+		span.SetAttributes(attribute.Int("anyquery.rows", len(rows)))
+		EndSpan(span, err)
+	}()
+	resp, err := m.client.Query(ctx, &pb.QueryRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		CursorIndex:  int64(cursorIndex),
+		Constraint:   protoConstraint,
+	})
+	if err != nil {
+		return nil, false, grpcError(err)
+	}
+	return rowsFromProto(resp.GetRows()), resp.GetNoMoreRows(), nil
+}
+
+func (m *PluginGRPCClient) QueryStream(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (RowStream, error) {
+	protoConstraint, err := constraintToProto(constraint)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, span := m.startSpan(ctx, "QueryStream", connectionID, tableIndex)
+	stream, err := m.client.QueryStream(ctx, &pb.QueryRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		CursorIndex:  int64(cursorIndex),
+		Constraint:   protoConstraint,
+	})
+	EndSpan(span, err)
+	if err != nil {
+		cancel()
+		return nil, grpcError(err)
+	}
+	return &grpcRowStream{stream: stream, cancel: cancel}, nil
+}
+
+func (m *PluginGRPCClient) QueryAggregate(connectionID int, tableIndex int, query AggregateQuery) (rows [][]interface{}, err error) {
+	protoConstraint, err := constraintToProto(query.Constraint)
+	if err != nil {
+		return nil, err
+	}
+	req := &pb.AggregateRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		Constraint:   protoConstraint,
+	}
+	for _, column := range query.GroupBy {
+		req.GroupBy = append(req.GroupBy, int64(column))
+	}
+	for _, aggregate := range query.Aggregates {
+		req.Aggregates = append(req.Aggregates, &pb.Aggregate{
+			Function: string(aggregate.Function),
+			ColumnId: int64(aggregate.ColumnID),
+		})
+	}
+	ctx, span := m.startSpan(context.Background(), "QueryAggregate", connectionID, tableIndex)
+	defer func() { EndSpan(span, err) }()
+	resp, err := m.client.QueryAggregate(ctx, req)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return rowsFromProto(resp.GetRows()), nil
+}
+
+func (m *PluginGRPCClient) Insert(connectionID int, tableIndex int, rows [][]interface{}) error {
+	protoRows, err := rowsToProto(rows)
+	if err != nil {
+		return err
+	}
+	ctx, span := m.startSpan(context.Background(), "Insert", connectionID, tableIndex)
+	_, err = m.client.Insert(ctx, &pb.InsertRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		Rows:         protoRows,
+	})
+	err = grpcError(err)
+	EndSpan(span, err)
+	return err
+}
+
+func (m *PluginGRPCClient) Update(connectionID int, tableIndex int, rows [][]interface{}) error {
+	protoRows, err := rowsToProto(rows)
+	if err != nil {
+		return err
+	}
+	ctx, span := m.startSpan(context.Background(), "Update", connectionID, tableIndex)
+	_, err = m.client.Update(ctx, &pb.UpdateRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		Rows:         protoRows,
+	})
+	err = grpcError(err)
+	EndSpan(span, err)
+	return err
+}
+
+func (m *PluginGRPCClient) Delete(connectionID int, tableIndex int, primaryKeys []interface{}) error {
+	protoKeys, err := valuesToProto(primaryKeys)
+	if err != nil {
+		return err
+	}
+	ctx, span := m.startSpan(context.Background(), "Delete", connectionID, tableIndex)
+	_, err = m.client.Delete(ctx, &pb.DeleteRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		PrimaryKeys:  protoKeys,
+	})
+	err = grpcError(err)
+	EndSpan(span, err)
+	return err
+}
+
+func (m *PluginGRPCClient) Transaction(connectionID int, tableIndex int, operation TransactionOperation, savepoint string) error {
+	ctx, span := m.startSpan(context.Background(), "Transaction", connectionID, tableIndex)
+	_, err := m.client.Transaction(ctx, &pb.TransactionRequest{
+		ConnectionId: int64(connectionID),
+		TableIndex:   int64(tableIndex),
+		Operation:    string(operation),
+		Savepoint:    savepoint,
+	})
+	err = grpcError(err)
+	EndSpan(span, err)
+	return err
+}
+
+func (m *PluginGRPCClient) Close(connectionID int) error {
+	ctx, span := m.startSpan(context.Background(), "Close", connectionID, -1)
+	_, err := m.client.Close(ctx, &pb.CloseRequest{ConnectionId: int64(connectionID)})
+	err = grpcError(err)
+	EndSpan(span, err)
+	return err
+}
+
+func (m *PluginGRPCServer) Initialize(ctx context.Context, req *pb.InitializeRequest) (*pb.DatabaseSchema, error) {
+	var schema DatabaseSchema
+	var err error
+	if impl, ok := m.Impl.(internalTraceServer); ok {
+		schema, err = impl.initializeTraced(int(req.GetConnectionId()), int(req.GetTableIndex()), configFromProto(req.GetConfig()),
+			incomingTraceContext(ctx))
+	} else {
+		schema, err = m.Impl.Initialize(int(req.GetConnectionId()), int(req.GetTableIndex()), configFromProto(req.GetConfig()))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schemaToProto(schema), nil
+}
+
+func (m *PluginGRPCServer) Query(ctx context.Context, req *pb.QueryRequest) (*pb.QueryResponse, error) {
+	connectionID, tableIndex, cursorIndex := int(req.GetConnectionId()), int(req.GetTableIndex()), int(req.GetCursorIndex())
+	constraint := constraintFromProto(req.GetConstraint())
+
+	var rows [][]interface{}
+	var noMoreRows bool
+	var err error
+	// If the plugin can be cancelled, we forward the deadline and the cancellation of the call
+	if impl, ok := m.Impl.(internalCancelServer); ok {
+		deadline, _ := ctx.Deadline()
+		stop := context.AfterFunc(ctx, func() {
+			impl.Cancel(connectionID, tableIndex, cursorIndex)
+		})
+		rows, noMoreRows, err = impl.queryDeadline(connectionID, tableIndex, cursorIndex, constraint, deadline,
+			incomingTraceContext(ctx))
+		stop()
+	} else {
+		rows, noMoreRows, err = m.Impl.Query(connectionID, tableIndex, cursorIndex, constraint)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	protoRows, err := rowsToProto(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.QueryResponse{Rows: protoRows, NoMoreRows: noMoreRows}, nil
+}
+
+func (m *PluginGRPCServer) QueryStream(req *pb.QueryRequest, stream grpc.ServerStreamingServer[pb.Row]) error {
+	impl, ok := m.Impl.(internalStreamServer)
+	if !ok {
+		return errors.New("plugin does not support streaming")
+	}
+	return impl.QueryStream(int(req.GetConnectionId()), int(req.GetTableIndex()), int(req.GetCursorIndex()),
+		constraintFromProto(req.GetConstraint()), &grpcRowWriter{stream: stream})
+}
+
+func (m *PluginGRPCServer) QueryAggregate(ctx context.Context, req *pb.AggregateRequest) (*pb.QueryResponse, error) {
+	impl, ok := m.Impl.(internalAggregateServer)
+	if !ok {
+		return nil, ErrAggregateNotSupported
+	}
+	query := AggregateQuery{Constraint: constraintFromProto(req.GetConstraint())}
+	for _, column := range req.GetGroupBy() {
+		query.GroupBy = append(query.GroupBy, int(column))
+	}
+	for _, aggregate := range req.GetAggregates() {
+		query.Aggregates = append(query.Aggregates, Aggregate{
+			Function: AggregateFunction(aggregate.GetFunction()),
+			ColumnID: int(aggregate.GetColumnId()),
+		})
+	}
+	rows, err := impl.QueryAggregate(int(req.GetConnectionId()), int(req.GetTableIndex()), query)
+	if err != nil {
+		return nil, err
+	}
+	protoRows, err := rowsToProto(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.QueryResponse{Rows: protoRows, NoMoreRows: true}, nil
+}
+
+func (m *PluginGRPCServer) Insert(ctx context.Context, req *pb.InsertRequest) (*pb.Empty, error) {
+	return &pb.Empty{}, m.Impl.Insert(int(req.GetConnectionId()), int(req.GetTableIndex()), rowsFromProto(req.GetRows()))
+}
+
+func (m *PluginGRPCServer) Update(ctx context.Context, req *pb.UpdateRequest) (*pb.Empty, error) {
+	return &pb.Empty{}, m.Impl.Update(int(req.GetConnectionId()), int(req.GetTableIndex()), rowsFromProto(req.GetRows()))
+}
+
+func (m *PluginGRPCServer) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.Empty, error) {
+	return &pb.Empty{}, m.Impl.Delete(int(req.GetConnectionId()), int(req.GetTableIndex()), valuesFromProto(req.GetPrimaryKeys()))
+}
+
+func (m *PluginGRPCServer) Transaction(ctx context.Context, req *pb.TransactionRequest) (*pb.Empty, error) {
+	impl, ok := m.Impl.(internalTransactionServer)
+	if !ok {
+		return nil, ErrTransactionNotSupported
+	}
+	return &pb.Empty{}, impl.Transaction(int(req.GetConnectionId()), int(req.GetTableIndex()),
+		TransactionOperation(req.GetOperation()), req.GetSavepoint())
+}
+
+func (m *PluginGRPCServer) Close(ctx context.Context, req *pb.CloseRequest) (*pb.Empty, error) {
+	return &pb.Empty{}, m.Impl.Close(int(req.GetConnectionId()))
+}
+
+// -- End of implementation of the gRPC methods --
+
+// grpcRowStream is the host side of a gRPC stream
+type grpcRowStream struct {
+	stream grpc.ServerStreamingClient[pb.Row]
+	cancel context.CancelFunc
+}
+
+func (s *grpcRowStream) Next() ([]interface{}, error) {
+	row, err := s.stream.Recv()
+	if err != nil {
+		// io.EOF is returned as is once the plugin has sent all the rows
+		return nil, grpcError(err)
+	}
+	return valuesFromProto(row.GetValues()), nil
+}
+
+func (s *grpcRowStream) Close() error {
+	s.cancel()
+	return nil
+}
+
+// grpcRowWriter is the plugin side of a gRPC stream
+type grpcRowWriter struct {
+	stream grpc.ServerStreamingServer[pb.Row]
+}
+
+func (w *grpcRowWriter) Write(row []interface{}) error {
+	values, err := valuesToProto(row)
+	if err != nil {
+		return err
+	}
+	return w.stream.Send(&pb.Row{Values: values})
+}
+
+func (w *grpcRowWriter) Context() context.Context {
+	ctx := w.stream.Context()
+	return incomingTraceContext(ctx).Context(ctx)
+}
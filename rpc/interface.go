@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"crypto/sha256"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"slices"
@@ -303,6 +304,11 @@ type TableMetadata struct {
 	//
 	//	[]string{"-- Get all the users\nSELECT * FROM users"}
 	Examples []string `json:"examples"`
+	// Whether the schema of the table sets DatabaseSchema.HandlesTransactions
+	//
+	// SQLite only forwards its transactions to the tables it doesn't create on the fly,
+	// so such a table is created when a connection opens, which starts the plugin
+	HandlesTransactions bool `json:"handles_transactions"`
 }
 
 // PluginManifest is a struct that holds the metadata of the plugin
@@ -317,6 +323,14 @@ type PluginManifest struct {
 	TablesMetadata map[string]TableMetadata `json:"tables_metadata"`
 
 	UserConfig []PluginConfigField
+
+	// The transport the plugin is served with: ProtocolNetRPC (the default) or ProtocolGRPC.
+	// If empty, the plugin can use either of them
+	Protocol string `json:"protocol"`
+
+	// The permissions the plugin requires. If nil, the plugin does not declare them
+	// and it has full network and filesystem access
+	Capabilities *PluginCapabilities `json:"capabilities"`
 }
 
 type PluginConfigField struct {
@@ -353,12 +367,28 @@ type DatabaseSchema struct {
 	// Whether the plugin can handle a DELETE statement
 	HandlesDelete bool
 
+	// Whether the table stages its writes in transactions (BEGIN ... COMMIT)
+	//
+	// The table must implement TransactionTable, and SavepointTable to support the savepoints.
+	// The manifest of the plugin must also set TableMetadata.HandlesTransactions for the table.
+	// Old versions of anyquery ignore this field and send the writes as they come
+	HandlesTransactions bool
+
 	// The following fields are used to optimize the queries
 
 	// HandleOffset is a boolean that specifies whether the plugin can handle the OFFSET clause.
 	// If not, the main program will skip the n offseted rows.
 	HandleOffset bool
 
+	// HandlesIn is a boolean that specifies whether the plugin can handle the OperatorIn constraint.
+	// If so, a query like
+	//	SELECT * FROM table WHERE id IN (1, 2, 3)
+	// sends a single constraint holding the three values rather than querying the plugin once per value.
+	// The values of an IN constraint on a required parameter are still sent one by one with OperatorEqual.
+	//
+	// Old versions of anyquery ignore this field and never send OperatorIn
+	HandlesIn bool
+
 	// How many rows should anyquery buffer before sending them to the plugin
 	//
 	// If set to 0, the main program will send the rows one by one
@@ -393,6 +423,53 @@ type DatabaseSchema struct {
 	//	[1, "world", 3.14]
 	PartialUpdate bool
 
+	// Whether the plugin streams the rows of the table as they are produced
+	// rather than returning them in fixed batches
+	//
+	// When set to true, the main program opens a stream for each cursor
+	// and the first rows reach SQLite as soon as the plugin produces them.
+	// The readers of the table should implement StreamReaderInterface.
+	// Otherwise, the pages returned by Query are streamed.
+	//
+	// Old versions of anyquery ignore this field and request the rows page by page
+	StreamRows bool
+
+	// The aggregate functions the plugin can compute server-side
+	// for the queries grouping the rows of the table (e.g. SELECT status, count(*) FROM table GROUP BY status)
+	//
+	// The readers of the table must implement AggregateReaderInterface.
+	// Old versions of anyquery ignore this field and request every row
+	Aggregates []AggregateFunction
+
+	// The approximate number of rows returned when the table is read without any constraint
+	//
+	// It helps SQLite to pick the order of the tables in a join
+	// (e.g. reading a small table first and querying a large one with its values).
+	// If set to 0, the table is assumed to be large
+	EstimatedRows int64
+
+	// The approximate cost of reading the table without any constraint (e.g. the number of API calls times 1000)
+	//
+	// If set to 0, the cost is EstimatedRows
+	EstimatedCost float64
+
+	// The estimates of the queries constraining some columns with an equality (e.g. WHERE id = 5)
+	//
+	// When several of them apply, the one with the lowest cost is used.
+	// If none of them applies, each column constrained by an equality
+	// divides the estimates of the table by 10
+	ConstraintCosts []ConstraintCost
+
+	// How long the main program caches the rows returned for a query
+	//
+	// When set, a query with the same constraints (see QueryConstraint.Hash) on the same profile
+	// is answered from the cache until the duration elapses, without calling the plugin.
+	// The user can override it in the profile, and clear the cache with the SQL function clear_query_cache.
+	// If set to 0, the rows are not cached (unless the profile sets a duration).
+	//
+	// Old versions of anyquery ignore this field
+	CacheTTL time.Duration
+
 	// A description of the table
 	// (Not used by early versions of anyquery)
 	//
@@ -424,6 +501,17 @@ const (
 	ColumnTypeJSON
 )
 
+// ConstraintCost estimates the rows and the cost of a query on a table
+// when the Columns are constrained by an equality (or a parameter of the table is set)
+type ConstraintCost struct {
+	// The indexes of the columns that must be constrained
+	Columns []int
+	// The approximate number of rows returned
+	EstimatedRows int64
+	// The approximate cost of the query. If set to 0, the cost is EstimatedRows
+	EstimatedCost float64
+}
+
 type DatabaseSchemaColumn struct {
 	// The name of the column
 	Name string
@@ -519,8 +607,19 @@ const (
 
 	// OperatorOFFSET is the OFFSET statement in a SQL query
 	OperatorOffset = 74
+
+	// OperatorIn is the IN operator (e.g. id IN (1, 2, 3))
+	//
+	// The value is a []interface{} holding the values of the list.
+	// It is only sent to the plugins setting DatabaseSchema.HandlesIn
+	OperatorIn = 75
 )
 
+func init() {
+	// The values of an OperatorIn constraint are sent in an interface{}
+	gob.Register([]interface{}{})
+}
+
 // QueryConstraint is a struct that holds the constraints for a SELECT query
 //
 // It specifies the WHERE conditions in the Columns field,
@@ -542,6 +641,13 @@ type QueryConstraint struct {
 
 	// The order by constraints (can be skipped and SQLite will handle it)
 	OrderBy []OrderConstraint
+
+	// ColumnsUsed[i] reports whether SQLite reads the column i of the schema
+	//
+	// The plugin can skip fetching the unused columns (e.g. the body of an email)
+	// and return nil in their place. It is nil when the main program does not send it
+	// (old versions of anyquery). Use IsColumnUsed rather than reading it directly
+	ColumnsUsed []bool
 }
 
 // Returns the sha256 hash of the query constraint for caching purposes
@@ -578,10 +684,11 @@ func (qc QueryConstraint) Hash() string {
 	})
 
 	clone := QueryConstraint{
-		Columns: clonedCol,
-		Limit:   qc.Limit,
-		Offset:  qc.Offset,
-		OrderBy: clonedOrder,
+		Columns:     clonedCol,
+		Limit:       qc.Limit,
+		Offset:      qc.Offset,
+		OrderBy:     clonedOrder,
+		ColumnsUsed: qc.ColumnsUsed,
 	}
 
 	marshalled, err := json.Marshal(clone)
@@ -605,6 +712,16 @@ func (qc QueryConstraint) GetColumnConstraint(columnID int) *ColumnConstraint {
 	return nil
 }
 
+// Returns whether SQLite reads the column at the index columnID of the schema
+//
+// If the main program did not send the used columns, it returns true
+func (qc QueryConstraint) IsColumnUsed(columnID int) bool {
+	if qc.ColumnsUsed == nil || columnID < 0 || columnID >= len(qc.ColumnsUsed) {
+		return true
+	}
+	return qc.ColumnsUsed[columnID]
+}
+
 type OrderConstraint struct {
 	ColumnID   int
 	Descending bool
@@ -624,6 +741,25 @@ func (cc *ColumnConstraint) IsEqual() bool {
 	return cc.Operator == OperatorEqual
 }
 
+// Returns the values of an IN constraint
+//
+// For an equal constraint, it returns its value as a list of one value
+// so that both can be handled the same way.
+// For any other operator or if the constraint does not exist, it returns nil
+func (cc *ColumnConstraint) GetInValues() []interface{} {
+	if cc == nil {
+		return nil
+	}
+	switch cc.Operator {
+	case OperatorIn:
+		values, _ := cc.Value.([]interface{})
+		return values
+	case OperatorEqual:
+		return []interface{}{cc.Value}
+	}
+	return nil
+}
+
 // Returns the string value of the column constraint
 //
 // If the value is not a string, it'll try to convert it to a string
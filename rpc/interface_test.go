@@ -1,6 +1,8 @@
 package rpc
 
 import (
+	"bytes"
+	"encoding/gob"
 	"testing"
 	"time"
 
@@ -227,3 +229,40 @@ func TestQueryConstraintHash(t *testing.T) {
 	require.NotEqual(t, hash2, hash3)
 
 }
+
+func TestQueryConstraintColumnsUsed(t *testing.T) {
+	// Old versions of anyquery don't send the used columns
+	constraints := QueryConstraint{}
+	require.True(t, constraints.IsColumnUsed(0))
+	require.True(t, constraints.IsColumnUsed(5))
+
+	constraints.ColumnsUsed = []bool{true, false, true}
+	require.True(t, constraints.IsColumnUsed(0))
+	require.False(t, constraints.IsColumnUsed(1))
+	require.True(t, constraints.IsColumnUsed(2))
+
+	// The projection is part of the hash because the rows differ
+	other := QueryConstraint{ColumnsUsed: []bool{true, true, true}}
+	require.NotEqual(t, constraints.Hash(), other.Hash())
+}
+
+func TestColumnConstraintInValues(t *testing.T) {
+	in := &ColumnConstraint{ColumnID: 0, Operator: OperatorIn, Value: []interface{}{int64(1), "two", 3.5}}
+	require.Equal(t, []interface{}{int64(1), "two", 3.5}, in.GetInValues())
+
+	equal := &ColumnConstraint{ColumnID: 0, Operator: OperatorEqual, Value: int64(4)}
+	require.Equal(t, []interface{}{int64(4)}, equal.GetInValues())
+
+	greater := &ColumnConstraint{ColumnID: 0, Operator: OperatorGreater, Value: int64(4)}
+	require.Nil(t, greater.GetInValues())
+
+	var missing *ColumnConstraint
+	require.Nil(t, missing.GetInValues())
+
+	// The values must survive the encoding between the main program and the plugin
+	var buf bytes.Buffer
+	require.NoError(t, gob.NewEncoder(&buf).Encode(QueryConstraint{Columns: []ColumnConstraint{*in}}))
+	var decoded QueryConstraint
+	require.NoError(t, gob.NewDecoder(&buf).Decode(&decoded))
+	require.Equal(t, in.GetInValues(), decoded.Columns[0].GetInValues())
+}
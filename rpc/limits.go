@@ -0,0 +1,81 @@
+package rpc
+
+// This file implements the resource limits of the plugins set in their profile.
+//
+// The limits of the operating system (memory, CPU time, open files) and the isolation
+// of the process are applied when the executable is started (see limits_linux.go).
+// The timeout of the calls is enforced by pooledPlugin (see restart.go): a plugin that does not
+// answer in time is killed, and started again on the next call.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ResourceLimits are the limits of the process of a plugin
+//
+// The zero value means no limits. Except CallTimeoutSeconds, they are only supported on Linux
+type ResourceLimits struct {
+	// The maximum size of the data segment of the process (heap and anonymous mappings) in MiB
+	MaxMemoryMB int64 `json:"max_memory_mb,omitempty"`
+	// The maximum CPU time of the process in seconds. The process is killed when it exceeds it
+	MaxCPUSeconds int64 `json:"max_cpu_seconds,omitempty"`
+	// The maximum number of files the process can open at the same time
+	MaxOpenFiles int64 `json:"max_open_files,omitempty"`
+	// The maximum wall-clock time of a call to the plugin in seconds
+	CallTimeoutSeconds int64 `json:"call_timeout_seconds,omitempty"`
+	// Run the plugin in new user, mount, PID, IPC and UTS namespaces
+	Isolate bool `json:"isolate,omitempty"`
+	// Run the plugin in a new network namespace. The plugin cannot reach the network.
+	// It implies Isolate
+	IsolateNetwork bool `json:"isolate_network,omitempty"`
+}
+
+// ParseResourceLimits parses the limits stored as a JSON string in the profile of a plugin
+//
+// An empty string means no limits
+func ParseResourceLimits(raw string) (ResourceLimits, error) {
+	limits := ResourceLimits{}
+	if raw == "" {
+		return limits, nil
+	}
+	err := json.Unmarshal([]byte(raw), &limits)
+	if err != nil {
+		return limits, fmt.Errorf("could not parse the resource limits: %w", err)
+	}
+	return limits, limits.Validate()
+}
+
+// Validate returns an error if a limit is negative
+func (l ResourceLimits) Validate() error {
+	if l.MaxMemoryMB < 0 || l.MaxCPUSeconds < 0 || l.MaxOpenFiles < 0 || l.CallTimeoutSeconds < 0 {
+		return errors.New("the resource limits cannot be negative")
+	}
+	return nil
+}
+
+// IsZero reports whether no limit is set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// String returns the limits as a JSON string, the format stored in the profile of a plugin
+func (l ResourceLimits) String() string {
+	raw, _ := json.Marshal(l)
+	return string(raw)
+}
+
+// CallTimeout returns the maximum wall-clock time of a call, or zero if the calls have no timeout
+func (l ResourceLimits) CallTimeout() time.Duration {
+	return time.Duration(l.CallTimeoutSeconds) * time.Second
+}
+
+// hasProcessLimits reports whether a limit must be applied by the operating system
+func (l ResourceLimits) hasProcessLimits() bool {
+	return l.MaxMemoryMB > 0 || l.MaxCPUSeconds > 0 || l.MaxOpenFiles > 0
+}
+
+// errCallTimeout is returned when a plugin does not answer within ResourceLimits.CallTimeoutSeconds
+var errCallTimeout = errors.New("the plugin did not answer in time")
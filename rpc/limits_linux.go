@@ -0,0 +1,64 @@
+//go:build linux
+
+package rpc
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// isolateCommand runs the command in new namespaces if the limits ask for it
+//
+// The user namespace maps the current user to itself so that the plugin
+// keeps the access to its files and to the socket of go-plugin
+func isolateCommand(command *exec.Cmd, limits ResourceLimits) error {
+	if !limits.Isolate && !limits.IsolateNetwork {
+		return nil
+	}
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if limits.IsolateNetwork {
+		flags |= syscall.CLONE_NEWNET
+	}
+	command.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: flags,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1},
+		},
+		// The plugin must not outlive the main program
+		Pdeathsig: syscall.SIGKILL,
+	}
+	return nil
+}
+
+// limitProcess applies the limits of the operating system to a running process
+//
+// The limits are set right after the handshake of go-plugin,
+// before the plugin receives any call from the main program
+func limitProcess(pid int, limits ResourceLimits) error {
+	set := func(resource int, value int64, name string) error {
+		if value <= 0 {
+			return nil
+		}
+		rlimit := unix.Rlimit{Cur: uint64(value), Max: uint64(value)}
+		if err := unix.Prlimit(pid, resource, &rlimit, nil); err != nil {
+			return fmt.Errorf("could not limit the %s of the plugin: %w", name, err)
+		}
+		return nil
+	}
+
+	if err := set(unix.RLIMIT_DATA, limits.MaxMemoryMB*1024*1024, "memory"); err != nil {
+		return err
+	}
+	if err := set(unix.RLIMIT_CPU, limits.MaxCPUSeconds, "CPU time"); err != nil {
+		return err
+	}
+	return set(unix.RLIMIT_NOFILE, limits.MaxOpenFiles, "open files")
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package rpc
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func isolateCommand(command *exec.Cmd, limits ResourceLimits) error {
+	if limits.Isolate || limits.IsolateNetwork {
+		return errors.New("the isolation of the plugins is only supported on Linux")
+	}
+	return nil
+}
+
+func limitProcess(pid int, limits ResourceLimits) error {
+	return errors.New("the resource limits of the plugins are only supported on Linux")
+}
@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -30,6 +31,16 @@ type TableCreatorArgs struct {
 	// ConnectionID is the index of the connection.
 	// It is used to identify the connection in the plugin and can change between restarts
 	ConnectionID int
+
+	// TraceContext is the trace context of the main program when it initialized the table.
+	// It is nil if the main program does not trace its queries (see Context)
+	TraceContext TraceContext
+}
+
+// Context returns a context holding the trace context of the main program,
+// so that the spans the table creator starts are part of the trace of the query
+func (a TableCreatorArgs) Context() context.Context {
+	return a.TraceContext.Context(context.Background())
 }
 
 // TableCreator is a function that creates a new table interface
@@ -127,7 +138,10 @@ type Plugin struct {
 	// cursors is a map that stores the readers of the tables
 	cursors map[cursorKey]ReaderInterface
 	// tableConnection maps a connection/tableID to a table interface
-	tableConnection   map[tableKey]Table
+	tableConnection map[tableKey]Table
+	// schemas maps a connection/tableID to the schema of the table,
+	// used to convert the values returned by the readers (see ConvertValue)
+	schemas           map[tableKey]DatabaseSchema
 	connectionStarted bool
 }
 
@@ -137,6 +151,7 @@ func NewPlugin(tables ...TableCreator) *Plugin {
 		table:           make(map[int]TableCreator),
 		cursors:         make(map[cursorKey]ReaderInterface),
 		tableConnection: make(map[tableKey]Table),
+		schemas:         make(map[tableKey]DatabaseSchema),
 	}
 	for i, table := range tables {
 		p.table[i] = table
@@ -164,6 +179,10 @@ func (p *Plugin) RegisterTable(tableIndex int, tableCreator TableCreator) error
 		p.tableConnection = make(map[tableKey]Table)
 	}
 
+	if p.schemas == nil {
+		p.schemas = make(map[tableKey]DatabaseSchema)
+	}
+
 	if _, ok := p.table[tableIndex]; ok {
 		return fmt.Errorf("table index is already registered")
 	}
@@ -176,6 +195,17 @@ func (p *Plugin) RegisterTable(tableIndex int, tableCreator TableCreator) error
 //
 // once called, any attempt to modify the plugin will be rejected
 func (p *Plugin) Serve() error {
+	return p.serve(false)
+}
+
+// ServeGRPC is the same as Serve but serves the plugin over gRPC
+//
+// The manifest of the plugin must set protocol to "grpc"
+func (p *Plugin) ServeGRPC() error {
+	return p.serve(true)
+}
+
+func (p *Plugin) serve(useGRPC bool) error {
 	if p.connectionStarted {
 		return fmt.Errorf("the plugin is already started")
 	}
@@ -215,7 +245,7 @@ func (p *Plugin) Serve() error {
 
 	internal := &internalInterface{plugin: p}
 
-	rpcPlugin.Serve(&rpcPlugin.ServeConfig{
+	config := &rpcPlugin.ServeConfig{
 		Plugins: map[string]rpcPlugin.Plugin{
 			"plugin": &InternalPlugin{Impl: internal},
 		},
@@ -224,7 +254,12 @@ func (p *Plugin) Serve() error {
 			MagicCookieKey:   MagicCookieKey,
 			MagicCookieValue: MagicCookieValue,
 		},
-	})
+	}
+	// go-plugin serves the plugin over gRPC once a gRPC server is set
+	if useGRPC {
+		config.GRPCServer = rpcPlugin.DefaultGRPCServer
+	}
+	rpcPlugin.Serve(config)
 
 	return nil
 }
@@ -233,10 +268,17 @@ func (p *Plugin) Serve() error {
 // required by the plugin library to work
 type internalInterface struct {
 	plugin *Plugin
+	// The contexts of the in-flight queries, used to cancel them
+	contexts cursorContexts
 	InternalExchangeInterface
 }
 
 func (i *internalInterface) Initialize(connectionIndex int, tableIndex int, config PluginConfig) (schema DatabaseSchema, err error) {
+	return i.initializeTraced(connectionIndex, tableIndex, config, nil)
+}
+
+// initializeTraced creates the table with the trace context of the main program
+func (i *internalInterface) initializeTraced(connectionIndex int, tableIndex int, config PluginConfig, traceContext TraceContext) (schema DatabaseSchema, err error) {
 	/* // We check if the table is registered
 	_, ok := i.plugin.table[tableIndex]
 	if !ok {
@@ -266,6 +308,7 @@ func (i *internalInterface) Initialize(connectionIndex int, tableIndex int, conf
 		UserConfig:   config,
 		TableIndex:   tableIndex,
 		ConnectionID: connectionIndex,
+		TraceContext: traceContext,
 	})
 	if err != nil {
 		return DatabaseSchema{}, fmt.Errorf("plugin did not initialize the table. Error: %v", err)
@@ -285,29 +328,21 @@ func (i *internalInterface) Initialize(connectionIndex int, tableIndex int, conf
 		schemaA.HandlesDelete = true
 	}
 
+	// The values returned by the readers are converted to the types of their columns
+	i.plugin.schemas[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}] = *schemaA
+
 	return *schemaA, nil
 
 }
 
 func (i *internalInterface) Query(connectionIndex int, tableIndex int, cursorIndex int, constraint QueryConstraint) (rows [][]interface{}, noMoreRows bool, err error) {
-	/* // We check if the table is registered
-	_, ok := i.plugin.table[tableIndex]
-	if !ok {
-		return nil, false, fmt.Errorf("plugin did not register the table")
-	}
-	// We check if the cursor exists
-	reader, ok := i.plugin.cursors[cursorKey{tableIndex: tableIndex, cursorIndex: cursorIndex}]
-	if !ok {
-		// We create a new cursor
-		reader = i.plugin.table[tableIndex].CreateReader()
-		i.plugin.cursors[cursorKey{tableIndex: tableIndex, cursorIndex: cursorIndex}] = reader
-	}
-
-	// We call the Query method of the reader to fetch the rows
-	// and return them to the main program
-	rows, noMoreRows, err := reader.Query(constraint)
-	return rows, noMoreRows, err */
+	return i.queryDeadline(connectionIndex, tableIndex, cursorIndex, constraint, time.Time{}, nil)
+}
 
+// queryDeadline runs the Query method of the reader with a context
+// that is cancelled by the Cancel method or once the deadline is reached,
+// and that holds the trace context of the main program
+func (i *internalInterface) queryDeadline(connectionIndex int, tableIndex int, cursorIndex int, constraint QueryConstraint, deadline time.Time, traceContext TraceContext) (rows [][]interface{}, noMoreRows bool, err error) {
 	// Catch the panic and return it as an error
 	defer func() {
 		if r := recover(); r != nil {
@@ -338,8 +373,99 @@ func (i *internalInterface) Query(connectionIndex int, tableIndex int, cursorInd
 
 	// We call the Query method of the reader to fetch the rows
 	// and return them to the main program
-	rows, noMoreRows, err = reader.Query(constraint)
-	return rows, noMoreRows, err
+	//
+	// If the reader accepts a context, it is cancelled when the main program cancels the query
+	if contextReader, ok := reader.(ContextReaderInterface); ok {
+		ctx, done := i.contexts.start(cursor, deadline, traceContext)
+		defer done()
+		rows, noMoreRows, err = contextReader.QueryContext(ctx, constraint)
+	} else {
+		rows, noMoreRows, err = reader.Query(constraint)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	columns := rowColumns(i.plugin.schemas[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}])
+	for index, row := range rows {
+		if err := convertRow(row, columns); err != nil {
+			return nil, false, fmt.Errorf("the row %d returned by the plugin does not match the schema of the table: %w", index, err)
+		}
+	}
+	return rows, noMoreRows, nil
+}
+
+// Cancel cancels the in-flight query of a cursor
+func (i *internalInterface) Cancel(connectionIndex int, tableIndex int, cursorIndex int) error {
+	i.contexts.cancel(cursorKey{connectionIndex: connectionIndex, tableIndex: tableIndex, cursorIndex: cursorIndex})
+	return nil
+}
+
+func (i *internalInterface) QueryStream(connectionIndex int, tableIndex int, cursorIndex int, constraint QueryConstraint, w RowWriter) (err error) {
+	// Catch the panic and return it as an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panicked while running QueryStream: %v", r)
+		}
+	}()
+
+	// We check if the table is registered
+	_, ok := i.plugin.table[tableIndex]
+	if !ok {
+		return fmt.Errorf("plugin did not register the table")
+	}
+
+	// We check if the table is registered
+	table, ok := i.plugin.tableConnection[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}]
+	if !ok {
+		return fmt.Errorf("main program did not initialize the table before querying it")
+	}
+
+	// The rows written by the reader are converted to the types of their columns
+	w = &convertingRowWriter{
+		RowWriter: w,
+		columns:   rowColumns(i.plugin.schemas[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}]),
+	}
+
+	// A stream is read only once, so we don't store the reader in the cursors map
+	reader := table.CreateReader()
+	if streamReader, ok := reader.(StreamReaderInterface); ok {
+		return streamReader.QueryStream(constraint, w)
+	}
+
+	// If the reader can't stream, we stream the pages it returns
+	contextReader, hasContext := reader.(ContextReaderInterface)
+	emptyPages := 0
+	for {
+		var rows [][]interface{}
+		var noMoreRows bool
+		var err error
+		if hasContext {
+			rows, noMoreRows, err = contextReader.QueryContext(w.Context(), constraint)
+		} else {
+			rows, noMoreRows, err = reader.Query(constraint)
+		}
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+		if noMoreRows {
+			return nil
+		}
+
+		if len(rows) == 0 {
+			emptyPages++
+			if emptyPages >= maxEmptyPagesStream {
+				return fmt.Errorf("the reader returned no rows after %d queries", maxEmptyPagesStream)
+			}
+		} else {
+			emptyPages = 0
+		}
+	}
 }
 
 func (i *internalInterface) Insert(connectionIndex int, tableIndex int, rows [][]interface{}) (err error) {
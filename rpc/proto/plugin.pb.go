@@ -0,0 +1,1740 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: plugin.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ColumnType int32
+
+const (
+	ColumnType_COLUMN_TYPE_INT      ColumnType = 0
+	ColumnType_COLUMN_TYPE_FLOAT    ColumnType = 1
+	ColumnType_COLUMN_TYPE_STRING   ColumnType = 2
+	ColumnType_COLUMN_TYPE_BLOB     ColumnType = 3
+	ColumnType_COLUMN_TYPE_BOOL     ColumnType = 4
+	ColumnType_COLUMN_TYPE_DATETIME ColumnType = 5
+	ColumnType_COLUMN_TYPE_DATE     ColumnType = 6
+	ColumnType_COLUMN_TYPE_TIME     ColumnType = 7
+	ColumnType_COLUMN_TYPE_JSON     ColumnType = 8
+)
+
+// Enum value maps for ColumnType.
+var (
+	ColumnType_name = map[int32]string{
+		0: "COLUMN_TYPE_INT",
+		1: "COLUMN_TYPE_FLOAT",
+		2: "COLUMN_TYPE_STRING",
+		3: "COLUMN_TYPE_BLOB",
+		4: "COLUMN_TYPE_BOOL",
+		5: "COLUMN_TYPE_DATETIME",
+		6: "COLUMN_TYPE_DATE",
+		7: "COLUMN_TYPE_TIME",
+		8: "COLUMN_TYPE_JSON",
+	}
+	ColumnType_value = map[string]int32{
+		"COLUMN_TYPE_INT":      0,
+		"COLUMN_TYPE_FLOAT":    1,
+		"COLUMN_TYPE_STRING":   2,
+		"COLUMN_TYPE_BLOB":     3,
+		"COLUMN_TYPE_BOOL":     4,
+		"COLUMN_TYPE_DATETIME": 5,
+		"COLUMN_TYPE_DATE":     6,
+		"COLUMN_TYPE_TIME":     7,
+		"COLUMN_TYPE_JSON":     8,
+	}
+)
+
+func (x ColumnType) Enum() *ColumnType {
+	p := new(ColumnType)
+	*p = x
+	return p
+}
+
+func (x ColumnType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ColumnType) Descriptor() protoreflect.EnumDescriptor {
+	return file_plugin_proto_enumTypes[0].Descriptor()
+}
+
+func (ColumnType) Type() protoreflect.EnumType {
+	return &file_plugin_proto_enumTypes[0]
+}
+
+func (x ColumnType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use ColumnType.Descriptor instead.
+func (ColumnType) EnumDescriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{0}
+}
+
+type Empty struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Empty) Reset() {
+	*x = Empty{}
+	mi := &file_plugin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Empty) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Empty) ProtoMessage() {}
+
+func (x *Empty) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Empty.ProtoReflect.Descriptor instead.
+func (*Empty) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{0}
+}
+
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_IntValue
+	//	*Value_FloatValue
+	//	*Value_StringValue
+	//	*Value_BytesValue
+	//	*Value_BoolValue
+	//	*Value_ListValue
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_plugin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetIntValue() int64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_IntValue); ok {
+			return x.IntValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetFloatValue() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_FloatValue); ok {
+			return x.FloatValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetBytesValue() []byte {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_BytesValue); ok {
+			return x.BytesValue
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *Value) GetListValue() *ValueList {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_ListValue); ok {
+			return x.ListValue
+		}
+	}
+	return nil
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_IntValue struct {
+	IntValue int64 `protobuf:"varint,1,opt,name=int_value,json=intValue,proto3,oneof"`
+}
+
+type Value_FloatValue struct {
+	FloatValue float64 `protobuf:"fixed64,2,opt,name=float_value,json=floatValue,proto3,oneof"`
+}
+
+type Value_StringValue struct {
+	StringValue string `protobuf:"bytes,3,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type Value_BytesValue struct {
+	BytesValue []byte `protobuf:"bytes,4,opt,name=bytes_value,json=bytesValue,proto3,oneof"`
+}
+
+type Value_BoolValue struct {
+	BoolValue bool `protobuf:"varint,5,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Value_ListValue struct {
+	ListValue *ValueList `protobuf:"bytes,6,opt,name=list_value,json=listValue,proto3,oneof"`
+}
+
+func (*Value_IntValue) isValue_Kind() {}
+
+func (*Value_FloatValue) isValue_Kind() {}
+
+func (*Value_StringValue) isValue_Kind() {}
+
+func (*Value_BytesValue) isValue_Kind() {}
+
+func (*Value_BoolValue) isValue_Kind() {}
+
+func (*Value_ListValue) isValue_Kind() {}
+
+type ValueList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ValueList) Reset() {
+	*x = ValueList{}
+	mi := &file_plugin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ValueList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ValueList) ProtoMessage() {}
+
+func (x *ValueList) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ValueList.ProtoReflect.Descriptor instead.
+func (*ValueList) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ValueList) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*Value               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_plugin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Row) GetValues() []*Value {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type InitializeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	Config        map[string]*Value      `protobuf:"bytes,3,rep,name=config,proto3" json:"config,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InitializeRequest) Reset() {
+	*x = InitializeRequest{}
+	mi := &file_plugin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InitializeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InitializeRequest) ProtoMessage() {}
+
+func (x *InitializeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InitializeRequest.ProtoReflect.Descriptor instead.
+func (*InitializeRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *InitializeRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *InitializeRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *InitializeRequest) GetConfig() map[string]*Value {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+type DatabaseSchemaColumn struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          ColumnType             `protobuf:"varint,2,opt,name=type,proto3,enum=anyquery.plugin.v1.ColumnType" json:"type,omitempty"`
+	IsParameter   bool                   `protobuf:"varint,3,opt,name=is_parameter,json=isParameter,proto3" json:"is_parameter,omitempty"`
+	IsRequired    bool                   `protobuf:"varint,4,opt,name=is_required,json=isRequired,proto3" json:"is_required,omitempty"`
+	Description   string                 `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseSchemaColumn) Reset() {
+	*x = DatabaseSchemaColumn{}
+	mi := &file_plugin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseSchemaColumn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseSchemaColumn) ProtoMessage() {}
+
+func (x *DatabaseSchemaColumn) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseSchemaColumn.ProtoReflect.Descriptor instead.
+func (*DatabaseSchemaColumn) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DatabaseSchemaColumn) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DatabaseSchemaColumn) GetType() ColumnType {
+	if x != nil {
+		return x.Type
+	}
+	return ColumnType_COLUMN_TYPE_INT
+}
+
+func (x *DatabaseSchemaColumn) GetIsParameter() bool {
+	if x != nil {
+		return x.IsParameter
+	}
+	return false
+}
+
+func (x *DatabaseSchemaColumn) GetIsRequired() bool {
+	if x != nil {
+		return x.IsRequired
+	}
+	return false
+}
+
+func (x *DatabaseSchemaColumn) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type ConstraintCost struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []int64                `protobuf:"varint,1,rep,packed,name=columns,proto3" json:"columns,omitempty"`
+	EstimatedRows int64                  `protobuf:"varint,2,opt,name=estimated_rows,json=estimatedRows,proto3" json:"estimated_rows,omitempty"`
+	EstimatedCost float64                `protobuf:"fixed64,3,opt,name=estimated_cost,json=estimatedCost,proto3" json:"estimated_cost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConstraintCost) Reset() {
+	*x = ConstraintCost{}
+	mi := &file_plugin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConstraintCost) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConstraintCost) ProtoMessage() {}
+
+func (x *ConstraintCost) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConstraintCost.ProtoReflect.Descriptor instead.
+func (*ConstraintCost) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ConstraintCost) GetColumns() []int64 {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *ConstraintCost) GetEstimatedRows() int64 {
+	if x != nil {
+		return x.EstimatedRows
+	}
+	return 0
+}
+
+func (x *ConstraintCost) GetEstimatedCost() float64 {
+	if x != nil {
+		return x.EstimatedCost
+	}
+	return 0
+}
+
+type DatabaseSchema struct {
+	state               protoimpl.MessageState  `protogen:"open.v1"`
+	Columns             []*DatabaseSchemaColumn `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	PrimaryKey          int64                   `protobuf:"varint,2,opt,name=primary_key,json=primaryKey,proto3" json:"primary_key,omitempty"`
+	HandlesInsert       bool                    `protobuf:"varint,3,opt,name=handles_insert,json=handlesInsert,proto3" json:"handles_insert,omitempty"`
+	HandlesUpdate       bool                    `protobuf:"varint,4,opt,name=handles_update,json=handlesUpdate,proto3" json:"handles_update,omitempty"`
+	HandlesDelete       bool                    `protobuf:"varint,5,opt,name=handles_delete,json=handlesDelete,proto3" json:"handles_delete,omitempty"`
+	HandlesTransactions bool                    `protobuf:"varint,6,opt,name=handles_transactions,json=handlesTransactions,proto3" json:"handles_transactions,omitempty"`
+	HandleOffset        bool                    `protobuf:"varint,7,opt,name=handle_offset,json=handleOffset,proto3" json:"handle_offset,omitempty"`
+	HandlesIn           bool                    `protobuf:"varint,8,opt,name=handles_in,json=handlesIn,proto3" json:"handles_in,omitempty"`
+	BufferInsert        uint64                  `protobuf:"varint,9,opt,name=buffer_insert,json=bufferInsert,proto3" json:"buffer_insert,omitempty"`
+	BufferUpdate        uint64                  `protobuf:"varint,10,opt,name=buffer_update,json=bufferUpdate,proto3" json:"buffer_update,omitempty"`
+	BufferDelete        uint64                  `protobuf:"varint,11,opt,name=buffer_delete,json=bufferDelete,proto3" json:"buffer_delete,omitempty"`
+	PartialUpdate       bool                    `protobuf:"varint,12,opt,name=partial_update,json=partialUpdate,proto3" json:"partial_update,omitempty"`
+	StreamRows          bool                    `protobuf:"varint,13,opt,name=stream_rows,json=streamRows,proto3" json:"stream_rows,omitempty"`
+	Aggregates          []string                `protobuf:"bytes,14,rep,name=aggregates,proto3" json:"aggregates,omitempty"`
+	EstimatedRows       int64                   `protobuf:"varint,15,opt,name=estimated_rows,json=estimatedRows,proto3" json:"estimated_rows,omitempty"`
+	EstimatedCost       float64                 `protobuf:"fixed64,16,opt,name=estimated_cost,json=estimatedCost,proto3" json:"estimated_cost,omitempty"`
+	ConstraintCosts     []*ConstraintCost       `protobuf:"bytes,17,rep,name=constraint_costs,json=constraintCosts,proto3" json:"constraint_costs,omitempty"`
+	Description         string                  `protobuf:"bytes,18,opt,name=description,proto3" json:"description,omitempty"`
+	CacheTtl            int64                   `protobuf:"varint,19,opt,name=cache_ttl,json=cacheTtl,proto3" json:"cache_ttl,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *DatabaseSchema) Reset() {
+	*x = DatabaseSchema{}
+	mi := &file_plugin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseSchema) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseSchema) ProtoMessage() {}
+
+func (x *DatabaseSchema) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseSchema.ProtoReflect.Descriptor instead.
+func (*DatabaseSchema) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DatabaseSchema) GetColumns() []*DatabaseSchemaColumn {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *DatabaseSchema) GetPrimaryKey() int64 {
+	if x != nil {
+		return x.PrimaryKey
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetHandlesInsert() bool {
+	if x != nil {
+		return x.HandlesInsert
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetHandlesUpdate() bool {
+	if x != nil {
+		return x.HandlesUpdate
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetHandlesDelete() bool {
+	if x != nil {
+		return x.HandlesDelete
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetHandlesTransactions() bool {
+	if x != nil {
+		return x.HandlesTransactions
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetHandleOffset() bool {
+	if x != nil {
+		return x.HandleOffset
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetHandlesIn() bool {
+	if x != nil {
+		return x.HandlesIn
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetBufferInsert() uint64 {
+	if x != nil {
+		return x.BufferInsert
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetBufferUpdate() uint64 {
+	if x != nil {
+		return x.BufferUpdate
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetBufferDelete() uint64 {
+	if x != nil {
+		return x.BufferDelete
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetPartialUpdate() bool {
+	if x != nil {
+		return x.PartialUpdate
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetStreamRows() bool {
+	if x != nil {
+		return x.StreamRows
+	}
+	return false
+}
+
+func (x *DatabaseSchema) GetAggregates() []string {
+	if x != nil {
+		return x.Aggregates
+	}
+	return nil
+}
+
+func (x *DatabaseSchema) GetEstimatedRows() int64 {
+	if x != nil {
+		return x.EstimatedRows
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetEstimatedCost() float64 {
+	if x != nil {
+		return x.EstimatedCost
+	}
+	return 0
+}
+
+func (x *DatabaseSchema) GetConstraintCosts() []*ConstraintCost {
+	if x != nil {
+		return x.ConstraintCosts
+	}
+	return nil
+}
+
+func (x *DatabaseSchema) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *DatabaseSchema) GetCacheTtl() int64 {
+	if x != nil {
+		return x.CacheTtl
+	}
+	return 0
+}
+
+type ColumnConstraint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ColumnId      int64                  `protobuf:"varint,1,opt,name=column_id,json=columnId,proto3" json:"column_id,omitempty"`
+	Operator      int32                  `protobuf:"varint,2,opt,name=operator,proto3" json:"operator,omitempty"`
+	Value         *Value                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ColumnConstraint) Reset() {
+	*x = ColumnConstraint{}
+	mi := &file_plugin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ColumnConstraint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ColumnConstraint) ProtoMessage() {}
+
+func (x *ColumnConstraint) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ColumnConstraint.ProtoReflect.Descriptor instead.
+func (*ColumnConstraint) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ColumnConstraint) GetColumnId() int64 {
+	if x != nil {
+		return x.ColumnId
+	}
+	return 0
+}
+
+func (x *ColumnConstraint) GetOperator() int32 {
+	if x != nil {
+		return x.Operator
+	}
+	return 0
+}
+
+func (x *ColumnConstraint) GetValue() *Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+type OrderConstraint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ColumnId      int64                  `protobuf:"varint,1,opt,name=column_id,json=columnId,proto3" json:"column_id,omitempty"`
+	Descending    bool                   `protobuf:"varint,2,opt,name=descending,proto3" json:"descending,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderConstraint) Reset() {
+	*x = OrderConstraint{}
+	mi := &file_plugin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderConstraint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderConstraint) ProtoMessage() {}
+
+func (x *OrderConstraint) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderConstraint.ProtoReflect.Descriptor instead.
+func (*OrderConstraint) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *OrderConstraint) GetColumnId() int64 {
+	if x != nil {
+		return x.ColumnId
+	}
+	return 0
+}
+
+func (x *OrderConstraint) GetDescending() bool {
+	if x != nil {
+		return x.Descending
+	}
+	return false
+}
+
+type QueryConstraint struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Columns       []*ColumnConstraint    `protobuf:"bytes,1,rep,name=columns,proto3" json:"columns,omitempty"`
+	Limit         int64                  `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset        int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	OrderBy       []*OrderConstraint     `protobuf:"bytes,4,rep,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`
+	ColumnsUsed   []bool                 `protobuf:"varint,5,rep,packed,name=columns_used,json=columnsUsed,proto3" json:"columns_used,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryConstraint) Reset() {
+	*x = QueryConstraint{}
+	mi := &file_plugin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryConstraint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryConstraint) ProtoMessage() {}
+
+func (x *QueryConstraint) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryConstraint.ProtoReflect.Descriptor instead.
+func (*QueryConstraint) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *QueryConstraint) GetColumns() []*ColumnConstraint {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *QueryConstraint) GetLimit() int64 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *QueryConstraint) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *QueryConstraint) GetOrderBy() []*OrderConstraint {
+	if x != nil {
+		return x.OrderBy
+	}
+	return nil
+}
+
+func (x *QueryConstraint) GetColumnsUsed() []bool {
+	if x != nil {
+		return x.ColumnsUsed
+	}
+	return nil
+}
+
+type QueryRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	CursorIndex   int64                  `protobuf:"varint,3,opt,name=cursor_index,json=cursorIndex,proto3" json:"cursor_index,omitempty"`
+	Constraint    *QueryConstraint       `protobuf:"bytes,4,opt,name=constraint,proto3" json:"constraint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_plugin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *QueryRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetCursorIndex() int64 {
+	if x != nil {
+		return x.CursorIndex
+	}
+	return 0
+}
+
+func (x *QueryRequest) GetConstraint() *QueryConstraint {
+	if x != nil {
+		return x.Constraint
+	}
+	return nil
+}
+
+type QueryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Rows          []*Row                 `protobuf:"bytes,1,rep,name=rows,proto3" json:"rows,omitempty"`
+	NoMoreRows    bool                   `protobuf:"varint,2,opt,name=no_more_rows,json=noMoreRows,proto3" json:"no_more_rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_plugin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *QueryResponse) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *QueryResponse) GetNoMoreRows() bool {
+	if x != nil {
+		return x.NoMoreRows
+	}
+	return false
+}
+
+type Aggregate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Function      string                 `protobuf:"bytes,1,opt,name=function,proto3" json:"function,omitempty"`
+	ColumnId      int64                  `protobuf:"varint,2,opt,name=column_id,json=columnId,proto3" json:"column_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Aggregate) Reset() {
+	*x = Aggregate{}
+	mi := &file_plugin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Aggregate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Aggregate) ProtoMessage() {}
+
+func (x *Aggregate) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Aggregate.ProtoReflect.Descriptor instead.
+func (*Aggregate) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Aggregate) GetFunction() string {
+	if x != nil {
+		return x.Function
+	}
+	return ""
+}
+
+func (x *Aggregate) GetColumnId() int64 {
+	if x != nil {
+		return x.ColumnId
+	}
+	return 0
+}
+
+type AggregateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	Constraint    *QueryConstraint       `protobuf:"bytes,3,opt,name=constraint,proto3" json:"constraint,omitempty"`
+	GroupBy       []int64                `protobuf:"varint,4,rep,packed,name=group_by,json=groupBy,proto3" json:"group_by,omitempty"`
+	Aggregates    []*Aggregate           `protobuf:"bytes,5,rep,name=aggregates,proto3" json:"aggregates,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AggregateRequest) Reset() {
+	*x = AggregateRequest{}
+	mi := &file_plugin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AggregateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AggregateRequest) ProtoMessage() {}
+
+func (x *AggregateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AggregateRequest.ProtoReflect.Descriptor instead.
+func (*AggregateRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *AggregateRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *AggregateRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *AggregateRequest) GetConstraint() *QueryConstraint {
+	if x != nil {
+		return x.Constraint
+	}
+	return nil
+}
+
+func (x *AggregateRequest) GetGroupBy() []int64 {
+	if x != nil {
+		return x.GroupBy
+	}
+	return nil
+}
+
+func (x *AggregateRequest) GetAggregates() []*Aggregate {
+	if x != nil {
+		return x.Aggregates
+	}
+	return nil
+}
+
+type InsertRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,3,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertRequest) Reset() {
+	*x = InsertRequest{}
+	mi := &file_plugin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertRequest) ProtoMessage() {}
+
+func (x *InsertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertRequest.ProtoReflect.Descriptor instead.
+func (*InsertRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *InsertRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *InsertRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *InsertRequest) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type UpdateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	Rows          []*Row                 `protobuf:"bytes,3,rep,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateRequest) Reset() {
+	*x = UpdateRequest{}
+	mi := &file_plugin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateRequest) ProtoMessage() {}
+
+func (x *UpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateRequest.ProtoReflect.Descriptor instead.
+func (*UpdateRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UpdateRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *UpdateRequest) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	PrimaryKeys   []*Value               `protobuf:"bytes,3,rep,name=primary_keys,json=primaryKeys,proto3" json:"primary_keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteRequest) Reset() {
+	*x = DeleteRequest{}
+	mi := &file_plugin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteRequest) ProtoMessage() {}
+
+func (x *DeleteRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteRequest.ProtoReflect.Descriptor instead.
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeleteRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *DeleteRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *DeleteRequest) GetPrimaryKeys() []*Value {
+	if x != nil {
+		return x.PrimaryKeys
+	}
+	return nil
+}
+
+type TransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	TableIndex    int64                  `protobuf:"varint,2,opt,name=table_index,json=tableIndex,proto3" json:"table_index,omitempty"`
+	Operation     string                 `protobuf:"bytes,3,opt,name=operation,proto3" json:"operation,omitempty"`
+	Savepoint     string                 `protobuf:"bytes,4,opt,name=savepoint,proto3" json:"savepoint,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest) Reset() {
+	*x = TransactionRequest{}
+	mi := &file_plugin_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest) ProtoMessage() {}
+
+func (x *TransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest.ProtoReflect.Descriptor instead.
+func (*TransactionRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *TransactionRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+func (x *TransactionRequest) GetTableIndex() int64 {
+	if x != nil {
+		return x.TableIndex
+	}
+	return 0
+}
+
+func (x *TransactionRequest) GetOperation() string {
+	if x != nil {
+		return x.Operation
+	}
+	return ""
+}
+
+func (x *TransactionRequest) GetSavepoint() string {
+	if x != nil {
+		return x.Savepoint
+	}
+	return ""
+}
+
+type CloseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnectionId  int64                  `protobuf:"varint,1,opt,name=connection_id,json=connectionId,proto3" json:"connection_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CloseRequest) Reset() {
+	*x = CloseRequest{}
+	mi := &file_plugin_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CloseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CloseRequest) ProtoMessage() {}
+
+func (x *CloseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_plugin_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CloseRequest.ProtoReflect.Descriptor instead.
+func (*CloseRequest) Descriptor() ([]byte, []int) {
+	return file_plugin_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *CloseRequest) GetConnectionId() int64 {
+	if x != nil {
+		return x.ConnectionId
+	}
+	return 0
+}
+
+var File_plugin_proto protoreflect.FileDescriptor
+
+const file_plugin_proto_rawDesc = "" +
+	"\n" +
+	"\fplugin.proto\x12\x12anyquery.plugin.v1\"\a\n" +
+	"\x05Empty\"\xfa\x01\n" +
+	"\x05Value\x12\x1d\n" +
+	"\tint_value\x18\x01 \x01(\x03H\x00R\bintValue\x12!\n" +
+	"\vfloat_value\x18\x02 \x01(\x01H\x00R\n" +
+	"floatValue\x12#\n" +
+	"\fstring_value\x18\x03 \x01(\tH\x00R\vstringValue\x12!\n" +
+	"\vbytes_value\x18\x04 \x01(\fH\x00R\n" +
+	"bytesValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x05 \x01(\bH\x00R\tboolValue\x12>\n" +
+	"\n" +
+	"list_value\x18\x06 \x01(\v2\x1d.anyquery.plugin.v1.ValueListH\x00R\tlistValueB\x06\n" +
+	"\x04kind\">\n" +
+	"\tValueList\x121\n" +
+	"\x06values\x18\x01 \x03(\v2\x19.anyquery.plugin.v1.ValueR\x06values\"8\n" +
+	"\x03Row\x121\n" +
+	"\x06values\x18\x01 \x03(\v2\x19.anyquery.plugin.v1.ValueR\x06values\"\xfa\x01\n" +
+	"\x11InitializeRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12I\n" +
+	"\x06config\x18\x03 \x03(\v21.anyquery.plugin.v1.InitializeRequest.ConfigEntryR\x06config\x1aT\n" +
+	"\vConfigEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12/\n" +
+	"\x05value\x18\x02 \x01(\v2\x19.anyquery.plugin.v1.ValueR\x05value:\x028\x01\"\xc4\x01\n" +
+	"\x14DatabaseSchemaColumn\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x122\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x1e.anyquery.plugin.v1.ColumnTypeR\x04type\x12!\n" +
+	"\fis_parameter\x18\x03 \x01(\bR\visParameter\x12\x1f\n" +
+	"\vis_required\x18\x04 \x01(\bR\n" +
+	"isRequired\x12 \n" +
+	"\vdescription\x18\x05 \x01(\tR\vdescription\"x\n" +
+	"\x0eConstraintCost\x12\x18\n" +
+	"\acolumns\x18\x01 \x03(\x03R\acolumns\x12%\n" +
+	"\x0eestimated_rows\x18\x02 \x01(\x03R\restimatedRows\x12%\n" +
+	"\x0eestimated_cost\x18\x03 \x01(\x01R\restimatedCost\"\x94\x06\n" +
+	"\x0eDatabaseSchema\x12B\n" +
+	"\acolumns\x18\x01 \x03(\v2(.anyquery.plugin.v1.DatabaseSchemaColumnR\acolumns\x12\x1f\n" +
+	"\vprimary_key\x18\x02 \x01(\x03R\n" +
+	"primaryKey\x12%\n" +
+	"\x0ehandles_insert\x18\x03 \x01(\bR\rhandlesInsert\x12%\n" +
+	"\x0ehandles_update\x18\x04 \x01(\bR\rhandlesUpdate\x12%\n" +
+	"\x0ehandles_delete\x18\x05 \x01(\bR\rhandlesDelete\x121\n" +
+	"\x14handles_transactions\x18\x06 \x01(\bR\x13handlesTransactions\x12#\n" +
+	"\rhandle_offset\x18\a \x01(\bR\fhandleOffset\x12\x1d\n" +
+	"\n" +
+	"handles_in\x18\b \x01(\bR\thandlesIn\x12#\n" +
+	"\rbuffer_insert\x18\t \x01(\x04R\fbufferInsert\x12#\n" +
+	"\rbuffer_update\x18\n" +
+	" \x01(\x04R\fbufferUpdate\x12#\n" +
+	"\rbuffer_delete\x18\v \x01(\x04R\fbufferDelete\x12%\n" +
+	"\x0epartial_update\x18\f \x01(\bR\rpartialUpdate\x12\x1f\n" +
+	"\vstream_rows\x18\r \x01(\bR\n" +
+	"streamRows\x12\x1e\n" +
+	"\n" +
+	"aggregates\x18\x0e \x03(\tR\n" +
+	"aggregates\x12%\n" +
+	"\x0eestimated_rows\x18\x0f \x01(\x03R\restimatedRows\x12%\n" +
+	"\x0eestimated_cost\x18\x10 \x01(\x01R\restimatedCost\x12M\n" +
+	"\x10constraint_costs\x18\x11 \x03(\v2\".anyquery.plugin.v1.ConstraintCostR\x0fconstraintCosts\x12 \n" +
+	"\vdescription\x18\x12 \x01(\tR\vdescription\x12\x1b\n" +
+	"\tcache_ttl\x18\x13 \x01(\x03R\bcacheTtl\"|\n" +
+	"\x10ColumnConstraint\x12\x1b\n" +
+	"\tcolumn_id\x18\x01 \x01(\x03R\bcolumnId\x12\x1a\n" +
+	"\boperator\x18\x02 \x01(\x05R\boperator\x12/\n" +
+	"\x05value\x18\x03 \x01(\v2\x19.anyquery.plugin.v1.ValueR\x05value\"N\n" +
+	"\x0fOrderConstraint\x12\x1b\n" +
+	"\tcolumn_id\x18\x01 \x01(\x03R\bcolumnId\x12\x1e\n" +
+	"\n" +
+	"descending\x18\x02 \x01(\bR\n" +
+	"descending\"\xe2\x01\n" +
+	"\x0fQueryConstraint\x12>\n" +
+	"\acolumns\x18\x01 \x03(\v2$.anyquery.plugin.v1.ColumnConstraintR\acolumns\x12\x14\n" +
+	"\x05limit\x18\x02 \x01(\x03R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12>\n" +
+	"\border_by\x18\x04 \x03(\v2#.anyquery.plugin.v1.OrderConstraintR\aorderBy\x12!\n" +
+	"\fcolumns_used\x18\x05 \x03(\bR\vcolumnsUsed\"\xbc\x01\n" +
+	"\fQueryRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12!\n" +
+	"\fcursor_index\x18\x03 \x01(\x03R\vcursorIndex\x12C\n" +
+	"\n" +
+	"constraint\x18\x04 \x01(\v2#.anyquery.plugin.v1.QueryConstraintR\n" +
+	"constraint\"^\n" +
+	"\rQueryResponse\x12+\n" +
+	"\x04rows\x18\x01 \x03(\v2\x17.anyquery.plugin.v1.RowR\x04rows\x12 \n" +
+	"\fno_more_rows\x18\x02 \x01(\bR\n" +
+	"noMoreRows\"D\n" +
+	"\tAggregate\x12\x1a\n" +
+	"\bfunction\x18\x01 \x01(\tR\bfunction\x12\x1b\n" +
+	"\tcolumn_id\x18\x02 \x01(\x03R\bcolumnId\"\xf7\x01\n" +
+	"\x10AggregateRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12C\n" +
+	"\n" +
+	"constraint\x18\x03 \x01(\v2#.anyquery.plugin.v1.QueryConstraintR\n" +
+	"constraint\x12\x19\n" +
+	"\bgroup_by\x18\x04 \x03(\x03R\agroupBy\x12=\n" +
+	"\n" +
+	"aggregates\x18\x05 \x03(\v2\x1d.anyquery.plugin.v1.AggregateR\n" +
+	"aggregates\"\x82\x01\n" +
+	"\rInsertRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12+\n" +
+	"\x04rows\x18\x03 \x03(\v2\x17.anyquery.plugin.v1.RowR\x04rows\"\x82\x01\n" +
+	"\rUpdateRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12+\n" +
+	"\x04rows\x18\x03 \x03(\v2\x17.anyquery.plugin.v1.RowR\x04rows\"\x93\x01\n" +
+	"\rDeleteRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12<\n" +
+	"\fprimary_keys\x18\x03 \x03(\v2\x19.anyquery.plugin.v1.ValueR\vprimaryKeys\"\x96\x01\n" +
+	"\x12TransactionRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId\x12\x1f\n" +
+	"\vtable_index\x18\x02 \x01(\x03R\n" +
+	"tableIndex\x12\x1c\n" +
+	"\toperation\x18\x03 \x01(\tR\toperation\x12\x1c\n" +
+	"\tsavepoint\x18\x04 \x01(\tR\tsavepoint\"3\n" +
+	"\fCloseRequest\x12#\n" +
+	"\rconnection_id\x18\x01 \x01(\x03R\fconnectionId*\xd8\x01\n" +
+	"\n" +
+	"ColumnType\x12\x13\n" +
+	"\x0fCOLUMN_TYPE_INT\x10\x00\x12\x15\n" +
+	"\x11COLUMN_TYPE_FLOAT\x10\x01\x12\x16\n" +
+	"\x12COLUMN_TYPE_STRING\x10\x02\x12\x14\n" +
+	"\x10COLUMN_TYPE_BLOB\x10\x03\x12\x14\n" +
+	"\x10COLUMN_TYPE_BOOL\x10\x04\x12\x18\n" +
+	"\x14COLUMN_TYPE_DATETIME\x10\x05\x12\x14\n" +
+	"\x10COLUMN_TYPE_DATE\x10\x06\x12\x14\n" +
+	"\x10COLUMN_TYPE_TIME\x10\a\x12\x14\n" +
+	"\x10COLUMN_TYPE_JSON\x10\b2\xc6\x05\n" +
+	"\x06Plugin\x12W\n" +
+	"\n" +
+	"Initialize\x12%.anyquery.plugin.v1.InitializeRequest\x1a\".anyquery.plugin.v1.DatabaseSchema\x12L\n" +
+	"\x05Query\x12 .anyquery.plugin.v1.QueryRequest\x1a!.anyquery.plugin.v1.QueryResponse\x12J\n" +
+	"\vQueryStream\x12 .anyquery.plugin.v1.QueryRequest\x1a\x17.anyquery.plugin.v1.Row0\x01\x12Y\n" +
+	"\x0eQueryAggregate\x12$.anyquery.plugin.v1.AggregateRequest\x1a!.anyquery.plugin.v1.QueryResponse\x12F\n" +
+	"\x06Insert\x12!.anyquery.plugin.v1.InsertRequest\x1a\x19.anyquery.plugin.v1.Empty\x12F\n" +
+	"\x06Update\x12!.anyquery.plugin.v1.UpdateRequest\x1a\x19.anyquery.plugin.v1.Empty\x12F\n" +
+	"\x06Delete\x12!.anyquery.plugin.v1.DeleteRequest\x1a\x19.anyquery.plugin.v1.Empty\x12P\n" +
+	"\vTransaction\x12&.anyquery.plugin.v1.TransactionRequest\x1a\x19.anyquery.plugin.v1.Empty\x12D\n" +
+	"\x05Close\x12 .anyquery.plugin.v1.CloseRequest\x1a\x19.anyquery.plugin.v1.EmptyB)Z'github.com/julien040/anyquery/rpc/protob\x06proto3"
+
+var (
+	file_plugin_proto_rawDescOnce sync.Once
+	file_plugin_proto_rawDescData []byte
+)
+
+func file_plugin_proto_rawDescGZIP() []byte {
+	file_plugin_proto_rawDescOnce.Do(func() {
+		file_plugin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_plugin_proto_rawDesc), len(file_plugin_proto_rawDesc)))
+	})
+	return file_plugin_proto_rawDescData
+}
+
+var file_plugin_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_plugin_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_plugin_proto_goTypes = []any{
+	(ColumnType)(0),              // 0: anyquery.plugin.v1.ColumnType
+	(*Empty)(nil),                // 1: anyquery.plugin.v1.Empty
+	(*Value)(nil),                // 2: anyquery.plugin.v1.Value
+	(*ValueList)(nil),            // 3: anyquery.plugin.v1.ValueList
+	(*Row)(nil),                  // 4: anyquery.plugin.v1.Row
+	(*InitializeRequest)(nil),    // 5: anyquery.plugin.v1.InitializeRequest
+	(*DatabaseSchemaColumn)(nil), // 6: anyquery.plugin.v1.DatabaseSchemaColumn
+	(*ConstraintCost)(nil),       // 7: anyquery.plugin.v1.ConstraintCost
+	(*DatabaseSchema)(nil),       // 8: anyquery.plugin.v1.DatabaseSchema
+	(*ColumnConstraint)(nil),     // 9: anyquery.plugin.v1.ColumnConstraint
+	(*OrderConstraint)(nil),      // 10: anyquery.plugin.v1.OrderConstraint
+	(*QueryConstraint)(nil),      // 11: anyquery.plugin.v1.QueryConstraint
+	(*QueryRequest)(nil),         // 12: anyquery.plugin.v1.QueryRequest
+	(*QueryResponse)(nil),        // 13: anyquery.plugin.v1.QueryResponse
+	(*Aggregate)(nil),            // 14: anyquery.plugin.v1.Aggregate
+	(*AggregateRequest)(nil),     // 15: anyquery.plugin.v1.AggregateRequest
+	(*InsertRequest)(nil),        // 16: anyquery.plugin.v1.InsertRequest
+	(*UpdateRequest)(nil),        // 17: anyquery.plugin.v1.UpdateRequest
+	(*DeleteRequest)(nil),        // 18: anyquery.plugin.v1.DeleteRequest
+	(*TransactionRequest)(nil),   // 19: anyquery.plugin.v1.TransactionRequest
+	(*CloseRequest)(nil),         // 20: anyquery.plugin.v1.CloseRequest
+	nil,                          // 21: anyquery.plugin.v1.InitializeRequest.ConfigEntry
+}
+var file_plugin_proto_depIdxs = []int32{
+	3,  // 0: anyquery.plugin.v1.Value.list_value:type_name -> anyquery.plugin.v1.ValueList
+	2,  // 1: anyquery.plugin.v1.ValueList.values:type_name -> anyquery.plugin.v1.Value
+	2,  // 2: anyquery.plugin.v1.Row.values:type_name -> anyquery.plugin.v1.Value
+	21, // 3: anyquery.plugin.v1.InitializeRequest.config:type_name -> anyquery.plugin.v1.InitializeRequest.ConfigEntry
+	0,  // 4: anyquery.plugin.v1.DatabaseSchemaColumn.type:type_name -> anyquery.plugin.v1.ColumnType
+	6,  // 5: anyquery.plugin.v1.DatabaseSchema.columns:type_name -> anyquery.plugin.v1.DatabaseSchemaColumn
+	7,  // 6: anyquery.plugin.v1.DatabaseSchema.constraint_costs:type_name -> anyquery.plugin.v1.ConstraintCost
+	2,  // 7: anyquery.plugin.v1.ColumnConstraint.value:type_name -> anyquery.plugin.v1.Value
+	9,  // 8: anyquery.plugin.v1.QueryConstraint.columns:type_name -> anyquery.plugin.v1.ColumnConstraint
+	10, // 9: anyquery.plugin.v1.QueryConstraint.order_by:type_name -> anyquery.plugin.v1.OrderConstraint
+	11, // 10: anyquery.plugin.v1.QueryRequest.constraint:type_name -> anyquery.plugin.v1.QueryConstraint
+	4,  // 11: anyquery.plugin.v1.QueryResponse.rows:type_name -> anyquery.plugin.v1.Row
+	11, // 12: anyquery.plugin.v1.AggregateRequest.constraint:type_name -> anyquery.plugin.v1.QueryConstraint
+	14, // 13: anyquery.plugin.v1.AggregateRequest.aggregates:type_name -> anyquery.plugin.v1.Aggregate
+	4,  // 14: anyquery.plugin.v1.InsertRequest.rows:type_name -> anyquery.plugin.v1.Row
+	4,  // 15: anyquery.plugin.v1.UpdateRequest.rows:type_name -> anyquery.plugin.v1.Row
+	2,  // 16: anyquery.plugin.v1.DeleteRequest.primary_keys:type_name -> anyquery.plugin.v1.Value
+	2,  // 17: anyquery.plugin.v1.InitializeRequest.ConfigEntry.value:type_name -> anyquery.plugin.v1.Value
+	5,  // 18: anyquery.plugin.v1.Plugin.Initialize:input_type -> anyquery.plugin.v1.InitializeRequest
+	12, // 19: anyquery.plugin.v1.Plugin.Query:input_type -> anyquery.plugin.v1.QueryRequest
+	12, // 20: anyquery.plugin.v1.Plugin.QueryStream:input_type -> anyquery.plugin.v1.QueryRequest
+	15, // 21: anyquery.plugin.v1.Plugin.QueryAggregate:input_type -> anyquery.plugin.v1.AggregateRequest
+	16, // 22: anyquery.plugin.v1.Plugin.Insert:input_type -> anyquery.plugin.v1.InsertRequest
+	17, // 23: anyquery.plugin.v1.Plugin.Update:input_type -> anyquery.plugin.v1.UpdateRequest
+	18, // 24: anyquery.plugin.v1.Plugin.Delete:input_type -> anyquery.plugin.v1.DeleteRequest
+	19, // 25: anyquery.plugin.v1.Plugin.Transaction:input_type -> anyquery.plugin.v1.TransactionRequest
+	20, // 26: anyquery.plugin.v1.Plugin.Close:input_type -> anyquery.plugin.v1.CloseRequest
+	8,  // 27: anyquery.plugin.v1.Plugin.Initialize:output_type -> anyquery.plugin.v1.DatabaseSchema
+	13, // 28: anyquery.plugin.v1.Plugin.Query:output_type -> anyquery.plugin.v1.QueryResponse
+	4,  // 29: anyquery.plugin.v1.Plugin.QueryStream:output_type -> anyquery.plugin.v1.Row
+	13, // 30: anyquery.plugin.v1.Plugin.QueryAggregate:output_type -> anyquery.plugin.v1.QueryResponse
+	1,  // 31: anyquery.plugin.v1.Plugin.Insert:output_type -> anyquery.plugin.v1.Empty
+	1,  // 32: anyquery.plugin.v1.Plugin.Update:output_type -> anyquery.plugin.v1.Empty
+	1,  // 33: anyquery.plugin.v1.Plugin.Delete:output_type -> anyquery.plugin.v1.Empty
+	1,  // 34: anyquery.plugin.v1.Plugin.Transaction:output_type -> anyquery.plugin.v1.Empty
+	1,  // 35: anyquery.plugin.v1.Plugin.Close:output_type -> anyquery.plugin.v1.Empty
+	27, // [27:36] is the sub-list for method output_type
+	18, // [18:27] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
+}
+
+func init() { file_plugin_proto_init() }
+func file_plugin_proto_init() {
+	if File_plugin_proto != nil {
+		return
+	}
+	file_plugin_proto_msgTypes[1].OneofWrappers = []any{
+		(*Value_IntValue)(nil),
+		(*Value_FloatValue)(nil),
+		(*Value_StringValue)(nil),
+		(*Value_BytesValue)(nil),
+		(*Value_BoolValue)(nil),
+		(*Value_ListValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_plugin_proto_rawDesc), len(file_plugin_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_plugin_proto_goTypes,
+		DependencyIndexes: file_plugin_proto_depIdxs,
+		EnumInfos:         file_plugin_proto_enumTypes,
+		MessageInfos:      file_plugin_proto_msgTypes,
+	}.Build()
+	File_plugin_proto = out.File
+	file_plugin_proto_goTypes = nil
+	file_plugin_proto_depIdxs = nil
+}
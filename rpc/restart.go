@@ -0,0 +1,488 @@
+package rpc
+
+// This file implements the restart of the plugins whose process exited (e.g. a panic or an OOM kill).
+//
+// ConnectionPool.NewClient returns a pooledPlugin rather than the RPC client of the plugin.
+// It forwards the calls to the plugin. When a call fails because the process exited,
+// the executable is started again, Initialize is called again for each open table,
+// and the call is retried if it is idempotent (reading the first page of a cursor, opening a stream,
+// computing aggregates). Writes are not retried because the plugin might have applied them.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	go_plugin "github.com/hashicorp/go-plugin"
+)
+
+// DefaultMaxRestarts is the number of times a plugin can be restarted within restartWindow
+// when NewClientParams.MaxRestarts is zero
+const DefaultMaxRestarts = 3
+
+// The window of the restart budget of a plugin
+const restartWindow = time.Minute
+
+// errPluginUnreachable is returned by the gRPC client when the connection to the plugin is lost
+var errPluginUnreachable = errors.New("the plugin is unreachable")
+
+// pluginInstance is a running process of a plugin
+type pluginInstance struct {
+	client *go_plugin.Client
+	plugin InternalExchangeInterface
+	// The proxy of the requests of the plugin, if it declares its capabilities
+	proxy *capabilityProxy
+	// Whether kill was called, so that the process is only counted once (see ProcessStats)
+	killed atomic.Bool
+}
+
+// kill stops the process and its proxy
+func (i *pluginInstance) kill() {
+	if i.killed.CompareAndSwap(false, true) {
+		processStats.running.Add(-1)
+	}
+	i.client.Kill()
+	if i.proxy != nil {
+		i.proxy.Close()
+	}
+}
+
+// pooledPlugin is the InternalExchangeInterface returned by ConnectionPool.NewClient
+//
+// Like the RPC clients, it implements all the optional interfaces (InternalContextInterface,
+// InternalStreamInterface, InternalAggregateInterface and InternalTransactionInterface).
+// It also implements InternalCursorCloseInterface to forget the cursors closed by SQLite
+type pooledPlugin struct {
+	params NewClientParams
+	logger hclog.Logger
+	// The client returned by ConnectionPool.NewClient, whose Client is replaced after a restart
+	internal *InternalClient
+
+	mu       sync.Mutex
+	instance *pluginInstance
+	// The config of the tables initialized by the main program, to initialize them again after a restart
+	tables map[tableKey]PluginConfig
+	// The cursors that returned a page. They cannot be resumed after a restart
+	cursors map[cursorKey]struct{}
+	// The times of the restarts within restartWindow
+	restarts []time.Time
+}
+
+// InternalCursorCloseInterface is implemented by the clients that keep a state per cursor
+type InternalCursorCloseInterface interface {
+	// CloseCursor forgets a cursor whose rows won't be requested anymore,
+	// even if the plugin did not return all of them (e.g. a LIMIT or an error)
+	CloseCursor(connectionID int, tableIndex int, cursorIndex int)
+}
+
+// startPlugin starts the executable of a plugin
+//
+// If the plugin declares its capabilities, its requests go through a proxy (see capabilities.go)
+func startPlugin(params NewClientParams) (*pluginInstance, error) {
+	if params.Capabilities == nil {
+		return startProcess(params, params.Limits, nil)
+	}
+
+	logger := params.Logger
+	if logger == nil {
+		logger = hclog.Default()
+	}
+	proxy, err := startCapabilityProxy(params.Capabilities, logger.Named("proxy"))
+	if err != nil {
+		return nil, err
+	}
+	env := append(os.Environ(), proxy.env()...)
+
+	// A plugin without network access cannot bypass the proxy in its own network namespace
+	if len(params.Capabilities.NetworkHosts) == 0 && !params.Limits.IsolateNetwork && runtime.GOOS == "linux" {
+		limits := params.Limits
+		limits.IsolateNetwork = true
+		instance, err := startProcess(params, limits, env)
+		if err == nil {
+			instance.proxy = proxy
+			return instance, nil
+		}
+		logger.Warn("could not run the plugin in a network namespace, only its proxy restricts the network",
+			"plugin", params.ExecutableLocation, "error", err)
+	}
+
+	instance, err := startProcess(params, params.Limits, env)
+	if err != nil {
+		proxy.Close()
+		return nil, err
+	}
+	instance.proxy = proxy
+	return instance, nil
+}
+
+// startProcess starts the executable of a plugin with limits and env (the environment of the main program if nil)
+func startProcess(params NewClientParams, limits ResourceLimits, env []string) (*pluginInstance, error) {
+	protocols, err := allowedProtocols(params.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	command := exec.Command(params.ExecutableLocation, params.ExecutableArg...)
+	command.Env = env
+	if err := isolateCommand(command, limits); err != nil {
+		return nil, err
+	}
+
+	// We use the same magic cookie as the main program
+	// to ensure that the plugin is compatible with the main program
+	client := go_plugin.NewClient(&go_plugin.ClientConfig{
+		HandshakeConfig: go_plugin.HandshakeConfig{
+			ProtocolVersion:  ProtocolVersion,
+			MagicCookieKey:   MagicCookieKey,
+			MagicCookieValue: MagicCookieValue,
+		},
+		Plugins: map[string]go_plugin.Plugin{
+			"plugin": &InternalPlugin{},
+		},
+		AllowedProtocols: protocols,
+		Cmd:              command,
+		Logger:           params.Logger,
+		Stderr:           params.Stderr,
+	})
+
+	// We get the RPC client
+	protocol, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	if limits.hasProcessLimits() {
+		if err := limitProcess(command.Process.Pid, limits); err != nil {
+			client.Kill()
+			return nil, err
+		}
+	}
+
+	// We request the plugin
+	raw, err := protocol.Dispense("plugin")
+	if err != nil {
+		client.Kill()
+		return nil, err
+	}
+
+	// We cast the plugin to the InternalExchangeInterface
+	plugin, ok := raw.(InternalExchangeInterface)
+	if !ok {
+		client.Kill()
+		return nil, errors.New("plugin does not implement InternalExchangeInterface")
+	}
+
+	processStats.started.Add(1)
+	processStats.running.Add(1)
+	return &pluginInstance{client: client, plugin: plugin}, nil
+}
+
+func newPooledPlugin(params NewClientParams, instance *pluginInstance) *pooledPlugin {
+	logger := params.Logger
+	if logger == nil {
+		logger = hclog.Default()
+	}
+	return &pooledPlugin{
+		params:   params,
+		logger:   logger,
+		instance: instance,
+		tables:   make(map[tableKey]PluginConfig),
+		cursors:  make(map[cursorKey]struct{}),
+	}
+}
+
+func (p *pooledPlugin) current() *pluginInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.instance
+}
+
+// kill stops the process of the plugin
+func (p *pooledPlugin) kill() {
+	p.current().kill()
+}
+
+// exited reports whether err was returned because the process of the plugin exited
+func exited(instance *pluginInstance, err error) bool {
+	if instance.client.Exited() {
+		return true
+	}
+	if !errors.Is(err, rpc.ErrShutdown) && !errors.Is(err, io.ErrUnexpectedEOF) &&
+		!errors.Is(err, io.EOF) && !errors.Is(err, errPluginUnreachable) {
+		return false
+	}
+	// The connection is closed before go-plugin notices that the process exited
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if instance.client.Exited() {
+			return true
+		}
+	}
+	return false
+}
+
+// restart starts the executable again if dead is still the current process of the plugin
+// and initializes the open tables
+func (p *pooledPlugin) restart(dead *pluginInstance) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Another call already restarted the plugin
+	if p.instance != dead {
+		return nil
+	}
+
+	maxRestarts := p.params.MaxRestarts
+	if maxRestarts == 0 {
+		maxRestarts = DefaultMaxRestarts
+	}
+	if maxRestarts < 0 {
+		return errors.New("the restarts of the plugin are disabled")
+	}
+	now := time.Now()
+	recent := p.restarts[:0]
+	for _, t := range p.restarts {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	p.restarts = recent
+	if len(p.restarts) >= maxRestarts {
+		return fmt.Errorf("the plugin exited %d times in the last %s", len(p.restarts)+1, restartWindow)
+	}
+	p.restarts = append(p.restarts, now)
+
+	p.logger.Warn("the plugin exited, restarting it", "plugin", p.params.ExecutableLocation,
+		"restart", len(p.restarts), "max_restarts", maxRestarts)
+
+	dead.kill()
+	instance, err := startPlugin(p.params)
+	if err != nil {
+		return err
+	}
+	for key, config := range p.tables {
+		if _, err := instance.plugin.Initialize(key.connectionIndex, key.tableIndex, config); err != nil {
+			instance.kill()
+			return fmt.Errorf("could not initialize the table %d of the connection %d: %w", key.tableIndex, key.connectionIndex, err)
+		}
+	}
+	p.instance = instance
+	p.internal.Client = instance.client
+	processStats.restarts.Add(1)
+	// The cursors of the previous process are lost
+	clear(p.cursors)
+	return nil
+}
+
+// run runs f with a process of the plugin
+//
+// If the plugin does not answer within the call timeout of its limits,
+// the process is killed so that the next call restarts it
+func (p *pooledPlugin) run(instance *pluginInstance, f func(plugin InternalExchangeInterface) error) error {
+	timeout := p.params.Limits.CallTimeout()
+	if timeout <= 0 {
+		return f(instance.plugin)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f(instance.plugin)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		p.logger.Warn("the plugin did not answer in time, killing it", "plugin", p.params.ExecutableLocation,
+			"timeout", timeout)
+		instance.kill()
+		return fmt.Errorf("%w (%s)", errCallTimeout, timeout)
+	}
+}
+
+// call runs f with the current process of the plugin
+//
+// If f fails because the process exited, the plugin is restarted.
+// f is then run again if retry is true. Otherwise, an error asks to run the operation again
+func (p *pooledPlugin) call(retry bool, f func(plugin InternalExchangeInterface) error) error {
+	instance := p.current()
+	err := p.run(instance, f)
+	// A call that timed out is not retried, the plugin is restarted on the next call
+	if err == nil || errors.Is(err, errCallTimeout) || !exited(instance, err) {
+		return err
+	}
+	if restartErr := p.restart(instance); restartErr != nil {
+		return fmt.Errorf("the plugin exited (%w) and could not be restarted: %w", err, restartErr)
+	}
+	if !retry {
+		return fmt.Errorf("the plugin exited and was restarted, the operation must be run again: %w", err)
+	}
+	return p.run(p.current(), f)
+}
+
+func (p *pooledPlugin) Initialize(connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error) {
+	return p.InitializeContext(context.Background(), connectionID, tableIndex, config)
+}
+
+func (p *pooledPlugin) InitializeContext(ctx context.Context, connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error) {
+	var schema DatabaseSchema
+	err := p.call(true, func(plugin InternalExchangeInterface) error {
+		var err error
+		if ctxPlugin, ok := plugin.(InternalInitializeContextInterface); ok {
+			schema, err = ctxPlugin.InitializeContext(ctx, connectionID, tableIndex, config)
+		} else {
+			schema, err = plugin.Initialize(connectionID, tableIndex, config)
+		}
+		return err
+	})
+	if err == nil {
+		p.mu.Lock()
+		p.tables[tableKey{connectionIndex: connectionID, tableIndex: tableIndex}] = config
+		p.mu.Unlock()
+	}
+	return schema, err
+}
+
+// query runs f to read a page of a cursor
+//
+// The first page is read again after a restart. The next ones cannot be
+// because the new process of the plugin does not know where the cursor stopped
+func (p *pooledPlugin) query(key cursorKey, f func(plugin InternalExchangeInterface) ([][]interface{}, bool, error)) ([][]interface{}, bool, error) {
+	p.mu.Lock()
+	_, started := p.cursors[key]
+	p.mu.Unlock()
+
+	var rows [][]interface{}
+	var noMoreRows bool
+	err := p.call(!started, func(plugin InternalExchangeInterface) error {
+		var err error
+		rows, noMoreRows, err = f(plugin)
+		return err
+	})
+	if err == nil {
+		p.mu.Lock()
+		if noMoreRows {
+			delete(p.cursors, key)
+		} else {
+			p.cursors[key] = struct{}{}
+		}
+		p.mu.Unlock()
+	}
+	return rows, noMoreRows, err
+}
+
+func (p *pooledPlugin) Query(connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) ([][]interface{}, bool, error) {
+	key := cursorKey{connectionIndex: connectionID, tableIndex: tableIndex, cursorIndex: cursorIndex}
+	return p.query(key, func(plugin InternalExchangeInterface) ([][]interface{}, bool, error) {
+		return plugin.Query(connectionID, tableIndex, cursorIndex, constraint)
+	})
+}
+
+func (p *pooledPlugin) QueryContext(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) ([][]interface{}, bool, error) {
+	key := cursorKey{connectionIndex: connectionID, tableIndex: tableIndex, cursorIndex: cursorIndex}
+	return p.query(key, func(plugin InternalExchangeInterface) ([][]interface{}, bool, error) {
+		if ctxPlugin, ok := plugin.(InternalContextInterface); ok {
+			return ctxPlugin.QueryContext(ctx, connectionID, tableIndex, cursorIndex, constraint)
+		}
+		return plugin.Query(connectionID, tableIndex, cursorIndex, constraint)
+	})
+}
+
+func (p *pooledPlugin) QueryStream(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (RowStream, error) {
+	var stream RowStream
+	err := p.call(true, func(plugin InternalExchangeInterface) error {
+		streamer, ok := plugin.(InternalStreamInterface)
+		if !ok {
+			return errors.New("the plugin cannot stream rows")
+		}
+		var err error
+		stream, err = streamer.QueryStream(ctx, connectionID, tableIndex, cursorIndex, constraint)
+		return err
+	})
+	return stream, err
+}
+
+func (p *pooledPlugin) QueryAggregate(connectionID int, tableIndex int, query AggregateQuery) ([][]interface{}, error) {
+	var rows [][]interface{}
+	err := p.call(true, func(plugin InternalExchangeInterface) error {
+		aggregator, ok := plugin.(InternalAggregateInterface)
+		if !ok {
+			return ErrAggregateNotSupported
+		}
+		var err error
+		rows, err = aggregator.QueryAggregate(connectionID, tableIndex, query)
+		return err
+	})
+	return rows, err
+}
+
+func (p *pooledPlugin) Insert(connectionID int, tableIndex int, rows [][]interface{}) error {
+	return p.call(false, func(plugin InternalExchangeInterface) error {
+		return plugin.Insert(connectionID, tableIndex, rows)
+	})
+}
+
+func (p *pooledPlugin) Update(connectionID int, tableIndex int, rows [][]interface{}) error {
+	return p.call(false, func(plugin InternalExchangeInterface) error {
+		return plugin.Update(connectionID, tableIndex, rows)
+	})
+}
+
+func (p *pooledPlugin) Delete(connectionID int, tableIndex int, primaryKeys []interface{}) error {
+	return p.call(false, func(plugin InternalExchangeInterface) error {
+		return plugin.Delete(connectionID, tableIndex, primaryKeys)
+	})
+}
+
+func (p *pooledPlugin) Transaction(connectionID int, tableIndex int, operation TransactionOperation, savepoint string) error {
+	// The staged writes are lost with the process, so the transaction is never retried
+	return p.call(false, func(plugin InternalExchangeInterface) error {
+		transactor, ok := plugin.(InternalTransactionInterface)
+		if !ok {
+			return errors.New("the plugin does not handle transactions")
+		}
+		return transactor.Transaction(connectionID, tableIndex, operation, savepoint)
+	})
+}
+
+func (p *pooledPlugin) CloseCursor(connectionID int, tableIndex int, cursorIndex int) {
+	p.mu.Lock()
+	delete(p.cursors, cursorKey{connectionIndex: connectionID, tableIndex: tableIndex, cursorIndex: cursorIndex})
+	p.mu.Unlock()
+}
+
+func (p *pooledPlugin) Close(connectionID int) error {
+	p.mu.Lock()
+	for key := range p.tables {
+		if key.connectionIndex == connectionID {
+			delete(p.tables, key)
+		}
+	}
+	for key := range p.cursors {
+		if key.connectionIndex == connectionID {
+			delete(p.cursors, key)
+		}
+	}
+	p.mu.Unlock()
+
+	// A plugin that exited has nothing to close
+	instance := p.current()
+	err := instance.plugin.Close(connectionID)
+	if err != nil && exited(instance, err) {
+		return nil
+	}
+	return err
+}
@@ -14,16 +14,16 @@ package rpc
 // https://github.com/hashicorp/go-plugin
 
 import (
-	"errors"
+	"context"
 	"io"
 	"net/rpc"
-	"os/exec"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/hashicorp/go-hclog"
 	go_plugin "github.com/hashicorp/go-plugin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const ProtocolVersion = 1
@@ -38,20 +38,23 @@ type InternalPlugin struct {
 // that will be called from the main program
 type PluginRPCClient struct {
 	client *rpc.Client
+	// Used to open the connections of the streams (see stream.go)
+	broker *go_plugin.MuxBroker
 }
 
 // PluginRPCServer is a struct that holds the RPC server
 // that will be runned by the plugin for the main program
 type PluginRPCServer struct {
-	Impl InternalExchangeInterface
+	Impl   InternalExchangeInterface
+	broker *go_plugin.MuxBroker
 }
 
-func (p *InternalPlugin) Server(*go_plugin.MuxBroker) (interface{}, error) {
-	return &PluginRPCServer{Impl: p.Impl}, nil
+func (p *InternalPlugin) Server(b *go_plugin.MuxBroker) (interface{}, error) {
+	return &PluginRPCServer{Impl: p.Impl, broker: b}, nil
 }
 
 func (p *InternalPlugin) Client(b *go_plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
-	return &PluginRPCClient{client: c}, nil
+	return &PluginRPCClient{client: c, broker: b}, nil
 }
 
 // -- Implementation of the RPC methods --
@@ -64,6 +67,8 @@ type InitializeArgs struct {
 	ConnectionID int
 	TableIndex   int
 	Config       PluginConfig
+	// The trace context of the main program (nil if it does not trace its queries). Ignored by old plugins
+	TraceContext TraceContext
 }
 
 // QueryArgs is a struct that holds the arguments for the Query method (see InitializeArgs)
@@ -72,11 +77,28 @@ type QueryArgs struct {
 	TableIndex   int
 	CursorIndex  int
 	Constraint   QueryConstraint
+	// The deadline of the query (zero if none). Ignored by old plugins
+	Deadline time.Time
+	// Whether the main program reads QueryReturn.TypedRows. Old versions of anyquery only read Rows
+	TypedValues bool
+	// The trace context of the main program (see InitializeArgs)
+	TraceContext TraceContext
 }
 
 type QueryReturn struct {
 	Rows       [][]interface{}
 	NoMoreRows bool
+	// The rows as typed values (see value.go), sent instead of Rows if QueryArgs.TypedValues is set.
+	// Old plugins never send them
+	TypedRows [][]Value
+}
+
+// rows returns the rows of the response, whichever field holds them
+func (r *QueryReturn) rows() [][]interface{} {
+	if r.TypedRows != nil {
+		return decodeRows(r.TypedRows)
+	}
+	return r.Rows
 }
 
 type InsertArgs struct {
@@ -98,53 +120,87 @@ type DeleteArgs struct {
 }
 
 func (m *PluginRPCClient) Initialize(connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error) {
+	return m.InitializeContext(context.Background(), connectionID, tableIndex, config)
+}
+
+func (m *PluginRPCClient) InitializeContext(ctx context.Context, connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error) {
+	ctx, span := startPluginSpan(ctx, "Initialize", connectionID, tableIndex)
 	args := &InitializeArgs{
 		ConnectionID: connectionID,
 		TableIndex:   tableIndex,
 		Config:       config,
+		TraceContext: traceContextFrom(ctx),
 	}
 	var resp DatabaseSchema
 	err := m.client.Call("Plugin.Initialize", args, &resp)
+	EndSpan(span, err)
 	return resp, err
 }
 
 func (m *PluginRPCClient) Query(connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) ([][]interface{}, bool, error) {
+	ctx, span := startPluginSpan(context.Background(), "Query", connectionID, tableIndex)
 	args := &QueryArgs{
 		ConnectionID: connectionID,
 		TableIndex:   tableIndex,
 		CursorIndex:  cursorIndex,
 		Constraint:   constraint,
+		TypedValues:  true,
+		TraceContext: traceContextFrom(ctx),
 	}
 	var resp QueryReturn
 	err := m.client.Call("Plugin.Query", args, &resp)
-	return resp.Rows, resp.NoMoreRows, err
+	rows := resp.rows()
+	span.SetAttributes(attribute.Int("anyquery.rows", len(rows)))
+	EndSpan(span, err)
+	return rows, resp.NoMoreRows, err
+}
+
+// call calls a method of the plugin in a span
+func (m *PluginRPCClient) call(method string, connectionID int, tableIndex int, args interface{}, reply interface{}) error {
+	_, span := startPluginSpan(context.Background(), method, connectionID, tableIndex)
+	err := m.client.Call("Plugin."+method, args, reply)
+	EndSpan(span, err)
+	return err
 }
 
 func (m *PluginRPCClient) Insert(connectionID int, tableIndex int, rows [][]interface{}) error {
-	return m.client.Call("Plugin.Insert", &InsertArgs{ConnectionID: connectionID, TableIndex: tableIndex, Rows: rows}, nil)
+	return m.call("Insert", connectionID, tableIndex, &InsertArgs{ConnectionID: connectionID, TableIndex: tableIndex, Rows: rows}, nil)
 }
 
 func (m *PluginRPCClient) Update(connectionID int, tableIndex int, rows [][]interface{}) error {
-	return m.client.Call("Plugin.Update", &UpdateArgs{ConnectionID: connectionID, TableIndex: tableIndex, Rows: rows}, nil)
+	return m.call("Update", connectionID, tableIndex, &UpdateArgs{ConnectionID: connectionID, TableIndex: tableIndex, Rows: rows}, nil)
 }
 
 func (m *PluginRPCClient) Delete(connectionID int, tableIndex int, primaryKeys []interface{}) error {
-	return m.client.Call("Plugin.Delete", &DeleteArgs{ConnectionID: connectionID, TableIndex: tableIndex, PrimaryKeys: primaryKeys}, nil)
+	return m.call("Delete", connectionID, tableIndex, &DeleteArgs{ConnectionID: connectionID, TableIndex: tableIndex, PrimaryKeys: primaryKeys}, nil)
 }
 
 func (m *PluginRPCClient) Close(connectionID int) error {
-	return m.client.Call("Plugin.Close", connectionID, nil)
+	return m.call("Close", connectionID, -1, connectionID, nil)
 }
 
 func (m *PluginRPCServer) Initialize(args *InitializeArgs, resp *DatabaseSchema) error {
 	var err error
-	*resp, err = m.Impl.Initialize(args.ConnectionID, args.TableIndex, args.Config)
+	if impl, ok := m.Impl.(internalTraceServer); ok {
+		*resp, err = impl.initializeTraced(args.ConnectionID, args.TableIndex, args.Config, args.TraceContext)
+	} else {
+		*resp, err = m.Impl.Initialize(args.ConnectionID, args.TableIndex, args.Config)
+	}
 	return err
 }
 
 func (m *PluginRPCServer) Query(args *QueryArgs, resp *QueryReturn) error {
 	var err error
-	resp.Rows, resp.NoMoreRows, err = m.Impl.Query(args.ConnectionID, args.TableIndex, args.CursorIndex, args.Constraint)
+	// If the plugin can be cancelled, we forward the deadline of the query
+	if impl, ok := m.Impl.(internalCancelServer); ok {
+		resp.Rows, resp.NoMoreRows, err = impl.queryDeadline(args.ConnectionID, args.TableIndex, args.CursorIndex, args.Constraint, args.Deadline, args.TraceContext)
+	} else {
+		resp.Rows, resp.NoMoreRows, err = m.Impl.Query(args.ConnectionID, args.TableIndex, args.CursorIndex, args.Constraint)
+	}
+	if err == nil && args.TypedValues {
+		resp.TypedRows, err = encodeRows(resp.Rows)
+		resp.Rows = nil
+	}
 	return err
 }
 
@@ -174,25 +230,28 @@ type InternalClient struct {
 // ConnectionPool is a struct that holds the connections to the plugins
 // It allows using the same executable for multiple connections
 //
+// If the process of a plugin exits (e.g. it panicked), the pool starts it again
+// on the next call (see restart.go)
+//
 // It is not intended to be used by plugins but by the main program
 type ConnectionPool struct {
-	connections map[string]*struct {
-		client          *InternalClient
-		connectionCount atomic.Int32
-	}
+	connections map[string]*pooledClient
 	// To ensure we are not creating multiple connections at the same time
 	mu sync.Mutex
 }
 
+type pooledClient struct {
+	client          *InternalClient
+	plugin          *pooledPlugin
+	connectionCount atomic.Int32
+}
+
 // NewConnectionPool creates a new connection pool
 //
 // Using the zero value is not recommended and might lead to a SIGSEGV
 func NewConnectionPool() *ConnectionPool {
 	return &ConnectionPool{
-		connections: make(map[string]*struct {
-			client          *InternalClient
-			connectionCount atomic.Int32
-		}),
+		connections: make(map[string]*pooledClient),
 
 		mu: sync.Mutex{},
 	}
@@ -203,6 +262,28 @@ type NewClientParams struct {
 	Logger             hclog.Logger
 	ExecutableArg      []string
 	Stderr             io.Writer
+	// The transport of the plugin set in its manifest (ProtocolNetRPC or ProtocolGRPC).
+	// If empty, the plugin can use either of them
+	Protocol string
+	// The number of times the plugin can be restarted within a minute after its process exited.
+	// Zero means DefaultMaxRestarts, and a negative value disables the restarts
+	MaxRestarts int
+	// The resource limits of the process of the plugin.
+	// Plugins with different limits run in different processes
+	Limits ResourceLimits
+	// The capabilities declared in the manifest of the plugin.
+	// If nil, the plugin is not restricted
+	Capabilities *PluginCapabilities
+}
+
+// Key returns the key of the client in the connection pool, to pass to CloseConnection
+//
+// It is the executable location, followed by the limits if any
+func (p NewClientParams) Key() string {
+	if p.Limits.IsZero() {
+		return p.ExecutableLocation
+	}
+	return p.ExecutableLocation + "?" + p.Limits.String()
 }
 
 // Request a new client from the connection pool. Each NewClient must be followed by a CloseConnection.
@@ -216,77 +297,48 @@ func (c *ConnectionPool) NewClient(params NewClientParams) (*InternalClient, err
 	defer c.mu.Unlock()
 
 	// We check if the client already exists
-	if client, ok := c.connections[params.ExecutableLocation]; ok {
+	key := params.Key()
+	if client, ok := c.connections[key]; ok {
 		client.connectionCount.Add(1)
 		return client.client, nil
 	}
 
-	client := new(InternalClient)
-
-	command := exec.Command(params.ExecutableLocation, params.ExecutableArg...)
-
-	// We use the same magic cookie as the main program
-	// to ensure that the plugin is compatible with the main program
-	client.Client = go_plugin.NewClient(&go_plugin.ClientConfig{
-		HandshakeConfig: go_plugin.HandshakeConfig{
-			ProtocolVersion:  ProtocolVersion,
-			MagicCookieKey:   MagicCookieKey,
-			MagicCookieValue: MagicCookieValue,
-		},
-		Plugins: map[string]go_plugin.Plugin{
-			"plugin": &InternalPlugin{},
-		},
-		Cmd:    command,
-		Logger: params.Logger,
-		Stderr: params.Stderr,
-	})
-
-	// We get the RPC client
-	protocol, err := client.Client.Client()
+	instance, err := startPlugin(params)
 	if err != nil {
 		return nil, err
 	}
 
-	// We request the plugin
-	raw, err := protocol.Dispense("plugin")
-	if err != nil {
-		return nil, err
-	}
-
-	// We cast the plugin to the InternalExchangeInterface
-	plugin, ok := raw.(InternalExchangeInterface)
-	if !ok {
-		return nil, errors.New("plugin does not implement InternalExchangeInterface")
-	}
-
-	client.Plugin = plugin
+	// The calls go through the pooled plugin so that the plugin is restarted if its process exits
+	plugin := newPooledPlugin(params, instance)
+	client := &InternalClient{Client: instance.client, Plugin: plugin}
+	plugin.internal = client
 
 	// We add the client to the connection pool
-	c.connections[params.ExecutableLocation] = &struct {
-		client          *InternalClient
-		connectionCount atomic.Int32
-	}{
-		client:          client,
-		connectionCount: atomic.Int32{},
+	c.connections[key] = &pooledClient{
+		client: client,
+		plugin: plugin,
 	}
 
 	// We increment the connection count
-	c.connections[params.ExecutableLocation].connectionCount.Add(1)
+	c.connections[key].connectionCount.Add(1)
 
 	return client, nil
 }
 
 // Warn the plugin that the connection will be closed, wait a few seconds and close the connection
 // If all connections are closed, the plugin is killed
-func (c *ConnectionPool) CloseConnection(executableLocation string, connectionID int) {
-	if client, ok := c.connections[executableLocation]; ok {
+//
+// The key is the one of the NewClientParams (see NewClientParams.Key).
+// It is the executable location if the plugin has no resource limits
+func (c *ConnectionPool) CloseConnection(key string, connectionID int) {
+	if client, ok := c.connections[key]; ok {
 		client.connectionCount.Add(-1)
 		// Call the destructor for the connection with a timeout of 5 seconds
 		timer := time.NewTimer(5 * time.Second)
 
 		chanClose := make(chan struct{})
 		go func() {
-			client.client.Plugin.Close(connectionID)
+			client.plugin.Close(connectionID)
 			chanClose <- struct{}{}
 			timer.Stop()
 		}()
@@ -306,8 +358,8 @@ func (c *ConnectionPool) CloseConnection(executableLocation string, connectionID
 		//
 		// So we don't need to lock the map
 		if client.connectionCount.Load() <= 0 {
-			client.client.Client.Kill()
-			delete(c.connections, executableLocation)
+			client.plugin.kill()
+			delete(c.connections, key)
 		}
 	}
 }
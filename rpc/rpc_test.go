@@ -1,9 +1,18 @@
 package rpc
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
@@ -74,10 +83,340 @@ func TestRPCPlugin(t *testing.T) {
 		rows, noMoreRows, err := client.Plugin.Query(0, 0, 0, QueryConstraint{})
 		require.NoError(t, err, "The plugin should be queried without errors")
 		require.Equal(t, [][]interface{}{
-			{1, "hello"},
-			{2, "world"},
+			{int64(1), "hello"},
+			{int64(2), "world"},
 		}, rows, "The rows should be correct")
 		require.True(t, noMoreRows, "The noMoreRows should be true")
 	})
 
 }
+
+func TestGRPCPlugin(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	output, err := exec.Command("go", "build", "-o", "_test/grpcplugin.out", "../test/grpcplugin.go").CombinedOutput()
+	if testing.Verbose() && len(output) > 0 && err != nil {
+		t.Logf("Output build: %s", output)
+	}
+	require.NoError(t, err, "The plugin should be built without errors")
+
+	logger := hclog.Default()
+	if testing.Verbose() {
+		logger.SetLevel(hclog.Debug)
+	}
+
+	pool := NewConnectionPool()
+
+	t.Run("A plugin served over gRPC is rejected by a net/rpc manifest", func(t *testing.T) {
+		_, err := pool.NewClient(NewClientParams{
+			ExecutableLocation: "_test/grpcplugin.out",
+			Logger:             logger,
+			Protocol:           ProtocolNetRPC,
+		})
+		require.Error(t, err)
+	})
+
+	client, err := pool.NewClient(NewClientParams{
+		ExecutableLocation: "_test/grpcplugin.out",
+		Logger:             logger,
+		Protocol:           ProtocolGRPC,
+	})
+	require.NoError(t, err, "The plugin should be started without errors")
+	defer pool.CloseConnection("_test/grpcplugin.out", 0)
+
+	t.Run("Initialize the plugin", func(t *testing.T) {
+		schema, err := client.Plugin.Initialize(0, 0, PluginConfig{"token": "secret", "ids": []interface{}{int64(1), "two"}})
+		require.NoError(t, err)
+		require.Equal(t, DatabaseSchema{
+			Columns: []DatabaseSchemaColumn{
+				{Name: "id", Type: ColumnTypeInt},
+				{Name: "kind", Type: ColumnTypeString},
+				{Name: "value", Type: ColumnTypeString},
+				{Name: "score", Type: ColumnTypeFloat},
+				{Name: "data", Type: ColumnTypeBlob, Description: "Some bytes"},
+			},
+			PrimaryKey:      -1,
+			HandlesInsert:   true,
+			HandlesIn:       true,
+			StreamRows:      true,
+			EstimatedRows:   42,
+			ConstraintCosts: []ConstraintCost{{Columns: []int{0}, EstimatedRows: 1}},
+		}, schema)
+	})
+
+	t.Run("Query the plugin", func(t *testing.T) {
+		rows, noMoreRows, err := client.Plugin.Query(0, 0, 0, QueryConstraint{
+			Columns: []ColumnConstraint{
+				{ColumnID: 0, Operator: OperatorIn, Value: []interface{}{int64(1), "a"}},
+				{ColumnID: 1, Operator: OperatorEqual, Value: nil},
+			},
+			Limit:  10,
+			Offset: -1,
+		})
+		require.NoError(t, err)
+		require.True(t, noMoreRows)
+		require.Equal(t, [][]interface{}{
+			{int64(1), "config", "secret", 1.5, nil},
+			{int64(2), "constraints", "[{0 75 [1 a]} {1 2 <nil>}]", 10.0, []byte("blob")},
+		}, rows)
+	})
+
+	t.Run("Stream the rows of the plugin", func(t *testing.T) {
+		stream, err := client.Plugin.(InternalStreamInterface).QueryStream(context.Background(), 0, 0, 1, QueryConstraint{})
+		require.NoError(t, err)
+		count := 0
+		for {
+			row, err := stream.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			count++
+			require.Equal(t, int64(count), row[0])
+		}
+		require.Equal(t, 1000, count)
+		require.NoError(t, stream.Close())
+	})
+
+	t.Run("Insert rows in the plugin", func(t *testing.T) {
+		err := client.Plugin.Insert(0, 0, [][]interface{}{{nil, "inserted", "hello", nil, nil}})
+		require.NoError(t, err)
+		rows, _, err := client.Plugin.Query(0, 0, 2, QueryConstraint{})
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{int64(3), "inserted", "hello", nil, nil}, rows[2])
+	})
+
+	t.Run("A cancelled query returns the error of the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err := client.Plugin.(InternalContextInterface).QueryContext(ctx, 0, 0, 3, QueryConstraint{})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestTypedValues(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	output, err := exec.Command("go", "build", "-o", "_test/typedplugin.out", "../test/typedplugin.go").CombinedOutput()
+	if testing.Verbose() && len(output) > 0 && err != nil {
+		t.Logf("Output build: %s", output)
+	}
+	require.NoError(t, err, "The plugin should be built without errors")
+
+	logger := hclog.Default()
+	if testing.Verbose() {
+		logger.SetLevel(hclog.Debug)
+	}
+
+	pool := NewConnectionPool()
+	client, err := pool.NewClient(NewClientParams{
+		ExecutableLocation: "_test/typedplugin.out",
+		Logger:             logger,
+	})
+	require.NoError(t, err, "The plugin should be started without errors")
+	defer pool.CloseConnection("_test/typedplugin.out", 0)
+
+	_, err = client.Plugin.Initialize(0, 0, PluginConfig{})
+	require.NoError(t, err)
+	_, err = client.Plugin.Initialize(0, 1, PluginConfig{})
+	require.NoError(t, err)
+
+	t.Run("The values are converted to the types of the columns", func(t *testing.T) {
+		rows, noMoreRows, err := client.Plugin.Query(0, 0, 0, QueryConstraint{})
+		require.NoError(t, err)
+		require.True(t, noMoreRows)
+		require.Equal(t, [][]interface{}{
+			{int64(1), int64(1), float64(0.5), "2024-05-06T07:08:09Z", "2024-05-06", `{"a":1}`, []byte("bytes"), "42"},
+			{int64(2), int64(0), float64(3), "2024-05-06T07:08:09+02:00", "2024-05-06", `[1,2]`, []byte{}, nil},
+		}, rows)
+	})
+
+	t.Run("A value that does not match its column fails the query", func(t *testing.T) {
+		_, _, err := client.Plugin.Query(0, 1, 0, QueryConstraint{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `column "created_at"`)
+		require.Contains(t, err.Error(), "yesterday")
+	})
+}
+
+func TestPluginRestart(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	output, err := exec.Command("go", "build", "-o", "_test/crashplugin.out", "../test/crashplugin.go").CombinedOutput()
+	if testing.Verbose() && len(output) > 0 && err != nil {
+		t.Logf("Output build: %s", output)
+	}
+	require.NoError(t, err, "The plugin should be built without errors")
+
+	logger := hclog.Default()
+	if testing.Verbose() {
+		logger.SetLevel(hclog.Debug)
+	}
+
+	pool := NewConnectionPool()
+	client, err := pool.NewClient(NewClientParams{
+		ExecutableLocation: "_test/crashplugin.out",
+		Logger:             logger,
+		MaxRestarts:        2,
+	})
+	require.NoError(t, err, "The plugin should be started without errors")
+	defer pool.CloseConnection("_test/crashplugin.out", 0)
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	_, err = client.Plugin.Initialize(0, 0, PluginConfig{"marker": marker})
+	require.NoError(t, err)
+
+	t.Run("The first page of a cursor is read again after a restart", func(t *testing.T) {
+		rows, noMoreRows, err := client.Plugin.Query(0, 0, 0, QueryConstraint{Limit: -1, Offset: -1})
+		require.NoError(t, err)
+		require.False(t, noMoreRows)
+		// The marker is only known if the table was initialized again
+		require.Equal(t, [][]interface{}{{int64(1), marker}}, rows)
+	})
+
+	t.Run("A closed cursor is forgotten", func(t *testing.T) {
+		pooled := client.Plugin.(*pooledPlugin)
+		pooled.mu.Lock()
+		require.Contains(t, pooled.cursors, cursorKey{connectionIndex: 0, tableIndex: 0, cursorIndex: 0})
+		pooled.mu.Unlock()
+
+		// SQLite stops reading the cursor before its last page, e.g. because of a LIMIT
+		pooled.CloseCursor(0, 0, 0)
+		pooled.mu.Lock()
+		require.Empty(t, pooled.cursors)
+		pooled.mu.Unlock()
+	})
+
+	t.Run("The next pages of a cursor are not read again", func(t *testing.T) {
+		_, _, err := client.Plugin.Query(0, 0, 1, QueryConstraint{Limit: 7, Offset: -1})
+		require.NoError(t, err)
+		_, _, err = client.Plugin.Query(0, 0, 1, QueryConstraint{Limit: 7, Offset: -1})
+		require.ErrorContains(t, err, "must be run again")
+
+		// The plugin works after the restart
+		rows, _, err := client.Plugin.Query(0, 0, 2, QueryConstraint{Limit: -1, Offset: -1})
+		require.NoError(t, err)
+		require.Equal(t, [][]interface{}{{int64(1), marker}}, rows)
+	})
+
+	t.Run("The restarts are limited", func(t *testing.T) {
+		_, _, err := client.Plugin.Query(0, 0, 3, QueryConstraint{Limit: 42, Offset: -1})
+		require.ErrorContains(t, err, "could not be restarted")
+	})
+}
+
+func TestResourceLimits(t *testing.T) {
+	os.Mkdir("_test", 0755)
+	output, err := exec.Command("go", "build", "-o", "_test/slowplugin.out", "../test/slowplugin.go").CombinedOutput()
+	if testing.Verbose() && len(output) > 0 && err != nil {
+		t.Logf("Output build: %s", output)
+	}
+	require.NoError(t, err, "The plugin should be built without errors")
+
+	limits := ResourceLimits{CallTimeoutSeconds: 1}
+	if runtime.GOOS == "linux" {
+		limits.MaxMemoryMB = 1024
+		limits.MaxOpenFiles = 64
+	}
+	params := NewClientParams{
+		ExecutableLocation: "_test/slowplugin.out",
+		Logger:             hclog.Default(),
+		Limits:             limits,
+	}
+	require.NotEqual(t, params.ExecutableLocation, params.Key(), "Plugins with limits must not share the process of plugins without limits")
+
+	parsed, err := ParseResourceLimits(limits.String())
+	require.NoError(t, err)
+	require.Equal(t, limits, parsed)
+	_, err = ParseResourceLimits(`{"max_memory_mb": -1}`)
+	require.Error(t, err)
+
+	pool := NewConnectionPool()
+	client, err := pool.NewClient(params)
+	require.NoError(t, err, "The plugin should be started without errors")
+	defer pool.CloseConnection(params.Key(), 0)
+
+	_, err = client.Plugin.Initialize(0, 0, PluginConfig{})
+	require.NoError(t, err)
+
+	t.Run("A call longer than the timeout fails", func(t *testing.T) {
+		start := time.Now()
+		_, _, err := client.Plugin.Query(0, 0, 0, QueryConstraint{Limit: -1, Offset: -1})
+		require.ErrorContains(t, err, "did not answer in time")
+		require.Less(t, time.Since(start), 10*time.Second)
+	})
+
+	t.Run("The plugin is restarted after a timeout", func(t *testing.T) {
+		_, err := client.Plugin.Initialize(0, 1, PluginConfig{})
+		require.NoError(t, err)
+	})
+}
+
+func TestPluginCapabilities(t *testing.T) {
+	capabilities := &PluginCapabilities{NetworkHosts: []string{"127.0.0.1", "*.example.com"}}
+	require.True(t, capabilities.AllowsHost("127.0.0.1"))
+	require.True(t, capabilities.AllowsHost("api.example.com"))
+	require.True(t, capabilities.AllowsHost("EXAMPLE.com"))
+	require.False(t, capabilities.AllowsHost("example.org"))
+	require.True(t, (*PluginCapabilities)(nil).AllowsHost("example.org"), "A plugin without capabilities is not restricted")
+
+	parsed, err := ParsePluginCapabilities(capabilities.String())
+	require.NoError(t, err)
+	require.Equal(t, capabilities, parsed)
+	parsed, err = ParsePluginCapabilities("")
+	require.NoError(t, err)
+	require.Nil(t, parsed)
+
+	t.Run("The proxy only reaches the declared hosts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}))
+		defer server.Close()
+
+		proxy, err := startCapabilityProxy(capabilities, hclog.NewNullLogger())
+		require.NoError(t, err)
+		defer proxy.Close()
+
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy.url())}}
+
+		response, err := client.Get(server.URL)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+		require.Equal(t, "hello", string(body))
+
+		// Same server, but the host is not declared
+		response, err = client.Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+		require.NoError(t, err)
+		response.Body.Close()
+		require.Equal(t, http.StatusForbidden, response.StatusCode)
+
+		// Another process of the machine doesn't know the password
+		proxyURL, err := url.Parse("http://" + proxy.listener.Addr().String())
+		require.NoError(t, err)
+		anonymous := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+		response, err = anonymous.Get(server.URL)
+		require.NoError(t, err)
+		response.Body.Close()
+		require.Equal(t, http.StatusProxyAuthRequired, response.StatusCode)
+	})
+
+	t.Run("A plugin without network starts", func(t *testing.T) {
+		os.Mkdir("_test", 0755)
+		output, err := exec.Command("go", "build", "-o", "_test/normalplugin.out", "../test/normalplugin.go").CombinedOutput()
+		if testing.Verbose() && len(output) > 0 && err != nil {
+			t.Logf("Output build: %s", output)
+		}
+		require.NoError(t, err, "The plugin should be built without errors")
+
+		pool := NewConnectionPool()
+		client, err := pool.NewClient(NewClientParams{
+			ExecutableLocation: "_test/normalplugin.out",
+			Logger:             hclog.NewNullLogger(),
+			Capabilities:       &PluginCapabilities{},
+		})
+		require.NoError(t, err)
+		defer pool.CloseConnection("_test/normalplugin.out", 0)
+
+		_, err = client.Plugin.Initialize(0, 0, PluginConfig{})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,30 @@
+package rpc
+
+import "sync/atomic"
+
+// The counters of the processes of the plugins started by the main program
+var processStats struct {
+	running  atomic.Int64
+	started  atomic.Int64
+	restarts atomic.Int64
+}
+
+// PluginProcessStats are the counters of the processes of the plugins
+// started by the main program, e.g. to export them as metrics
+type PluginProcessStats struct {
+	// The processes started and not killed yet
+	Running int64
+	// The processes started since the main program started, restarts included
+	Started int64
+	// The times a plugin was restarted because its process exited (see restart.go)
+	Restarts int64
+}
+
+// ProcessStats returns the counters of the processes of the plugins
+func ProcessStats() PluginProcessStats {
+	return PluginProcessStats{
+		Running:  processStats.running.Load(),
+		Started:  processStats.started.Load(),
+		Restarts: processStats.restarts.Load(),
+	}
+}
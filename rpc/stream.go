@@ -0,0 +1,356 @@
+package rpc
+
+// This file implements the streaming mode of the plugin protocol.
+//
+// Instead of returning fixed pages of rows through net/rpc, the plugin dials
+// a dedicated connection through the go-plugin MuxBroker and pushes the rows
+// one by one as they are produced.
+// Because the connection is a yamux stream, the plugin is blocked as soon as the flow-control window
+// is full and the main program stops reading (backpressure), which keeps the memory bounded.
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// The maximum number of consecutive empty pages a paginated reader can return
+// when its rows are streamed before the stream is aborted
+const maxEmptyPagesStream = 16
+
+// InternalStreamInterface is implemented by the clients that can stream rows
+// from the plugin rather than requesting them page by page
+//
+// The main program must only call it for tables whose schema has StreamRows set to true
+// because old plugins do not know how to handle a stream
+type InternalStreamInterface interface {
+	// QueryStream opens a stream of rows for a given SELECT query
+	//
+	// The constraints have the same meaning as in InternalExchangeInterface.Query.
+	// Once ctx is done, the stream is closed and the plugin stops producing rows
+	QueryStream(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (RowStream, error)
+}
+
+// RowStream is a stream of rows returned by InternalStreamInterface.QueryStream
+type RowStream interface {
+	// Next returns the next row of the stream
+	//
+	// It blocks until the plugin produces a row.
+	// Once the plugin has no more rows, it returns io.EOF
+	Next() ([]interface{}, error)
+
+	// Close stops the stream. The plugin is notified and stops producing rows
+	Close() error
+}
+
+// RowWriter is passed to StreamReaderInterface.QueryStream to send rows to the main program
+type RowWriter interface {
+	// Write sends a row to the main program
+	//
+	// It blocks when the main program does not consume the rows fast enough.
+	// If it returns an error, the main program is not interested in the rows anymore
+	// and the reader must stop
+	Write(row []interface{}) error
+
+	// Context returns a context that is cancelled once the main program closes the stream
+	// (e.g. the query is cancelled or has enough rows)
+	//
+	// The reader should pass it to its API calls
+	Context() context.Context
+}
+
+// StreamReaderInterface can be implemented by a reader in addition to ReaderInterface
+// to push rows as they are produced rather than returning pages of rows
+//
+// It is only used for tables whose schema has StreamRows set to true.
+// If a reader of such a table does not implement this interface, the plugin library
+// calls Query until the cursor is exhausted and streams the returned rows
+type StreamReaderInterface interface {
+	ReaderInterface
+
+	// QueryStream writes the rows for a given SELECT query to w
+	// and returns once all the rows have been written
+	//
+	// Constraints are passed as arguments for optimization purposes (see ReaderInterface.Query)
+	QueryStream(constraint QueryConstraint, w RowWriter) error
+}
+
+// internalStreamServer is implemented by the plugin-side InternalExchangeInterface
+// that can handle a stream
+type internalStreamServer interface {
+	QueryStream(connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint, w RowWriter) error
+}
+
+// QueryStreamArgs is a struct that holds the arguments for the QueryStream method (see InitializeArgs)
+type QueryStreamArgs struct {
+	ConnectionID int
+	TableIndex   int
+	CursorIndex  int
+	Constraint   QueryConstraint
+	// The ID of the MuxBroker connection the plugin must dial to send the rows
+	StreamID uint32
+	// The deadline of the query (zero if none)
+	Deadline time.Time
+	// Whether the main program reads streamFrame.TypedRow (see QueryArgs)
+	TypedValues bool
+	// The trace context of the main program (see InitializeArgs)
+	TraceContext TraceContext
+}
+
+// streamFrame is a message sent by the plugin on the stream connection
+//
+// A frame holds either a row, or marks the end of the stream.
+// In the latter case, Err holds the error returned by the reader if any
+type streamFrame struct {
+	Row  []interface{}
+	Done bool
+	Err  string
+	// The row as typed values, sent instead of Row if QueryStreamArgs.TypedValues is set
+	TypedRow []Value
+}
+
+func (m *PluginRPCClient) QueryStream(ctx context.Context, connectionID int, tableIndex int, cursorIndex int, constraint QueryConstraint) (RowStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if m.broker == nil {
+		return nil, errors.New("the plugin connection does not support streaming")
+	}
+
+	// The plugin dials the connection while handling the call
+	// so we must accept it concurrently
+	streamID := m.broker.NextId()
+	type acceptResult struct {
+		conn net.Conn
+		err  error
+	}
+	accepted := make(chan acceptResult, 1)
+	go func() {
+		conn, err := m.broker.Accept(streamID)
+		accepted <- acceptResult{conn, err}
+	}()
+
+	args := &QueryStreamArgs{
+		ConnectionID: connectionID,
+		TableIndex:   tableIndex,
+		CursorIndex:  cursorIndex,
+		Constraint:   constraint,
+		StreamID:     streamID,
+		TypedValues:  true,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		args.Deadline = deadline
+	}
+	// The span only covers the opening of the stream
+	spanCtx, span := startPluginSpan(ctx, "QueryStream", connectionID, tableIndex)
+	args.TraceContext = traceContextFrom(spanCtx)
+	err := m.client.Call("Plugin.QueryStream", args, new(struct{}))
+	EndSpan(span, err)
+	if err != nil {
+		// Wait for the accept goroutine to release the connection if any
+		go func() {
+			if res := <-accepted; res.conn != nil {
+				res.conn.Close()
+			}
+		}()
+		return nil, err
+	}
+
+	res := <-accepted
+	if res.err != nil {
+		return nil, fmt.Errorf("could not accept the stream connection: %w", res.err)
+	}
+
+	stream := &pluginRowStream{
+		conn:    res.conn,
+		decoder: gob.NewDecoder(res.conn),
+		closed:  make(chan struct{}),
+	}
+
+	// Closing the connection unblocks Next and notifies the plugin
+	go func() {
+		select {
+		case <-ctx.Done():
+			stream.conn.Close()
+		case <-stream.closed:
+		}
+	}()
+
+	return stream, nil
+}
+
+func (m *PluginRPCServer) QueryStream(args *QueryStreamArgs, resp *struct{}) error {
+	impl, ok := m.Impl.(internalStreamServer)
+	if !ok {
+		return errors.New("plugin does not support streaming")
+	}
+	if m.broker == nil {
+		return errors.New("the plugin connection does not support streaming")
+	}
+
+	conn, err := m.broker.Dial(args.StreamID)
+	if err != nil {
+		return fmt.Errorf("could not dial the stream connection: %w", err)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if args.Deadline.IsZero() {
+		ctx, cancel = context.WithCancel(args.TraceContext.Context(context.Background()))
+	} else {
+		ctx, cancel = context.WithDeadline(args.TraceContext.Context(context.Background()), args.Deadline)
+	}
+
+	writer := &streamWriter{
+		conn:    conn,
+		encoder: gob.NewEncoder(conn),
+		ctx:     ctx,
+		cancel:  cancel,
+		typed:   args.TypedValues,
+	}
+
+	// The main program never writes on the connection.
+	// Therefore, once the read returns, the main program closed the stream
+	// and we close our side so that any blocked Write returns
+	go func() {
+		io.Copy(io.Discard, conn)
+		writer.close()
+	}()
+
+	// The rows are produced in the background so that the call returns
+	// and the main program can start reading the stream
+	go func() {
+		err := impl.QueryStream(args.ConnectionID, args.TableIndex, args.CursorIndex, args.Constraint, writer)
+		writer.end(err)
+	}()
+
+	return nil
+}
+
+// pluginRowStream is the host side of a stream
+type pluginRowStream struct {
+	conn      net.Conn
+	decoder   *gob.Decoder
+	done      bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (s *pluginRowStream) Next() ([]interface{}, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	var frame streamFrame
+	err := s.decoder.Decode(&frame)
+	if err != nil {
+		s.done = true
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errors.New("the stream was closed before the plugin sent all the rows. The plugin process was probably killed or crashed")
+		}
+		return nil, fmt.Errorf("could not decode the row sent by the plugin: %w", err)
+	}
+
+	if frame.Done {
+		s.Close()
+		if frame.Err != "" {
+			return nil, errors.New(frame.Err)
+		}
+		return nil, io.EOF
+	}
+
+	if frame.TypedRow != nil {
+		return decodeRows([][]Value{frame.TypedRow})[0], nil
+	}
+	return frame.Row, nil
+}
+
+func (s *pluginRowStream) Close() error {
+	s.done = true
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.conn.Close()
+	})
+	return err
+}
+
+// convertingRowWriter converts the values of the rows to the types of their columns
+// before writing them (see ConvertValue)
+type convertingRowWriter struct {
+	RowWriter
+	columns []DatabaseSchemaColumn
+	written int
+}
+
+func (w *convertingRowWriter) Write(row []interface{}) error {
+	if err := convertRow(row, w.columns); err != nil {
+		return fmt.Errorf("the row %d written by the plugin does not match the schema of the table: %w", w.written, err)
+	}
+	w.written++
+	return w.RowWriter.Write(row)
+}
+
+// streamWriter is the plugin side of a stream
+type streamWriter struct {
+	conn    net.Conn
+	encoder *gob.Encoder
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	closed  bool
+	// Whether the rows are sent as typed values
+	typed bool
+}
+
+func (w *streamWriter) Write(row []interface{}) error {
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if closed {
+		return errors.New("the stream was closed by the main program")
+	}
+	if w.typed {
+		typedRows, err := encodeRows([][]interface{}{row})
+		if err != nil {
+			return err
+		}
+		return w.encoder.Encode(&streamFrame{TypedRow: typedRows[0]})
+	}
+	return w.encoder.Encode(&streamFrame{Row: row})
+}
+
+func (w *streamWriter) Context() context.Context {
+	return w.ctx
+}
+
+// end sends the last frame of the stream and closes the connection
+func (w *streamWriter) end(err error) {
+	frame := streamFrame{Done: true}
+	if err != nil {
+		frame.Err = err.Error()
+	}
+	w.mu.Lock()
+	closed := w.closed
+	w.mu.Unlock()
+	if !closed {
+		w.encoder.Encode(&frame)
+	}
+	w.close()
+}
+
+func (w *streamWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.cancel()
+	w.conn.Close()
+}
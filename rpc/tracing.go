@@ -0,0 +1,128 @@
+package rpc
+
+// This file implements the tracing of the calls to the plugins.
+//
+// The main program starts a span for each call to a plugin, and sends its trace context
+// (a W3C traceparent header) along with the call: in the arguments of the call with net/rpc,
+// and in the metadata of the call with gRPC. The plugin library passes it to the table creator
+// (see TableCreatorArgs.Context) and to the context of the readers (see ContextReaderInterface).
+//
+// The spans are sent to the tracer provider registered with otel.SetTracerProvider.
+// If none is registered, they are discarded.
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+// TracerName is the name of the tracer of the spans started by anyquery
+const TracerName = "github.com/julien040/anyquery"
+
+// The propagator of the trace context sent to the plugins.
+// It does not depend on the global propagator so that the plugins don't have to register one
+var traceContextPropagator = propagation.TraceContext{}
+
+// TraceContext holds the trace context of a call to a plugin
+// (the traceparent and tracestate headers of the W3C Trace Context specification)
+type TraceContext map[string]string
+
+// Context returns a context holding the trace context, so that the spans
+// started with it are children of the span of the main program
+func (t TraceContext) Context(ctx context.Context) context.Context {
+	if len(t) == 0 {
+		return ctx
+	}
+	return traceContextPropagator.Extract(ctx, propagation.MapCarrier(t))
+}
+
+// traceContextFrom returns the trace context of the span of ctx, or nil if ctx has no span
+func traceContextFrom(ctx context.Context) TraceContext {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	carrier := propagation.MapCarrier{}
+	traceContextPropagator.Inject(ctx, carrier)
+	return TraceContext(carrier)
+}
+
+// StartSpan starts a span with the tracer of anyquery
+//
+// The span must be ended with EndSpan
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// EndSpan records err in the span if it is not nil, and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startPluginSpan starts the span of a call to a plugin
+//
+// tableIndex is -1 for the calls that are not about a table (e.g. Close)
+func startPluginSpan(ctx context.Context, method string, connectionID int, tableIndex int) (context.Context, trace.Span) {
+	attributes := []attribute.KeyValue{
+		attribute.String("rpc.system", "anyquery"),
+		attribute.String("rpc.method", method),
+		attribute.Int("anyquery.connection_id", connectionID),
+	}
+	if tableIndex >= 0 {
+		attributes = append(attributes, attribute.Int("anyquery.table_index", tableIndex))
+	}
+	return otel.Tracer(TracerName).Start(ctx, "plugin."+method,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+}
+
+// outgoingTraceContext adds the trace context of ctx to the metadata of a gRPC call
+func outgoingTraceContext(ctx context.Context) context.Context {
+	traceContext := traceContextFrom(ctx)
+	if len(traceContext) == 0 {
+		return ctx
+	}
+	pairs := make([]string, 0, len(traceContext)*2)
+	for key, value := range traceContext {
+		pairs = append(pairs, key, value)
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// incomingTraceContext returns the trace context of the metadata of a gRPC call
+func incomingTraceContext(ctx context.Context) TraceContext {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	traceContext := TraceContext{}
+	for _, key := range traceContextPropagator.Fields() {
+		if values := md.Get(key); len(values) > 0 {
+			traceContext[key] = values[0]
+		}
+	}
+	if len(traceContext) == 0 {
+		return nil
+	}
+	return traceContext
+}
+
+// InternalInitializeContextInterface is implemented by the clients that send
+// the trace context of ctx to the plugin when a table is initialized
+type InternalInitializeContextInterface interface {
+	// InitializeContext is the same as InternalExchangeInterface.Initialize
+	InitializeContext(ctx context.Context, connectionID int, tableIndex int, config PluginConfig) (DatabaseSchema, error)
+}
+
+// internalTraceServer is implemented by the plugin-side InternalExchangeInterface
+// that passes the trace context of the main program to the table creator
+type internalTraceServer interface {
+	initializeTraced(connectionID int, tableIndex int, config PluginConfig, traceContext TraceContext) (DatabaseSchema, error)
+}
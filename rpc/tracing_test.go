@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceContext(t *testing.T) {
+	// Without a span, no trace context is sent
+	require.Nil(t, traceContextFrom(context.Background()))
+	require.Equal(t, context.Background(), TraceContext(nil).Context(context.Background()))
+
+	provider := sdktrace.NewTracerProvider()
+	defer provider.Shutdown(context.Background())
+	ctx, span := provider.Tracer(TracerName).Start(context.Background(), "query")
+	defer span.End()
+
+	// net/rpc: the trace context is sent in the arguments of the call
+	traceContext := traceContextFrom(ctx)
+	require.Contains(t, traceContext, "traceparent")
+	received := trace.SpanContextFromContext(traceContext.Context(context.Background()))
+	require.True(t, received.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), received.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), received.SpanID())
+
+	// gRPC: the trace context is sent in the metadata of the call
+	outgoing, ok := metadata.FromOutgoingContext(outgoingTraceContext(ctx))
+	require.True(t, ok)
+	incoming := incomingTraceContext(metadata.NewIncomingContext(context.Background(), outgoing))
+	require.Equal(t, traceContext, incoming)
+
+	require.Nil(t, incomingTraceContext(context.Background()))
+}
@@ -0,0 +1,142 @@
+package rpc
+
+// This file implements the transactions of the writable tables.
+//
+// When a transaction is opened (BEGIN or SAVEPOINT), the main program calls Begin
+// on the tables written in the transaction before sending them the first write.
+// The plugin stages the inserts, updates and deletes it receives, and applies them
+// on Commit or discards them on Rollback.
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TransactionOperation is an operation on the transaction of a table
+type TransactionOperation string
+
+const (
+	TransactionBegin      TransactionOperation = "begin"
+	TransactionCommit     TransactionOperation = "commit"
+	TransactionRollback   TransactionOperation = "rollback"
+	TransactionSavepoint  TransactionOperation = "savepoint"
+	TransactionRelease    TransactionOperation = "release"
+	TransactionRollbackTo TransactionOperation = "rollback_to"
+)
+
+// ErrTransactionNotSupported is returned when the table does not implement
+// the interface of the requested operation (TransactionTable or SavepointTable)
+var ErrTransactionNotSupported = errors.New("the table does not support transactions")
+
+// TransactionTable can be implemented by a table in addition to Table
+// to apply the writes of a transaction atomically
+//
+// It is only used for tables whose schema sets HandlesTransactions.
+// Between Begin and Commit, the inserts, updates and deletes of the table belong to the transaction
+type TransactionTable interface {
+	// Begin opens a transaction. The following writes must be staged
+	Begin() error
+	// Commit applies the staged writes
+	//
+	// It is called before SQLite commits. If it returns an error, the COMMIT statement fails
+	// and the transaction is rolled back: Rollback is called, and the writes are discarded
+	Commit() error
+	// Rollback discards the staged writes
+	Rollback() error
+}
+
+// SavepointTable can be implemented by a TransactionTable to support the savepoints
+// (SAVEPOINT, RELEASE and ROLLBACK TO statements)
+type SavepointTable interface {
+	// Savepoint marks the current state of the transaction with name
+	Savepoint(name string) error
+	// Release forgets the savepoint name and the savepoints created after it.
+	// Their writes stay in the transaction
+	Release(name string) error
+	// RollbackTo discards the writes made since the savepoint name.
+	// The savepoint stays open
+	RollbackTo(name string) error
+}
+
+// InternalTransactionInterface is implemented by the clients that can manage the transactions of the tables
+type InternalTransactionInterface interface {
+	Transaction(connectionID int, tableIndex int, operation TransactionOperation, savepoint string) error
+}
+
+// internalTransactionServer is implemented by the plugin-side InternalExchangeInterface
+// that can manage transactions
+type internalTransactionServer interface {
+	Transaction(connectionID int, tableIndex int, operation TransactionOperation, savepoint string) error
+}
+
+// TransactionArgs is a struct that holds the arguments for the Transaction method (see InitializeArgs)
+type TransactionArgs struct {
+	ConnectionID int
+	TableIndex   int
+	Operation    TransactionOperation
+	// The name of the savepoint for TransactionSavepoint, TransactionRelease and TransactionRollbackTo
+	Savepoint string
+}
+
+func (m *PluginRPCClient) Transaction(connectionID int, tableIndex int, operation TransactionOperation, savepoint string) error {
+	var resp struct{}
+	return m.call("Transaction", connectionID, tableIndex, &TransactionArgs{
+		ConnectionID: connectionID,
+		TableIndex:   tableIndex,
+		Operation:    operation,
+		Savepoint:    savepoint,
+	}, &resp)
+}
+
+func (m *PluginRPCServer) Transaction(args *TransactionArgs, resp *struct{}) error {
+	impl, ok := m.Impl.(internalTransactionServer)
+	if !ok {
+		return ErrTransactionNotSupported
+	}
+	return impl.Transaction(args.ConnectionID, args.TableIndex, args.Operation, args.Savepoint)
+}
+
+func (i *internalInterface) Transaction(connectionIndex int, tableIndex int, operation TransactionOperation, savepoint string) (err error) {
+	// Catch the panic and return it as an error
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panicked while running Transaction: %v", r)
+		}
+	}()
+
+	// We check if the connection initialized the table
+	table, ok := i.plugin.tableConnection[tableKey{connectionIndex: connectionIndex, tableIndex: tableIndex}]
+	if !ok {
+		return fmt.Errorf("main program did not initialize the table before opening a transaction")
+	}
+
+	switch operation {
+	case TransactionBegin, TransactionCommit, TransactionRollback:
+		tx, ok := table.(TransactionTable)
+		if !ok {
+			return ErrTransactionNotSupported
+		}
+		switch operation {
+		case TransactionBegin:
+			return tx.Begin()
+		case TransactionCommit:
+			return tx.Commit()
+		default:
+			return tx.Rollback()
+		}
+	case TransactionSavepoint, TransactionRelease, TransactionRollbackTo:
+		sp, ok := table.(SavepointTable)
+		if !ok {
+			return ErrTransactionNotSupported
+		}
+		switch operation {
+		case TransactionSavepoint:
+			return sp.Savepoint(savepoint)
+		case TransactionRelease:
+			return sp.Release(savepoint)
+		default:
+			return sp.RollbackTo(savepoint)
+		}
+	}
+	return fmt.Errorf("unknown transaction operation %q", operation)
+}
@@ -0,0 +1,443 @@
+package rpc
+
+// This file implements the typed values of the rows returned by the plugins.
+//
+// Before being sent, each value returned by a reader is converted according to the ColumnType
+// of its column (e.g. an int to an int64, a time.Time to an RFC3339 string, a map to a JSON string).
+// A value that cannot be converted (e.g. "yesterday" in a ColumnTypeDateTime column) fails the query
+// with a *ValueError naming the column, as for the aggregates (see aggregate.go).
+//
+// The converted values are sent as Value envelopes rather than interface{} values
+// so that they keep their type over the wire.
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValueKind is the SQLite storage class of a Value
+type ValueKind uint8
+
+const (
+	ValueKindNull ValueKind = iota
+	ValueKindInt
+	ValueKindFloat
+	ValueKindText
+	ValueKindBlob
+)
+
+// Value is a value of a row sent to the main program
+//
+// Only the field matching Kind is set
+type Value struct {
+	Kind  ValueKind
+	Int   int64
+	Float float64
+	Text  string
+	Blob  []byte
+}
+
+// Interface returns the value as an int64, a float64, a string, a []byte or nil
+func (v Value) Interface() interface{} {
+	switch v.Kind {
+	case ValueKindInt:
+		return v.Int
+	case ValueKindFloat:
+		return v.Float
+	case ValueKindText:
+		return v.Text
+	case ValueKindBlob:
+		if v.Blob == nil {
+			// gob does not send empty slices
+			return []byte{}
+		}
+		return v.Blob
+	}
+	return nil
+}
+
+// newValue wraps a value in a Value
+//
+// The values not converted by ConvertValue (e.g. the rows of the plugins implementing
+// InternalExchangeInterface directly) are converted with convertUntyped
+func newValue(value interface{}) (Value, error) {
+	value, err := convertUntyped(value)
+	if err != nil {
+		return Value{}, err
+	}
+	switch v := value.(type) {
+	case int64:
+		return Value{Kind: ValueKindInt, Int: v}, nil
+	case float64:
+		return Value{Kind: ValueKindFloat, Float: v}, nil
+	case string:
+		return Value{Kind: ValueKindText, Text: v}, nil
+	case []byte:
+		return Value{Kind: ValueKindBlob, Blob: v}, nil
+	}
+	return Value{}, nil
+}
+
+func encodeRows(rows [][]interface{}) ([][]Value, error) {
+	encoded := make([][]Value, len(rows))
+	for i, row := range rows {
+		encoded[i] = make([]Value, len(row))
+		for j, value := range row {
+			var err error
+			encoded[i][j], err = newValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("the value %d of the row %d cannot be sent: %w", j, i, err)
+			}
+		}
+	}
+	return encoded, nil
+}
+
+func decodeRows(rows [][]Value) [][]interface{} {
+	decoded := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		decoded[i] = make([]interface{}, len(row))
+		for j, value := range row {
+			decoded[i][j] = value.Interface()
+		}
+	}
+	return decoded
+}
+
+// String returns the name of the column type (e.g. datetime)
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnTypeInt:
+		return "int"
+	case ColumnTypeFloat:
+		return "float"
+	case ColumnTypeString:
+		return "string"
+	case ColumnTypeBlob:
+		return "blob"
+	case ColumnTypeBool:
+		return "bool"
+	case ColumnTypeDateTime:
+		return "datetime"
+	case ColumnTypeDate:
+		return "date"
+	case ColumnTypeTime:
+		return "time"
+	case ColumnTypeJSON:
+		return "json"
+	}
+	return "unknown(" + strconv.Itoa(int(t)) + ")"
+}
+
+// ValueError is returned when a value cannot be converted to the type of its column
+type ValueError struct {
+	Type   ColumnType
+	Value  interface{}
+	Reason string
+}
+
+func (e *ValueError) Error() string {
+	value := fmt.Sprintf("%v", e.Value)
+	if len(value) > 50 {
+		value = value[:47] + "..."
+	}
+	return fmt.Sprintf("the value %q of type %T is not a valid %s: %s", value, e.Value, e.Type, e.Reason)
+}
+
+// ConvertValue converts a value returned by a reader to the type of its column
+//
+// It returns an int64, a float64, a string, a []byte or nil:
+//   - ColumnTypeInt: an int64. Floats without a fractional part and numeric strings are accepted
+//   - ColumnTypeBool: an int64 set to 0 or 1. Booleans, 0, 1, "true" and "false" are accepted
+//   - ColumnTypeFloat: a float64. Integers and numeric strings are accepted
+//   - ColumnTypeString: a string. Numbers and booleans are formatted,
+//     and slices, maps and structs are encoded in JSON
+//   - ColumnTypeBlob: a []byte. Strings are accepted
+//   - ColumnTypeJSON: a string holding valid JSON. Any other value is encoded in JSON
+//   - ColumnTypeDateTime, ColumnTypeDate and ColumnTypeTime: a string in the format of the column type.
+//     A time.Time is formatted, and a string must already be in the format.
+//     A ColumnTypeDateTime column accepts the formats of the date functions of SQLite (see datetimeLayouts)
+//
+// nil and empty slices are converted to nil, and so is an empty string
+// for the types other than ColumnTypeString and ColumnTypeBlob.
+// If the value cannot be converted, a *ValueError is returned
+func ConvertValue(value interface{}, columnType ColumnType) (interface{}, error) {
+	if isNull(value) {
+		return nil, nil
+	}
+
+	invalid := func(reason string) (interface{}, error) {
+		return nil, &ValueError{Type: columnType, Value: value, Reason: reason}
+	}
+
+	// Many APIs return an empty string for a missing value
+	if value == "" && columnType != ColumnTypeString && columnType != ColumnTypeBlob {
+		return nil, nil
+	}
+
+	switch columnType {
+	case ColumnTypeInt:
+		switch v := numberValue(value).(type) {
+		case int64:
+			return v, nil
+		case float64:
+			if v != math.Trunc(v) || math.IsInf(v, 0) {
+				return invalid("it has a fractional part")
+			}
+			return int64(v), nil
+		case string:
+			parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return invalid("it is not an integer")
+			}
+			return parsed, nil
+		}
+		return invalid("expected an integer")
+
+	case ColumnTypeBool:
+		switch v := numberValue(value).(type) {
+		case int64:
+			if v == 0 || v == 1 {
+				return v, nil
+			}
+		case float64:
+			if v == 0 || v == 1 {
+				return int64(v), nil
+			}
+		case string:
+			parsed, err := strconv.ParseBool(strings.TrimSpace(v))
+			if err == nil {
+				return boolToInt(parsed), nil
+			}
+		}
+		return invalid("expected a boolean, 0 or 1")
+
+	case ColumnTypeFloat:
+		switch v := numberValue(value).(type) {
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return invalid("it is not a number")
+			}
+			return parsed, nil
+		}
+		return invalid("expected a number")
+
+	case ColumnTypeString:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case time.Time:
+			return v.Format(time.RFC3339), nil
+		case fmt.Stringer:
+			return v.String(), nil
+		}
+		switch v := numberValue(value).(type) {
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		return string(encoded), nil
+
+	case ColumnTypeBlob:
+		switch v := value.(type) {
+		case []byte:
+			return v, nil
+		case string:
+			return []byte(v), nil
+		}
+		return invalid("expected bytes or a string")
+
+	case ColumnTypeJSON:
+		switch v := value.(type) {
+		case string:
+			if !json.Valid([]byte(v)) {
+				return invalid("it is not valid JSON")
+			}
+			return v, nil
+		case []byte:
+			if !json.Valid(v) {
+				return invalid("it is not valid JSON")
+			}
+			return string(v), nil
+		case json.RawMessage:
+			if !json.Valid(v) {
+				return invalid("it is not valid JSON")
+			}
+			return string(v), nil
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		return string(encoded), nil
+
+	case ColumnTypeDateTime:
+		format := "a format of the date functions of SQLite (e.g. 2006-01-02T15:04:05Z or 2006-01-02 15:04:05)"
+		if v, ok := value.(string); ok {
+			for _, layout := range datetimeLayouts {
+				if _, err := time.Parse(layout, v); err == nil {
+					return v, nil
+				}
+			}
+			return invalid("it is not in " + format)
+		}
+		return convertTime(value, time.RFC3339, format, invalid)
+	case ColumnTypeDate:
+		return convertTime(value, time.DateOnly, "the YYYY-MM-DD format", invalid)
+	case ColumnTypeTime:
+		return convertTime(value, time.TimeOnly, "the HH:MM:SS format", invalid)
+	}
+
+	return invalid("the column type is unknown")
+}
+
+// datetimeLayouts are the formats of a ColumnTypeDateTime string, those accepted by the date functions of SQLite:
+// a date, optionally followed by a time (HH:MM, HH:MM:SS or HH:MM:SS.SSS) separated by a space or a T,
+// and a time zone (Z or ±HH:MM). RFC3339 is one of them
+var datetimeLayouts = []string{
+	time.DateOnly,
+	// time.Parse accepts fractional seconds even if the layout has none
+	"2006-01-02 15:04", "2006-01-02 15:04Z07:00", "2006-01-02T15:04", "2006-01-02T15:04Z07:00",
+	"2006-01-02 15:04:05", "2006-01-02 15:04:05Z07:00", "2006-01-02T15:04:05", time.RFC3339,
+}
+
+// convertTime formats a time.Time with layout, or checks that a string is in this layout
+func convertTime(value interface{}, layout string, format string, invalid func(string) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout), nil
+	case *time.Time:
+		return v.Format(layout), nil
+	case string:
+		// time.Parse accepts fractional seconds even if the layout has none
+		if _, err := time.Parse(layout, v); err != nil {
+			return invalid("it is not in " + format)
+		}
+		return v, nil
+	}
+	return invalid("expected a time.Time or a string in " + format)
+}
+
+// numberValue converts the integers to int64, the floats to float64 and the booleans to 0 or 1
+//
+// Any other value is returned as is
+func numberValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case float64:
+		return v
+	case bool:
+		return boolToInt(v)
+	}
+	return value
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// isNull reports whether the value is nil, a nil pointer or an empty slice (except []byte)
+func isNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if _, ok := value.([]byte); ok {
+		return false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// convertRow converts the values of a row to the types of the columns (see ConvertValue)
+//
+// If a value cannot be converted, the error names its column.
+// Like the main program, it tolerates rows that are shorter or longer than the columns.
+// The missing values are NULL and the extra values are ignored
+func convertRow(row []interface{}, columns []DatabaseSchemaColumn) error {
+	for i, value := range row {
+		if i >= len(columns) {
+			break
+		}
+		converted, err := ConvertValue(value, columns[i].Type)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", columns[i].Name, err)
+		}
+		row[i] = converted
+	}
+	return nil
+}
+
+// convertUntyped converts a value whose column type is unknown (e.g. the result of an aggregate)
+// to an int64, a float64, a string, a []byte or nil
+func convertUntyped(value interface{}) (interface{}, error) {
+	if isNull(value) {
+		return nil, nil
+	}
+	switch v := numberValue(value).(type) {
+	case int64, float64, string, []byte:
+		return v, nil
+	}
+	return ConvertValue(value, ColumnTypeString)
+}
+
+// rowColumns returns the columns of the values of the rows returned by the readers of the table
+//
+// The parameters are not returned by the readers
+func rowColumns(schema DatabaseSchema) []DatabaseSchemaColumn {
+	columns := make([]DatabaseSchemaColumn, 0, len(schema.Columns))
+	for _, column := range schema.Columns {
+		if !column.IsParameter {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
@@ -0,0 +1,106 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertValue(t *testing.T) {
+	valid := []struct {
+		value      interface{}
+		columnType ColumnType
+		expected   interface{}
+	}{
+		{nil, ColumnTypeInt, nil},
+		{[]string{}, ColumnTypeJSON, nil},
+		{(*time.Time)(nil), ColumnTypeDateTime, nil},
+		{uint8(3), ColumnTypeInt, int64(3)},
+		{float64(4), ColumnTypeInt, int64(4)},
+		{" 12 ", ColumnTypeInt, int64(12)},
+		{true, ColumnTypeBool, int64(1)},
+		{"false", ColumnTypeBool, int64(0)},
+		{int64(1), ColumnTypeBool, int64(1)},
+		{5, ColumnTypeFloat, float64(5)},
+		{"1.25", ColumnTypeFloat, float64(1.25)},
+		{1.5, ColumnTypeString, "1.5"},
+		{[]int{1, 2}, ColumnTypeString, "[1,2]"},
+		{"abc", ColumnTypeBlob, []byte("abc")},
+		{[]byte{}, ColumnTypeBlob, []byte{}},
+		{json.RawMessage(`{"a":true}`), ColumnTypeJSON, `{"a":true}`},
+		{map[string]int{"a": 1}, ColumnTypeJSON, `{"a":1}`},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ColumnTypeDateTime, "2024-01-02T03:04:05Z"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ColumnTypeDate, "2024-01-02"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ColumnTypeTime, "03:04:05"},
+		{"2024-01-02T03:04:05.123+01:00", ColumnTypeDateTime, "2024-01-02T03:04:05.123+01:00"},
+		{"2024-01-02", ColumnTypeDateTime, "2024-01-02"},
+		{"2024-01-02 03:04", ColumnTypeDateTime, "2024-01-02 03:04"},
+		{"2024-01-02 03:04:05", ColumnTypeDateTime, "2024-01-02 03:04:05"},
+		{"2024-01-02 03:04:05.123", ColumnTypeDateTime, "2024-01-02 03:04:05.123"},
+		{"2024-01-02T03:04:05", ColumnTypeDateTime, "2024-01-02T03:04:05"},
+		{"2024-01-02 03:04:05-04:00", ColumnTypeDateTime, "2024-01-02 03:04:05-04:00"},
+		{"", ColumnTypeDateTime, nil},
+		{"", ColumnTypeInt, nil},
+		{"", ColumnTypeString, ""},
+	}
+	for _, test := range valid {
+		converted, err := ConvertValue(test.value, test.columnType)
+		require.NoError(t, err, "%v (%T) to %s", test.value, test.value, test.columnType)
+		require.Equal(t, test.expected, converted, "%v (%T) to %s", test.value, test.value, test.columnType)
+	}
+
+	invalid := []struct {
+		value      interface{}
+		columnType ColumnType
+	}{
+		{1.5, ColumnTypeInt},
+		{"abc", ColumnTypeInt},
+		{2, ColumnTypeBool},
+		{"abc", ColumnTypeFloat},
+		{1, ColumnTypeBlob},
+		{"{", ColumnTypeJSON},
+		{"yesterday", ColumnTypeDateTime},
+		{"2024-01-02 3pm", ColumnTypeDateTime},
+		{"02/01/2024 03:04:05", ColumnTypeDateTime},
+		{"02/01/2024", ColumnTypeDate},
+		{3, ColumnTypeTime},
+		{1, ColumnType(42)},
+	}
+	for _, test := range invalid {
+		_, err := ConvertValue(test.value, test.columnType)
+		var valueErr *ValueError
+		require.True(t, errors.As(err, &valueErr), "%v (%T) to %s", test.value, test.value, test.columnType)
+	}
+}
+
+func TestConvertRow(t *testing.T) {
+	columns := []DatabaseSchemaColumn{
+		{Name: "id", Type: ColumnTypeInt},
+		{Name: "created_at", Type: ColumnTypeDateTime},
+	}
+
+	row := []interface{}{1, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	require.NoError(t, convertRow(row, columns))
+	require.Equal(t, []interface{}{int64(1), "2024-01-02T03:04:05Z"}, row)
+
+	err := convertRow([]interface{}{1, "tomorrow"}, columns)
+	require.ErrorContains(t, err, `column "created_at"`)
+	var valueErr *ValueError
+	require.ErrorAs(t, err, &valueErr)
+
+	// The extra values are ignored by the main program
+	row = []interface{}{1, nil, "extra"}
+	require.NoError(t, convertRow(row, columns))
+	require.Equal(t, []interface{}{int64(1), nil, "extra"}, row)
+}
+
+func TestEncodeRows(t *testing.T) {
+	// The rows of the plugins implementing InternalExchangeInterface are not converted beforehand
+	encoded, err := encodeRows([][]interface{}{{1, true, float32(0.5), []string{"a"}, []string{}, nil, []byte{}}})
+	require.NoError(t, err)
+	// gob drops the empty blobs, they must come back as empty blobs rather than NULL
+	require.Equal(t, [][]interface{}{{int64(1), int64(1), float64(0.5), `["a"]`, nil, nil, []byte{}}}, decodeRows(encoded))
+}
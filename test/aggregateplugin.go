@@ -0,0 +1,141 @@
+package main
+
+import (
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin registers a table computing count and sum server-side
+
+type aggregateTable struct {
+}
+
+type aggregateReader struct {
+}
+
+// The rows of the table: id, state, amount
+func items() [][]interface{} {
+	rows := make([][]interface{}, 0, 10)
+	for i := 1; i <= 10; i++ {
+		state := "closed"
+		if i%2 == 0 {
+			state = "open"
+		}
+		rows = append(rows, []interface{}{i, state, i * 10})
+	}
+	return rows
+}
+
+func (r *aggregateReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	return items(), true, nil
+}
+
+// match returns whether the row matches the equality and comparison constraints
+func match(row []interface{}, constraint rpc.QueryConstraint) bool {
+	for _, cst := range constraint.Columns {
+		switch value := row[cst.ColumnID].(type) {
+		case string:
+			if cst.Operator == rpc.OperatorEqual && value != cst.Value {
+				return false
+			}
+		case int:
+			other, _ := cst.Value.(int64)
+			switch cst.Operator {
+			case rpc.OperatorEqual:
+				if int64(value) != other {
+					return false
+				}
+			case rpc.OperatorGreater:
+				if int64(value) <= other {
+					return false
+				}
+			case rpc.OperatorLess:
+				if int64(value) >= other {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func (r *aggregateReader) QueryAggregate(query rpc.AggregateQuery) ([][]interface{}, error) {
+	type group struct {
+		key    []interface{}
+		values []int
+	}
+	groups := []*group{}
+	index := map[string]*group{}
+
+	for _, row := range items() {
+		if !match(row, query.Constraint) {
+			continue
+		}
+		key := []interface{}{}
+		strKey := ""
+		for _, col := range query.GroupBy {
+			key = append(key, row[col])
+			strKey += "|" + row[col].(string)
+		}
+		g, ok := index[strKey]
+		if !ok {
+			g = &group{key: key, values: make([]int, len(query.Aggregates))}
+			index[strKey] = g
+			groups = append(groups, g)
+		}
+		for i, agg := range query.Aggregates {
+			switch agg.Function {
+			case rpc.AggregateCount:
+				g.values[i]++
+			case rpc.AggregateSum:
+				g.values[i] += row[agg.ColumnID].(int)
+			default:
+				return nil, rpc.ErrAggregateNotSupported
+			}
+		}
+	}
+
+	rows := [][]interface{}{}
+	for _, g := range groups {
+		row := append([]interface{}{}, g.key...)
+		for _, v := range g.values {
+			row = append(row, v)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (t *aggregateTable) CreateReader() rpc.ReaderInterface {
+	return &aggregateReader{}
+}
+
+func (t *aggregateTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &aggregateTable{}, &rpc.DatabaseSchema{
+			Columns: []rpc.DatabaseSchemaColumn{
+				{
+					Name: "id",
+					Type: rpc.ColumnTypeInt,
+				},
+				{
+					Name: "state",
+					Type: rpc.ColumnTypeString,
+				},
+				{
+					Name: "amount",
+					Type: rpc.ColumnTypeInt,
+				},
+			},
+			PrimaryKey: -1,
+			Aggregates: []rpc.AggregateFunction{rpc.AggregateCount, rpc.AggregateSum},
+		}, nil
+	})
+
+	plugin.Serve()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin exits to test the restarts of the plugins:
+//   - the first query exits if the file set in the config "marker" does not exist, and creates it
+//   - a query with a limit of 42 always exits
+//   - a query with a limit of 7 exits on the second page of a cursor
+
+type crashTable struct {
+	marker string
+}
+
+type crashReader struct {
+	marker string
+	page   int
+}
+
+func (r *crashReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	if constraint.Limit == 42 {
+		os.Exit(1)
+	}
+	if _, err := os.Stat(r.marker); err != nil {
+		os.WriteFile(r.marker, []byte{}, 0644)
+		os.Exit(1)
+	}
+	r.page++
+	if constraint.Limit == 7 && r.page == 2 {
+		os.Exit(1)
+	}
+	return [][]interface{}{{r.page, r.marker}}, r.page == 2, nil
+}
+
+func (t *crashTable) CreateReader() rpc.ReaderInterface {
+	return &crashReader{marker: t.marker}
+}
+
+func (t *crashTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &crashTable{marker: args.UserConfig.GetString("marker")}, &rpc.DatabaseSchema{
+			Columns: []rpc.DatabaseSchemaColumn{
+				{Name: "page", Type: rpc.ColumnTypeInt},
+				{Name: "marker", Type: rpc.ColumnTypeString},
+			},
+			PrimaryKey: -1,
+		}, nil
+	})
+
+	plugin.Serve()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin is served over gRPC.
+// Its reader returns a row describing the constraints it receives,
+// and its rows can be streamed and inserted
+
+type grpcTable struct {
+	config   rpc.PluginConfig
+	inserted [][]interface{}
+}
+
+type grpcReader struct {
+	table *grpcTable
+}
+
+func (r *grpcReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	rows := [][]interface{}{
+		{1, "config", r.table.config.GetString("token"), 1.5, nil},
+		{2, "constraints", fmt.Sprint(constraint.Columns), float64(constraint.Limit), []byte("blob")},
+	}
+	for i, row := range r.table.inserted {
+		rows = append(rows, []interface{}{i + 3, "inserted", row[2], nil, nil})
+	}
+	return rows, true, nil
+}
+
+func (r *grpcReader) QueryStream(constraint rpc.QueryConstraint, w rpc.RowWriter) error {
+	for i := 1; i <= 1000; i++ {
+		if err := w.Write([]interface{}{i, "stream", nil, nil, nil}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *grpcTable) CreateReader() rpc.ReaderInterface {
+	return &grpcReader{table: t}
+}
+
+func (t *grpcTable) Insert(rows [][]interface{}) error {
+	t.inserted = append(t.inserted, rows...)
+	return nil
+}
+
+func (t *grpcTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin(func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &grpcTable{config: args.UserConfig}, &rpc.DatabaseSchema{
+			Columns: []rpc.DatabaseSchemaColumn{
+				{Name: "id", Type: rpc.ColumnTypeInt},
+				{Name: "kind", Type: rpc.ColumnTypeString},
+				{Name: "value", Type: rpc.ColumnTypeString},
+				{Name: "score", Type: rpc.ColumnTypeFloat},
+				{Name: "data", Type: rpc.ColumnTypeBlob, Description: "Some bytes"},
+			},
+			PrimaryKey:      -1,
+			HandlesIn:       true,
+			StreamRows:      true,
+			EstimatedRows:   42,
+			ConstraintCosts: []rpc.ConstraintCost{{Columns: []int{0}, EstimatedRows: 1}},
+		}, nil
+	})
+
+	plugin.ServeGRPC()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin registers a table handling IN lists
+//
+// The column "received" tells how the plugin received the constraint on the id column
+
+type inTable struct {
+}
+
+type inReader struct {
+}
+
+func (r *inReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	received := "scan"
+	ids := []interface{}{}
+	for _, cst := range constraint.Columns {
+		if cst.ColumnID != 0 {
+			continue
+		}
+		switch cst.Operator {
+		case rpc.OperatorIn:
+			received = fmt.Sprintf("in:%d", len(cst.GetInValues()))
+			ids = cst.GetInValues()
+		case rpc.OperatorEqual:
+			received = "equal"
+			ids = cst.GetInValues()
+		}
+	}
+	if received == "scan" {
+		for i := 1; i <= 10; i++ {
+			ids = append(ids, int64(i))
+		}
+	}
+
+	rows := make([][]interface{}, 0, len(ids))
+	for _, id := range ids {
+		// No row has an id that is not an integer
+		if _, ok := id.(int64); !ok {
+			continue
+		}
+		rows = append(rows, []interface{}{id, received})
+	}
+	return rows, true, nil
+}
+
+func (t *inTable) CreateReader() rpc.ReaderInterface {
+	return &inReader{}
+}
+
+func (t *inTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &inTable{}, &rpc.DatabaseSchema{
+			Columns: []rpc.DatabaseSchemaColumn{
+				{
+					Name: "id",
+					Type: rpc.ColumnTypeInt,
+				},
+				{
+					Name: "received",
+					Type: rpc.ColumnTypeString,
+				},
+			},
+			PrimaryKey: -1,
+			HandlesIn:  true,
+		}, nil
+	})
+
+	plugin.Serve()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin registers tables that never return their rows
+// unless the query is cancelled
+
+type slowTable struct {
+}
+
+type slowReader struct {
+}
+
+func (r *slowReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	// Old behaviour: the reader cannot be cancelled
+	time.Sleep(time.Minute)
+	return nil, true, nil
+}
+
+func (r *slowReader) QueryContext(ctx context.Context, constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	<-ctx.Done()
+	return nil, true, ctx.Err()
+}
+
+func (r *slowReader) QueryStream(constraint rpc.QueryConstraint, w rpc.RowWriter) error {
+	<-w.Context().Done()
+	return w.Context().Err()
+}
+
+func (t *slowTable) CreateReader() rpc.ReaderInterface {
+	return &slowReader{}
+}
+
+func (t *slowTable) Close() error {
+	return nil
+}
+
+func slowSchema(stream bool) *rpc.DatabaseSchema {
+	return &rpc.DatabaseSchema{
+		Columns: []rpc.DatabaseSchemaColumn{
+			{
+				Name: "id",
+				Type: rpc.ColumnTypeInt,
+			},
+		},
+		PrimaryKey: -1,
+		StreamRows: stream,
+	}
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &slowTable{}, slowSchema(false), nil
+	})
+
+	plugin.RegisterTable(1, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &slowTable{}, slowSchema(true), nil
+	})
+
+	plugin.Serve()
+}
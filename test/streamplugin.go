@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin registers a table streaming its rows
+// and a table whose paginated reader is streamed by the plugin library
+
+type streamTable struct {
+}
+
+type streamReader struct {
+}
+
+func (r *streamReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	return nil, true, nil
+}
+
+func (r *streamReader) QueryStream(constraint rpc.QueryConstraint, w rpc.RowWriter) error {
+	for i := 1; i <= 5000; i++ {
+		if err := w.Write([]interface{}{i, "row"}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *streamTable) CreateReader() rpc.ReaderInterface {
+	return &streamReader{}
+}
+
+func (t *streamTable) Close() error {
+	return nil
+}
+
+type pageTable struct {
+}
+
+type pageReader struct {
+	page int
+}
+
+func (r *pageReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	r.page++
+	rows := make([][]interface{}, 0, 100)
+	for i := 1; i <= 100; i++ {
+		rows = append(rows, []interface{}{(r.page-1)*100 + i, "page"})
+	}
+	return rows, r.page == 3, nil
+}
+
+func (t *pageTable) CreateReader() rpc.ReaderInterface {
+	return &pageReader{}
+}
+
+func (t *pageTable) Close() error {
+	return nil
+}
+
+var schema = rpc.DatabaseSchema{
+	Columns: []rpc.DatabaseSchemaColumn{
+		{
+			Name: "id",
+			Type: rpc.ColumnTypeInt,
+		},
+		{
+			Name: "name",
+			Type: rpc.ColumnTypeString,
+		},
+	},
+	PrimaryKey: -1,
+	StreamRows: true,
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &streamTable{}, &schema, nil
+	})
+
+	plugin.RegisterTable(1, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &pageTable{}, &schema, nil
+	})
+
+	plugin.Serve()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin registers a table staging its inserts in transactions
+//
+// Only the committed rows are returned. A commit fails if a staged row is titled "fail"
+
+type transactionTable struct {
+	committed [][]interface{}
+	staged    [][]interface{}
+	// The number of staged rows when each savepoint was created
+	savepoints map[string]int
+	inTx       bool
+}
+
+type transactionReader struct {
+	rows [][]interface{}
+}
+
+func (r *transactionReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	return r.rows, true, nil
+}
+
+func (t *transactionTable) CreateReader() rpc.ReaderInterface {
+	return &transactionReader{rows: t.committed}
+}
+
+func (t *transactionTable) Insert(rows [][]interface{}) error {
+	if !t.inTx {
+		t.committed = append(t.committed, rows...)
+		return nil
+	}
+	t.staged = append(t.staged, rows...)
+	return nil
+}
+
+func (t *transactionTable) Begin() error {
+	if t.inTx {
+		return errors.New("a transaction is already open")
+	}
+	t.inTx = true
+	t.savepoints = map[string]int{}
+	return nil
+}
+
+func (t *transactionTable) Commit() error {
+	for _, row := range t.staged {
+		if row[1] == "fail" {
+			return errors.New("the row cannot be committed")
+		}
+	}
+	t.committed = append(t.committed, t.staged...)
+	t.staged = nil
+	t.inTx = false
+	return nil
+}
+
+func (t *transactionTable) Rollback() error {
+	t.staged = nil
+	t.inTx = false
+	return nil
+}
+
+func (t *transactionTable) Savepoint(name string) error {
+	t.savepoints[name] = len(t.staged)
+	return nil
+}
+
+func (t *transactionTable) Release(name string) error {
+	delete(t.savepoints, name)
+	return nil
+}
+
+func (t *transactionTable) RollbackTo(name string) error {
+	length, ok := t.savepoints[name]
+	if !ok {
+		return errors.New("unknown savepoint")
+	}
+	t.staged = t.staged[:length]
+	return nil
+}
+
+func (t *transactionTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	plugin.RegisterTable(0, func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+		return &transactionTable{}, &rpc.DatabaseSchema{
+			Columns: []rpc.DatabaseSchemaColumn{
+				{
+					Name: "id",
+					Type: rpc.ColumnTypeInt,
+				},
+				{
+					Name: "title",
+					Type: rpc.ColumnTypeString,
+				},
+			},
+			PrimaryKey:          0,
+			HandlesInsert:       true,
+			HandlesTransactions: true,
+		}, nil
+	})
+
+	plugin.Serve()
+}
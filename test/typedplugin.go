@@ -0,0 +1,73 @@
+package main
+
+import (
+	"time"
+
+	"github.com/julien040/anyquery/rpc"
+)
+
+// This plugin returns Go values that must be converted to the types of the columns.
+// Table 1 returns a value that does not match its column
+
+type typedTable struct {
+	invalid bool
+}
+
+type typedReader struct {
+	invalid bool
+}
+
+func (r *typedReader) Query(constraint rpc.QueryConstraint) ([][]interface{}, bool, error) {
+	if r.invalid {
+		return [][]interface{}{
+			{1, true, 1.5, "yesterday", nil, nil, nil, nil},
+		}, true, nil
+	}
+	return [][]interface{}{
+		{
+			int32(1),
+			true,
+			float32(0.5),
+			time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+			time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+			map[string]interface{}{"a": 1},
+			"bytes",
+			42,
+		},
+		{"2", 0, 3, "2024-05-06T07:08:09+02:00", "2024-05-06", `[1,2]`, []byte{}, []string{}},
+	}, true, nil
+}
+
+func (t *typedTable) CreateReader() rpc.ReaderInterface {
+	return &typedReader{invalid: t.invalid}
+}
+
+func (t *typedTable) Close() error {
+	return nil
+}
+
+func main() {
+	plugin := rpc.NewPlugin()
+
+	tableFunc := func(invalid bool) rpc.TableCreator {
+		return func(args rpc.TableCreatorArgs) (rpc.Table, *rpc.DatabaseSchema, error) {
+			return &typedTable{invalid: invalid}, &rpc.DatabaseSchema{
+				Columns: []rpc.DatabaseSchemaColumn{
+					{Name: "id", Type: rpc.ColumnTypeInt},
+					{Name: "active", Type: rpc.ColumnTypeBool},
+					{Name: "score", Type: rpc.ColumnTypeFloat},
+					{Name: "created_at", Type: rpc.ColumnTypeDateTime},
+					{Name: "day", Type: rpc.ColumnTypeDate},
+					{Name: "metadata", Type: rpc.ColumnTypeJSON},
+					{Name: "data", Type: rpc.ColumnTypeBlob},
+					{Name: "label", Type: rpc.ColumnTypeString},
+				},
+				PrimaryKey: -1,
+			}, nil
+		}
+	}
+	plugin.RegisterTable(0, tableFunc(false))
+	plugin.RegisterTable(1, tableFunc(true))
+
+	plugin.Serve()
+}